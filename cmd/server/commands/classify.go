@@ -0,0 +1,187 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	classifyRules string
+	classifyDiff  string
+	classifyJSON  bool
+)
+
+// classifyCmd runs the classifier over local message files
+var classifyCmd = &cobra.Command{
+	Use:   "classify <files...>",
+	Short: "Classify local .eml, mbox, Maildir or JSON files",
+	Long: `Classify local files, read like parse does, and print the category,
+score, confidence and fired signals of each message. JSON inputs are
+classified again rather than trusting the stored verdict.
+
+The rules are the configured classifier section, or --rules: a config file
+with a classifier section or a file holding just that section.
+
+--diff runs a second rule set over the same messages and lists only the
+messages whose verdict changed, followed by a summary, so a rule change can
+be reviewed against a corpus before it is deployed:
+
+  classify --diff new-rules.yaml fixtures/*.eml takeout.mbox`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runClassify,
+}
+
+func init() {
+	rootCmd.AddCommand(classifyCmd)
+
+	classifyCmd.Flags().StringVar(&classifyRules, "rules", "", "Rules file to classify with (defaults to the configured rules)")
+	classifyCmd.Flags().StringVar(&classifyDiff, "diff", "", "Rules file to compare against --rules or the configured rules")
+	classifyCmd.Flags().BoolVar(&classifyJSON, "json", false, "Print the results as JSON")
+}
+
+// classifyResult is the verdict on one message; After is only set by --diff.
+type classifyResult struct {
+	Name   string                   `json:"name"`
+	Before entities.Classification  `json:"classification"`
+	After  *entities.Classification `json:"after,omitempty"`
+}
+
+func runClassify(cmd *cobra.Command, args []string) error {
+	rules, err := classifyBaseRules()
+	if err != nil {
+		return err
+	}
+	base := classifier.New(rules)
+	var other *classifier.Classifier
+	if classifyDiff != "" {
+		rules, err := config.ReadClassifierRules(classifyDiff)
+		if err != nil {
+			return err
+		}
+		other = classifier.New(rules)
+	}
+
+	fixtures, failed := readFixtures(args, base)
+	results := make([]classifyResult, 0, len(fixtures))
+	for _, f := range fixtures {
+		result := classifyResult{Name: f.name, Before: base.Classify(f.email)}
+		if other != nil {
+			after := other.Classify(f.email)
+			if sameVerdict(result.Before, after) {
+				continue
+			}
+			result.After = &after
+		}
+		results = append(results, result)
+	}
+
+	if classifyJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+	} else if err := printClassifyResults(results, other != nil); err != nil {
+		return err
+	}
+	if other != nil {
+		printDiffSummary(len(fixtures), results)
+	}
+	return fetchStatus(len(fixtures), failed)
+}
+
+func classifyBaseRules() (classifier.Rules, error) {
+	if classifyRules != "" {
+		return config.ReadClassifierRules(classifyRules)
+	}
+	cfg, err := config.LoadUnvalidated()
+	if err != nil {
+		return classifier.Rules{}, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return cfg.Classifier.Rules(), nil
+}
+
+func sameVerdict(a, b entities.Classification) bool {
+	return a.Category == b.Category && a.Score == b.Score && strings.Join(a.Signals, ",") == strings.Join(b.Signals, ",")
+}
+
+func printClassifyResults(results []classifyResult, diff bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if diff {
+		fmt.Fprintln(w, "MESSAGE\tBEFORE\tAFTER\tSIGNALS")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, verdict(r.Before), verdict(*r.After), strings.Join(signalChanges(r.Before.Signals, r.After.Signals), " "))
+		}
+		return w.Flush()
+	}
+
+	fmt.Fprintln(w, "MESSAGE\tCATEGORY\tSCORE\tCONFIDENCE\tSIGNALS")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.2f\t%s\n", r.Name, r.Before.Category, r.Before.Score, r.Before.Confidence, strings.Join(r.Before.Signals, " "))
+	}
+	return w.Flush()
+}
+
+func verdict(c entities.Classification) string {
+	return fmt.Sprintf("%s (%d)", c.Category, c.Score)
+}
+
+// signalChanges lists the signals only after fired as +signal and the ones
+// only before fired as -signal.
+func signalChanges(before, after []string) []string {
+	fired := make(map[string]int, len(before)+len(after))
+	for _, signal := range before {
+		fired[signal]--
+	}
+	for _, signal := range after {
+		fired[signal]++
+	}
+
+	var changes []string
+	for _, signal := range after {
+		if fired[signal] > 0 {
+			changes = append(changes, "+"+signal)
+		}
+	}
+	for _, signal := range before {
+		if fired[signal] < 0 {
+			changes = append(changes, "-"+signal)
+		}
+	}
+	return changes
+}
+
+// printDiffSummary goes to stderr so --json output stays a single document.
+func printDiffSummary(total int, changed []classifyResult) {
+	moved := map[string]int{}
+	scoreOnly := 0
+	for _, r := range changed {
+		if r.Before.Category == r.After.Category {
+			scoreOnly++
+			continue
+		}
+		moved[r.Before.Category+" -> "+r.After.Category]++
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d messages, %d changed category, %d changed score or signals only\n", total, len(changed)-scoreOnly, scoreOnly)
+	for _, transition := range []string{
+		classifier.CategoryPrimary + " -> " + classifier.CategoryPromotions,
+		classifier.CategoryPromotions + " -> " + classifier.CategoryPrimary,
+	} {
+		if moved[transition] > 0 {
+			fmt.Fprintf(os.Stderr, "  %s: %d\n", transition, moved[transition])
+		}
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/bootstrap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultConfigFile = "configs/config.yaml"
+
+var (
+	configShowJSON  bool
+	configPreflight bool
+	configInitForce bool
+)
+
+// configCmd inspects and creates the configuration
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show, validate or create the configuration",
+	Long: `Settings are merged from the built-in defaults, the config file
+(configs/config.yaml, ./config.yaml or --config), configs/.env and PRIVCY_*
+environment variables, each overriding the one before.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value came from",
+	Long: `Print every setting with its effective value and origin: default,
+file, .env or env. Credentials are masked unless they are secret references
+such as env:NAME. The configuration is not validated, so show also works on
+one that validate rejects.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration and the secrets it refers to",
+	Long: `Validate the configuration and resolve every secret reference in it.
+
+With --preflight the adapters are also built and their dependencies probed,
+the same checks as GET /health/ready: the mailbox API, S3 and DynamoDB.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runConfigValidate,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a commented starter config file",
+	Long: `Write a commented starter config to --config, or configs/config.yaml
+by default. An existing file is only replaced with --force.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigInit,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd, configValidateCmd, configInitCmd)
+
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Print the settings as JSON")
+	configValidateCmd.Flags().BoolVar(&configPreflight, "preflight", false, "Also connect to the mailbox, S3 and DynamoDB")
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "Overwrite an existing file")
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadUnvalidated()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	settings := cfg.Settings()
+
+	if configShowJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(settings)
+	}
+
+	file := config.File()
+	if file == "" {
+		file = "none found"
+	}
+	fmt.Printf("Config file: %s\n\n", file)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tORIGIN")
+	for _, setting := range settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Origin)
+	}
+	return w.Flush()
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	secretResolver := bootstrap.NewSecretResolver(cfg)
+	fields := cfg.SecretFields()
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	failed := 0
+	for _, key := range keys {
+		if fields[key] == "" {
+			continue
+		}
+		if _, err := secretResolver.Resolve(cmd.Context(), fields[key]); err != nil {
+			fmt.Printf("  %s: %v\n", key, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d secret(s) could not be resolved", failed)
+	}
+	fmt.Println("Configuration is valid")
+
+	if !configPreflight {
+		return nil
+	}
+	return preflight(cmd.Context(), cfg)
+}
+
+// preflight builds the services like serve does and runs the readiness
+// checks against the real dependencies.
+func preflight(ctx context.Context, cfg *config.Config) error {
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+	services, err := bootstrap.New(ctx, cfg, bootstrap.Options{Logger: logger})
+	if err != nil {
+		return err
+	}
+	health, err := services.HealthService.CheckHealth(ctx)
+	if err != nil {
+		return fmt.Errorf("preflight failed: %w", err)
+	}
+
+	names := make([]string, 0, len(health.Checks))
+	for name := range health.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := health.Checks[name]
+		fmt.Printf("  %-10s %-9s %s %s\n", name, check.Status, check.Duration.Round(time.Millisecond), check.Error)
+	}
+
+	if !health.IsReady() {
+		return fmt.Errorf("preflight failed: %s", health.Status)
+	}
+	fmt.Printf("Preflight %s\n", health.Status)
+	return nil
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path := cfgFile
+	if path == "" {
+		path = defaultConfigFile
+	}
+
+	if _, err := os.Stat(path); err == nil && !configInitForce {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, config.Starter, 0o644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/bootstrap"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	fetchOutputJSON   = "json"
+	fetchOutputNDJSON = "ndjson"
+	fetchOutputDir    = "dir"
+	fetchOutputStore  = "store"
+
+	// exitPartial is the exit status when some messages were delivered and
+	// others failed.
+	exitPartial = 2
+)
+
+var (
+	fetchQuery   string
+	fetchLimit   int
+	fetchAccount string
+	fetchThreads bool
+	fetchOutput  string
+	fetchDir     string
+	fetchDryRun  bool
+)
+
+// fetchCmd runs one fetch through the pipeline without the HTTP server
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetch, parse and classify messages once, without the HTTP server",
+	Long: `Read messages from the configured source through the same service as
+GET /emails/all and write them to --output:
+
+  json    one JSON document on stdout (default)
+  ndjson  one message per line on stdout
+  dir     one <id>.json file per message in --dir
+  store   S3 and DynamoDB, followed by the mailbox rules, like GET /emails/all
+
+--dry-run fetches and parses but writes nothing and changes nothing in the
+mailbox; it only prints a summary. Logs and summaries go to stderr.
+
+Exit status is 0 when every message was delivered, 2 when some were and
+others failed, and 1 when nothing was delivered or the run failed.`,
+	Args: cobra.NoArgs,
+	// A partial failure is reported through the exit status; usage text
+	// would only get in the way of scripts.
+	SilenceUsage: true,
+	RunE:         runFetch,
+}
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+
+	fetchCmd.Flags().StringVarP(&fetchQuery, "query", "q", "", "Source search query, e.g. Gmail search syntax or an IMAP SEARCH key")
+	fetchCmd.Flags().IntVarP(&fetchLimit, "limit", "n", 50, "Maximum number of messages")
+	fetchCmd.Flags().StringVar(&fetchAccount, "account", "", "Mailbox to read: Gmail user ID, IMAP username or Graph user (defaults to the configured one)")
+	fetchCmd.Flags().BoolVar(&fetchThreads, "threads", false, "Fetch whole threads of the matching messages; --limit counts the matches, not the messages returned")
+	fetchCmd.Flags().StringVarP(&fetchOutput, "output", "o", fetchOutputJSON, "Where to write messages: json, ndjson, dir or store")
+	fetchCmd.Flags().StringVar(&fetchDir, "dir", "", "Directory for --output dir")
+	fetchCmd.Flags().BoolVar(&fetchDryRun, "dry-run", false, "Fetch and parse only; write nothing")
+}
+
+func runFetch(cmd *cobra.Command, args []string) error {
+	switch fetchOutput {
+	case fetchOutputJSON, fetchOutputNDJSON, fetchOutputStore:
+	case fetchOutputDir:
+		if fetchDir == "" {
+			return fmt.Errorf("--output dir needs --dir")
+		}
+	default:
+		return fmt.Errorf("--output must be json, ndjson, dir or store, got %q", fetchOutput)
+	}
+	if fetchLimit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+	if fetchAccount != "" {
+		switch cfg.Source {
+		case config.SourceGmail:
+			cfg.Gmail.UserID = fetchAccount
+		case config.SourceIMAP:
+			cfg.IMAP.Username = fetchAccount
+		case config.SourceGraph:
+			cfg.Graph.User = fetchAccount
+		}
+	}
+
+	ctx := logging.WithJobID(cmd.Context(), newJobID())
+	services, err := bootstrap.New(ctx, cfg, bootstrap.Options{Logger: logger})
+	if err != nil {
+		return err
+	}
+	filter := entities.EmailFilter{
+		MaxResults:    fetchLimit,
+		Query:         fetchQuery,
+		ExpandThreads: fetchThreads,
+	}
+
+	if fetchOutput == fetchOutputStore && !fetchDryRun {
+		emailList, key, err := services.EmailService.GetEmails(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("fetch failed: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Stored %d messages in %s (%d failed)\n", len(emailList.Emails), key, emailList.Failed)
+		return fetchStatus(len(emailList.Emails), emailList.Failed)
+	}
+
+	emailList, err := services.EmailService.FetchEmails(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("fetch failed: %w", err)
+	}
+	if fetchDryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: fetched %d messages (%d failed), wrote nothing\n", len(emailList.Emails), emailList.Failed)
+		return fetchStatus(len(emailList.Emails), emailList.Failed)
+	}
+
+	delivered, failed := len(emailList.Emails), emailList.Failed
+	switch fetchOutput {
+	case fetchOutputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(emailList); err != nil {
+			return fmt.Errorf("failed to write messages: %w", err)
+		}
+	case fetchOutputNDJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		for i := range emailList.Emails {
+			if err := encoder.Encode(emailList.Emails[i]); err != nil {
+				return fmt.Errorf("failed to write messages: %w", err)
+			}
+		}
+	case fetchOutputDir:
+		written := writeEmailFiles(fetchDir, emailList.Emails, logger)
+		failed += delivered - written
+		delivered = written
+		fmt.Fprintf(os.Stderr, "Wrote %d messages to %s (%d failed)\n", delivered, fetchDir, failed)
+	}
+	return fetchStatus(delivered, failed)
+}
+
+// writeEmailFiles writes each message to dir as <id>.json and returns how
+// many were written; failures are logged.
+func writeEmailFiles(dir string, emails []entities.EmailMessage, logger *slog.Logger) int {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logger.Error("failed to create output directory", "dir", dir, "error", err)
+		return 0
+	}
+
+	// IDs come from the source; keep them from escaping the directory.
+	fileName := strings.NewReplacer("/", "_", `\`, "_").Replace
+	written := 0
+	for i := range emails {
+		data, err := json.MarshalIndent(emails[i], "", "  ")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, fileName(emails[i].ID)+".json"), append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			logger.Error("failed to write message", "message_id", emails[i].ID, "error", err)
+			continue
+		}
+		written++
+	}
+	return written
+}
+
+// fetchStatus maps the outcome to the exit status described in fetch --help.
+func fetchStatus(delivered, failed int) error {
+	switch {
+	case failed == 0:
+		return nil
+	case delivered == 0:
+		return fmt.Errorf("all %d messages failed", failed)
+	default:
+		return &exitError{code: exitPartial, err: fmt.Errorf("%d of %d messages failed", failed, delivered+failed)}
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/rand"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/pkg/logging"
+	"encoding/hex"
+	"log/slog"
+)
+
+// logLevel is the level of the logger built by newLogger; configuration
+// reloads change it in place.
+var logLevel = new(slog.LevelVar)
+
+// newLogger builds the logger from app settings and installs it as the slog
+// and log default, so output from libraries is redacted the same way.
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	logConfig, err := cfg.App.Logging()
+	if err != nil {
+		return nil, err
+	}
+	logLevel.Set(logConfig.Level.Level())
+	logConfig.Level = logLevel
+	logger := logging.New(logConfig).With("app", cfg.App.Name, "env", cfg.App.Environment)
+	slog.SetDefault(logger)
+
+	if logConfig.PIIDebug {
+		logger.Warn("PII debug logging is on: message content and addresses are logged unmasked")
+	}
+	return logger, nil
+}
+
+// newJobID identifies one run of a background job in its log records.
+func newJobID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
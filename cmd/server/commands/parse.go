@@ -0,0 +1,228 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/adapters/seondary/mailfile"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var parseNDJSON bool
+
+// parseCmd parses local message files without touching the network
+var parseCmd = &cobra.Command{
+	Use:   "parse <files...>",
+	Short: "Parse local .eml, mbox, Maildir or JSON files and print the messages",
+	Long: `Parse local files the way the pipeline does and print each resulting
+message as JSON, for checking parser changes against fixtures. Nothing is
+fetched or stored.
+
+Inputs are recognised by their name and content:
+
+  directory              Maildir
+  .json, .ndjson, .jsonl messages written by fetch or GET /emails/all; printed
+                         as they were stored
+  .mbox or "From " line  mbox (mboxrd)
+  anything else          a single RFC 822 message (.eml)
+
+Raw messages are classified with the configured classifier rules. Files that
+cannot be read are reported on stderr; the exit status is 2 when some inputs
+failed and 1 when all did.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runParse,
+}
+
+func init() {
+	rootCmd.AddCommand(parseCmd)
+
+	parseCmd.Flags().BoolVar(&parseNDJSON, "ndjson", false, "Print one message per line instead of indented JSON")
+}
+
+func runParse(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadUnvalidated()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	fixtures, failed := readFixtures(args, classifier.New(cfg.Classifier.Rules()))
+
+	encoder := json.NewEncoder(os.Stdout)
+	if !parseNDJSON {
+		encoder.SetIndent("", "  ")
+	}
+	for i := range fixtures {
+		if err := encoder.Encode(fixtures[i].email); err != nil {
+			return fmt.Errorf("failed to write messages: %w", err)
+		}
+	}
+	return fetchStatus(len(fixtures), failed)
+}
+
+// fixture is one message read from a local file. name is the file, followed
+// by #n for the nth message of an mbox, Maildir or JSON stream.
+type fixture struct {
+	name  string
+	email *entities.EmailMessage
+}
+
+// readFixtures reads every message in paths, classifying raw ones with c.
+// Unreadable files and messages are reported on stderr and counted.
+func readFixtures(paths []string, c *classifier.Classifier) ([]fixture, int) {
+	var fixtures []fixture
+	failed := 0
+	for _, path := range paths {
+		read, err := readFixture(path, c)
+		fixtures = append(fixtures, read...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed++
+		}
+	}
+	return fixtures, failed
+}
+
+func readFixture(path string, c *classifier.Classifier) ([]fixture, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readMailSource(path, entities.ImportFormatMaildir, c)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".ndjson", ".jsonl":
+		return readJSONFixture(path)
+	case ".mbox":
+		return readMailSource(path, entities.ImportFormatMbox, c)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("From ")) {
+		return readMailSource(path, entities.ImportFormatMbox, c)
+	}
+	email, err := parseFixture(entities.RawMessage{Name: path, Data: data}, c)
+	if err != nil {
+		return nil, err
+	}
+	return []fixture{{name: path, email: email}}, nil
+}
+
+// readMailSource reads an mbox file or Maildir directory. A message that
+// does not parse fails the rest of the archive, like a truncated file would.
+func readMailSource(path, format string, c *classifier.Classifier) ([]fixture, error) {
+	source, err := mailfile.NewOpener().Open(path, format, "")
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	var fixtures []fixture
+	for n := 1; ; n++ {
+		raw, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			return fixtures, nil
+		}
+		name := fmt.Sprintf("%s#%d", path, n)
+		if err != nil {
+			return fixtures, fmt.Errorf("%s: %w", name, err)
+		}
+		raw.Name = name
+		email, err := parseFixture(*raw, c)
+		if err != nil {
+			return fixtures, err
+		}
+		fixtures = append(fixtures, fixture{name: name, email: email})
+	}
+}
+
+func parseFixture(raw entities.RawMessage, c *classifier.Classifier) (*entities.EmailMessage, error) {
+	email, err := parser.ParseMessage(raw.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", raw.Name, entities.ErrInvalidMessage, err)
+	}
+	email.Labels = raw.Labels
+	if email.ReceivedAt.IsZero() && !raw.ReceivedAt.IsZero() {
+		email.ReceivedAt = raw.ReceivedAt
+		if email.InvalidDate {
+			email.Date = raw.ReceivedAt
+		}
+	}
+	c.Apply(email)
+	return email, nil
+}
+
+// readJSONFixture reads a stream of JSON values, each a message, an array of
+// messages or an EmailList, which covers every output format of fetch.
+func readJSONFixture(path string) ([]fixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var emails []entities.EmailMessage
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		decoded, err := decodeEmails(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		emails = append(emails, decoded...)
+	}
+
+	fixtures := make([]fixture, len(emails))
+	for i := range emails {
+		name := path
+		if len(emails) > 1 {
+			name = fmt.Sprintf("%s#%d", path, i+1)
+		}
+		fixtures[i] = fixture{name: name, email: &emails[i]}
+	}
+	return fixtures, nil
+}
+
+func decodeEmails(value json.RawMessage) ([]entities.EmailMessage, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(value), []byte("[")) {
+		var emails []entities.EmailMessage
+		err := json.Unmarshal(value, &emails)
+		return emails, err
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(value, &object); err != nil {
+		return nil, err
+	}
+	if _, ok := object["emails"]; ok {
+		var list entities.EmailList
+		err := json.Unmarshal(value, &list)
+		return list.Emails, err
+	}
+	var email entities.EmailMessage
+	err := json.Unmarshal(value, &email)
+	return []entities.EmailMessage{email}, err
+}
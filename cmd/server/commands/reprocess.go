@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/bootstrap"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reprocessPrefix      string
+	reprocessSince       string
+	reprocessUntil       string
+	reprocessConcurrency int
+	reprocessDryRun      bool
+	reprocessRestart     bool
+	reprocessJSON        bool
+)
+
+// reprocessCmd re-runs stored messages through the current pipeline
+var reprocessCmd = &cobra.Command{
+	Use:   "reprocess",
+	Short: "Re-parse and reclassify the messages already in S3",
+	Long: `List the stored messages under the S3 prefix, in any s3.layout and s3.format, run
+every message through the current parser and classifier again and write
+back the objects, and the DynamoDB headers, of messages that changed.
+Messages archived with archive.raw_eml are parsed again from the raw copy;
+the others can only be reclassified.
+
+--prefix narrows the listing below s3.prefix, e.g. account=me/date=2025/09,
+or emails_202509 in the batch layout, and --since/--until bound the message
+date recorded in the key: the date=YYYY/MM/DD partition, which selects whole
+days, or the batch's emails_<timestamp> (RFC 3339 or YYYY-MM-DD, until
+exclusive).
+
+Progress is checkpointed in import.state_dir, so an interrupted run resumes
+where it stopped; objects that failed are retried by the next run. The same
+job can be started on a running server with POST /admin/reprocess.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runReprocess,
+}
+
+func init() {
+	rootCmd.AddCommand(reprocessCmd)
+
+	reprocessCmd.Flags().StringVar(&reprocessPrefix, "prefix", "", "Only objects whose key below s3.prefix starts with this")
+	reprocessCmd.Flags().StringVar(&reprocessSince, "since", "", "Only messages dated at or after this time (whole days for per-message objects)")
+	reprocessCmd.Flags().StringVar(&reprocessUntil, "until", "", "Only messages dated before this time")
+	reprocessCmd.Flags().IntVar(&reprocessConcurrency, "concurrency", 0, "Objects processed in parallel (defaults to reprocess.concurrency)")
+	reprocessCmd.Flags().BoolVar(&reprocessDryRun, "dry-run", false, "Report what would change without writing anything")
+	reprocessCmd.Flags().BoolVar(&reprocessRestart, "restart", false, "Ignore the saved checkpoint and start from the first object")
+	reprocessCmd.Flags().BoolVar(&reprocessJSON, "json", false, "Print the summary as JSON")
+}
+
+func runReprocess(cmd *cobra.Command, args []string) error {
+	req := entities.ReprocessRequest{
+		Prefix:      reprocessPrefix,
+		Concurrency: reprocessConcurrency,
+		DryRun:      reprocessDryRun,
+		Restart:     reprocessRestart,
+	}
+	var err error
+	if req.Since, err = parseTimeFlag("since", reprocessSince); err != nil {
+		return err
+	}
+	if req.Until, err = parseTimeFlag("until", reprocessUntil); err != nil {
+		return err
+	}
+	if !req.Since.IsZero() && !req.Until.IsZero() && !req.Since.Before(req.Until) {
+		return fmt.Errorf("--since must be before --until")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+
+	bootstrap.ApplyClassifier(cfg)
+	storageService, dbService, err := bootstrap.NewStorage(cmd.Context(), cfg, logger, nil)
+	if err != nil {
+		return err
+	}
+	reprocessService, err := bootstrap.NewReprocessService(cfg, storageService, dbService, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx := logging.WithJobID(cmd.Context(), newJobID())
+	result, err := reprocessService.Reprocess(ctx, req, func(p entities.ReprocessProgress) {
+		fmt.Fprintf(os.Stderr, "🔁 objects %d, messages %d, changed %d, failed %d\n", p.Objects, p.Messages, p.Changed, p.Failed+p.FailedObjects)
+	})
+	if result != nil && result.Resumed {
+		fmt.Fprintf(os.Stderr, "Resumed after %s\n", result.LastKey)
+	}
+	if result != nil {
+		if err := printReprocessSummary(result); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reprocess failed: %w", err)
+	}
+	if result.FailedObjects > 0 || result.Failed > 0 {
+		return &exitError{code: exitPartial, err: fmt.Errorf("%d objects and %d messages failed, run again to retry the objects", result.FailedObjects, result.Failed)}
+	}
+	return nil
+}
+
+func printReprocessSummary(result *entities.ReprocessProgress) error {
+	if reprocessJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	verb := "Rewrote"
+	if reprocessDryRun {
+		verb = "Dry run: would rewrite"
+	}
+	fmt.Printf("✓ %s %d of %d objects (%d skipped, %d failed)\n", verb, result.Rewritten, result.Objects, result.Skipped, result.FailedObjects)
+	fmt.Printf("  %d messages, %d changed, %d failed\n", result.Messages, result.Changed, result.Failed)
+	printCounts("  changed fields:", result.Fields)
+	printCounts("  category moves:", result.Categories)
+	return nil
+}
+
+func printCounts(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println(title)
+	for _, name := range names {
+		fmt.Printf("    %-24s %d\n", name, counts[name])
+	}
+}
+
+// parseTimeFlag accepts RFC 3339 or a plain date, read as UTC midnight.
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("--%s must be RFC 3339 or YYYY-MM-DD, got %q", name, value)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/adapters/seondary/secrets"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	secretsFile  string
+	secretsValue string
+)
+
+// secretsCmd manages the local encrypted secrets store
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the local encrypted secrets store",
+	Long: `Manage secrets.file, a local store whose values are encrypted with
+AES-256-GCM. The key is read from the variable named by secrets.key_env
+(PRIVCY_SECRETS_KEY by default) or from secrets.key_file; create one with
+"secrets keygen".
+
+Credentials in the configuration can then refer to stored secrets, e.g.
+auth.access_token: secret:gmail_token. They may also refer to a file
+(file:///run/secrets/gmail) or an environment variable (env:GMAIL_TOKEN).`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret, read from stdin unless --value is given",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretsSet,
+}
+
+var secretsGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a stored secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretsGet,
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of stored secrets",
+	Args:  cobra.NoArgs,
+	RunE:  runSecretsList,
+}
+
+var secretsKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Print a new random store key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsSetCmd, secretsGetCmd, secretsListCmd, secretsKeygenCmd)
+
+	secretsCmd.PersistentFlags().StringVar(&secretsFile, "file", "", "Secrets file (defaults to secrets.file)")
+	// A value on the command line ends up in shell history; prefer stdin.
+	secretsSetCmd.Flags().StringVar(&secretsValue, "value", "", "Secret value (read from stdin when omitted)")
+}
+
+func runSecretsSet(cmd *cobra.Command, args []string) error {
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+
+	value := secretsValue
+	if !cmd.Flags().Changed("value") {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read secret from stdin: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	if value == "" {
+		return fmt.Errorf("secret %q is empty", args[0])
+	}
+
+	if err := store.Set(args[0], value); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Stored %s; refer to it as %s%s\n", args[0], secrets.PrefixStore, args[0])
+	return nil
+}
+
+func runSecretsGet(cmd *cobra.Command, args []string) error {
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+	value, err := store.Get(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func runSecretsList(cmd *cobra.Command, args []string) error {
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+	for _, name := range store.Names() {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// openSecretStore skips config validation: the store is often filled before
+// the rest of the configuration is complete.
+func openSecretStore() (*secrets.Store, error) {
+	cfg, err := config.LoadUnvalidated()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if secretsFile != "" {
+		cfg.Secrets.File = secretsFile
+	}
+	if cfg.Secrets.File == "" {
+		return nil, fmt.Errorf("no secrets file: set secrets.file or --file")
+	}
+
+	key, err := secrets.LoadKey(cfg.Secrets.KeyEnv, cfg.Secrets.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.OpenStore(cfg.Secrets.File, key)
+}
@@ -4,10 +4,17 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	httpAdapter "email-parser-poc/internal/adapters/primary/http"
 	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/bootstrap"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/internal/ports/outgoing"
 	httpserver "email-parser-poc/pkg/http-server"
+	"email-parser-poc/pkg/metrics"
 	"fmt"
+	"log/slog"
 
 	"github.com/spf13/cobra"
 )
@@ -42,8 +49,8 @@ func init() {
 	// is called directly, e.g.:
 	// server/commands/serverCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
-	serverCmd.Flags().StringVarP(&port, "port", "p", "8080", "Port to listen on")
-	serverCmd.Flags().StringVar(&host, "host", "0.0.0.0", "Host to bind to")
+	serverCmd.Flags().StringVarP(&port, "port", "p", "", "Port to listen on (overrides server.port)")
+	serverCmd.Flags().StringVar(&host, "host", "", "Host to bind to (overrides server.host)")
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
@@ -53,27 +60,132 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	fmt.Printf("Config loaded successfully!\n")
-	fmt.Printf("Access Token: '%s'\n", cfg.Auth.AccessToken)
-	fmt.Printf("Access Token Length: %d\n", len(cfg.Auth.AccessToken))
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+	logger.Info("configuration loaded", "source", cfg.Source, "access_token_set", cfg.Auth.AccessToken != "")
 
-	routerConfig := httpAdapter.RouterConfig{
-		Version:     "1.0.0",
-		AccessToken: cfg.GetAccessToken(),
+	shutdownTracing, err := newTracing(context.Background(), cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to configure tracing: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+	}()
+
+	var appMetrics *metrics.Metrics
+	if cfg.Admin.Enabled {
+		appMetrics = metrics.New()
 	}
 
-	router := httpAdapter.NewRouter(routerConfig)
+	var healthCheckers []outgoing.HealthChecker
+	var heartbeat *application_api.Heartbeat
+	if cfg.Push.Enabled && cfg.Push.Topic != "" {
+		heartbeat = application_api.NewHeartbeat("watch_renewal", renewMaxAge(cfg.Push.RenewInterval))
+		healthCheckers = append(healthCheckers, heartbeat)
+	}
+
+	services, err := bootstrap.New(cmd.Context(), cfg, bootstrap.Options{
+		Logger:         logger,
+		Metrics:        appMetrics,
+		HealthCheckers: healthCheckers,
+	})
+	if err != nil {
+		return err
+	}
+
+	reloader := bootstrap.NewReloader(cfg, bootstrap.ReloaderOptions{
+		LogLevel:      logLevel,
+		PostProcessor: services.PostProcessor,
+		Logger:        logger,
+	})
+	stopWatching, err := reloader.Watch()
+	if err != nil {
+		return err
+	}
+	defer stopWatching()
+
+	if cfg.Admin.Enabled {
+		adminServer := startAdminServer(cfg.Admin, appMetrics, reloader, services.ReprocessService, logger)
+		defer adminServer.Shutdown(context.Background())
+	}
+
+	if heartbeat != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// Renewals share the webhook's service so one store owns the state file.
+		go renewWatch(ctx, services.SyncService, reloader.RenewInterval, heartbeat, logger)
+	}
+
+	if cfg.SMTP.Enabled {
+		smtpServer, err := newSMTPServer(cfg, services.EmailService, logger)
+		if err != nil {
+			return fmt.Errorf("failed to start SMTP listener: %w", err)
+		}
+		defer smtpServer.Close()
+		go func() {
+			if err := smtpServer.ListenAndServe(); err != nil {
+				logger.Error("SMTP listener stopped", "error", err)
+			}
+		}()
+	}
+
+	router := httpAdapter.NewRouter(httpAdapter.RouterConfig{
+		EmailService:  services.EmailService,
+		HealthService: services.HealthService,
+		SyncService:   services.SyncService,
+		PushVerifier:  services.PushVerifier,
+
+		UploadMaxBytes: cfg.Upload.MaxBytes,
+
+		Logger:  logger,
+		Metrics: appMetrics,
+	})
+
+	// --host and --port win over server.host and server.port.
+	if cmd.Flags().Changed("host") {
+		cfg.Server.Host = host
+	}
+	if cmd.Flags().Changed("port") {
+		cfg.Server.Port = port
+	}
 
 	serverConfig := httpserver.Config{
-		Port:            port,
-		Host:            host,
+		Port:            cfg.Server.Port,
+		Host:            cfg.Server.Host,
 		Handler:         router,
-		ShutdownTimeout: 0,
-		ReadTimeout:     0,
-		WriteTimeout:    0,
-		IdleTimeout:     0,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		ReadTimeout:     cfg.Server.ReadTimeout,
+		WriteTimeout:    cfg.Server.WriteTimeout,
+		IdleTimeout:     cfg.Server.IdleTimeout,
+		Logger:          logger,
 	}
 
 	server := httpserver.NewConfig(serverConfig)
 	return server.StaertWithGracefulShutdown()
 }
+
+// startAdminServer serves the admin router on its own listener in the
+// background. It exits with serve, so a listen failure is only logged.
+func startAdminServer(admin config.AdminConfig, m *metrics.Metrics, reloader incoming.ConfigReloader, reprocess incoming.ReprocessService, logger *slog.Logger) *httpserver.Server {
+	server := httpserver.NewConfig(httpserver.Config{
+		Host: admin.Host,
+		Port: admin.Port,
+		Handler: httpAdapter.NewAdminRouter(httpAdapter.AdminRouterConfig{
+			Metrics:   m,
+			Reloader:  reloader,
+			Reprocess: reprocess,
+			Logger:    logger,
+		}),
+		Logger: logger.With("listener", "admin"),
+	})
+	go func() {
+		if err := server.Start(); err != nil {
+			logger.Error("admin listener stopped", "error", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,41 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	smtpAdapter "email-parser-poc/internal/adapters/primary/smtp"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/ports/incoming"
+	"fmt"
+	"log/slog"
+)
+
+// newSMTPServer builds the inbound SMTP/LMTP listener. Received messages go
+// through the same ingestion as uploaded .eml files.
+func newSMTPServer(cfg *config.Config, emailService incoming.EmailService, logger *slog.Logger) (*smtpAdapter.Server, error) {
+	var tlsConfig *tls.Config
+	if cfg.SMTP.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.SMTP.TLSCertFile, cfg.SMTP.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SMTP TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	return smtpAdapter.NewServer(smtpAdapter.Config{
+		Network:          cfg.SMTP.Network,
+		Addr:             cfg.SMTP.Addr,
+		LMTP:             cfg.SMTP.LMTP,
+		Domain:           cfg.SMTP.Domain,
+		RecipientDomains: cfg.SMTP.RecipientDomains,
+		MaxMessageBytes:  cfg.SMTP.MaxMessageBytes,
+		MaxRecipients:    cfg.SMTP.MaxRecipients,
+		ReadTimeout:      cfg.SMTP.ReadTimeout,
+		WriteTimeout:     cfg.SMTP.WriteTimeout,
+		TLSConfig:        tlsConfig,
+		RequireTLS:       cfg.SMTP.RequireTLS,
+		Logger:           logger,
+	}, emailService)
+}
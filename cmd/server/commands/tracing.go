@@ -0,0 +1,32 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/pkg/tracing"
+	"log/slog"
+)
+
+// newTracing installs the configured span exporter. The returned function
+// flushes pending spans and is safe to call when tracing is off.
+func newTracing(ctx context.Context, cfg *config.Config, logger *slog.Logger) (func(context.Context) error, error) {
+	shutdown, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.Endpoint,
+		Insecure:       cfg.Tracing.Insecure,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    cfg.App.Name,
+		ServiceVersion: cfg.App.Version,
+		Environment:    cfg.App.Environment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		logger.Info("tracing enabled", "exporter", cfg.Tracing.Exporter, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+	return shutdown, nil
+}
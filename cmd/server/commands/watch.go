@@ -0,0 +1,105 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/bootstrap"
+	"email-parser-poc/internal/ports/incoming"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchTopic  string
+	watchLabels []string
+	watchForce  bool
+)
+
+// watchCmd registers Gmail push notifications for the configured mailbox
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Register or renew Gmail push notifications",
+	Long: `Register users.watch so Gmail publishes mailbox changes to a Pub/Sub
+topic, which delivers them to POST /webhooks/gmail.
+
+Gmail drops a watch after 7 days. Without --force the registration is only
+renewed when it expires within push.renew_before; the serve command also
+renews it in the background while push is enabled.`,
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringVar(&watchTopic, "topic", "", "Pub/Sub topic, e.g. projects/<project>/topics/<topic> (defaults to push.topic)")
+	watchCmd.Flags().StringSliceVar(&watchLabels, "label", nil, "Label IDs to watch (defaults to push.label_ids)")
+	watchCmd.Flags().BoolVar(&watchForce, "force", false, "Re-register even if the current watch is still valid")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+	if watchTopic != "" {
+		cfg.Push.Topic = watchTopic
+	}
+	if len(watchLabels) > 0 {
+		cfg.Push.LabelIDs = watchLabels
+	}
+
+	state, err := bootstrap.NewWatchService(cfg, logger).EnsureWatch(cmd.Context(), watchForce)
+	if err != nil {
+		return fmt.Errorf("failed to register watch: %w", err)
+	}
+
+	fmt.Printf("Watching %s via %s\n", state.EmailAddress, state.Topic)
+	fmt.Printf("History ID: %d\n", state.HistoryID)
+	fmt.Printf("Expires: %s (in %s)\n", state.Expiration.Format(time.RFC3339), time.Until(state.Expiration).Round(time.Minute))
+	return nil
+}
+
+// renewWatch keeps the Gmail watch registration alive until ctx is done,
+// beating heartbeat on every pass so readiness notices a stalled loop.
+// interval is read before each wait so a reloaded push.renew_interval takes
+// effect after the current one.
+func renewWatch(ctx context.Context, service incoming.SyncService, interval func() time.Duration, heartbeat *application_api.Heartbeat, logger *slog.Logger) {
+	timer := time.NewTimer(interval())
+	defer timer.Stop()
+
+	for {
+		heartbeat.Beat()
+		if state, err := service.EnsureWatch(ctx, false); err != nil {
+			logger.ErrorContext(ctx, "failed to renew Gmail watch", "error", err)
+		} else {
+			logger.InfoContext(ctx, "Gmail watch valid", "expires", state.Expiration.Format(time.RFC3339))
+		}
+
+		next := interval()
+		heartbeat.SetMaxAge(renewMaxAge(next))
+		timer.Reset(next)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// renewMaxAge is how long renewWatch may go without a beat. A pass may take
+// a while on a slow API; allow two missed ticks.
+func renewMaxAge(interval time.Duration) time.Duration {
+	return 2*interval + time.Minute
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"email-parser-poc/internal/adapters/primary/http/handlers"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminRouterConfig struct {
+	Metrics *metrics.Metrics
+	// Reloader enables POST /admin/reload when set.
+	Reloader incoming.ConfigReloader
+	// Reprocess enables POST and GET /admin/reprocess when set.
+	Reprocess incoming.ReprocessService
+	Logger    *slog.Logger
+}
+
+// NewAdminRouter serves operational endpoints that should not be reachable
+// through the public listener: the Prometheus scrape target, config reloads
+// and reprocess jobs.
+func NewAdminRouter(config AdminRouterConfig) http.Handler {
+	r := chi.NewRouter()
+	logger := logging.OrDefault(config.Logger)
+
+	r.Use(middleware.RequestID)
+	r.Use(requestLogger(logger.With("listener", "admin")))
+	r.Use(middleware.Recoverer)
+
+	r.Method(http.MethodGet, "/metrics", config.Metrics.Handler())
+
+	if config.Reloader != nil {
+		configHandler := handlers.NewConfigHandler(config.Reloader)
+		r.Post("/admin/reload", configHandler.Reload)
+	}
+	if config.Reprocess != nil {
+		reprocessHandler := handlers.NewReprocessHandler(config.Reprocess, logger)
+		r.Post("/admin/reprocess", reprocessHandler.Start)
+		r.Get("/admin/reprocess", reprocessHandler.Status)
+	}
+
+	return r
+}
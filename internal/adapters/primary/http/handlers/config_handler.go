@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/incoming"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type ConfigHandler struct {
+	reloader incoming.ConfigReloader
+}
+
+func NewConfigHandler(reloader incoming.ConfigReloader) *ConfigHandler {
+	return &ConfigHandler{reloader: reloader}
+}
+
+// Reload applies the configuration file now instead of waiting for the file
+// watcher. An invalid file is answered with 422 and changes nothing.
+func (h *ConfigHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	result, err := h.reloader.Reload(r.Context())
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidConfig) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -4,8 +4,11 @@ import (
 	"email-parser-poc/internal/domain/entities"
 	"email-parser-poc/internal/ports/incoming"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type EmailHandler struct {
@@ -30,10 +33,12 @@ func (h *EmailHandler) GetAllEmails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	pageToken := r.URL.Query().Get("page_token")
+	expandThreads, _ := strconv.ParseBool(r.URL.Query().Get("threads"))
 
 	filter := entities.EmailFilter{
-		MaxResults: limit,
-		PageToken:  pageToken,
+		MaxResults:    limit,
+		PageToken:     pageToken,
+		ExpandThreads: expandThreads,
 	}
 
 	emailList, s3Filename, err := h.emailService.GetEmails(ctx, filter)
@@ -51,3 +56,30 @@ func (h *EmailHandler) GetAllEmails(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(respone)
 }
+
+func (h *EmailHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	threadID := chi.URLParam(r, "id")
+	if threadID == "" {
+		http.Error(w, "thread id is required", http.StatusBadRequest)
+		return
+	}
+
+	thread, err := h.emailService.GetThread(ctx, threadID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, entities.ErrNotSupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(thread)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/pkg/logging"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReprocessHandler runs one reprocess job at a time in the background and
+// reports on the latest one.
+type ReprocessHandler struct {
+	service incoming.ReprocessService
+	logger  *slog.Logger
+
+	mu  sync.Mutex
+	job *entities.ReprocessJob
+}
+
+func NewReprocessHandler(service incoming.ReprocessService, logger *slog.Logger) *ReprocessHandler {
+	return &ReprocessHandler{service: service, logger: logging.OrDefault(logger)}
+}
+
+// Start accepts an optional JSON entities.ReprocessRequest and answers 202
+// with the new job, or 409 while another one is running.
+func (h *ReprocessHandler) Start(w http.ResponseWriter, r *http.Request) {
+	var req entities.ReprocessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid reprocess request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !req.Since.IsZero() && !req.Until.IsZero() && !req.Since.Before(req.Until) {
+		http.Error(w, "since must be before until", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	if h.job != nil && h.job.Status == entities.JobRunning {
+		job := *h.job
+		h.mu.Unlock()
+		writeJSON(w, http.StatusConflict, job)
+		return
+	}
+	h.job = &entities.ReprocessJob{
+		ID:        newJobID(),
+		Status:    entities.JobRunning,
+		Request:   req,
+		StartedAt: time.Now().UTC(),
+	}
+	job := *h.job
+	h.mu.Unlock()
+
+	// The job outlives the request but keeps its log attributes.
+	ctx := logging.WithJobID(context.WithoutCancel(r.Context()), job.ID)
+	go h.run(ctx, job.ID, req)
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// Status returns the running or last finished job, or 404 before the first.
+func (h *ReprocessHandler) Status(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.job == nil {
+		http.Error(w, "no reprocess job has run", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.job)
+}
+
+func (h *ReprocessHandler) run(ctx context.Context, id string, req entities.ReprocessRequest) {
+	h.logger.InfoContext(ctx, "reprocess started", "prefix", req.Prefix, "dry_run", req.DryRun)
+	result, err := h.service.Reprocess(ctx, req, func(p entities.ReprocessProgress) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.job.ID == id {
+			h.job.Progress = p
+		}
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if result != nil {
+		h.job.Progress = *result
+	}
+	h.job.FinishedAt = time.Now().UTC()
+	if err != nil {
+		h.job.Status = entities.JobFailed
+		h.job.Error = err.Error()
+		h.logger.ErrorContext(ctx, "reprocess failed", "error", err)
+		return
+	}
+	h.job.Status = entities.JobSucceeded
+	h.logger.InfoContext(ctx, "reprocess finished", "objects", h.job.Progress.Objects, "changed", h.job.Progress.Changed, "failed", h.job.Progress.Failed)
+}
+
+func newJobID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -17,6 +17,8 @@ func NewHealthHandler(healthService incoming.HealthPort) *HealthHandler {
 	}
 }
 
+// CheckHealth reports readiness: 503 when a critical dependency is down,
+// 200 when healthy or degraded.
 func (h *HealthHandler) CheckHealth(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
@@ -28,13 +30,24 @@ func (h *HealthHandler) CheckHealth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	statusCode := http.StatusOK
-	if !health.IsHealthy() {
+	if !health.IsReady() {
 		statusCode = http.StatusServiceUnavailable
 	}
 
 	h.writeJsonResponse(w, statusCode, health)
 }
 
+// Live reports that the process is up without checking dependencies, so a
+// backend outage doesn't get the server restarted.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	health, err := h.HealthService.CheckLiveness(r.Context())
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to get health status", err)
+		return
+	}
+	h.writeJsonResponse(w, http.StatusOK, health)
+}
+
 func (h *HealthHandler) writeJsonResponse(w http.ResponseWriter, statuscode int, data interface{}) {
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(statuscode)
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/incoming"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type UploadHandler struct {
+	emailService incoming.EmailService
+	maxBytes     int64
+}
+
+// NewUploadHandler builds the .eml upload handler. maxBytes caps the whole
+// request body, across all files.
+func NewUploadHandler(emailService incoming.EmailService, maxBytes int64) *UploadHandler {
+	return &UploadHandler{
+		emailService: emailService,
+		maxBytes:     maxBytes,
+	}
+}
+
+// UploadEmails accepts .eml files as multipart/form-data, or a single message
+// as a message/rfc822 body, and returns the parsed and classified messages.
+func (h *UploadHandler) UploadEmails(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "Content-Type must be multipart/form-data or message/rfc822", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var messages []entities.RawMessage
+	switch mediaType {
+	case "multipart/form-data":
+		messages, err = readUploadParts(r)
+	case "message/rfc822":
+		var data []byte
+		data, err = io.ReadAll(r.Body)
+		messages = []entities.RawMessage{{Name: "body", Data: data}}
+	default:
+		http.Error(w, "Content-Type must be multipart/form-data or message/rfc822", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			http.Error(w, fmt.Sprintf("upload exceeds %d bytes", h.maxBytes), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, errUnsupportedPart):
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if len(messages) == 0 {
+		http.Error(w, "no .eml files in upload", http.StatusBadRequest)
+		return
+	}
+	// Messages without Received headers count as received now.
+	uploadedAt := time.Now().UTC()
+	for i := range messages {
+		if len(messages[i].Data) == 0 {
+			http.Error(w, fmt.Sprintf("%s is empty", messages[i].Name), http.StatusBadRequest)
+			return
+		}
+		messages[i].ReceivedAt = uploadedAt
+	}
+
+	emailList, s3Filename, err := h.emailService.IngestRawEmails(ctx, messages)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidMessage) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respone := map[string]interface{}{
+		"emails":      emailList,
+		"s3_filename": s3Filename,
+		"message":     "Emails successfully stored in S3",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(respone)
+}
+
+var errUnsupportedPart = errors.New("unsupported file type")
+
+// uploadPartTypes are the part types browsers and curl send for .eml files.
+var uploadPartTypes = map[string]bool{
+	"":                         true,
+	"message/rfc822":           true,
+	"application/octet-stream": true,
+	"text/plain":               true,
+}
+
+// readUploadParts streams every file part of a multipart upload. Plain form
+// fields are ignored.
+func readUploadParts(r *http.Request) ([]entities.RawMessage, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []entities.RawMessage
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		name := filepath.Base(part.FileName())
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if !uploadPartTypes[partType] && !strings.EqualFold(filepath.Ext(name), ".eml") {
+			part.Close()
+			return nil, fmt.Errorf("%s: %w %q, expected message/rfc822", name, errUnsupportedPart, partType)
+		}
+
+		data, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, entities.RawMessage{Name: name, Data: data})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/pkg/logging"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// PushVerifier authenticates the bearer token on a Pub/Sub push request.
+type PushVerifier interface {
+	Verify(ctx context.Context, token string) error
+}
+
+type PushVerifierFunc func(ctx context.Context, token string) error
+
+func (f PushVerifierFunc) Verify(ctx context.Context, token string) error {
+	return f(ctx, token)
+}
+
+type WebhookHandler struct {
+	syncService incoming.SyncService
+	verifier    PushVerifier
+	logger      *slog.Logger
+}
+
+// NewWebhookHandler builds the Gmail push handler. A nil verifier accepts
+// unauthenticated pushes and is only meant for local development.
+func NewWebhookHandler(syncService incoming.SyncService, verifier PushVerifier, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		syncService: syncService,
+		verifier:    verifier,
+		logger:      logging.OrDefault(logger),
+	}
+}
+
+// pushEnvelope is the body Pub/Sub POSTs to push subscriptions.
+type pushEnvelope struct {
+	Message struct {
+		Data        string            `json:"data"`
+		MessageID   string            `json:"messageId"`
+		PublishTime string            `json:"publishTime"`
+		Attributes  map[string]string `json:"attributes"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+func (h *WebhookHandler) GmailPush(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.verifier != nil {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		if err := h.verifier.Verify(ctx, token); err != nil {
+			h.logger.WarnContext(ctx, "rejected push", "error", err)
+			http.Error(w, "invalid push token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	notification, err := decodePushNotification(r)
+	if err != nil {
+		// Acknowledge malformed pushes so Pub/Sub doesn't redeliver them forever.
+		h.logger.WarnContext(ctx, "dropping malformed push", "error", err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	result, err := h.syncService.HandlePushNotification(ctx, *notification)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "push sync failed", "error", err)
+		// A non-2xx response makes Pub/Sub retry the delivery with backoff.
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func decodePushNotification(r *http.Request) (*entities.PushNotification, error) {
+	var envelope pushEnvelope
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(envelope.Message.Data)
+	if err != nil {
+		data, err = base64.URLEncoding.DecodeString(envelope.Message.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message data: %w", err)
+		}
+	}
+
+	var notification entities.PushNotification
+	if err := json.Unmarshal(data, &notification); err != nil {
+		return nil, fmt.Errorf("invalid notification: %w", err)
+	}
+	if notification.EmailAddress == "" || notification.HistoryID == 0 {
+		return nil, fmt.Errorf("notification is missing emailAddress or historyId")
+	}
+	return &notification, nil
+}
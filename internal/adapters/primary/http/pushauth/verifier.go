@@ -0,0 +1,336 @@
+// Package pushauth verifies the OIDC JWT that Pub/Sub attaches to
+// authenticated push deliveries.
+package pushauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GoogleJWKSURL publishes the keys Google signs push tokens with.
+	GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	jwksCacheTTL = time.Hour
+	// jwksMinRefetch bounds refetches triggered by unknown kids, so tokens
+	// with made-up kids can't hammer the JWKS endpoint.
+	jwksMinRefetch = time.Minute
+	clockSkew      = 5 * time.Minute
+)
+
+var googleIssuers = map[string]bool{
+	"accounts.google.com":         true,
+	"https://accounts.google.com": true,
+}
+
+type Config struct {
+	// Audience must match the token's aud claim, normally the push endpoint
+	// URL configured on the subscription.
+	Audience string
+	// ServiceAccountEmail, when set, must match the verified email claim.
+	ServiceAccountEmail string
+	// JWKSURL is fetched and cached for signing keys. Empty disables remote
+	// keys, leaving only KeyFiles.
+	JWKSURL string
+	// KeyFiles are PEM public keys or certificates, or JWKS JSON documents.
+	KeyFiles   []string
+	HTTPClient *http.Client
+}
+
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Audience      []string `json:"-"`
+}
+
+type Verifier struct {
+	config Config
+
+	mu         sync.Mutex
+	staticKeys map[string]*rsa.PublicKey
+	remoteKeys map[string]*rsa.PublicKey
+	fetchedAt  time.Time
+	// attemptedAt is the last fetch, successful or not.
+	attemptedAt time.Time
+	now         func() time.Time
+}
+
+func NewVerifier(config Config) (*Verifier, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.JWKSURL == "" && len(config.KeyFiles) == 0 {
+		return nil, fmt.Errorf("push verification needs a JWKS URL or key files")
+	}
+
+	v := &Verifier{config: config, staticKeys: make(map[string]*rsa.PublicKey), now: time.Now}
+	for _, path := range config.KeyFiles {
+		keys, err := loadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			v.staticKeys[kid] = key
+		}
+	}
+	return v, nil
+}
+
+// Verify checks the RS256 signature, issuer, audience, lifetime and optional
+// service account of a push token.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	keys, err := v.keysFor(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range keys {
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("token signature verification failed")
+	}
+
+	var raw struct {
+		Claims
+		Audience json.RawMessage `json:"aud"`
+	}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	claims := raw.Claims
+	claims.Audience = parseAudience(raw.Audience)
+
+	now := v.now()
+	if !googleIssuers[claims.Issuer] {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("token issued in the future")
+	}
+	if v.config.Audience != "" && !contains(claims.Audience, v.config.Audience) {
+		return nil, fmt.Errorf("unexpected audience %v", claims.Audience)
+	}
+	if v.config.ServiceAccountEmail != "" && (!claims.EmailVerified || !strings.EqualFold(claims.Email, v.config.ServiceAccountEmail)) {
+		return nil, fmt.Errorf("unexpected service account %q", claims.Email)
+	}
+	return &claims, nil
+}
+
+// keysFor returns the candidate keys for kid, refreshing the remote JWKS when
+// it is stale or doesn't know the kid yet (Google rotates keys regularly), at
+// most once per jwksMinRefetch.
+func (v *Verifier) keysFor(ctx context.Context, kid string) ([]*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.staticKeys[kid]; ok && kid != "" {
+		return []*rsa.PublicKey{key}, nil
+	}
+
+	if v.config.JWKSURL != "" {
+		now := v.now()
+		_, known := v.remoteKeys[kid]
+		stale := !known || now.Sub(v.fetchedAt) > jwksCacheTTL
+		if stale && now.Sub(v.attemptedAt) >= jwksMinRefetch {
+			v.attemptedAt = now
+			keys, err := v.fetchJWKS(ctx)
+			if err != nil && v.remoteKeys == nil {
+				return nil, err
+			}
+			if err == nil {
+				v.remoteKeys, v.fetchedAt = keys, now
+			}
+		}
+		if key, ok := v.remoteKeys[kid]; ok {
+			return []*rsa.PublicKey{key}, nil
+		}
+	}
+
+	// Keys loaded from bare PEM files have no kid, so try them all.
+	keys := make([]*rsa.PublicKey, 0, len(v.staticKeys))
+	for _, key := range v.staticKeys {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key found for kid %q", kid)
+	}
+	return keys, nil
+}
+
+func (v *Verifier) fetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	return set.rsaKeys()
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (s jwks) rsaKeys() (map[string]*rsa.PublicKey, error) {
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range s.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for kid %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for kid %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func loadKeyFile(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var set jwks
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("failed to decode JWKS file %s: %w", path, err)
+		}
+		return set.rsaKeys()
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for i := 0; ; i++ {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var parsed interface{}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate in %s: %w", path, err)
+			}
+			parsed = cert.PublicKey
+		case "RSA PUBLIC KEY":
+			parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key in %s: %w", path, err)
+		}
+
+		key, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key in %s is not an RSA key", path)
+		}
+		keys[fmt.Sprintf("%s#%d", path, i)] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found in %s", path)
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func parseAudience(raw json.RawMessage) []string {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return []string{single}
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+	return many
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
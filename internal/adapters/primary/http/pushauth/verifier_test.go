@@ -0,0 +1,143 @@
+package pushauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// jwksServer publishes key under kid and counts fetches.
+func jwksServer(t *testing.T, kid string, key *rsa.PublicKey) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": kid,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestVerifyRateLimitsJWKSRefetch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, fetches := jwksServer(t, "current", &key.PublicKey)
+	verifier, err := NewVerifier(Config{Audience: "https://push.example.com", JWKSURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	claims := map[string]any{
+		"iss": "https://accounts.google.com",
+		"aud": "https://push.example.com",
+		"exp": now.Add(time.Hour).Unix(),
+		"iat": now.Unix(),
+	}
+	ctx := context.Background()
+	if _, err := verifier.Verify(ctx, sign(t, key, "current", claims)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown kids refetch at most once a minute.
+	for i := 0; i < 5; i++ {
+		if _, err := verifier.Verify(ctx, sign(t, key, "unknown", claims)); err == nil {
+			t.Fatal("token with an unknown kid verified")
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches within a minute = %d, want 1", n)
+	}
+
+	now = now.Add(jwksMinRefetch)
+	verifier.Verify(ctx, sign(t, key, "unknown", claims))
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches after a minute = %d, want 2", n)
+	}
+
+	// Known keys keep verifying from the cache.
+	if _, err := verifier.Verify(ctx, sign(t, key, "current", claims)); err != nil || fetches.Load() != 2 {
+		t.Errorf("cached key: err = %v, fetches = %d", err, fetches.Load())
+	}
+}
+
+// TestVerifyLifetimeUsesClock runs the verifier with a clock years away from
+// the real one, so every lifetime check must go through v.now.
+func TestVerifyLifetimeUsesClock(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ := jwksServer(t, "current", &key.PublicKey)
+	verifier, err := NewVerifier(Config{Audience: "https://push.example.com", JWKSURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2040, 1, 1, 12, 0, 0, 0, time.UTC)
+	verifier.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		exp     time.Time
+		iat     time.Time
+		wantErr string
+	}{
+		{name: "valid", exp: now.Add(time.Hour), iat: now},
+		{name: "expired within skew", exp: now.Add(-clockSkew + time.Second), iat: now.Add(-time.Hour)},
+		{name: "expired", exp: now.Add(-clockSkew - time.Second), iat: now.Add(-time.Hour), wantErr: "token expired"},
+		{name: "issued within skew", exp: now.Add(time.Hour), iat: now.Add(clockSkew - time.Second)},
+		{name: "issued in the future", exp: now.Add(time.Hour), iat: now.Add(clockSkew + time.Second), wantErr: "issued in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, key, "current", map[string]any{
+				"iss": "accounts.google.com",
+				"aud": "https://push.example.com",
+				"exp": tt.exp.Unix(),
+				"iat": tt.iat.Unix(),
+			})
+			_, err := verifier.Verify(context.Background(), token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
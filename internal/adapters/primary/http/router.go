@@ -2,58 +2,64 @@ package http
 
 import (
 	"email-parser-poc/internal/adapters/primary/http/handlers"
-	"email-parser-poc/internal/adapters/seondary/dynamodb"
-	"email-parser-poc/internal/adapters/seondary/gmail"
-	"email-parser-poc/internal/adapters/seondary/s3bucket"
-	"email-parser-poc/internal/adapters/seondary/token"
-	"email-parser-poc/internal/application_api"
-	"fmt"
-	"log"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
+	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 )
 
+// RouterConfig carries the services built by the composition root (see
+// internal/bootstrap); the router only maps them onto routes.
 type RouterConfig struct {
-	Version     string
-	AccessToken string
+	EmailService  incoming.EmailService
+	HealthService incoming.HealthPort
+	// SyncService enables POST /webhooks/gmail when set. PushVerifier
+	// authenticates those requests; nil accepts them unverified.
+	SyncService  incoming.SyncService
+	PushVerifier handlers.PushVerifier
+
+	UploadMaxBytes int64
+
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
 }
 
 func NewRouter(config RouterConfig) http.Handler {
 	// new router by initilizing chi NewRouter method
 	r := chi.NewRouter()
+	logger := logging.OrDefault(config.Logger)
 
 	// Basic middleware
+	r.Use(middleware.RequestID)
+	r.Use(requestTracing)
+	r.Use(requestLogger(logger))
+	r.Use(requestMetrics(config.Metrics))
 	r.Use(middleware.Recoverer)
 	// r.Use(middleware.Timeout(30 * time.Millisecond))
 	r.Use(middleware.Heartbeat("/ping"))
 
-	// Intilizing healthservice for dependency for healthhandler
-	healthService := application_api.NewHealthService(config.Version)
-	healthHandler := handlers.NewHealthHandler(healthService)
-
-	token1 := config.AccessToken
-	fmt.Printf("token : %s", token1)
-
-	tokenProvider := token.NewStaticTokenProvider(config.AccessToken)
-	emailRepo := gmail.NewGmailRepository(tokenProvider)
-	storageService, err := s3bucket.NewS3Storage("sample-bucket", "http://localhost:4566")
-	if err != nil {
-		log.Fatalf("Failed to initialize S3 storage: %v", err)
-	}
-	dbService, err := dynamodb.NewDynamoDbInstance("http://localhost:4566")
-	if err != nil {
-		log.Fatalf("Failed to initialize Db storage: %v", err)
-	}
-	emailService := application_api.NewEmailService(emailRepo, storageService, dbService)
-	emailHandler := handlers.NewEmailHandler(emailService)
+	healthHandler := handlers.NewHealthHandler(config.HealthService)
+	emailHandler := handlers.NewEmailHandler(config.EmailService)
+	uploadHandler := handlers.NewUploadHandler(config.EmailService, config.UploadMaxBytes)
 
 	r.Route("/health", func(r chi.Router) {
 		r.Get("/", healthHandler.CheckHealth)
+		r.Get("/live", healthHandler.Live)
+		r.Get("/ready", healthHandler.CheckHealth)
 	})
 
 	r.Get("/emails/all", emailHandler.GetAllEmails)
+	r.Post("/emails/upload", uploadHandler.UploadEmails)
+	r.Get("/threads/{id}", emailHandler.GetThread)
+
+	if config.SyncService != nil {
+		webhookHandler := handlers.NewWebhookHandler(config.SyncService, config.PushVerifier, logger)
+		r.Post("/webhooks/gmail", webhookHandler.GmailPush)
+	}
 
 	return r
 }
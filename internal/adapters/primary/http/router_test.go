@@ -0,0 +1,303 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	httpadapter "email-parser-poc/internal/adapters/primary/http"
+	"email-parser-poc/internal/adapters/seondary/gmail"
+	"email-parser-poc/internal/adapters/seondary/gmail/gmailfake"
+	"email-parser-poc/internal/adapters/seondary/syncstate"
+	"email-parser-poc/internal/adapters/seondary/token"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/metrics"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStorage records uploaded batches in place of S3 and DynamoDB.
+type memoryStorage struct {
+	mu      sync.Mutex
+	batches []entities.EmailList
+	headers int
+}
+
+func (m *memoryStorage) UploadEmails(_ context.Context, emails *entities.EmailList) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches = append(m.batches, *emails)
+	return fmt.Sprintf("emails/batch-%d.json", len(m.batches)), nil
+}
+
+func (m *memoryStorage) UploadRawEmail(_ context.Context, messageID string, _ []byte) (string, error) {
+	return "raw/" + messageID + ".eml", nil
+}
+
+func (m *memoryStorage) UploadHeaders(_ context.Context, emails *entities.EmailList) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.headers += len(emails.Emails)
+	return nil
+}
+
+type testEnv struct {
+	gmail   *gmailfake.Server
+	storage *memoryStorage
+	metrics *metrics.Metrics
+	router  http.Handler
+}
+
+// newTestEnv wires the router to the Gmail adapter against the fake, the way
+// internal/bootstrap does, with a rule labelling and reading promotions.
+func newTestEnv(t *testing.T, timeout time.Duration) *testEnv {
+	t.Helper()
+	server := gmailfake.NewServer()
+	t.Cleanup(server.Close)
+
+	config := gmail.Config{
+		BaseURL:       server.BaseURL(),
+		TokenProvider: token.NewStaticTokenProvider(server.Token),
+		HTTPClient:    &http.Client{Timeout: timeout},
+	}
+	repo := gmail.NewGmailRepository(config)
+	storage := &memoryStorage{}
+	rules := []entities.MailboxRule{{
+		Name:      "promotions",
+		Category:  "promotions",
+		AddLabels: []string{"Processed"},
+		MarkRead:  true,
+	}}
+	postProcessor := application_api.NewPostProcessor(repo, rules, false, nil)
+	m := metrics.New()
+
+	router := httpadapter.NewRouter(httpadapter.RouterConfig{
+		EmailService: application_api.NewEmailService(repo, storage, storage, postProcessor, false, nil, nil),
+		SyncService: application_api.NewSyncService(gmail.NewGmailWatcher(config), repo,
+			syncstate.NewFileStore(filepath.Join(t.TempDir(), "sync.json")), storage, storage,
+			application_api.SyncOptions{PostProcessor: postProcessor}),
+		UploadMaxBytes: 1 << 20,
+		Metrics:        m,
+	})
+	return &testEnv{gmail: server, storage: storage, metrics: m, router: router}
+}
+
+func (e *testEnv) do(t *testing.T, method, path string, body []byte, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if path == "/emails/upload" {
+		req.Header.Set("Content-Type", "message/rfc822")
+	}
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, rec.Body)
+		}
+	}
+	return rec.Code
+}
+
+func (e *testEnv) push(t *testing.T, historyID uint64) (int, entities.SyncResult) {
+	t.Helper()
+	data, _ := json.Marshal(entities.PushNotification{EmailAddress: e.gmail.EmailAddress, HistoryID: historyID})
+	envelope, _ := json.Marshal(map[string]any{
+		"message":      map[string]string{"data": base64.StdEncoding.EncodeToString(data), "messageId": "1"},
+		"subscription": "projects/p/subscriptions/gmail",
+	})
+	var result entities.SyncResult
+	status := e.do(t, http.MethodPost, "/webhooks/gmail", envelope, &result)
+	return status, result
+}
+
+func message(subject, messageID, extra string) gmailfake.Message {
+	return gmailfake.Message{Raw: []byte("From: Shop <deals@shop.example>\r\n" +
+		"To: me@example.com\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: Mon, 3 Mar 2025 10:00:00 +0000\r\n" +
+		"Message-ID: <" + messageID + ">\r\n" +
+		extra +
+		"\r\n" +
+		"Huge discount, this week only.\r\n")}
+}
+
+func promotion(subject, messageID string) gmailfake.Message {
+	return message(subject, messageID, "List-Unsubscribe: <mailto:u@shop.example>\r\n")
+}
+
+type emailsResponse struct {
+	Emails     entities.EmailList `json:"emails"`
+	S3Filename string             `json:"s3_filename"`
+}
+
+func TestGetAllEmails(t *testing.T) {
+	env := newTestEnv(t, 0)
+	first := env.gmail.AddMessage(promotion("Spring sale", "spring@shop.example"))
+	env.gmail.AddMessage(promotion("Summer sale", "summer@shop.example"))
+
+	var resp emailsResponse
+	if status := env.do(t, http.MethodGet, "/emails/all?limit=10", nil, &resp); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if resp.Emails.TotalCount != 2 || resp.S3Filename != "emails/batch-1.json" {
+		t.Errorf("total = %d, s3 = %q", resp.Emails.TotalCount, resp.S3Filename)
+	}
+	if len(env.storage.batches) != 1 || env.storage.headers != 2 {
+		t.Errorf("stored %d batches, %d headers", len(env.storage.batches), env.storage.headers)
+	}
+
+	labels := env.gmail.Message(first.ID).LabelIDs
+	if !slices.Contains(labels, "Label_1") || slices.Contains(labels, "UNREAD") {
+		t.Errorf("labels after post-processing = %v", labels)
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	env := newTestEnv(t, 0)
+	first := env.gmail.AddMessage(promotion("Spring sale", "spring@shop.example"))
+	env.gmail.AddMessage(message("Re: Spring sale", "reply@example.com", "In-Reply-To: <spring@shop.example>\r\n"))
+
+	var thread entities.Thread
+	if status := env.do(t, http.MethodGet, "/threads/"+first.ThreadID, nil, &thread); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if thread.MessageCount != 2 || thread.Messages[1].ParentID == "" {
+		t.Errorf("thread = %+v", thread)
+	}
+
+	if status := env.do(t, http.MethodGet, "/threads/missing", nil, nil); status != http.StatusNotFound {
+		t.Errorf("missing thread status = %d, want 404", status)
+	}
+}
+
+func TestRequestMetricsUseRoutePattern(t *testing.T) {
+	env := newTestEnv(t, 0)
+	first := env.gmail.AddMessage(promotion("Spring sale", "spring@shop.example"))
+
+	env.do(t, http.MethodGet, "/threads/"+first.ThreadID, nil, nil)
+	env.do(t, http.MethodGet, "/threads/missing", nil, nil)
+	env.do(t, http.MethodGet, "/no/such/route", nil, nil)
+
+	rec := httptest.NewRecorder()
+	env.metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`email_parser_http_request_duration_seconds_count{method="GET",route="/threads/{id}",status="200"} 1`,
+		`email_parser_http_request_duration_seconds_count{method="GET",route="/threads/{id}",status="404"} 1`,
+		`email_parser_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+	if strings.Contains(body, first.ThreadID) {
+		t.Error("thread ID leaked into a metric label")
+	}
+}
+
+func TestGmailWebhookSyncsHistory(t *testing.T) {
+	env := newTestEnv(t, 0)
+	env.gmail.AddMessage(promotion("Spring sale", "spring@shop.example"))
+
+	// The first push only records where to start.
+	status, result := env.push(t, env.gmail.HistoryID())
+	if status != http.StatusOK || !result.Skipped {
+		t.Fatalf("first push: status = %d, result = %+v", status, result)
+	}
+
+	added := env.gmail.AddMessage(promotion("Summer sale", "summer@shop.example"))
+	historyID := env.gmail.HistoryID()
+	status, result = env.push(t, historyID)
+	if status != http.StatusOK || result.MessagesFetched != 1 || result.HistoryID != historyID {
+		t.Fatalf("second push: status = %d, result = %+v", status, result)
+	}
+	if batch := env.storage.batches[0]; len(batch.Emails) != 1 || batch.Emails[0].ID != added.ID {
+		t.Errorf("stored %+v", batch)
+	}
+	if labels := env.gmail.Message(added.ID).LabelIDs; slices.Contains(labels, "UNREAD") {
+		t.Errorf("labels after sync = %v", labels)
+	}
+
+	// An expired checkpoint falls back to a full fetch.
+	env.gmail.ExpireHistory()
+	env.gmail.AddMessage(promotion("Autumn sale", "autumn@shop.example"))
+	status, result = env.push(t, env.gmail.HistoryID())
+	if status != http.StatusOK || !result.Resynced || result.MessagesFetched != 3 {
+		t.Errorf("resync: status = %d, result = %+v", status, result)
+	}
+
+	// A failed sync is reported so Pub/Sub redelivers the push.
+	env.gmail.InjectFault(gmailfake.Fault{Path: "/history", Status: http.StatusInternalServerError})
+	env.gmail.AddMessage(promotion("Winter sale", "winter@shop.example"))
+	if status, _ := env.push(t, env.gmail.HistoryID()); status != http.StatusInternalServerError {
+		t.Errorf("failed sync status = %d, want 500", status)
+	}
+}
+
+func TestGmailFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   gmailfake.Fault
+		timeout time.Duration
+	}{
+		{name: "expired token", fault: gmailfake.Fault{Status: http.StatusUnauthorized}},
+		{name: "throttled", fault: gmailfake.Fault{Status: http.StatusTooManyRequests, RetryAfter: 10}},
+		{name: "outage", fault: gmailfake.Fault{Status: http.StatusInternalServerError}},
+		{name: "slow", fault: gmailfake.Fault{Delay: time.Second}, timeout: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, tt.timeout)
+			first := env.gmail.AddMessage(promotion("Spring sale", "spring@shop.example"))
+			env.gmail.InjectFault(tt.fault)
+
+			if status := env.do(t, http.MethodGet, "/emails/all", nil, nil); status != http.StatusInternalServerError {
+				t.Errorf("/emails/all status = %d, want 500", status)
+			}
+			if status := env.do(t, http.MethodGet, "/threads/"+first.ThreadID, nil, nil); status != http.StatusInternalServerError {
+				t.Errorf("/threads status = %d, want 500", status)
+			}
+			if len(env.storage.batches) != 0 {
+				t.Errorf("stored %d batches after a failed fetch", len(env.storage.batches))
+			}
+
+			env.gmail.ClearFaults()
+			if status := env.do(t, http.MethodGet, "/emails/all", nil, nil); status != http.StatusOK {
+				t.Errorf("status after recovery = %d", status)
+			}
+		})
+	}
+}
+
+func TestUploadRejectsNonMessages(t *testing.T) {
+	env := newTestEnv(t, 0)
+
+	for name, body := range map[string]string{
+		"plain text":     "Dear team,\r\nplease find the notes below.\r\n",
+		"space in name":  "From: ann@example.com\r\nX Bad Name: value\r\n\r\nbody\r\n",
+		"non-ASCII name": "From: ann@example.com\r\nSübject: value\r\n\r\nbody\r\n",
+		"no identity":    "Subject: status\r\nX-Priority: 1\r\n\r\nbody\r\n",
+		"HTTP headers":   "Host: example.com\r\nAccept: */*\r\n\r\n",
+	} {
+		if status := env.do(t, http.MethodPost, "/emails/upload", []byte(body), nil); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status = %d, want 422", name, status)
+		}
+	}
+	if len(env.storage.batches) != 0 {
+		t.Errorf("stored %d batches", len(env.storage.batches))
+	}
+
+	valid := "Message-ID: <note@example.com>\r\nSubject: notes\r\n\r\nbody\r\n"
+	if status := env.do(t, http.MethodPost, "/emails/upload", []byte(valid), nil); status != http.StatusOK {
+		t.Errorf("valid message: status = %d", status)
+	}
+}
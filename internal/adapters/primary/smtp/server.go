@@ -0,0 +1,264 @@
+package smtp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/pkg/logging"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	gosmtp "github.com/emersion/go-smtp"
+)
+
+type Config struct {
+	// Network is "tcp" or "unix"; LMTP is usually served on a unix socket.
+	Network string
+	Addr    string
+	LMTP    bool
+	// Domain is the host name announced in the greeting and Received header.
+	Domain string
+	// RecipientDomains lists the domains mail is accepted for; anything else
+	// is refused at RCPT so the listener can't be used as a relay.
+	RecipientDomains []string
+	MaxMessageBytes  int
+	MaxRecipients    int
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	// TLSConfig enables STARTTLS. RequireTLS refuses MAIL before it.
+	TLSConfig  *tls.Config
+	RequireTLS bool
+	// IngestTimeout bounds storing one message.
+	IngestTimeout time.Duration
+	Logger        *slog.Logger
+}
+
+// Server accepts mail over SMTP or LMTP and hands every message to the
+// email service, the same way uploaded .eml files are ingested.
+type Server struct {
+	config  Config
+	server  *gosmtp.Server
+	domains map[string]bool
+}
+
+func NewServer(config Config, emailService incoming.EmailService) (*Server, error) {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.IngestTimeout == 0 {
+		config.IngestTimeout = time.Minute
+	}
+	config.Logger = logging.OrDefault(config.Logger)
+	if len(config.RecipientDomains) == 0 {
+		return nil, fmt.Errorf("at least one recipient domain is required")
+	}
+
+	domains := make(map[string]bool, len(config.RecipientDomains))
+	for _, domain := range config.RecipientDomains {
+		normalized, err := parser.NormalizeDomain(domain)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient domain %q: %w", domain, err)
+		}
+		domains[normalized] = true
+	}
+
+	s := &Server{config: config, domains: domains}
+	backend := &backend{server: s, emailService: emailService}
+
+	server := gosmtp.NewServer(backend)
+	server.Addr = config.Addr
+	server.LMTP = config.LMTP
+	server.Domain = config.Domain
+	server.MaxMessageBytes = config.MaxMessageBytes
+	server.MaxRecipients = config.MaxRecipients
+	server.ReadTimeout = config.ReadTimeout
+	server.WriteTimeout = config.WriteTimeout
+	server.TLSConfig = config.TLSConfig
+	server.AuthDisabled = true
+	server.EnableSMTPUTF8 = true
+	s.server = server
+
+	return s, nil
+}
+
+// ListenAndServe blocks until Close is called.
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen(s.config.Network, s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.Addr, err)
+	}
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	protocol := "SMTP"
+	if s.config.LMTP {
+		protocol = "LMTP"
+	}
+	s.config.Logger.Info("mail listener started", "protocol", protocol, "addr", listener.Addr().String(), "domains", s.config.RecipientDomains)
+	return s.server.Serve(listener)
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+func (s *Server) acceptsDomain(domain string) bool {
+	normalized, err := parser.NormalizeDomain(domain)
+	return err == nil && s.domains[normalized]
+}
+
+type backend struct {
+	server       *Server
+	emailService incoming.EmailService
+}
+
+func (b *backend) Login(state *gosmtp.ConnectionState, username, password string) (gosmtp.Session, error) {
+	return nil, gosmtp.ErrAuthUnsupported
+}
+
+func (b *backend) AnonymousLogin(state *gosmtp.ConnectionState) (gosmtp.Session, error) {
+	if b.server.config.RequireTLS && !state.TLS.HandshakeComplete {
+		return nil, &gosmtp.SMTPError{
+			Code:         530,
+			EnhancedCode: gosmtp.EnhancedCode{5, 7, 0},
+			Message:      "Must issue a STARTTLS command first",
+		}
+	}
+	return &session{backend: b, state: *state}, nil
+}
+
+type session struct {
+	backend    *backend
+	state      gosmtp.ConnectionState
+	from       string
+	recipients []string
+}
+
+func (s *session) Reset() {
+	s.from = ""
+	s.recipients = nil
+}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+func (s *session) Mail(from string, opts gosmtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *session) Rcpt(to string) error {
+	_, domain, ok := strings.Cut(to, "@")
+	if !ok || !s.backend.server.acceptsDomain(domain) {
+		return &gosmtp.SMTPError{
+			Code:         550,
+			EnhancedCode: gosmtp.EnhancedCode{5, 7, 1},
+			Message:      "Relaying denied",
+		}
+	}
+	s.recipients = append(s.recipients, to)
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	return s.ingest(r)
+}
+
+// LMTPData delivers once for all recipients, so they share one status.
+func (s *session) LMTPData(r io.Reader, status gosmtp.StatusCollector) error {
+	err := s.ingest(r)
+	for _, rcpt := range s.recipients {
+		status.SetStatus(rcpt, err)
+	}
+	return err
+}
+
+func (s *session) ingest(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		// go-smtp reports oversized messages as an SMTPError already.
+		return err
+	}
+
+	receivedAt := time.Now().UTC()
+	id := newQueueID()
+	message := entities.RawMessage{
+		Name:       "smtp:" + id,
+		Data:       append([]byte(s.receivedHeader(id, receivedAt)), data...),
+		Labels:     []string{"INBOX", "UNREAD"},
+		ReceivedAt: receivedAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.backend.server.config.IngestTimeout)
+	defer cancel()
+	ctx = logging.WithRequestID(ctx, id)
+	logger := s.backend.server.config.Logger
+
+	emailList, _, err := s.backend.emailService.IngestRawEmails(ctx, []entities.RawMessage{message})
+	if err != nil {
+		logger.WarnContext(ctx, "failed to ingest message", "from", s.from, "error", err)
+		if errors.Is(err, entities.ErrInvalidMessage) {
+			return &gosmtp.SMTPError{
+				Code:         554,
+				EnhancedCode: gosmtp.EnhancedCode{5, 6, 0},
+				Message:      "Message is not valid RFC 5322",
+			}
+		}
+		// Temporary, so the sending MTA queues and retries.
+		return &gosmtp.SMTPError{
+			Code:         451,
+			EnhancedCode: gosmtp.EnhancedCode{4, 3, 0},
+			Message:      "Temporary failure storing message",
+		}
+	}
+
+	logger.InfoContext(ctx, "received message", "from", s.from, "recipients", s.recipients, "message_id", emailList.Emails[0].ID)
+	return nil
+}
+
+// receivedHeader builds the RFC 5321 trace field this hop adds.
+func (s *session) receivedHeader(id string, at time.Time) string {
+	config := s.backend.server.config
+
+	protocol := "ESMTP"
+	switch {
+	case config.LMTP:
+		protocol = "LMTP"
+	case s.state.TLS.HandshakeComplete:
+		protocol = "ESMTPS"
+	}
+
+	remote := "unknown"
+	if s.state.RemoteAddr != nil {
+		remote = s.state.RemoteAddr.String()
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = "[" + host + "]"
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Received: from %s (%s)\r\n", s.state.Hostname, remote)
+	fmt.Fprintf(&b, "\tby %s with %s id %s", config.Domain, protocol, id)
+	if len(s.recipients) == 1 {
+		fmt.Fprintf(&b, "\r\n\tfor <%s>", s.recipients[0])
+	}
+	fmt.Fprintf(&b, "; %s\r\n", at.Format(time.RFC1123Z))
+	return b.String()
+}
+
+func newQueueID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
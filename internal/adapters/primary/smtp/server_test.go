@@ -0,0 +1,203 @@
+package smtp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"math/big"
+	"net"
+	netsmtp "net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStorage records what the email service stores, raw copies included.
+type memoryStorage struct {
+	mu     sync.Mutex
+	emails []entities.EmailMessage
+	raw    [][]byte
+}
+
+func (m *memoryStorage) UploadEmails(_ context.Context, emails *entities.EmailList) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.emails = append(m.emails, emails.Emails...)
+	return "emails/batch.json", nil
+}
+
+func (m *memoryStorage) UploadRawEmail(_ context.Context, messageID string, raw []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.raw = append(m.raw, raw)
+	return "raw/" + messageID + ".eml", nil
+}
+
+func (m *memoryStorage) UploadHeaders(context.Context, *entities.EmailList) error {
+	return nil
+}
+
+func (m *memoryStorage) stored() ([]entities.EmailMessage, [][]byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.emails, m.raw
+}
+
+// startServer serves on a free loopback port and returns its address.
+func startServer(t *testing.T, config Config) (string, *memoryStorage) {
+	t.Helper()
+	storage := &memoryStorage{}
+	config.Domain = "mx.example.com"
+	config.RecipientDomains = []string{"example.com"}
+	server, err := NewServer(config, application_api.NewEmailService(nil, storage, storage, nil, true, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String(), storage
+}
+
+const message = "From: Ann <ann@sender.example>\r\n" +
+	"To: me@example.com\r\n" +
+	"Subject: Hello\r\n" +
+	"Date: Mon, 3 Mar 2025 10:00:00 +0000\r\n" +
+	"Message-ID: <hello@sender.example>\r\n" +
+	"\r\n" +
+	"Hi there.\r\n"
+
+func smtpCode(err error) int {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code
+	}
+	return 0
+}
+
+func TestReceiveAddsReceivedHeader(t *testing.T) {
+	addr, storage := startServer(t, Config{})
+
+	if err := netsmtp.SendMail(addr, nil, "ann@sender.example", []string{"me@example.com"}, []byte(message)); err != nil {
+		t.Fatal(err)
+	}
+
+	emails, raw := storage.stored()
+	if len(emails) != 1 || emails[0].Subject != "Hello" {
+		t.Fatalf("stored %+v", emails)
+	}
+	if !slices.Contains(emails[0].Labels, "INBOX") || emails[0].ReceivedAt.IsZero() {
+		t.Errorf("labels = %v, received = %v", emails[0].Labels, emails[0].ReceivedAt)
+	}
+
+	received, _, _ := strings.Cut(string(raw[0]), "\r\nFrom: ")
+	for _, want := range []string{"Received: from localhost ([127.0.0.1])", "by mx.example.com with ESMTP id ", "for <me@example.com>;"} {
+		if !strings.Contains(received, want) {
+			t.Errorf("Received header %q lacks %q", received, want)
+		}
+	}
+	if !strings.HasSuffix(string(raw[0]), message) {
+		t.Errorf("message body changed: %q", raw[0])
+	}
+}
+
+func TestRejectsRelaying(t *testing.T) {
+	addr, storage := startServer(t, Config{})
+
+	for _, rcpt := range []string{"someone@elsewhere.example", "me@sub.example.com", "no-domain"} {
+		err := netsmtp.SendMail(addr, nil, "ann@sender.example", []string{rcpt}, []byte(message))
+		if code := smtpCode(err); code != 550 {
+			t.Errorf("%s: err = %v, want 550", rcpt, err)
+		}
+	}
+
+	// Recipient domains match case-insensitively.
+	if err := netsmtp.SendMail(addr, nil, "ann@sender.example", []string{"me@EXAMPLE.com"}, []byte(message)); err != nil {
+		t.Errorf("upper-case domain: %v", err)
+	}
+	if emails, _ := storage.stored(); len(emails) != 1 {
+		t.Errorf("stored %d messages, want 1", len(emails))
+	}
+}
+
+func TestRejectsInvalidMessage(t *testing.T) {
+	addr, storage := startServer(t, Config{})
+
+	err := netsmtp.SendMail(addr, nil, "ann@sender.example", []string{"me@example.com"}, []byte("just some text\r\n"))
+	if code := smtpCode(err); code != 554 {
+		t.Errorf("err = %v, want 554", err)
+	}
+	if emails, _ := storage.stored(); len(emails) != 0 {
+		t.Errorf("stored %d messages", len(emails))
+	}
+}
+
+func TestRequireTLS(t *testing.T) {
+	addr, storage := startServer(t, Config{TLSConfig: selfSignedTLS(t), RequireTLS: true})
+
+	client, err := netsmtp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Mail("ann@sender.example"); smtpCode(err) != 530 {
+		t.Fatalf("MAIL before STARTTLS: err = %v, want 530", err)
+	}
+
+	if err := client.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Mail("ann@sender.example"); err != nil {
+		t.Fatalf("MAIL after STARTTLS: %v", err)
+	}
+	if err := client.Rcpt("me@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(message))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client.Quit()
+
+	if _, raw := storage.stored(); len(raw) != 1 || !strings.Contains(string(raw[0]), "with ESMTPS id ") {
+		t.Errorf("stored %q", raw)
+	}
+}
+
+func selfSignedTLS(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mx.example.com"},
+		DNSNames:     []string{"mx.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
@@ -0,0 +1,89 @@
+// Package awsconfig loads the AWS SDK configuration shared by the S3 and
+// DynamoDB adapters.
+package awsconfig
+
+import (
+	"context"
+	"email-parser-poc/internal/ports/outgoing"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const (
+	// CredentialsDefault uses the SDK's default chain: environment, shared
+	// config files, then container or instance roles.
+	CredentialsDefault = "default"
+	// CredentialsStatic uses the keys given in Config, e.g. for LocalStack.
+	CredentialsStatic = "static"
+)
+
+type Config struct {
+	Region string
+	// Endpoint overrides every service endpoint, e.g. http://localhost:4566
+	// for LocalStack. Adapters may override it again per service.
+	Endpoint        string
+	Credentials     string
+	Profile         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Secrets resolves the static keys when set, so they may be references
+	// such as env:AWS_SECRET_ACCESS_KEY.
+	Secrets outgoing.SecretResolver
+}
+
+func Load(ctx context.Context, cfg Config) (aws.Config, error) {
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.Region),
+	}
+	if cfg.Endpoint != "" {
+		options = append(options, config.WithBaseEndpoint(cfg.Endpoint))
+	}
+	if cfg.Profile != "" {
+		options = append(options, config.WithSharedConfigProfile(cfg.Profile))
+	}
+
+	switch cfg.Credentials {
+	case CredentialsDefault, "":
+	case CredentialsStatic:
+		var provider aws.CredentialsProvider = credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+		if cfg.Secrets != nil {
+			provider = aws.NewCredentialsCache(secretCredentials(cfg))
+		}
+		options = append(options, config.WithCredentialsProvider(provider))
+	default:
+		return aws.Config{}, fmt.Errorf("unknown AWS credentials source %q", cfg.Credentials)
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	return awsConfig, nil
+}
+
+// secretCredentials resolves the static keys whenever the SDK asks for
+// credentials; the cache in front of it asks once.
+func secretCredentials(cfg Config) aws.CredentialsProviderFunc {
+	return func(ctx context.Context) (aws.Credentials, error) {
+		credentials := aws.Credentials{Source: "SecretResolver"}
+		for _, field := range []struct {
+			ref   string
+			value *string
+		}{
+			{cfg.AccessKeyID, &credentials.AccessKeyID},
+			{cfg.SecretAccessKey, &credentials.SecretAccessKey},
+			{cfg.SessionToken, &credentials.SessionToken},
+		} {
+			value, err := cfg.Secrets.Resolve(ctx, field.ref)
+			if err != nil {
+				return aws.Credentials{}, fmt.Errorf("failed to resolve AWS credentials: %w", err)
+			}
+			*field.value = value
+		}
+		return credentials, nil
+	}
+}
@@ -1,7 +1,15 @@
 package config
 
 import (
+	"email-parser-poc/internal/adapters/seondary/awsconfig"
+	"email-parser-poc/internal/adapters/seondary/s3bucket"
+	"email-parser-poc/internal/adapters/seondary/secrets"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/tracing"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -13,6 +21,229 @@ type Config struct {
 	App    AppConfig    `mapstructure:"app"`
 	Server ServerConfig `mapstructure:"server"`
 	Auth   AuthConfig   `mapstructure:"auth"`
+	Push   PushConfig   `mapstructure:"push"`
+
+	// Source selects the mailbox adapter: "gmail" (default), "imap" or
+	// "graph".
+	Source string      `mapstructure:"source"`
+	Gmail  GmailConfig `mapstructure:"gmail"`
+	IMAP   IMAPConfig  `mapstructure:"imap"`
+	Graph  GraphConfig `mapstructure:"graph"`
+
+	AWS        AWSConfig        `mapstructure:"aws"`
+	S3         S3Config         `mapstructure:"s3"`
+	DynamoDB   DynamoDBConfig   `mapstructure:"dynamodb"`
+	Classifier ClassifierConfig `mapstructure:"classifier"`
+
+	PostProcessing PostProcessingConfig `mapstructure:"post_processing"`
+	Archive        ArchiveConfig        `mapstructure:"archive"`
+	Import         ImportConfig         `mapstructure:"import"`
+	Reprocess      ReprocessConfig      `mapstructure:"reprocess"`
+	Upload         UploadConfig         `mapstructure:"upload"`
+	SMTP           SMTPConfig           `mapstructure:"smtp"`
+	Admin          AdminConfig          `mapstructure:"admin"`
+	Tracing        TracingConfig        `mapstructure:"tracing"`
+	Health         HealthConfig         `mapstructure:"health"`
+	Secrets        SecretsConfig        `mapstructure:"secrets"`
+}
+
+// SecretsConfig locates the encrypted store behind "secret:" references.
+// Credentials elsewhere in the config may also be "file://" or "env:"
+// references; see the secrets package.
+type SecretsConfig struct {
+	File    string `mapstructure:"file"`
+	KeyEnv  string `mapstructure:"key_env"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+func (c SecretsConfig) Resolver() secrets.Config {
+	return secrets.Config{
+		StoreFile: c.File,
+		KeyEnv:    c.KeyEnv,
+		KeyFile:   c.KeyFile,
+	}
+}
+
+// HealthConfig tunes /health/ready. Timeout bounds each dependency check;
+// CacheTTL is how long a result is served before the checks run again.
+type HealthConfig struct {
+	Timeout  time.Duration `mapstructure:"timeout"`
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+// TracingConfig controls OpenTelemetry tracing. Exporter is "none"
+// (default, tracing off), "stdout" or "otlp", the latter sending OTLP/HTTP
+// to Endpoint.
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// AdminConfig controls the admin listener started by serve, which exposes
+// /metrics. It binds to loopback by default so it stays off the public port.
+type AdminConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Host    string `mapstructure:"host"`
+	Port    string `mapstructure:"port"`
+}
+
+// AWSConfig is shared by the S3 and DynamoDB adapters. Credentials is
+// "default" for the SDK's chain (environment, shared files, instance roles)
+// or "static" for the keys below. Endpoint points both services elsewhere,
+// e.g. at LocalStack; leave it empty for AWS itself.
+type AWSConfig struct {
+	Region          string `mapstructure:"region"`
+	Endpoint        string `mapstructure:"endpoint"`
+	Credentials     string `mapstructure:"credentials"`
+	Profile         string `mapstructure:"profile"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	SessionToken    string `mapstructure:"session_token"`
+}
+
+func (c AWSConfig) SDK() awsconfig.Config {
+	return awsconfig.Config{
+		Region:          c.Region,
+		Endpoint:        c.Endpoint,
+		Credentials:     c.Credentials,
+		Profile:         c.Profile,
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+	}
+}
+
+// S3Config locates stored messages. Prefix is prepended to every key.
+// Endpoint overrides aws.endpoint for S3 alone.
+//
+// Layout "message" stores one object per message at KeyTemplate, with a
+// manifest per request when Manifests is set; "batch" stores each request as
+// one emails_<timestamp>.json. Account fills {account} in the template and
+// defaults to the source mailbox.
+//
+// Format is "json", "ndjson" or "parquet" and Compression "none", "gzip" or
+// "zstd"; the key extension follows them, e.g. .ndjson.gz.
+type S3Config struct {
+	Bucket       string `mapstructure:"bucket"`
+	Prefix       string `mapstructure:"prefix"`
+	Endpoint     string `mapstructure:"endpoint"`
+	UsePathStyle bool   `mapstructure:"use_path_style"`
+	Layout       string `mapstructure:"layout"`
+	KeyTemplate  string `mapstructure:"key_template"`
+	Manifests    bool   `mapstructure:"manifests"`
+	Account      string `mapstructure:"account"`
+	Format       string `mapstructure:"format"`
+	Compression  string `mapstructure:"compression"`
+}
+
+// DynamoDBConfig names the tables. Endpoint overrides aws.endpoint for
+// DynamoDB alone.
+type DynamoDBConfig struct {
+	HeadersTable string `mapstructure:"headers_table"`
+	Endpoint     string `mapstructure:"endpoint"`
+}
+
+// GmailConfig configures the Gmail source. BaseURL overrides the API root,
+// e.g. with a gmailfake server. UserID is the mailbox, "me" for the token's
+// own.
+type GmailConfig struct {
+	BaseURL string        `mapstructure:"base_url"`
+	UserID  string        `mapstructure:"user_id"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ClassifierConfig overrides the built-in promotional rules. Empty fields
+// keep their defaults.
+type ClassifierConfig struct {
+	Keywords      []string       `mapstructure:"keywords"`
+	KeywordWeight int            `mapstructure:"keyword_weight"`
+	HeaderWeights map[string]int `mapstructure:"header_weights"`
+	Threshold     int            `mapstructure:"threshold"`
+}
+
+func (c ClassifierConfig) Rules() classifier.Rules {
+	rules := classifier.DefaultRules()
+	if len(c.Keywords) > 0 {
+		rules.Keywords = c.Keywords
+	}
+	if c.KeywordWeight > 0 {
+		rules.KeywordWeight = c.KeywordWeight
+	}
+	if len(c.HeaderWeights) > 0 {
+		rules.HeaderWeights = c.HeaderWeights
+	}
+	if c.Threshold > 0 {
+		rules.Threshold = c.Threshold
+	}
+	return rules
+}
+
+// ReadClassifierRules reads the rules from the classifier section of a config
+// file, or from a file holding just that section, in any format viper reads.
+// Nothing else in the file is looked at, and the environment is ignored.
+func ReadClassifierRules(path string) (classifier.Rules, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return classifier.Rules{}, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	if section := v.Sub("classifier"); section != nil {
+		v = section
+	}
+
+	var c ClassifierConfig
+	if err := v.Unmarshal(&c); err != nil {
+		return classifier.Rules{}, fmt.Errorf("failed to unmarshal rules in %s: %w", path, err)
+	}
+	if c.KeywordWeight < 0 || c.Threshold < 0 {
+		return classifier.Rules{}, fmt.Errorf("%s: keyword_weight and threshold cannot be negative", path)
+	}
+	return c.Rules(), nil
+}
+
+// SMTPConfig controls the optional inbound SMTP/LMTP listener started by
+// serve. Mail is only accepted for RecipientDomains. STARTTLS is offered
+// when a certificate is configured.
+type SMTPConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	LMTP             bool          `mapstructure:"lmtp"`
+	Network          string        `mapstructure:"network"`
+	Addr             string        `mapstructure:"addr"`
+	Domain           string        `mapstructure:"domain"`
+	RecipientDomains []string      `mapstructure:"recipient_domains"`
+	MaxMessageBytes  int           `mapstructure:"max_message_bytes"`
+	MaxRecipients    int           `mapstructure:"max_recipients"`
+	ReadTimeout      time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout     time.Duration `mapstructure:"write_timeout"`
+	TLSCertFile      string        `mapstructure:"tls_cert_file"`
+	TLSKeyFile       string        `mapstructure:"tls_key_file"`
+	RequireTLS       bool          `mapstructure:"require_tls"`
+}
+
+// UploadConfig limits POST /emails/upload. MaxBytes caps the request body.
+type UploadConfig struct {
+	MaxBytes int64 `mapstructure:"max_bytes"`
+}
+
+// ImportConfig controls the `import` command. StateDir holds checkpoints and
+// the Message-IDs already imported.
+type ImportConfig struct {
+	StateDir  string `mapstructure:"state_dir"`
+	BatchSize int    `mapstructure:"batch_size"`
+}
+
+// ReprocessConfig controls `reprocess` and POST /admin/reprocess.
+// Checkpoints are kept in import.state_dir.
+type ReprocessConfig struct {
+	Concurrency int `mapstructure:"concurrency"`
+}
+
+// ArchiveConfig controls what is kept besides the parsed JSON. RawEML stores
+// the original RFC 822 message as .eml for legal hold and reprocessing.
+type ArchiveConfig struct {
+	RawEML bool `mapstructure:"raw_eml"`
 }
 
 type AppConfig struct {
@@ -20,6 +251,36 @@ type AppConfig struct {
 	Version     string `mapstructure:"version"`
 	Environment string `mapstructure:"environment"`
 	Debug       bool   `mapstructure:"debug"`
+	// LogLevel and LogFormat default from Debug: debug and text when set,
+	// info and json otherwise.
+	LogLevel  string `mapstructure:"log_level"`
+	LogFormat string `mapstructure:"log_format"`
+	// PIIDebug logs message bodies and addresses unmasked. Never enable it
+	// in production.
+	PIIDebug bool `mapstructure:"pii_debug"`
+}
+
+// Logging resolves the logger settings.
+func (c AppConfig) Logging() (logging.Config, error) {
+	levelName, format := "info", logging.FormatJSON
+	if c.Debug {
+		levelName, format = "debug", logging.FormatText
+	}
+	if c.LogLevel != "" {
+		levelName = c.LogLevel
+	}
+	if c.LogFormat != "" {
+		format = strings.ToLower(c.LogFormat)
+	}
+
+	level, err := logging.ParseLevel(levelName)
+	if err != nil {
+		return logging.Config{}, fmt.Errorf("app.log_level: %w", err)
+	}
+	if !logging.ValidFormat(format) {
+		return logging.Config{}, fmt.Errorf("app.log_format must be %q or %q, got %q", logging.FormatJSON, logging.FormatText, c.LogFormat)
+	}
+	return logging.Config{Level: level, Format: format, PIIDebug: c.PIIDebug}, nil
 }
 
 type ServerConfig struct {
@@ -35,6 +296,75 @@ type AuthConfig struct {
 	AccessToken string `mapstructure:"access_token"`
 }
 
+// IMAPConfig configures the IMAP source. Security is "tls", "starttls" or
+// "none"; Auth is "login" or "xoauth2", the latter using auth.access_token.
+type IMAPConfig struct {
+	Host          string `mapstructure:"host"`
+	Port          int    `mapstructure:"port"`
+	Security      string `mapstructure:"security"`
+	Auth          string `mapstructure:"auth"`
+	Username      string `mapstructure:"username"`
+	Password      string `mapstructure:"password"`
+	Folder        string `mapstructure:"folder"`
+	ArchiveFolder string `mapstructure:"archive_folder"`
+	TrashFolder   string `mapstructure:"trash_folder"`
+}
+
+// GraphConfig configures the Microsoft Graph source, authenticated with the
+// client credentials of an app registration in TenantID. User is the
+// mailbox's user ID or principal name.
+type GraphConfig struct {
+	TenantID      string `mapstructure:"tenant_id"`
+	ClientID      string `mapstructure:"client_id"`
+	ClientSecret  string `mapstructure:"client_secret"`
+	User          string `mapstructure:"user"`
+	Folder        string `mapstructure:"folder"`
+	ArchiveFolder string `mapstructure:"archive_folder"`
+	// BaseURL and TokenURL override the public endpoints, e.g. for national
+	// clouds or a local fake.
+	BaseURL  string `mapstructure:"base_url"`
+	TokenURL string `mapstructure:"token_url"`
+}
+
+// PushConfig controls Gmail push notifications delivered through Pub/Sub.
+type PushConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	Topic               string        `mapstructure:"topic"`
+	LabelIDs            []string      `mapstructure:"label_ids"`
+	VerifyJWT           bool          `mapstructure:"verify_jwt"`
+	Audience            string        `mapstructure:"audience"`
+	ServiceAccountEmail string        `mapstructure:"service_account_email"`
+	JWKSURL             string        `mapstructure:"jwks_url"`
+	KeyFiles            []string      `mapstructure:"key_files"`
+	RenewBefore         time.Duration `mapstructure:"renew_before"`
+	RenewInterval       time.Duration `mapstructure:"renew_interval"`
+	StateFile           string        `mapstructure:"state_file"`
+}
+
+// PostProcessingConfig holds the mailbox rules applied after messages are
+// stored. DryRun logs the mutations without sending them to the source.
+type PostProcessingConfig struct {
+	DryRun bool                `mapstructure:"dry_run"`
+	Rules  []MailboxRuleConfig `mapstructure:"rules"`
+}
+
+type MailboxRuleConfig struct {
+	Name          string   `mapstructure:"name"`
+	Category      string   `mapstructure:"category"`
+	MinConfidence float64  `mapstructure:"min_confidence"`
+	AddLabels     []string `mapstructure:"add_labels"`
+	RemoveLabels  []string `mapstructure:"remove_labels"`
+	Archive       bool     `mapstructure:"archive"`
+	MarkRead      bool     `mapstructure:"mark_read"`
+	Trash         bool     `mapstructure:"trash"`
+}
+
+const (
+	SourceGmail = "gmail"
+	SourceIMAP  = "imap"
+	SourceGraph = "graph"
+)
+
 func DefaultConfig() Config {
 	config := Config{
 		App: AppConfig{
@@ -54,29 +384,195 @@ func DefaultConfig() Config {
 		Auth: AuthConfig{
 			AccessToken: "",
 		},
+		Secrets: SecretsConfig{
+			File:   "configs/secrets.enc",
+			KeyEnv: "PRIVCY_SECRETS_KEY",
+		},
+		Source: SourceGmail,
+		Gmail: GmailConfig{
+			BaseURL: "https://gmail.googleapis.com",
+			UserID:  "me",
+			Timeout: 30 * time.Second,
+		},
+		// The AWS defaults target the LocalStack container from
+		// docker-compose.yml. Production sets aws.credentials to "default"
+		// and clears aws.endpoint. There is no default session token:
+		// LocalStack needs none, and it would be sent along with real
+		// static keys, which AWS then rejects.
+		AWS: AWSConfig{
+			Region:          "us-east-1",
+			Endpoint:        "http://localhost:4566",
+			Credentials:     awsconfig.CredentialsStatic,
+			AccessKeyID:     "test-key",
+			SecretAccessKey: "test-secret",
+		},
+		S3: S3Config{
+			Bucket:       "sample-bucket",
+			Prefix:       "emails/",
+			UsePathStyle: true,
+			Layout:       s3bucket.LayoutMessage,
+			KeyTemplate:  s3bucket.DefaultKeyTemplate,
+			Manifests:    true,
+			Format:       s3bucket.FormatJSON,
+			Compression:  s3bucket.CompressionNone,
+		},
+		DynamoDB: DynamoDBConfig{
+			HeadersTable: "gmail-headers",
+		},
+		IMAP: IMAPConfig{
+			Security:      "tls",
+			Auth:          "login",
+			Folder:        "INBOX",
+			ArchiveFolder: "Archive",
+			TrashFolder:   "Trash",
+		},
+		Graph: GraphConfig{
+			Folder:        "inbox",
+			ArchiveFolder: "archive",
+		},
+		Push: PushConfig{
+			Enabled:       false,
+			LabelIDs:      []string{"INBOX"},
+			VerifyJWT:     true,
+			JWKSURL:       "https://www.googleapis.com/oauth2/v3/certs",
+			RenewBefore:   24 * time.Hour,
+			RenewInterval: time.Hour,
+			StateFile:     "data/sync_state.json",
+		},
+		SMTP: SMTPConfig{
+			Enabled:         false,
+			Network:         "tcp",
+			Addr:            ":2525",
+			Domain:          "localhost",
+			MaxMessageBytes: 25 << 20,
+			MaxRecipients:   50,
+			ReadTimeout:     60 * time.Second,
+			WriteTimeout:    60 * time.Second,
+		},
+		Upload: UploadConfig{
+			MaxBytes: 25 << 20,
+		},
+		Admin: AdminConfig{
+			Enabled: true,
+			Host:    "127.0.0.1",
+			Port:    "9090",
+		},
+		Health: HealthConfig{
+			Timeout:  2 * time.Second,
+			CacheTTL: 5 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+		Import: ImportConfig{
+			StateDir:  "data/import",
+			BatchSize: 100,
+		},
+		Reprocess: ReprocessConfig{
+			Concurrency: 4,
+		},
+		PostProcessing: PostProcessingConfig{
+			DryRun: true,
+		},
 	}
 	return config
 }
 
+// defaultMailboxRules apply when post_processing.rules is not set at all.
+// They are kept out of DefaultConfig because viper decodes list items over
+// the default elements, so a configured rule would inherit their fields.
+func defaultMailboxRules() []MailboxRuleConfig {
+	return []MailboxRuleConfig{
+		{
+			Name:          "archive-promotions",
+			Category:      "promotions",
+			MinConfidence: 0.9,
+			AddLabels:     []string{"parsed/promotions"},
+			Archive:       true,
+		},
+	}
+}
+
 func Load() (*Config, error) {
+	config, err := LoadUnvalidated()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
 
-	godotenv.Load("configs/.env")
+// LoadUnvalidated loads the configuration like Load but skips validation,
+// for commands that only need part of it, such as secrets.
+func LoadUnvalidated() (*Config, error) {
 
-	config := DefaultConfig()
+	loadDotEnv(dotEnvFile)
 
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("./configs")
-	viper.AddConfigPath(".")
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath("./configs")
+		viper.AddConfigPath(".")
+	}
 
 	viper.SetEnvPrefix("PRIVCY")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	bindEnv()
 
-	viper.BindEnv("auth.access_token", "ACCESS_TOKEN")
+	return read()
+}
+
+const dotEnvFile = "configs/.env"
+
+// configFile is the file given with SetFile; empty searches ./configs and
+// the working directory for config.yaml.
+var configFile string
+
+// SetFile makes Load read path instead of searching for config.yaml.
+func SetFile(path string) {
+	configFile = path
+}
+
+// File is the config file read by the last Load, or "" when none was found.
+func File() string {
+	return viper.ConfigFileUsed()
+}
+
+// loadDotEnv adds the variables in path to the environment, remembering
+// which ones it set so Settings can tell them apart.
+func loadDotEnv(path string) {
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return
+	}
+	for name, value := range values {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+		os.Setenv(name, value)
+		dotEnvVars[name] = true
+	}
+}
+
+// read reads the config file found by Load into a fresh Config, without
+// validating it.
+func read() (*Config, error) {
+	config := DefaultConfig()
 
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		switch err.(type) {
+		case viper.ConfigFileNotFoundError:
+		case viper.ConfigParseError:
+			return nil, fmt.Errorf("failed to read config file: %w: %w", entities.ErrInvalidConfig, err)
+		default:
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
@@ -85,12 +581,18 @@ func Load() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
+	if !viper.IsSet("post_processing.rules") {
+		config.PostProcessing.Rules = defaultMailboxRules()
 	}
 	return &config, nil
+}
 
+// validate is Validate with the error marked as entities.ErrInvalidConfig.
+func (c *Config) validate() error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("config validation failed: %w: %w", entities.ErrInvalidConfig, err)
+	}
+	return nil
 }
 
 func (c *Config) Validate() error {
@@ -103,8 +605,226 @@ func (c *Config) Validate() error {
 	if c.Server.Port == "" {
 		return fmt.Errorf("server.port is required")
 	}
-	if c.Auth.AccessToken == "" {
-		return fmt.Errorf("auth.access_token is required - set ACCESS_TOKEN environment variable")
+	if _, err := c.App.Logging(); err != nil {
+		return err
+	}
+	if c.App.PIIDebug && strings.EqualFold(c.App.Environment, "production") {
+		return fmt.Errorf("app.pii_debug cannot be enabled in production")
+	}
+	switch c.Source {
+	case SourceGmail:
+		if c.Auth.AccessToken == "" {
+			return fmt.Errorf("auth.access_token is required - set ACCESS_TOKEN environment variable")
+		}
+	case SourceIMAP:
+		if err := c.IMAP.validate(c.Auth.AccessToken); err != nil {
+			return err
+		}
+		if c.Push.Enabled {
+			return fmt.Errorf("push notifications are only supported for the gmail source")
+		}
+	case SourceGraph:
+		if err := c.Graph.validate(); err != nil {
+			return err
+		}
+		if c.Push.Enabled {
+			return fmt.Errorf("push notifications are only supported for the gmail source")
+		}
+	default:
+		return fmt.Errorf("source must be %q, %q or %q, got %q", SourceGmail, SourceIMAP, SourceGraph, c.Source)
+	}
+	for i, rule := range c.PostProcessing.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("post_processing.rules[%d].name is required", i)
+		}
+		if rule.MinConfidence < 0 || rule.MinConfidence > 1 {
+			return fmt.Errorf("post_processing.rules[%d].min_confidence must be between 0 and 1", i)
+		}
+	}
+	if c.Upload.MaxBytes <= 0 {
+		return fmt.Errorf("upload.max_bytes must be positive")
+	}
+	if c.SMTP.Enabled {
+		if len(c.SMTP.RecipientDomains) == 0 {
+			return fmt.Errorf("smtp.recipient_domains is required when smtp is enabled")
+		}
+		if c.SMTP.Network != "tcp" && c.SMTP.Network != "unix" {
+			return fmt.Errorf("smtp.network must be tcp or unix")
+		}
+		if c.SMTP.MaxMessageBytes <= 0 {
+			return fmt.Errorf("smtp.max_message_bytes must be positive")
+		}
+		if (c.SMTP.TLSCertFile == "") != (c.SMTP.TLSKeyFile == "") {
+			return fmt.Errorf("smtp.tls_cert_file and smtp.tls_key_file must be set together")
+		}
+		if c.SMTP.RequireTLS && c.SMTP.TLSCertFile == "" {
+			return fmt.Errorf("smtp.require_tls needs smtp.tls_cert_file and smtp.tls_key_file")
+		}
+	}
+	if c.Import.StateDir == "" {
+		return fmt.Errorf("import.state_dir is required")
+	}
+	if c.Reprocess.Concurrency <= 0 {
+		return fmt.Errorf("reprocess.concurrency must be positive")
+	}
+	if err := c.validateStorage(); err != nil {
+		return err
+	}
+	if err := c.validateSecrets(); err != nil {
+		return err
+	}
+	if c.Gmail.Timeout <= 0 {
+		return fmt.Errorf("gmail.timeout must be positive")
+	}
+	if c.Classifier.KeywordWeight < 0 || c.Classifier.Threshold < 0 {
+		return fmt.Errorf("classifier.keyword_weight and classifier.threshold cannot be negative")
+	}
+	if c.Health.Timeout <= 0 {
+		return fmt.Errorf("health.timeout must be positive")
+	}
+	if c.Health.CacheTTL < 0 {
+		return fmt.Errorf("health.cache_ttl cannot be negative")
+	}
+	if !tracing.ValidExporter(c.Tracing.Exporter) {
+		return fmt.Errorf("tracing.exporter must be %q, %q or %q, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+	if c.Admin.Enabled {
+		if c.Admin.Port == "" {
+			return fmt.Errorf("admin.port is required when admin is enabled")
+		}
+		if c.Admin.Port == c.Server.Port {
+			return fmt.Errorf("admin.port must differ from server.port")
+		}
+	}
+	if c.Push.Enabled {
+		if c.Push.StateFile == "" {
+			return fmt.Errorf("push.state_file is required when push is enabled")
+		}
+		if c.Push.VerifyJWT && c.Push.Audience == "" {
+			return fmt.Errorf("push.audience is required when push.verify_jwt is enabled")
+		}
+		if c.Push.VerifyJWT && c.Push.JWKSURL == "" && len(c.Push.KeyFiles) == 0 {
+			return fmt.Errorf("push.jwks_url or push.key_files is required when push.verify_jwt is enabled")
+		}
+		// Gmail drops a watch after 7 days; renewing later than that loses pushes.
+		if c.Push.RenewBefore <= 0 || c.Push.RenewBefore >= 7*24*time.Hour {
+			return fmt.Errorf("push.renew_before must be between 0 and 168h")
+		}
+		if c.Push.RenewInterval <= 0 {
+			return fmt.Errorf("push.renew_interval must be positive")
+		}
+	}
+	return nil
+}
+
+// SecretFields are the settings that may hold secret references, keyed by
+// their config path.
+func (c *Config) SecretFields() map[string]string {
+	return map[string]string{
+		"auth.access_token":     c.Auth.AccessToken,
+		"imap.password":         c.IMAP.Password,
+		"graph.client_secret":   c.Graph.ClientSecret,
+		"aws.access_key_id":     c.AWS.AccessKeyID,
+		"aws.secret_access_key": c.AWS.SecretAccessKey,
+		"aws.session_token":     c.AWS.SessionToken,
+	}
+}
+
+func (c *Config) validateSecrets() error {
+	for key, value := range c.SecretFields() {
+		if err := secrets.ValidateReference(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if strings.HasPrefix(value, secrets.PrefixStore) && c.Secrets.File == "" {
+			return fmt.Errorf("%s refers to the secrets store but secrets.file is not set", key)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateStorage() error {
+	if c.AWS.Region == "" {
+		return fmt.Errorf("aws.region is required")
+	}
+	switch c.AWS.Credentials {
+	case awsconfig.CredentialsDefault:
+	case awsconfig.CredentialsStatic:
+		if c.AWS.AccessKeyID == "" || c.AWS.SecretAccessKey == "" {
+			return fmt.Errorf("aws.access_key_id and aws.secret_access_key are required for static credentials")
+		}
+	default:
+		return fmt.Errorf("aws.credentials must be %q or %q, got %q", awsconfig.CredentialsDefault, awsconfig.CredentialsStatic, c.AWS.Credentials)
+	}
+	if c.S3.Bucket == "" {
+		return fmt.Errorf("s3.bucket is required")
+	}
+	if strings.HasPrefix(c.S3.Prefix, "/") {
+		return fmt.Errorf("s3.prefix must not start with /")
+	}
+	switch c.S3.Layout {
+	case s3bucket.LayoutMessage:
+		if err := s3bucket.ValidateKeyTemplate(c.S3.KeyTemplate); err != nil {
+			return fmt.Errorf("s3.key_template: %w", err)
+		}
+	case s3bucket.LayoutBatch:
+	default:
+		return fmt.Errorf("s3.layout must be %q or %q, got %q", s3bucket.LayoutMessage, s3bucket.LayoutBatch, c.S3.Layout)
+	}
+	switch c.S3.Format {
+	case s3bucket.FormatJSON, s3bucket.FormatNDJSON, s3bucket.FormatParquet:
+	default:
+		return fmt.Errorf("s3.format must be %q, %q or %q, got %q", s3bucket.FormatJSON, s3bucket.FormatNDJSON, s3bucket.FormatParquet, c.S3.Format)
+	}
+	switch c.S3.Compression {
+	case s3bucket.CompressionNone, s3bucket.CompressionGzip, s3bucket.CompressionZstd:
+	default:
+		return fmt.Errorf("s3.compression must be %q, %q or %q, got %q", s3bucket.CompressionNone, s3bucket.CompressionGzip, s3bucket.CompressionZstd, c.S3.Compression)
+	}
+	if c.DynamoDB.HeadersTable == "" {
+		return fmt.Errorf("dynamodb.headers_table is required")
+	}
+	return nil
+}
+
+func (c IMAPConfig) validate(accessToken string) error {
+	if c.Host == "" {
+		return fmt.Errorf("imap.host is required")
+	}
+	if c.Username == "" {
+		return fmt.Errorf("imap.username is required")
+	}
+	switch c.Security {
+	case "tls", "starttls", "none":
+	default:
+		return fmt.Errorf("imap.security must be tls, starttls or none")
+	}
+	switch c.Auth {
+	case "login":
+		if c.Password == "" {
+			return fmt.Errorf("imap.password is required for login auth")
+		}
+	case "xoauth2":
+		if accessToken == "" {
+			return fmt.Errorf("auth.access_token is required for xoauth2 auth")
+		}
+	default:
+		return fmt.Errorf("imap.auth must be login or xoauth2")
+	}
+	return nil
+}
+
+func (c GraphConfig) validate() error {
+	if c.TenantID == "" && c.TokenURL == "" {
+		return fmt.Errorf("graph.tenant_id is required")
+	}
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return fmt.Errorf("graph.client_id and graph.client_secret are required")
+	}
+	if c.User == "" {
+		return fmt.Errorf("graph.user is required")
 	}
 	return nil
 }
@@ -112,3 +832,21 @@ func (c *Config) Validate() error {
 func (c *Config) GetAccessToken() string {
 	return c.Auth.AccessToken
 }
+
+// MailboxRules converts the configured post-processing rules to domain rules.
+func (c *Config) MailboxRules() []entities.MailboxRule {
+	rules := make([]entities.MailboxRule, 0, len(c.PostProcessing.Rules))
+	for _, rule := range c.PostProcessing.Rules {
+		rules = append(rules, entities.MailboxRule{
+			Name:          rule.Name,
+			Category:      rule.Category,
+			MinConfidence: rule.MinConfidence,
+			AddLabels:     rule.AddLabels,
+			RemoveLabels:  rule.RemoveLabels,
+			Archive:       rule.Archive,
+			MarkRead:      rule.MarkRead,
+			Trash:         rule.Trash,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// load reads yaml as the config file, without validating it.
+func load(t *testing.T, yaml string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	SetFile(path)
+	t.Cleanup(func() {
+		SetFile("")
+		viper.Reset()
+	})
+	config, err := LoadUnvalidated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestLoadRulesReplaceDefaults(t *testing.T) {
+	config := load(t, `
+post_processing:
+  dry_run: false
+  rules:
+    - name: label-news
+      category: newsletters
+      add_labels: [news]
+`)
+	rules := config.PostProcessing.Rules
+	if len(rules) != 1 {
+		t.Fatalf("rules = %+v", rules)
+	}
+	if rule := rules[0]; rule.Name != "label-news" || rule.Archive || rule.MinConfidence != 0 {
+		t.Errorf("rule inherited defaults: %+v", rule)
+	}
+}
+
+func TestLoadDefaultRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		rules int
+	}{
+		{name: "unset", yaml: "post_processing:\n  dry_run: true\n", rules: 1},
+		{name: "empty", yaml: "post_processing:\n  rules: []\n", rules: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := load(t, tt.yaml)
+			if got := len(config.PostProcessing.Rules); got != tt.rules {
+				t.Errorf("%d rules, want %d", got, tt.rules)
+			}
+		})
+	}
+}
+
+func TestLoadStaticKeysWithoutSessionToken(t *testing.T) {
+	config := load(t, `
+aws:
+  endpoint: ""
+  access_key_id: AKIAEXAMPLE
+  secret_access_key: example-secret
+`)
+	if config.AWS.SessionToken != "" {
+		t.Errorf("session token = %q, want none", config.AWS.SessionToken)
+	}
+	if sdk := config.AWS.SDK(); sdk.AccessKeyID != "AKIAEXAMPLE" || sdk.SessionToken != "" {
+		t.Errorf("SDK config = %+v", sdk)
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadDebounce coalesces the burst of events editors produce on save
+// (truncate, write, rename) into one reload.
+const reloadDebounce = 100 * time.Millisecond
+
+var reloadMu sync.Mutex
+
+// Reload reads the config file picked by Load again. Load must have been
+// called first. A file that fails validation returns an error wrapping
+// entities.ErrInvalidConfig.
+func Reload() (*Config, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	config, err := read()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Watch calls onChange with the result of Reload whenever the content of
+// the config file changes, until stop is called. It does nothing when no
+// config file was found.
+func Watch(onChange func(*Config, error)) (stop func(), err error) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return func() {}, nil
+	}
+	file, err = filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config file: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	// Watch the directory rather than the file: editors and config maps
+	// replace the file, which ends a watch on the file itself. A Kubernetes
+	// config map update only swaps the ..data symlink, so any event in the
+	// directory re-reads the file through its symlinks and reloads when the
+	// content differs.
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", file, err)
+	}
+
+	var mu sync.Mutex
+	last, _ := fileDigest(file)
+	reload := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		digest, err := fileDigest(file)
+		if err != nil || digest == last {
+			// Missing mid-swap or unchanged; a later event retries.
+			return
+		}
+		last = digest
+		onChange(Reload())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case <-done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDebounce, reload)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onChange(nil, fmt.Errorf("config watcher: %w", err))
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			watcher.Close()
+		})
+	}, nil
+}
+
+// fileDigest hashes the file path currently resolves to.
+func fileDigest(path string) ([sha256.Size]byte, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// Changes lists the settings that differ between c and next as dotted keys,
+// e.g. "server.port". Lists and maps are compared as a whole.
+func (c *Config) Changes(next *Config) []string {
+	nextFields := next.fields()
+	var keys []string
+	for i, field := range c.fields() {
+		if !reflect.DeepEqual(field.value, nextFields[i].value) {
+			keys = append(keys, field.key)
+		}
+	}
+	return keys
+}
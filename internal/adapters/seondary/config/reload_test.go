@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func validYAML(port string) string {
+	return "auth:\n  access_token: abc\nserver:\n  port: \"" + port + "\"\n"
+}
+
+// watch loads path and watches it, sending each reload to the channel.
+func watch(t *testing.T, path string) chan *Config {
+	t.Helper()
+	SetFile(path)
+	t.Cleanup(func() {
+		SetFile("")
+		viper.Reset()
+	})
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan *Config, 4)
+	stop, err := Watch(func(next *Config, err error) {
+		if err != nil {
+			t.Errorf("reload: %v", err)
+			return
+		}
+		reloads <- next
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+	return reloads
+}
+
+func expectReload(t *testing.T, reloads chan *Config, port string) {
+	t.Helper()
+	select {
+	case next := <-reloads:
+		if next.Server.Port != port {
+			t.Errorf("reloaded port = %s, want %s", next.Server.Port, port)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload")
+	}
+}
+
+func expectNoReload(t *testing.T, reloads chan *Config) {
+	t.Helper()
+	select {
+	case next := <-reloads:
+		t.Errorf("unexpected reload with port %s", next.Server.Port)
+	case <-time.After(5 * reloadDebounce):
+	}
+}
+
+func TestWatchReloadsOnWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, validYAML("8080"))
+	reloads := watch(t, path)
+
+	writeConfig(t, path, validYAML("8081"))
+	expectReload(t, reloads, "8081")
+
+	// Other files in the directory and rewrites with the same content
+	// don't reload.
+	writeConfig(t, filepath.Join(filepath.Dir(path), "other.yaml"), "x: 1\n")
+	writeConfig(t, path, validYAML("8081"))
+	expectNoReload(t, reloads)
+}
+
+// TestWatchReloadsConfigMapUpdate lays the directory out like a mounted
+// Kubernetes config map and updates it the way the kubelet does: only the
+// ..data symlink changes, config.yaml itself sees no event.
+func TestWatchReloadsConfigMapUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, "..2025_01_01", "config.yaml"), validYAML("8080"))
+	symlink(t, "..2025_01_01", filepath.Join(dir, "..data"))
+	symlink(t, filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml"))
+	reloads := watch(t, filepath.Join(dir, "config.yaml"))
+
+	writeConfig(t, filepath.Join(dir, "..2025_01_02", "config.yaml"), validYAML("8081"))
+	symlink(t, "..2025_01_02", filepath.Join(dir, "..data_tmp"))
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "..2025_01_01")); err != nil {
+		t.Fatal(err)
+	}
+	expectReload(t, reloads, "8081")
+}
+
+func TestChanges(t *testing.T) {
+	current := DefaultConfig()
+	next := DefaultConfig()
+	next.Server.Port = "9000"
+	next.PostProcessing.Rules = []MailboxRuleConfig{{Name: "label-news"}}
+	next.App.LogLevel = "debug"
+
+	want := []string{"app.log_level", "server.port", "post_processing.rules"}
+	if got := current.Changes(&next); !slices.Equal(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	if got := current.Changes(&current); len(got) != 0 {
+		t.Errorf("changes against itself = %v", got)
+	}
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"email-parser-poc/internal/adapters/seondary/secrets"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Starter is the commented configuration written by `config init`.
+//
+//go:embed starter.yaml
+var Starter []byte
+
+// Origins of a setting, from lowest to highest precedence.
+const (
+	OriginDefault = "default"
+	OriginFile    = "file"
+	// OriginDotEnv is a variable from configs/.env; OriginEnv one that was
+	// already in the environment, which .env does not override.
+	OriginDotEnv = ".env"
+	OriginEnv    = "env"
+)
+
+// Setting is one effective value and where it came from. Secrets are masked
+// unless the value is a reference such as env:NAME.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+}
+
+// envAliases are variables bound to a key besides its PRIVCY_ name.
+var envAliases = map[string]string{
+	"auth.access_token": "ACCESS_TOKEN",
+}
+
+// dotEnvVars are the variables that Load took from configs/.env.
+var dotEnvVars = map[string]bool{}
+
+type field struct {
+	key   string
+	value any
+}
+
+// fields flattens c into its leaf settings in declaration order. Lists and
+// maps are leaves.
+func (c *Config) fields() []field {
+	var fields []field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			key := v.Type().Field(i).Tag.Get("mapstructure")
+			if key == "" {
+				key = v.Type().Field(i).Name
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if v.Field(i).Kind() == reflect.Struct {
+				walk(key, v.Field(i))
+				continue
+			}
+			fields = append(fields, field{key: key, value: v.Field(i).Interface()})
+		}
+	}
+	walk("", reflect.ValueOf(*c))
+	return fields
+}
+
+// bindEnv makes every setting overridable by its PRIVCY_ variable. viper
+// only consults the environment for keys it knows about, which without this
+// are just the keys present in the config file.
+func bindEnv() {
+	defaults := DefaultConfig()
+	for _, field := range defaults.fields() {
+		if alias, ok := envAliases[field.key]; ok {
+			viper.BindEnv(field.key, envName(field.key), alias)
+			continue
+		}
+		viper.BindEnv(field.key)
+	}
+}
+
+func envName(key string) string {
+	return "PRIVCY_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Settings lists the effective configuration with the origin of each value.
+// It reflects the sources read by the last Load or Reload.
+func (c *Config) Settings() []Setting {
+	secretKeys := c.SecretFields()
+	fields := c.fields()
+	settings := make([]Setting, 0, len(fields))
+	for _, field := range fields {
+		value := formatValue(field.value)
+		if _, ok := secretKeys[field.key]; ok && value != "" && !secrets.IsReference(value) {
+			value = "****"
+		}
+		settings = append(settings, Setting{Key: field.key, Value: value, Origin: origin(field.key)})
+	}
+	return settings
+}
+
+func origin(key string) string {
+	names := []string{envName(key)}
+	if alias, ok := envAliases[key]; ok {
+		names = append(names, alias)
+	}
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			if dotEnvVars[name] {
+				return OriginDotEnv
+			}
+			return OriginEnv
+		}
+	}
+	if viper.InConfig(key) {
+		return OriginFile
+	}
+	return OriginDefault
+}
+
+func formatValue(value any) string {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Slice, reflect.Map:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
@@ -4,42 +4,56 @@ import (
 	"context"
 	"email-parser-poc/internal/domain/entities"
 	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("email-parser-poc/internal/adapters/seondary/dynamodb")
+
+type Config struct {
+	AWS          aws.Config
+	HeadersTable string
+	// Endpoint overrides the DynamoDB endpoint from the AWS config.
+	Endpoint string
+	Logger   *slog.Logger
+	Metrics  *metrics.Metrics
+}
+
 type DB struct {
 	DynamoClient *dynamodb.Client
+	HeadersTable string
+	Logger       *slog.Logger
+	Metrics      *metrics.Metrics
 }
 
-func NewDynamoDbInstance(localstackEndpoint string) (outgoing.DbService, error) {
-	// Load config with LocalStack endpoint and static credentials
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider("test-key", "test-secret", "test-session"),
-		),
-		config.WithRegion("us-east-1"),
-		config.WithEndpointResolver(
-			aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-				return aws.Endpoint{
-					URL:           localstackEndpoint,
-					SigningRegion: "us-east-1",
-				}, nil
-			}),
-		),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+func NewDynamoDbInstance(config Config) (outgoing.DbService, error) {
+	if config.HeadersTable == "" {
+		return nil, fmt.Errorf("dynamodb headers table name is required")
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
-	return &DB{DynamoClient: client}, nil
+	client := dynamodb.NewFromConfig(config.AWS, func(o *dynamodb.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
+	})
+	return &DB{
+		DynamoClient: client,
+		HeadersTable: config.HeadersTable,
+		Logger:       logging.OrDefault(config.Logger),
+		Metrics:      config.Metrics,
+	}, nil
 }
 
 func (d *DB) UploadHeaders(ctx context.Context, emails *entities.EmailList) error {
@@ -73,17 +87,49 @@ func (d *DB) UploadHeaders(ctx context.Context, emails *entities.EmailList) erro
 			}
 			batch := writeRequests[i:end]
 
-			_, err := d.DynamoClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
-				RequestItems: map[string][]types.WriteRequest{
-					"gmail-headers": batch,
-				},
-			})
+			unprocessed, err := d.batchWrite(ctx, batch)
+			d.Metrics.ObserveDynamoBatchWrite(unprocessed, err)
 			if err != nil {
 				return fmt.Errorf("failed to batch write headers: %w", err)
 			}
+			if unprocessed > 0 {
+				d.Logger.WarnContext(ctx, "dynamodb left headers unprocessed", "message_id", email.ID, "unprocessed", unprocessed)
+			}
 		}
 
-		fmt.Printf("✅ Uploaded %d headers for email %s\n", len(email.Headers), email.ID)
+		d.Logger.DebugContext(ctx, "uploaded headers", "message_id", email.ID, "count", len(email.Headers))
 	}
 	return nil
 }
+
+// batchWrite sends one BatchWriteItem call in its own span and returns how
+// many items DynamoDB left unprocessed.
+func (d *DB) batchWrite(ctx context.Context, batch []types.WriteRequest) (int, error) {
+	ctx, span := tracer.Start(ctx, "DynamoDB.BatchWriteItem",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemDynamoDB,
+			semconv.AWSDynamoDBTableNames(d.HeadersTable),
+			attribute.Int("aws.dynamodb.items", len(batch)),
+		),
+	)
+	defer span.End()
+
+	output, err := d.DynamoClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{
+			d.HeadersTable: batch,
+		},
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	unprocessed := 0
+	for _, requests := range output.UnprocessedItems {
+		unprocessed += len(requests)
+	}
+	span.SetAttributes(attribute.Int("aws.dynamodb.unprocessed_items", unprocessed))
+	return unprocessed, nil
+}
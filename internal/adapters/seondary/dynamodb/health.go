@@ -0,0 +1,41 @@
+package dynamodb
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var _ outgoing.HealthChecker = (*DB)(nil)
+
+func (d *DB) Name() string {
+	return "dynamodb"
+}
+
+func (d *DB) Critical() bool {
+	return true
+}
+
+// Check requires the headers table to be ACTIVE. A table that is being
+// updated still takes writes, so that only degrades the service.
+func (d *DB) Check(ctx context.Context) error {
+	output, err := d.DynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.HeadersTable)})
+	if err != nil {
+		return fmt.Errorf("failed to describe table %s: %w", d.HeadersTable, err)
+	}
+
+	status := output.Table.TableStatus
+	switch status {
+	case types.TableStatusActive:
+		return nil
+	case types.TableStatusUpdating:
+		return fmt.Errorf("table %s is %s: %w", d.HeadersTable, status, entities.ErrDegraded)
+	default:
+		return fmt.Errorf("table %s is %s", d.HeadersTable, status)
+	}
+}
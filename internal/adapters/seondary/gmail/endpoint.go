@@ -0,0 +1,53 @@
+package gmail
+
+import (
+	"net/http"
+	"strings"
+)
+
+// endpointName maps a Gmail API request to the method name used in Google's
+// API reference, keeping message and label IDs out of metric labels.
+func endpointName(req *http.Request) string {
+	_, path, found := strings.Cut(req.URL.Path, "/gmail/v1/users/")
+	if !found {
+		return "other"
+	}
+	segments := strings.Split(path, "/")[1:]
+	if len(segments) == 0 {
+		return "other"
+	}
+
+	switch segments[0] {
+	case "profile":
+		return "getProfile"
+	case "watch", "stop":
+		return segments[0]
+	case "history":
+		return "history.list"
+	case "labels":
+		if len(segments) == 1 {
+			if req.Method == http.MethodPost {
+				return "labels.create"
+			}
+			return "labels.list"
+		}
+		return "labels.get"
+	case "threads":
+		if len(segments) == 1 {
+			return "threads.list"
+		}
+		return "threads.get"
+	case "messages":
+		switch {
+		case len(segments) == 1:
+			return "messages.list"
+		case len(segments) == 2:
+			return "messages.get"
+		case segments[2] == "attachments":
+			return "messages.attachments.get"
+		default:
+			return "messages." + segments[2]
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,77 @@
+package gmail
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/gmail/gmailfake"
+	"email-parser-poc/internal/adapters/seondary/token"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEndpointName(t *testing.T) {
+	const users = "https://gmail.googleapis.com/gmail/v1/users/"
+	tests := []struct {
+		method string
+		url    string
+		want   string
+	}{
+		{http.MethodGet, users + "me/profile", "getProfile"},
+		{http.MethodPost, users + "me/watch", "watch"},
+		{http.MethodPost, users + "me/stop", "stop"},
+		{http.MethodGet, users + "me/history?startHistoryId=1", "history.list"},
+		{http.MethodGet, users + "me/labels", "labels.list"},
+		{http.MethodPost, users + "me/labels", "labels.create"},
+		{http.MethodGet, users + "me/labels/Label_1", "labels.get"},
+		{http.MethodGet, users + "me/threads", "threads.list"},
+		{http.MethodGet, users + "me/threads/18f0a", "threads.get"},
+		{http.MethodGet, users + "me/messages?maxResults=10", "messages.list"},
+		{http.MethodGet, users + "alice%40example.com/messages/18f0a?format=full", "messages.get"},
+		{http.MethodPost, users + "me/messages/18f0a/modify", "messages.modify"},
+		{http.MethodPost, users + "me/messages/18f0a/trash", "messages.trash"},
+		{http.MethodGet, users + "me/messages/18f0a/attachments/ANGjdJ-1", "messages.attachments.get"},
+		{http.MethodGet, users + "me/drafts", "other"},
+		{http.MethodGet, users + "me", "other"},
+		{http.MethodGet, "https://oauth2.googleapis.com/token", "other"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		if got := endpointName(req); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRepositoryRecordsEndpointMetrics(t *testing.T) {
+	server := gmailfake.NewServer()
+	t.Cleanup(server.Close)
+	m := metrics.New()
+	repo := newGmailRepository(Config{
+		BaseURL:       server.BaseURL(),
+		TokenProvider: token.NewStaticTokenProvider(server.Token),
+		Metrics:       m,
+	})
+	msg := server.AddMessage(gmailfake.Message{Raw: promotion(1)})
+
+	if _, err := repo.FetchEmails(context.Background(), entities.EmailFilter{MaxResults: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`email_parser_gmail_api_requests_total{endpoint="messages.list",status="200"} 1`,
+		`email_parser_gmail_api_requests_total{endpoint="messages.get",status="200"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+	if strings.Contains(body, msg.ID) {
+		t.Error("message ID leaked into a metric label")
+	}
+}
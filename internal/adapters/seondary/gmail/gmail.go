@@ -2,65 +2,142 @@ package gmail
 
 import (
 	"context"
+	"email-parser-poc/internal/domain/classifier"
 	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
 	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+const DefaultBaseURL = "https://gmail.googleapis.com"
+
+type Config struct {
+	// BaseURL defaults to DefaultBaseURL; tests point it at a local fake.
+	BaseURL string
+	// UserID is the mailbox to read, "me" (the token's user) by default.
+	UserID        string
+	TokenProvider outgoing.TokenProvider
+	HTTPClient    *http.Client
+	Logger        *slog.Logger
+	// Metrics, when set, records every API call by endpoint and status.
+	// Calls are also traced as client spans named after the endpoint.
+	Metrics *metrics.Metrics
+}
+
 type gmailRepository struct {
+	baseURL       string
+	userID        string
 	client        *http.Client
 	tokenProvider outgoing.TokenProvider
+	logger        *slog.Logger
+
+	labelsMu sync.Mutex
+	labels   map[string]string
 }
 
-func NewGmailRepository(tokenProvider outgoing.TokenProvider) outgoing.EmailRepository {
+func NewGmailRepository(config Config) outgoing.EmailRepository {
+	return newGmailRepository(config)
+}
 
+func newGmailRepository(config Config) *gmailRepository {
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	userID := config.UserID
+	if userID == "" {
+		userID = "me"
+	}
+	instrumented := *client
+	instrumented.Transport = config.Metrics.Transport(otelhttp.NewTransport(client.Transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "gmail " + endpointName(req)
+		}),
+	), endpointName)
 	return &gmailRepository{
-		client:        &http.Client{},
-		tokenProvider: tokenProvider,
+		baseURL:       baseURL,
+		userID:        userID,
+		client:        &instrumented,
+		tokenProvider: config.TokenProvider,
+		logger:        logging.OrDefault(config.Logger),
 	}
 }
 
+// FetchEmails lists up to filter.MaxResults promotional messages. With
+// ExpandThreads each one is returned with its whole thread, which is never
+// cut short, so the result can exceed MaxResults.
 func (r *gmailRepository) FetchEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, error) {
 	var allEmails []entities.EmailMessage
+	failed := 0
 	pageToken := filter.PageToken
 	filter.OnlyPromotional = true
 
+	seenThreads := make(map[string]bool)
+
 	for {
-		messageIDs, nextPageToken, err := r.getMessageIDs(ctx, filter.MaxResults, pageToken)
+		messageRefs, nextPageToken, err := r.getMessageIDs(ctx, filter.MaxResults, filter.Query, pageToken)
 		if err != nil {
 			return nil, err
 		}
 
-		fmt.Printf("✓ Got %d message IDs from Gmail API\n", len(messageIDs)) // DEBUG
+		r.logger.DebugContext(ctx, "listed gmail messages", "count", len(messageRefs))
 
-		for _, msgID := range messageIDs {
+		for _, ref := range messageRefs {
 			if len(allEmails) >= filter.MaxResults {
 				break
 			}
 
-			fmt.Printf("Processing email ID: %s\n", msgID) // DEBUG
+			if filter.ExpandThreads {
+				if seenThreads[ref.ThreadID] {
+					continue
+				}
+				seenThreads[ref.ThreadID] = true
+
+				thread, err := r.FetchThread(ctx, ref.ThreadID)
+				if err != nil {
+					r.logger.WarnContext(ctx, "failed to get thread", "thread_id", ref.ThreadID, "error", err)
+					failed++
+					continue
+				}
+				if filter.OnlyPromotional && !anyPromotional(thread) {
+					continue
+				}
+				allEmails = append(allEmails, thread...)
+				continue
+			}
 
+			msgID := ref.ID
 			email, err := r.getEmailContent(ctx, msgID)
 			if err != nil {
-				fmt.Printf("❌ Failed to get email %s: %v\n", msgID, err)
+				r.logger.WarnContext(ctx, "failed to get email", "message_id", msgID, "error", err)
+				failed++
 				continue
 			}
 			if filter.OnlyPromotional && !email.IsPromotional {
 				continue // Skip non-promotional emails
 			}
 
-			fmt.Printf("✓ Successfully processed email: %s\n", email.Subject) // DEBUG
+			r.logger.DebugContext(ctx, "processed email", "message_id", msgID)
 			allEmails = append(allEmails, *email)
 		}
 
-		fmt.Printf("Total emails processed: %d\n", len(allEmails)) // DEBUG
+		r.logger.DebugContext(ctx, "processed gmail page", "total", len(allEmails))
 
 		if nextPageToken == "" || len(allEmails) >= filter.MaxResults {
 			break
@@ -73,159 +150,99 @@ func (r *gmailRepository) FetchEmails(ctx context.Context, filter entities.Email
 		Emails:        allEmails,
 		NextPageToken: "",
 		TotalCount:    len(allEmails),
+		Failed:        failed,
 	}, nil
 }
 
-func (r *gmailRepository) getMessageIDs(ctx context.Context, maxResults int, pageToken string) ([]string, string, error) {
-	apiURL := "https://gmail.googleapis.com/gmail/v1/users/me/messages"
-	params := url.Values{}
-	params.Add("maxResults", fmt.Sprintf("%d", min(maxResults, 500)))
+// FetchThread returns every message of a Gmail thread in the order the API
+// lists them.
+func (r *gmailRepository) FetchThread(ctx context.Context, threadID string) ([]entities.EmailMessage, error) {
+	apiURL := r.userURL() + "/threads/" + url.PathEscape(threadID) + "?format=full"
 
-	if pageToken != "" {
-		params.Add("pageToken", pageToken)
+	var thread GmailThread
+	if err := r.doJSON(ctx, http.MethodGet, apiURL, nil, &thread); err != nil {
+		return nil, fmt.Errorf("failed to get thread %s: %w", threadID, err)
 	}
 
-	fullURL := apiURL + "?" + params.Encode()
-
-	fmt.Printf("🔍 Making request to: %s\n", fullURL) // DEBUG
-
-	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	emails := make([]entities.EmailMessage, 0, len(thread.Messages))
+	for _, gmailMsg := range thread.Messages {
+		emails = append(emails, r.parseGmailMessage(gmailMsg))
 	}
+	return emails, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+r.tokenProvider.GetAccessToken())
-
-	resp, err := r.client.Do(req)
-	if err != nil {
-		fmt.Printf("❌ Request failed: %v\n", err) // DEBUG
-		return nil, "", fmt.Errorf("failed to get message list: %w", err)
+func anyPromotional(emails []entities.EmailMessage) bool {
+	for _, email := range emails {
+		if email.IsPromotional {
+			return true
+		}
 	}
-	defer resp.Body.Close()
-
-	fmt.Printf("📡 Response status: %s\n", resp.Status) // DEBUG
+	return false
+}
 
-	// Read the full response body for debugging
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to read response body: %w", err)
+func (r *gmailRepository) getMessageIDs(ctx context.Context, maxResults int, query, pageToken string) ([]MessageRef, string, error) {
+	params := url.Values{}
+	params.Add("maxResults", fmt.Sprintf("%d", min(maxResults, 500)))
+	if query != "" {
+		params.Add("q", query)
 	}
-
-	fmt.Printf("📄 Response body: %s\n", string(bodyBytes)) // DEBUG
-
-	// Check for HTTP errors
-	if resp.StatusCode != 200 {
-		return nil, "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if pageToken != "" {
+		params.Add("pageToken", pageToken)
 	}
 
 	var listResp MessagesListResponse
-	if err := json.Unmarshal(bodyBytes, &listResp); err != nil {
-		return nil, "", fmt.Errorf("failed to decode message list: %w", err)
-	}
-
-	var messageIDs []string
-	for _, msg := range listResp.Messages {
-		messageIDs = append(messageIDs, msg.ID)
+	if err := r.doJSON(ctx, http.MethodGet, r.userURL()+"/messages?"+params.Encode(), nil, &listResp); err != nil {
+		return nil, "", fmt.Errorf("failed to get message list: %w", err)
 	}
-
-	return messageIDs, listResp.NextPageToken, nil
+	return listResp.Messages, listResp.NextPageToken, nil
 }
-func (r *gmailRepository) getEmailContent(ctx context.Context, messageID string) (*entities.EmailMessage, error) {
-	apiURL := fmt.Sprintf("https://gmail.googleapis.com/gmail/v1/users/me/messages/%s?format=full", messageID)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
 
-	req.Header.Set("Authorization", "Bearer "+r.tokenProvider.GetAccessToken())
-
-	resp, err := r.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get message: %w", err)
-	}
-	defer resp.Body.Close()
+func (r *gmailRepository) getEmailContent(ctx context.Context, messageID string) (*entities.EmailMessage, error) {
+	apiURL := r.userURL() + "/messages/" + url.PathEscape(messageID) + "?format=full"
 
 	var gmailMsg GmailMessage
-	if err := json.NewDecoder(resp.Body).Decode(&gmailMsg); err != nil {
-		return nil, fmt.Errorf("failed to decode message: %w", err)
+	if err := r.doJSON(ctx, http.MethodGet, apiURL, nil, &gmailMsg); err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 
 	email := r.parseGmailMessage(gmailMsg)
 	return &email, nil
 }
 
-func (r *gmailRepository) parseGmailMessage(gmailMsg GmailMessage) entities.EmailMessage {
-	email := entities.EmailMessage{
-		ID:      gmailMsg.ID,
-		Headers: make(map[string]string),
-	}
-
-	for _, header := range gmailMsg.Payload.Headers {
-		email.Headers[header.Name] = header.Value
-
-		switch strings.ToLower(header.Name) {
-		case "subject":
-			email.Subject = header.Value
-		case "from":
-			email.From = header.Value
-		case "to":
-			email.To = header.Value
-		case "date":
-			email.Date = r.parseDate(header.Value)
-		}
-	}
-
-	email.Body = r.extractBody(gmailMsg.Payload)
-	email.IsPromotional = r.classifyAsPromotional(&email)
-	return email
+// userURL is the API root for the configured mailbox.
+func (r *gmailRepository) userURL() string {
+	return r.baseURL + "/gmail/v1/users/" + url.PathEscape(r.userID)
 }
 
-func (r *gmailRepository) parseDate(dateStr string) time.Time {
-	layouts := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-		time.RFC3339,
+func (r *gmailRepository) parseGmailMessage(gmailMsg GmailMessage) entities.EmailMessage {
+	headers := make([]parser.Header, 0, len(gmailMsg.Payload.Headers))
+	for _, header := range gmailMsg.Payload.Headers {
+		headers = append(headers, parser.Header{Name: header.Name, Value: header.Value})
 	}
 
-	for _, layout := range layouts {
-		if t, err := time.Parse(layout, dateStr); err == nil {
-			return t
-		}
-	}
+	body := r.extractBody(gmailMsg.Payload)
+	email := parser.NewMessage(gmailMsg.ID, headers, body, r.parseInternalDate(gmailMsg.InternalDate))
+	email.ThreadID = gmailMsg.ThreadID
+	email.Labels = gmailMsg.LabelIDs
 
-	return time.Now()
+	classifier.Default().Apply(&email)
+	return email
 }
 
-func (r *gmailRepository) classifyAsPromotional(email *entities.EmailMessage) bool {
-	promotionalKeywords := []string{
-		"sale", "discount", "off", "deal", "offer", "save", "free",
-		"limited time", "expires", "ending soon", "last chance",
-		"25%", "50%", "percent", "promo", "coupon", "unsubscribe",
-		"marketing email", "promotional", "newsletter",
-	}
-	content := strings.ToLower(email.Subject + " " + email.Body + " " + email.From)
-
-	score := 0
-
-	for _, keyword := range promotionalKeywords {
-		if strings.Contains(content, keyword) {
-			score++
-		}
-	}
-
-	if _, exits := email.Headers["List-Unsubscribe"]; exits {
-		score += 3
+// parseInternalDate converts Gmail's internalDate, milliseconds since the
+// epoch as a string, into the time Gmail received the message.
+func (r *gmailRepository) parseInternalDate(internalDate string) time.Time {
+	ms, err := strconv.ParseInt(internalDate, 10, 64)
+	if err != nil {
+		return time.Time{}
 	}
-
-	return score >= 2
+	return time.UnixMilli(ms).UTC()
 }
 
 func (r *gmailRepository) extractBody(payload Payload) string {
 	if payload.Body.Data != "" {
 		if decoded, err := r.decodeBase64URL(payload.Body.Data); err == nil {
-			return decoded
+			return parser.DecodeBody([]byte(decoded), contentType(payload.Headers))
 		}
 	}
 
@@ -233,7 +250,7 @@ func (r *gmailRepository) extractBody(payload Payload) string {
 		if part.MimeType == "text/plain" || part.MimeType == "text/html" {
 			if part.Body.Data != "" {
 				if decoded, err := r.decodeBase64URL(part.Body.Data); err == nil {
-					return decoded
+					return parser.DecodeBody([]byte(decoded), contentType(part.Headers))
 				}
 			}
 		}
@@ -249,6 +266,15 @@ func (r *gmailRepository) extractBody(payload Payload) string {
 	return ""
 }
 
+func contentType(headers []Header) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, "Content-Type") {
+			return header.Value
+		}
+	}
+	return ""
+}
+
 func (r *gmailRepository) decodeBase64URL(data string) (string, error) {
 	data = strings.ReplaceAll(data, "-", "+")
 	data = strings.ReplaceAll(data, "_", "/")
@@ -311,6 +337,12 @@ type MessageRef struct {
 	ThreadID string `json:"threadId"`
 }
 
+type GmailThread struct {
+	ID        string         `json:"id"`
+	HistoryID string         `json:"historyId"`
+	Messages  []GmailMessage `json:"messages"`
+}
+
 type MessagesListResponse struct {
 	Messages      []MessageRef `json:"messages"`
 	NextPageToken string       `json:"nextPageToken"`
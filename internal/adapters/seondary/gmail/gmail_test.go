@@ -0,0 +1,353 @@
+package gmail
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/gmail/gmailfake"
+	"email-parser-poc/internal/adapters/seondary/token"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const messagesPath = "/gmail/v1/users/me/messages"
+
+func promotion(n int) []byte {
+	return []byte(fmt.Sprintf("From: Shop <deals@shop.example>\r\n"+
+		"To: me@example.com\r\n"+
+		"Subject: Spring sale %d\r\n"+
+		"Date: Mon, 3 Mar 2025 10:%02d:00 +0000\r\n"+
+		"Message-ID: <promo-%d@shop.example>\r\n"+
+		"List-Unsubscribe: <mailto:unsubscribe@shop.example>\r\n"+
+		"\r\n"+
+		"Our biggest discount yet.\r\n", n, n, n))
+}
+
+func personal(n int, inReplyTo string) []byte {
+	reply := ""
+	if inReplyTo != "" {
+		reply = "In-Reply-To: " + inReplyTo + "\r\n"
+	}
+	return []byte(fmt.Sprintf("From: Alice <alice@example.org>\r\n"+
+		"To: me@example.com\r\n"+
+		"Subject: Lunch %d\r\n"+
+		"Date: Mon, 3 Mar 2025 11:%02d:00 +0000\r\n"+
+		"Message-ID: <lunch-%d@example.org>\r\n"+
+		reply+
+		"\r\n"+
+		"Are you free tomorrow?\r\n", n, n, n))
+}
+
+func newTestRepository(t *testing.T, server *gmailfake.Server, client *http.Client) *gmailRepository {
+	t.Helper()
+	t.Cleanup(server.Close)
+	return newGmailRepository(Config{
+		BaseURL:       server.BaseURL(),
+		TokenProvider: token.NewStaticTokenProvider(server.Token),
+		HTTPClient:    client,
+	})
+}
+
+func subjects(emails []entities.EmailMessage) []string {
+	var result []string
+	for _, email := range emails {
+		result = append(result, email.Subject)
+	}
+	return result
+}
+
+func TestFetchEmailsPagesUntilEnoughPromotions(t *testing.T) {
+	server := gmailfake.NewServer()
+	repo := newTestRepository(t, server, nil)
+
+	// Listed newest first: promo 5, 4, 3, lunch 2, promo 2, lunch 1, promo 1.
+	server.AddMessage(gmailfake.Message{Raw: promotion(1)})
+	server.AddMessage(gmailfake.Message{Raw: personal(1, "")})
+	server.AddMessage(gmailfake.Message{Raw: promotion(2)})
+	server.AddMessage(gmailfake.Message{Raw: personal(2, "")})
+	for n := 3; n <= 5; n++ {
+		server.AddMessage(gmailfake.Message{Raw: promotion(n)})
+	}
+
+	list, err := repo.FetchEmails(context.Background(), entities.EmailFilter{MaxResults: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Spring sale 5", "Spring sale 4", "Spring sale 3", "Spring sale 2"}
+	if got := subjects(list.Emails); !slices.Equal(got, want) {
+		t.Errorf("subjects = %v, want %v", got, want)
+	}
+	if list.TotalCount != 4 || list.Failed != 0 {
+		t.Errorf("total = %d, failed = %d", list.TotalCount, list.Failed)
+	}
+	if got := server.Requests(http.MethodGet, messagesPath); got != 2 {
+		t.Errorf("listed %d pages, want 2", got)
+	}
+	for _, email := range list.Emails {
+		if !email.IsPromotional || !slices.Contains(email.Labels, "INBOX") || email.ReceivedAt.IsZero() {
+			t.Errorf("%s: promotional = %v, labels = %v, received = %v", email.Subject, email.IsPromotional, email.Labels, email.ReceivedAt)
+		}
+	}
+}
+
+func TestFetchEmailsBodyMatchesParser(t *testing.T) {
+	raw := []byte("From: Shop <deals@shop.example>\r\n" +
+		"Subject: =?UTF-8?Q?Sommer=C3=BCberraschung?=\r\n" +
+		"Date: Tue, 4 Mar 2025 09:00:00 +0100\r\n" +
+		"Message-ID: <mime@shop.example>\r\n" +
+		"List-Unsubscribe: <https://shop.example/u>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n" +
+		"\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n" +
+		"\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Gro=DFer Rabatt, jetzt im Sale\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"PHA+R3Jvw59lciBSYWJhdHQ8L3A+\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: application/pdf; name=flyer.pdf\r\n" +
+		"Content-Disposition: attachment; filename=flyer.pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQK\r\n" +
+		"--outer--\r\n")
+
+	server := gmailfake.NewServer()
+	repo := newTestRepository(t, server, nil)
+	server.AddMessage(gmailfake.Message{Raw: raw})
+
+	list, err := repo.FetchEmails(context.Background(), entities.EmailFilter{MaxResults: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Emails) != 1 {
+		t.Fatalf("got %d emails, want 1", len(list.Emails))
+	}
+
+	parsed, err := parser.ParseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := list.Emails[0]
+	if got.Body != parsed.Body || got.Body != "Großer Rabatt, jetzt im Sale" {
+		t.Errorf("body = %q, parser gives %q", got.Body, parsed.Body)
+	}
+	if got.Subject != parsed.Subject || got.MessageID != "mime@shop.example" {
+		t.Errorf("subject = %q, message id = %q", got.Subject, got.MessageID)
+	}
+}
+
+func TestFetchThread(t *testing.T) {
+	server := gmailfake.NewServer()
+	repo := newTestRepository(t, server, nil)
+
+	first := server.AddMessage(gmailfake.Message{Raw: personal(1, "")})
+	server.AddMessage(gmailfake.Message{Raw: personal(2, "<lunch-1@example.org>")})
+	server.AddMessage(gmailfake.Message{Raw: promotion(1)})
+
+	thread, err := repo.FetchThread(context.Background(), first.ThreadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(thread), []string{"Lunch 1", "Lunch 2"}; !slices.Equal(got, want) {
+		t.Errorf("thread = %v, want %v", got, want)
+	}
+
+	if _, err := repo.FetchThread(context.Background(), "missing"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("missing thread: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchHistory(t *testing.T) {
+	server := gmailfake.NewServer()
+	repo := newTestRepository(t, server, nil)
+	ctx := context.Background()
+
+	server.AddMessage(gmailfake.Message{Raw: promotion(1)})
+	start := server.HistoryID()
+	server.AddMessage(gmailfake.Message{Raw: promotion(2)})
+	server.AddMessage(gmailfake.Message{Raw: personal(1, "")})
+
+	list, latest, err := repo.FetchHistory(ctx, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(list.Emails), []string{"Spring sale 2", "Lunch 1"}; !slices.Equal(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+	if latest != server.HistoryID() {
+		t.Errorf("latest = %d, want %d", latest, server.HistoryID())
+	}
+
+	list, latest, err = repo.FetchHistory(ctx, latest)
+	if err != nil || len(list.Emails) != 0 || latest != server.HistoryID() {
+		t.Errorf("up to date: %d emails, latest %d, err %v", len(list.Emails), latest, err)
+	}
+
+	server.ExpireHistory()
+	if _, _, err := repo.FetchHistory(ctx, start); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("expired history: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestModifyLabels(t *testing.T) {
+	server := gmailfake.NewServer()
+	repo := newTestRepository(t, server, nil)
+	ctx := context.Background()
+	msg := server.AddMessage(gmailfake.Message{Raw: promotion(1)})
+
+	if err := repo.ModifyLabels(ctx, msg.ID, []string{"Deals/Seen"}, []string{"unread", "No Such Label"}); err != nil {
+		t.Fatal(err)
+	}
+	labelID, err := repo.CreateLabel(ctx, "deals/seen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Archive(ctx, msg.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	got := server.Message(msg.ID).LabelIDs
+	if !slices.Contains(got, labelID) || slices.Contains(got, "UNREAD") || slices.Contains(got, "INBOX") {
+		t.Errorf("labels = %v, want %s without UNREAD and INBOX", got, labelID)
+	}
+	if n := server.Requests(http.MethodPost, "/gmail/v1/users/me/labels"); n != 1 {
+		t.Errorf("created labels %d times, want 1", n)
+	}
+	if n := server.Requests(http.MethodGet, "/gmail/v1/users/me/labels"); n != 1 {
+		t.Errorf("listed labels %d times, want 1", n)
+	}
+
+	if err := repo.Trash(ctx, msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Message(msg.ID).LabelIDs; !slices.Contains(got, "TRASH") {
+		t.Errorf("labels after trash = %v", got)
+	}
+	if err := repo.MarkRead(ctx, "missing"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("missing message: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchEmailsFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   gmailfake.Fault
+		token   string
+		timeout time.Duration
+		// wantErr is part of the error, or empty when the fetch succeeds
+		// with failed messages.
+		wantErr    string
+		wantFailed int
+	}{
+		{name: "expired token", token: "expired", wantErr: "status 401"},
+		{name: "unauthorized", fault: gmailfake.Fault{Path: "/messages", Status: 401}, wantErr: "status 401"},
+		{name: "throttled", fault: gmailfake.Fault{Path: "/messages", Status: 429, RetryAfter: 30}, wantErr: "status 429"},
+		{name: "outage", fault: gmailfake.Fault{Status: 500}, wantErr: "status 500"},
+		{name: "slow", fault: gmailfake.Fault{Delay: time.Second}, timeout: 50 * time.Millisecond, wantErr: "Client.Timeout"},
+		{name: "one message fails", fault: gmailfake.Fault{Path: "/messages/", Status: 500, Times: 1}, wantFailed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := gmailfake.NewServer()
+			client := &http.Client{Timeout: tt.timeout}
+			repo := newTestRepository(t, server, client)
+			if tt.token != "" {
+				repo.tokenProvider = token.NewStaticTokenProvider(tt.token)
+			}
+			for n := 1; n <= 3; n++ {
+				server.AddMessage(gmailfake.Message{Raw: promotion(n)})
+			}
+			server.InjectFault(tt.fault)
+
+			list, err := repo.FetchEmails(context.Background(), entities.EmailFilter{MaxResults: 10})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if list.Failed != tt.wantFailed || len(list.Emails) != 3-tt.wantFailed {
+				t.Errorf("got %d emails, %d failed", len(list.Emails), list.Failed)
+			}
+		})
+	}
+}
+
+// TestParseGmailMatchesRawMessage fetches a message through the API and
+// parses the same bytes as an .eml file; apart from what only the mailbox
+// knows, both must give the same EmailMessage.
+func TestParseGmailMatchesRawMessage(t *testing.T) {
+	raw := []byte("Received: from mx.shop.example by mx.example.com;\r\n" +
+		"\tTue, 4 Mar 2025 08:00:05 +0000\r\n" +
+		"From: =?UTF-8?Q?Sch=C3=B6n?= Shop <deals@SHOP.example>\r\n" +
+		"To: \"Doe, Jane\" <jane@example.com>,\r\n" +
+		"\tteam: bob@example.com, carl@example.com;\r\n" +
+		"Subject: =?UTF-8?Q?Fr=C3=BChlingsangebote?= and\r\n" +
+		"\t more:  up to 50%\r\n" +
+		"Date: Tue, 4 Mar 2025 09:00:00 +0100 (CET)\r\n" +
+		"Message-ID: <parity@shop.example>\r\n" +
+		"In-Reply-To: <earlier@shop.example>\r\n" +
+		"References: <first@shop.example>\r\n" +
+		" <earlier@shop.example>\r\n" +
+		"List-Unsubscribe: <https://shop.example/u>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/alternative; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Sch=F6ne Angebote\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<p>Schöne Angebote</p>\r\n" +
+		"--b--\r\n")
+
+	server := gmailfake.NewServer()
+	repo := newTestRepository(t, server, nil)
+	stored := server.AddMessage(gmailfake.Message{Raw: raw})
+
+	got, err := repo.getEmailContent(context.Background(), stored.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := parser.ParseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ID, thread, labels and received time come from the mailbox, and
+	// the repository classifies what it fetches.
+	want.ID, want.ThreadID, want.Labels, want.ReceivedAt = got.ID, got.ThreadID, got.Labels, got.ReceivedAt
+	classifier.Default().Apply(want)
+
+	if !reflect.DeepEqual(*got, *want) {
+		t.Errorf("Gmail API gives\n%+v\nraw message gives\n%+v", *got, *want)
+	}
+	if want.Subject != "Frühlingsangebote and\t more:  up to 50%" {
+		t.Errorf("subject = %q", want.Subject)
+	}
+}
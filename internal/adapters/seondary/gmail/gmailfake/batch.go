@@ -0,0 +1,91 @@
+package gmailfake
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBatchSize is the API's limit on calls per batch request.
+const maxBatchSize = 100
+
+// batch serves the multipart/mixed batch endpoint. Every part is an
+// application/http request that is replayed against the API with the outer
+// request's credentials; responses come back in the same order.
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" || params["boundary"] == "" {
+		writeError(w, http.StatusBadRequest, "batch requests must be multipart/mixed")
+		return
+	}
+
+	type call struct {
+		contentID string
+		req       *http.Request
+	}
+	var calls []call
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed batch body: "+err.Error())
+			return
+		}
+		inner, err := http.ReadRequest(bufio.NewReader(p))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed batch part: "+err.Error())
+			return
+		}
+		if strings.HasPrefix(inner.URL.Path, "/batch") {
+			writeError(w, http.StatusBadRequest, "batch requests cannot be nested")
+			return
+		}
+		calls = append(calls, call{contentID: p.Header.Get("Content-ID"), req: inner})
+	}
+	if len(calls) > maxBatchSize {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("too many requests in batch, max %d", maxBatchSize))
+		return
+	}
+
+	var out bytes.Buffer
+	writer := multipart.NewWriter(&out)
+	for _, c := range calls {
+		// Drop the outer routing state so the inner request is routed afresh.
+		inner := c.req.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, (*chi.Context)(nil)))
+		inner.RequestURI = ""
+		if inner.Header.Get("Authorization") == "" {
+			inner.Header.Set("Authorization", r.Header.Get("Authorization"))
+		}
+
+		recorder := httptest.NewRecorder()
+		s.api.ServeHTTP(recorder, inner)
+
+		partHeader := map[string][]string{"Content-Type": {"application/http"}}
+		if c.contentID != "" {
+			partHeader["Content-Id"] = []string{"response-" + strings.Trim(c.contentID, "<>")}
+		}
+		pw, _ := writer.CreatePart(partHeader)
+		result := recorder.Result()
+		fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\n", result.StatusCode, http.StatusText(result.StatusCode))
+		result.Header.Write(pw)
+		fmt.Fprint(pw, "\r\n")
+		pw.Write(recorder.Body.Bytes())
+	}
+	writer.Close()
+
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	w.WriteHeader(http.StatusOK)
+	w.Write(out.Bytes())
+}
@@ -0,0 +1,99 @@
+package gmailfake
+
+import (
+	"email-parser-poc/internal/domain/parser"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+type header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type body struct {
+	AttachmentID string `json:"attachmentId,omitempty"`
+	Size         int    `json:"size"`
+	Data         string `json:"data,omitempty"`
+}
+
+// part mirrors the API's MessagePart resource.
+type part struct {
+	PartID   string   `json:"partId"`
+	MimeType string   `json:"mimeType"`
+	Filename string   `json:"filename"`
+	Headers  []header `json:"headers"`
+	Body     body     `json:"body"`
+	Parts    []part   `json:"parts,omitempty"`
+}
+
+// buildPayload converts raw MIME into the format=full payload, splitting it
+// with the same parser the adapters use. Leaf bodies are base64url encoded
+// again, as Gmail does; parts with a file name are left out and returned
+// keyed by attachment ID instead.
+func buildPayload(messageID string, raw []byte) (part, map[string][]byte) {
+	attachments := make(map[string][]byte)
+	root, err := parser.ParseMIME(raw)
+	if err != nil {
+		// Gmail still serves what it stored; show it as a plain body.
+		root = &parser.Part{MediaType: "text/plain", Body: raw}
+	}
+	return buildPart(messageID, "", root, attachments), attachments
+}
+
+func buildPart(messageID, partID string, parsed *parser.Part, attachments map[string][]byte) part {
+	headers := make([]header, 0, len(parsed.Headers))
+	for _, h := range parsed.Headers {
+		headers = append(headers, header{Name: h.Name, Value: h.Value})
+	}
+	p := part{PartID: partID, MimeType: parsed.MediaType, Headers: headers}
+
+	if len(parsed.Parts) > 0 {
+		for i := range parsed.Parts {
+			childID := fmt.Sprint(i)
+			if partID != "" {
+				childID = partID + "." + childID
+			}
+			p.Parts = append(p.Parts, buildPart(messageID, childID, &parsed.Parts[i], attachments))
+		}
+		return p
+	}
+
+	p.Filename = parsed.Filename()
+	p.Body.Size = len(parsed.Body)
+	if p.Filename != "" {
+		p.Body.AttachmentID = attachmentID(messageID, partID)
+		attachments[p.Body.AttachmentID] = parsed.Body
+	} else {
+		p.Body.Data = base64.URLEncoding.EncodeToString(parsed.Body)
+	}
+	return p
+}
+
+// attachmentID is stable for a part so IDs handed out by one response stay
+// valid for the attachments endpoint.
+func attachmentID(messageID, partID string) string {
+	if partID == "" {
+		partID = "0"
+	}
+	return "ANGjdJ-" + messageID + "-" + strings.ReplaceAll(partID, ".", "_")
+}
+
+// snippet is the start of the first text/plain part, whitespace collapsed.
+func snippet(p part) string {
+	if p.MimeType == "text/plain" && p.Body.Data != "" {
+		data, _ := base64.URLEncoding.DecodeString(p.Body.Data)
+		text := strings.Join(strings.Fields(string(data)), " ")
+		if runes := []rune(text); len(runes) > 200 {
+			text = string(runes[:200])
+		}
+		return text
+	}
+	for _, child := range p.Parts {
+		if s := snippet(child); s != "" {
+			return s
+		}
+	}
+	return ""
+}
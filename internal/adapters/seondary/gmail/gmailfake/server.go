@@ -0,0 +1,939 @@
+// Package gmailfake is an in-process stand-in for the Gmail API, for
+// exercising the gmail adapter and the router without a Google account. It
+// implements just enough of Gmail's behaviour: message listing and paging,
+// full/raw/metadata formats built from fixture MIME, threads, history,
+// attachments, labels, watch and batch requests. Faults can be injected per
+// endpoint to simulate expired tokens, throttling, outages and slow calls.
+package gmailfake
+
+import (
+	"email-parser-poc/internal/domain/parser"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+	// firstHistoryID is where the mailbox's history starts.
+	firstHistoryID = 1000
+)
+
+// systemLabels exist in every mailbox.
+var systemLabels = []string{
+	"INBOX", "UNREAD", "STARRED", "IMPORTANT", "SENT", "DRAFT", "SPAM", "TRASH",
+	"CATEGORY_PERSONAL", "CATEGORY_SOCIAL", "CATEGORY_PROMOTIONS", "CATEGORY_UPDATES", "CATEGORY_FORUMS",
+}
+
+// Message is one stored message.
+type Message struct {
+	ID       string
+	ThreadID string
+	// LabelIDs defaults to INBOX and UNREAD when nil.
+	LabelIDs     []string
+	Raw          []byte
+	InternalDate time.Time
+
+	seq int
+}
+
+// Fault makes matching requests fail or stall before they are handled.
+type Fault struct {
+	// Method and Path select requests; empty matches any. Path is a prefix
+	// of the path below /gmail/v1/users/{user}, e.g. "/messages" or
+	// "/history", or "/batch" for the batch endpoint.
+	Method string
+	Path   string
+	// Status is the error returned, e.g. 401, 429 or 500; 0 only delays.
+	Status int
+	// RetryAfter is sent as the Retry-After header, in seconds, when set.
+	RetryAfter int
+	// Delay stalls the request first, or until the client gives up.
+	Delay time.Duration
+	// Times limits how often the fault fires; 0 means until ClearFaults.
+	Times int
+}
+
+type historyRecord struct {
+	id            uint64
+	messageID     string
+	messageAdded  bool
+	labelsAdded   []string
+	labelsRemoved []string
+}
+
+type Server struct {
+	*httptest.Server
+
+	// Token is the bearer token the API accepts.
+	Token        string
+	EmailAddress string
+
+	api *chi.Mux
+
+	mu           sync.Mutex
+	seq          int
+	messages     map[string]*Message
+	labels       map[string]string // ID -> name
+	labelSeq     int
+	historyID    uint64
+	historyFloor uint64
+	history      []historyRecord
+	watchTopic   string
+	faults       []*Fault
+	// requests counts calls per "METHOD path" for assertions.
+	requests map[string]int
+}
+
+func NewServer() *Server {
+	s := &Server{
+		Token:        "fake-gmail-token",
+		EmailAddress: "me@example.com",
+		messages:     make(map[string]*Message),
+		labels:       make(map[string]string),
+		historyID:    firstHistoryID,
+		historyFloor: firstHistoryID,
+		requests:     make(map[string]int),
+	}
+	for _, id := range systemLabels {
+		s.labels[id] = id
+	}
+
+	r := chi.NewRouter()
+	r.Use(s.intercept)
+	r.Post("/batch/gmail/v1", s.batch)
+	r.Post("/batch", s.batch)
+	r.Route("/gmail/v1/users/{user}", func(r chi.Router) {
+		r.Get("/profile", s.profile)
+		r.Get("/messages", s.list)
+		r.Get("/messages/{id}", s.get)
+		r.Post("/messages/{id}/modify", s.modify)
+		r.Post("/messages/{id}/trash", s.trash)
+		r.Get("/messages/{id}/attachments/{attachmentId}", s.attachment)
+		r.Get("/threads/{id}", s.thread)
+		r.Get("/history", s.listHistory)
+		r.Get("/labels", s.listLabels)
+		r.Post("/labels", s.createLabel)
+		r.Get("/labels/{id}", s.getLabel)
+		r.Post("/watch", s.watch)
+		r.Post("/stop", s.stop)
+	})
+	s.api = r
+
+	s.Server = httptest.NewServer(r)
+	return s
+}
+
+// BaseURL is the API root to configure the adapter with.
+func (s *Server) BaseURL() string {
+	return s.URL
+}
+
+// AddMessage stores a copy of msg and returns the stored message. Unset IDs
+// are generated; a message without a thread joins the thread of a stored
+// message it references through In-Reply-To or References, and otherwise
+// starts its own. InternalDate defaults to the Date header, then now.
+func (s *Server) AddMessage(msg Message) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	stored := msg
+	stored.seq = s.seq
+	stored.Raw = append([]byte(nil), msg.Raw...)
+	if stored.ID == "" {
+		stored.ID = fmt.Sprintf("%016x", 0x18f0000000000000+s.seq)
+	}
+	if stored.LabelIDs == nil {
+		stored.LabelIDs = []string{"INBOX", "UNREAD"}
+	}
+	stored.LabelIDs = append([]string(nil), stored.LabelIDs...)
+	for _, id := range stored.LabelIDs {
+		if _, ok := s.labels[id]; !ok {
+			s.labels[id] = id
+		}
+	}
+
+	headers, _ := parser.ParseHeaders(stored.Raw)
+	if stored.ThreadID == "" {
+		stored.ThreadID = s.threadFor(headers)
+	}
+	if stored.ThreadID == "" {
+		stored.ThreadID = stored.ID
+	}
+	if stored.InternalDate.IsZero() {
+		stored.InternalDate = dateOf(headers)
+	}
+
+	s.messages[stored.ID] = &stored
+	s.historyID++
+	s.history = append(s.history, historyRecord{id: s.historyID, messageID: stored.ID, messageAdded: true})
+
+	copied := stored
+	return &copied
+}
+
+// LoadDir adds every *.eml file in dir, in name order, with the given
+// labels.
+func (s *Server) LoadDir(dir string, labelIDs ...string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read fixture %s: %w", path, err)
+		}
+		s.AddMessage(Message{Raw: raw, LabelIDs: labelIDs})
+	}
+	return nil
+}
+
+// Message returns a copy of the stored message, or nil.
+func (s *Server) Message(id string) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[id]
+	if !ok {
+		return nil
+	}
+	copied := *msg
+	copied.LabelIDs = append([]string(nil), msg.LabelIDs...)
+	return &copied
+}
+
+// HistoryID is the mailbox's current history ID.
+func (s *Server) HistoryID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.historyID
+}
+
+// ExpireHistory drops all history so far, making older start IDs fail with
+// 404 as they do once Gmail stops retaining them.
+func (s *Server) ExpireHistory() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.historyFloor = s.historyID
+	s.history = nil
+}
+
+// WatchTopic is the topic of the last watch call, or empty after stop.
+func (s *Server) WatchTopic() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watchTopic
+}
+
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := fault
+	s.faults = append(s.faults, &copied)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+func (s *Server) Requests(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method+" "+path]
+}
+
+// intercept counts the request, applies the first matching fault and checks
+// the bearer token.
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		fault := s.matchFault(r)
+		s.mu.Unlock()
+
+		if fault != nil {
+			if fault.Delay > 0 {
+				select {
+				case <-time.After(fault.Delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if fault.Status != 0 {
+				if fault.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+				}
+				writeError(w, fault.Status, "injected fault")
+				return
+			}
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+s.Token {
+			writeError(w, http.StatusUnauthorized, "Request had invalid authentication credentials.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) matchFault(r *http.Request) *Fault {
+	path := r.URL.Path
+	if rest, ok := strings.CutPrefix(path, "/gmail/v1/users/"); ok {
+		path = ""
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			path = rest[i:]
+		}
+	} else if strings.HasPrefix(path, "/batch") {
+		path = "/batch"
+	}
+
+	for i, fault := range s.faults {
+		if fault.Method != "" && fault.Method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+		fired := *fault
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &fired
+	}
+	return nil
+}
+
+func (s *Server) profile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threads := make(map[string]bool)
+	for _, msg := range s.messages {
+		threads[msg.ThreadID] = true
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"emailAddress":  s.EmailAddress,
+		"messagesTotal": len(s.messages),
+		"threadsTotal":  len(threads),
+		"historyId":     strconv.FormatUint(s.historyID, 10),
+	})
+}
+
+// list returns message references newest first. It supports labelIds,
+// includeSpamTrash, a small subset of q (is:unread, is:starred, in:NAME,
+// label:NAME, and plain words matched against the raw message) and
+// offset-based page tokens.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	pageSize, ok := pageSize(query.Get("maxResults"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid maxResults")
+		return
+	}
+	offset, ok := pageOffset(query.Get("pageToken"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid pageToken")
+		return
+	}
+
+	required := append([]string(nil), query["labelIds"]...)
+	var words []string
+	for _, term := range strings.Fields(query.Get("q")) {
+		lower := strings.ToLower(term)
+		switch {
+		case lower == "is:unread":
+			required = append(required, "UNREAD")
+		case lower == "is:starred":
+			required = append(required, "STARRED")
+		case strings.HasPrefix(lower, "in:"), strings.HasPrefix(lower, "label:"):
+			_, name, _ := strings.Cut(term, ":")
+			required = append(required, s.resolveLabel(name))
+		default:
+			words = append(words, lower)
+		}
+	}
+	includeSpamTrash := query.Get("includeSpamTrash") == "true"
+
+	var matched []*Message
+	for _, msg := range s.newestFirst() {
+		if !includeSpamTrash && (hasLabel(msg, "SPAM") || hasLabel(msg, "TRASH")) {
+			continue
+		}
+		if !hasAllLabels(msg, required) || !containsAll(msg.Raw, words) {
+			continue
+		}
+		matched = append(matched, msg)
+	}
+
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	end := min(offset+pageSize, len(matched))
+
+	refs := make([]map[string]string, 0, end-offset)
+	for _, msg := range matched[offset:end] {
+		refs = append(refs, map[string]string{"id": msg.ID, "threadId": msg.ThreadID})
+	}
+	resp := map[string]interface{}{"resultSizeEstimate": len(matched)}
+	if len(refs) > 0 {
+		resp["messages"] = refs
+	}
+	if end < len(matched) {
+		resp["nextPageToken"] = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "full"
+	}
+	if !validFormat(format) {
+		writeError(w, http.StatusBadRequest, "Invalid format")
+		return
+	}
+	s.withMessage(w, r, func(msg *Message) {
+		writeJSON(w, http.StatusOK, s.render(msg, format, r.URL.Query()["metadataHeaders"]))
+	})
+}
+
+func (s *Server) modify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AddLabelIDs    []string `json:"addLabelIds"`
+		RemoveLabelIDs []string `json:"removeLabelIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.withMessage(w, r, func(msg *Message) {
+		for _, id := range append(append([]string(nil), req.AddLabelIDs...), req.RemoveLabelIDs...) {
+			if _, ok := s.labels[id]; !ok {
+				writeError(w, http.StatusBadRequest, "Invalid label: "+id)
+				return
+			}
+		}
+		s.changeLabels(msg, req.AddLabelIDs, req.RemoveLabelIDs)
+		writeJSON(w, http.StatusOK, s.render(msg, "minimal", nil))
+	})
+}
+
+func (s *Server) trash(w http.ResponseWriter, r *http.Request) {
+	s.withMessage(w, r, func(msg *Message) {
+		s.changeLabels(msg, []string{"TRASH"}, nil)
+		writeJSON(w, http.StatusOK, s.render(msg, "minimal", nil))
+	})
+}
+
+func (s *Server) attachment(w http.ResponseWriter, r *http.Request) {
+	s.withMessage(w, r, func(msg *Message) {
+		_, attachments := buildPayload(msg.ID, msg.Raw)
+		id := chi.URLParam(r, "attachmentId")
+		data, ok := attachments[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Requested entity was not found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"attachmentId": id,
+			"size":         len(data),
+			"data":         base64.URLEncoding.EncodeToString(data),
+		})
+	})
+}
+
+// thread returns the thread's messages oldest first.
+func (s *Server) thread(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "full"
+	}
+	if !validFormat(format) || format == "raw" {
+		writeError(w, http.StatusBadRequest, "Invalid format")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threadID := chi.URLParam(r, "id")
+	var messages []*Message
+	for _, msg := range s.newestFirst() {
+		if msg.ThreadID == threadID {
+			messages = append([]*Message{msg}, messages...)
+		}
+	}
+	if len(messages) == 0 {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+
+	rendered := make([]map[string]interface{}, 0, len(messages))
+	historyID := uint64(0)
+	for _, msg := range messages {
+		rendered = append(rendered, s.render(msg, format, r.URL.Query()["metadataHeaders"]))
+		historyID = max(historyID, s.lastHistoryOf(msg.ID))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":        threadID,
+		"historyId": strconv.FormatUint(historyID, 10),
+		"messages":  rendered,
+	})
+}
+
+// listHistory pages through records after startHistoryId, optionally
+// limited by historyTypes and labelId. A start ID older than the retained
+// history is a 404.
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	start, err := strconv.ParseUint(query.Get("startHistoryId"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid startHistoryId")
+		return
+	}
+	if start < s.historyFloor {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	pageSize, ok := pageSize(query.Get("maxResults"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid maxResults")
+		return
+	}
+	offset, ok := pageOffset(query.Get("pageToken"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid pageToken")
+		return
+	}
+	types := make(map[string]bool)
+	for _, t := range query["historyTypes"] {
+		types[t] = true
+	}
+	labelID := query.Get("labelId")
+
+	var matched []map[string]interface{}
+	for _, record := range s.history {
+		if record.id <= start {
+			continue
+		}
+		msg, ok := s.messages[record.messageID]
+		if !ok {
+			continue
+		}
+		if labelID != "" && !hasLabel(msg, labelID) {
+			continue
+		}
+		if entry := renderHistory(record, msg, types); entry != nil {
+			matched = append(matched, entry)
+		}
+	}
+
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	end := min(offset+pageSize, len(matched))
+	resp := map[string]interface{}{"historyId": strconv.FormatUint(s.historyID, 10)}
+	if end > offset {
+		resp["history"] = matched[offset:end]
+	}
+	if end < len(matched) {
+		resp["nextPageToken"] = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func renderHistory(record historyRecord, msg *Message, types map[string]bool) map[string]interface{} {
+	ref := map[string]interface{}{"id": msg.ID, "threadId": msg.ThreadID, "labelIds": msg.LabelIDs}
+	entry := map[string]interface{}{
+		"id":       strconv.FormatUint(record.id, 10),
+		"messages": []map[string]interface{}{{"id": msg.ID, "threadId": msg.ThreadID}},
+	}
+	wanted := func(t string) bool { return len(types) == 0 || types[t] }
+
+	found := false
+	if record.messageAdded && wanted("messageAdded") {
+		entry["messagesAdded"] = []map[string]interface{}{{"message": ref}}
+		found = true
+	}
+	if len(record.labelsAdded) > 0 && wanted("labelAdded") {
+		entry["labelsAdded"] = []map[string]interface{}{{"message": ref, "labelIds": record.labelsAdded}}
+		found = true
+	}
+	if len(record.labelsRemoved) > 0 && wanted("labelRemoved") {
+		entry["labelsRemoved"] = []map[string]interface{}{{"message": ref, "labelIds": record.labelsRemoved}}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return entry
+}
+
+func (s *Server) listLabels(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.labels))
+	for id := range s.labels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	labels := make([]map[string]string, 0, len(ids))
+	for _, id := range ids {
+		labels = append(labels, s.renderLabel(id))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"labels": labels})
+}
+
+func (s *Server) getLabel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := chi.URLParam(r, "id")
+	if _, ok := s.labels[id]; !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.renderLabel(id))
+}
+
+// createLabel rejects names that clash with an existing label, ignoring
+// case, with 409 as Gmail does.
+func (s *Server) createLabel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "Invalid label name")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range s.labels {
+		if strings.EqualFold(name, req.Name) {
+			writeError(w, http.StatusConflict, "Label name exists or conflicts")
+			return
+		}
+	}
+	s.labelSeq++
+	id := fmt.Sprintf("Label_%d", s.labelSeq)
+	s.labels[id] = req.Name
+	writeJSON(w, http.StatusOK, s.renderLabel(id))
+}
+
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TopicName string `json:"topicName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TopicName == "" {
+		writeError(w, http.StatusBadRequest, "topicName is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchTopic = req.TopicName
+	expiration := time.Now().Add(7 * 24 * time.Hour).UnixMilli()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"historyId":  strconv.FormatUint(s.historyID, 10),
+		"expiration": strconv.FormatInt(expiration, 10),
+	})
+}
+
+func (s *Server) stop(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchTopic = ""
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) withMessage(w http.ResponseWriter, r *http.Request, fn func(msg *Message)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[chi.URLParam(r, "id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Requested entity was not found.")
+		return
+	}
+	fn(msg)
+}
+
+func (s *Server) changeLabels(msg *Message, add, remove []string) {
+	var added, removed []string
+	for _, id := range add {
+		if !hasLabel(msg, id) {
+			msg.LabelIDs = append(msg.LabelIDs, id)
+			added = append(added, id)
+		}
+	}
+	for _, id := range remove {
+		for i, existing := range msg.LabelIDs {
+			if existing == id {
+				msg.LabelIDs = append(msg.LabelIDs[:i], msg.LabelIDs[i+1:]...)
+				removed = append(removed, id)
+				break
+			}
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	s.historyID++
+	s.history = append(s.history, historyRecord{id: s.historyID, messageID: msg.ID, labelsAdded: added, labelsRemoved: removed})
+}
+
+// render produces the Message resource in the requested format.
+func (s *Server) render(msg *Message, format string, metadataHeaders []string) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":           msg.ID,
+		"threadId":     msg.ThreadID,
+		"labelIds":     msg.LabelIDs,
+		"historyId":    strconv.FormatUint(s.lastHistoryOf(msg.ID), 10),
+		"internalDate": strconv.FormatInt(msg.InternalDate.UnixMilli(), 10),
+		"sizeEstimate": len(msg.Raw),
+	}
+
+	switch format {
+	case "raw":
+		out["raw"] = base64.URLEncoding.EncodeToString(msg.Raw)
+	case "full", "metadata":
+		payload, _ := buildPayload(msg.ID, msg.Raw)
+		out["snippet"] = snippet(payload)
+		if format == "metadata" {
+			payload = part{MimeType: payload.MimeType, Headers: filterHeaders(payload.Headers, metadataHeaders)}
+		}
+		out["payload"] = payload
+	}
+	return out
+}
+
+func (s *Server) renderLabel(id string) map[string]string {
+	labelType := "user"
+	if s.labels[id] == id && !strings.HasPrefix(id, "Label_") {
+		labelType = "system"
+	}
+	return map[string]string{
+		"id":                    id,
+		"name":                  s.labels[id],
+		"type":                  labelType,
+		"labelListVisibility":   "labelShow",
+		"messageListVisibility": "show",
+	}
+}
+
+func (s *Server) lastHistoryOf(messageID string) uint64 {
+	latest := s.historyFloor
+	for _, record := range s.history {
+		if record.messageID == messageID {
+			latest = record.id
+		}
+	}
+	return latest
+}
+
+// threadFor finds the thread of a stored message referenced by headers.
+func (s *Server) threadFor(headers []parser.Header) string {
+	var refs []string
+	for _, h := range headers {
+		switch strings.ToLower(h.Name) {
+		case "in-reply-to", "references":
+			refs = append(refs, parser.ParseMessageIDs(h.Value)...)
+		}
+	}
+	if len(refs) == 0 {
+		return ""
+	}
+
+	for _, msg := range s.messages {
+		stored, _ := parser.ParseHeaders(msg.Raw)
+		for _, h := range stored {
+			if !strings.EqualFold(h.Name, "Message-ID") {
+				continue
+			}
+			id := parser.ParseMessageID(h.Value)
+			for _, ref := range refs {
+				if ref == id {
+					return msg.ThreadID
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// resolveLabel maps a label name used in q to its ID.
+func (s *Server) resolveLabel(name string) string {
+	for id, labelName := range s.labels {
+		if strings.EqualFold(id, name) || strings.EqualFold(labelName, name) {
+			return id
+		}
+	}
+	return name
+}
+
+func (s *Server) newestFirst() []*Message {
+	list := make([]*Message, 0, len(s.messages))
+	for _, msg := range s.messages {
+		list = append(list, msg)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].InternalDate.Equal(list[j].InternalDate) {
+			return list[i].InternalDate.After(list[j].InternalDate)
+		}
+		return list[i].seq > list[j].seq
+	})
+	return list
+}
+
+func dateOf(headers []parser.Header) time.Time {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, "Date") {
+			if date, err := parser.ParseDate(h.Value); err == nil {
+				return date.UTC()
+			}
+		}
+	}
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func filterHeaders(headers []header, names []string) []header {
+	if len(names) == 0 {
+		return headers
+	}
+	var out []header
+	for _, h := range headers {
+		for _, name := range names {
+			if strings.EqualFold(h.Name, name) {
+				out = append(out, h)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func hasLabel(msg *Message, id string) bool {
+	for _, existing := range msg.LabelIDs {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAllLabels(msg *Message, ids []string) bool {
+	for _, id := range ids {
+		if !hasLabel(msg, id) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAll(raw []byte, words []string) bool {
+	lower := strings.ToLower(string(raw))
+	for _, word := range words {
+		if !strings.Contains(lower, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func validFormat(format string) bool {
+	switch format {
+	case "full", "raw", "metadata", "minimal":
+		return true
+	}
+	return false
+}
+
+func pageSize(value string) (int, bool) {
+	if value == "" {
+		return defaultPageSize, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return min(n, maxPageSize), true
+}
+
+func pageOffset(token string) (int, bool) {
+	if token == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(token)
+	return n, err == nil && n >= 0
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError uses Google's error envelope.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"status":  googleStatus(status),
+		},
+	})
+}
+
+func googleStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ALREADY_EXISTS"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	}
+	return "INTERNAL"
+}
@@ -0,0 +1,26 @@
+package gmail
+
+import (
+	"context"
+	"email-parser-poc/internal/ports/outgoing"
+	"fmt"
+)
+
+var _ outgoing.HealthChecker = (*gmailRepository)(nil)
+
+func (r *gmailRepository) Name() string {
+	return "gmail"
+}
+
+func (r *gmailRepository) Critical() bool {
+	return true
+}
+
+// Check confirms the access token is still accepted by fetching the mailbox
+// profile, the cheapest authorized call.
+func (r *gmailRepository) Check(ctx context.Context) error {
+	if _, err := r.EmailAddress(ctx); err != nil {
+		return fmt.Errorf("access token check failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package gmail
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// System labels Gmail exposes with an ID equal to their name.
+const (
+	labelInbox  = "INBOX"
+	labelUnread = "UNREAD"
+)
+
+// ModifyLabels adds and removes labels by name or ID. Missing user labels in
+// add are created first; unknown labels in remove are ignored.
+func (r *gmailRepository) ModifyLabels(ctx context.Context, messageID string, add, remove []string) error {
+	body := ModifyRequest{}
+	for _, name := range add {
+		id, err := r.labelID(ctx, name, true)
+		if err != nil {
+			return err
+		}
+		body.AddLabelIDs = append(body.AddLabelIDs, id)
+	}
+	for _, name := range remove {
+		id, err := r.labelID(ctx, name, false)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			body.RemoveLabelIDs = append(body.RemoveLabelIDs, id)
+		}
+	}
+	if len(body.AddLabelIDs) == 0 && len(body.RemoveLabelIDs) == 0 {
+		return nil
+	}
+
+	apiURL := r.userURL() + "/messages/" + url.PathEscape(messageID) + "/modify"
+	if err := r.doJSON(ctx, http.MethodPost, apiURL, body, nil); err != nil {
+		return fmt.Errorf("failed to modify labels of %s: %w", messageID, err)
+	}
+	return nil
+}
+
+// CreateLabel creates a user label, returning the existing ID if a label
+// with that name already exists.
+func (r *gmailRepository) CreateLabel(ctx context.Context, name string) (string, error) {
+	return r.labelID(ctx, name, true)
+}
+
+func (r *gmailRepository) Archive(ctx context.Context, messageID string) error {
+	return r.ModifyLabels(ctx, messageID, nil, []string{labelInbox})
+}
+
+func (r *gmailRepository) MarkRead(ctx context.Context, messageID string) error {
+	return r.ModifyLabels(ctx, messageID, nil, []string{labelUnread})
+}
+
+func (r *gmailRepository) Trash(ctx context.Context, messageID string) error {
+	apiURL := r.userURL() + "/messages/" + url.PathEscape(messageID) + "/trash"
+	if err := r.doJSON(ctx, http.MethodPost, apiURL, nil, nil); err != nil {
+		return fmt.Errorf("failed to trash %s: %w", messageID, err)
+	}
+	return nil
+}
+
+// labelID resolves a label name or ID, loading the mailbox's labels once and
+// optionally creating a missing one.
+func (r *gmailRepository) labelID(ctx context.Context, name string, create bool) (string, error) {
+	r.labelsMu.Lock()
+	defer r.labelsMu.Unlock()
+
+	if r.labels == nil {
+		if err := r.loadLabels(ctx); err != nil {
+			return "", err
+		}
+	}
+	if id, ok := r.labels[strings.ToLower(name)]; ok {
+		return id, nil
+	}
+	if !create {
+		return "", nil
+	}
+
+	var created Label
+	body := Label{Name: name, LabelListVisibility: "labelShow", MessageListVisibility: "show"}
+	err := r.doJSON(ctx, http.MethodPost, r.userURL()+"/labels", body, &created)
+	if err != nil {
+		// Another process may have created it since we listed; reload once.
+		if reloadErr := r.loadLabels(ctx); reloadErr == nil {
+			if id, ok := r.labels[strings.ToLower(name)]; ok {
+				return id, nil
+			}
+		}
+		return "", fmt.Errorf("failed to create label %q: %w", name, err)
+	}
+
+	r.logger.InfoContext(ctx, "created gmail label", "label", name, "label_id", created.ID)
+	r.labels[strings.ToLower(created.Name)] = created.ID
+	return created.ID, nil
+}
+
+func (r *gmailRepository) loadLabels(ctx context.Context) error {
+	var list LabelsListResponse
+	if err := r.doJSON(ctx, http.MethodGet, r.userURL()+"/labels", nil, &list); err != nil {
+		if errors.Is(err, entities.ErrNotFound) {
+			return fmt.Errorf("failed to list labels: mailbox not found")
+		}
+		return fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	r.labels = make(map[string]string, 2*len(list.Labels))
+	for _, label := range list.Labels {
+		r.labels[strings.ToLower(label.Name)] = label.ID
+		r.labels[strings.ToLower(label.ID)] = label.ID
+	}
+	return nil
+}
+
+type Label struct {
+	ID                    string `json:"id,omitempty"`
+	Name                  string `json:"name"`
+	Type                  string `json:"type,omitempty"`
+	LabelListVisibility   string `json:"labelListVisibility,omitempty"`
+	MessageListVisibility string `json:"messageListVisibility,omitempty"`
+}
+
+type LabelsListResponse struct {
+	Labels []Label `json:"labels"`
+}
+
+type ModifyRequest struct {
+	AddLabelIDs    []string `json:"addLabelIds,omitempty"`
+	RemoveLabelIDs []string `json:"removeLabelIds,omitempty"`
+}
@@ -0,0 +1,33 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// FetchRawEmail returns the exact RFC 822 bytes Gmail stored for a message,
+// as retrieved with format=raw.
+func (r *gmailRepository) FetchRawEmail(ctx context.Context, messageID string) ([]byte, error) {
+	apiURL := r.userURL() + "/messages/" + url.PathEscape(messageID) + "?format=raw"
+
+	var rawMsg RawMessage
+	if err := r.doJSON(ctx, http.MethodGet, apiURL, nil, &rawMsg); err != nil {
+		return nil, fmt.Errorf("failed to get raw message %s: %w", messageID, err)
+	}
+
+	decoded, err := r.decodeBase64URL(rawMsg.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode raw message %s: %w", messageID, err)
+	}
+	return []byte(decoded), nil
+}
+
+type RawMessage struct {
+	ID           string   `json:"id"`
+	ThreadID     string   `json:"threadId"`
+	LabelIDs     []string `json:"labelIds"`
+	InternalDate string   `json:"internalDate"`
+	Raw          string   `json:"raw"`
+}
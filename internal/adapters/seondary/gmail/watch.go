@@ -0,0 +1,197 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var _ outgoing.MailboxWatcher = (*gmailRepository)(nil)
+
+// NewGmailWatcher returns the Gmail adapter as a MailboxWatcher for push
+// notification handling.
+func NewGmailWatcher(config Config) outgoing.MailboxWatcher {
+	return newGmailRepository(config)
+}
+
+func (r *gmailRepository) EmailAddress(ctx context.Context) (string, error) {
+	var profile ProfileResponse
+	if err := r.doJSON(ctx, http.MethodGet, r.userURL()+"/profile", nil, &profile); err != nil {
+		return "", fmt.Errorf("failed to get profile: %w", err)
+	}
+	return profile.EmailAddress, nil
+}
+
+// Watch registers (or re-registers) users.watch so Gmail publishes mailbox
+// changes to the Pub/Sub topic. Registrations lapse after seven days.
+func (r *gmailRepository) Watch(ctx context.Context, watchReq entities.WatchRequest) (*entities.WatchState, error) {
+	emailAddress, err := r.EmailAddress(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body := WatchRequest{TopicName: watchReq.Topic, LabelIDs: watchReq.LabelIDs}
+	if len(body.LabelIDs) > 0 {
+		body.LabelFilterBehavior = "include"
+	}
+
+	var watchResp WatchResponse
+	if err := r.doJSON(ctx, http.MethodPost, r.userURL()+"/watch", body, &watchResp); err != nil {
+		return nil, fmt.Errorf("failed to register watch: %w", err)
+	}
+
+	historyID, err := strconv.ParseUint(watchResp.HistoryID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid historyId %q: %w", watchResp.HistoryID, err)
+	}
+
+	return &entities.WatchState{
+		EmailAddress: emailAddress,
+		Topic:        watchReq.Topic,
+		HistoryID:    historyID,
+		Expiration:   r.parseInternalDate(watchResp.Expiration),
+		UpdatedAt:    time.Now().UTC(),
+	}, nil
+}
+
+// FetchHistory returns the messages added since startHistoryID together with
+// the mailbox's current history ID. A start ID Gmail no longer retains is
+// reported as entities.ErrNotFound and needs a full sync.
+func (r *gmailRepository) FetchHistory(ctx context.Context, startHistoryID uint64) (*entities.EmailList, uint64, error) {
+	var (
+		messageIDs []string
+		seen       = make(map[string]bool)
+		latest     = startHistoryID
+		pageToken  string
+	)
+
+	for {
+		params := url.Values{}
+		params.Add("startHistoryId", strconv.FormatUint(startHistoryID, 10))
+		params.Add("historyTypes", "messageAdded")
+		if pageToken != "" {
+			params.Add("pageToken", pageToken)
+		}
+
+		var historyResp HistoryListResponse
+		apiURL := r.userURL() + "/history?" + params.Encode()
+		if err := r.doJSON(ctx, http.MethodGet, apiURL, nil, &historyResp); err != nil {
+			return nil, 0, fmt.Errorf("failed to list history: %w", err)
+		}
+
+		for _, record := range historyResp.History {
+			for _, added := range record.MessagesAdded {
+				if !seen[added.Message.ID] {
+					seen[added.Message.ID] = true
+					messageIDs = append(messageIDs, added.Message.ID)
+				}
+			}
+		}
+		if id, err := strconv.ParseUint(historyResp.HistoryID, 10, 64); err == nil && id > latest {
+			latest = id
+		}
+
+		if historyResp.NextPageToken == "" {
+			break
+		}
+		pageToken = historyResp.NextPageToken
+	}
+
+	var emails []entities.EmailMessage
+	for _, msgID := range messageIDs {
+		email, err := r.getEmailContent(ctx, msgID)
+		if err != nil {
+			r.logger.WarnContext(ctx, "failed to get email", "message_id", msgID, "error", err)
+			continue
+		}
+		emails = append(emails, *email)
+	}
+
+	return &entities.EmailList{
+		Emails:     emails,
+		TotalCount: len(emails),
+	}, latest, nil
+}
+
+// doJSON sends an authorized request with an optional JSON body and decodes a
+// JSON response into out. A 404 is reported as entities.ErrNotFound.
+func (r *gmailRepository) doJSON(ctx context.Context, method, apiURL string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+r.tokenProvider.GetAccessToken())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return entities.ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+type ProfileResponse struct {
+	EmailAddress  string `json:"emailAddress"`
+	MessagesTotal int    `json:"messagesTotal"`
+	ThreadsTotal  int    `json:"threadsTotal"`
+	HistoryID     string `json:"historyId"`
+}
+
+type WatchRequest struct {
+	TopicName           string   `json:"topicName"`
+	LabelIDs            []string `json:"labelIds,omitempty"`
+	LabelFilterBehavior string   `json:"labelFilterBehavior,omitempty"`
+}
+
+type WatchResponse struct {
+	HistoryID  string `json:"historyId"`
+	Expiration string `json:"expiration"`
+}
+
+type HistoryRecord struct {
+	ID            string `json:"id"`
+	MessagesAdded []struct {
+		Message MessageRef `json:"message"`
+	} `json:"messagesAdded"`
+}
+
+type HistoryListResponse struct {
+	History       []HistoryRecord `json:"history"`
+	NextPageToken string          `json:"nextPageToken"`
+	HistoryID     string          `json:"historyId"`
+}
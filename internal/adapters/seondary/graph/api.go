@@ -0,0 +1,145 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// errConflict is a 409, e.g. creating a category that already exists.
+var errConflict = errors.New("conflict")
+
+// maxRetryAfter caps how long a throttled request waits before its single
+// retry.
+const maxRetryAfter = 30 * time.Second
+
+// doJSON sends an authorized request with an optional JSON body and decodes a
+// JSON response into out. A 404 is reported as entities.ErrNotFound.
+func (r *graphRepository) doJSON(ctx context.Context, method, apiURL string, body, out interface{}, header http.Header) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	resp, err := r.do(ctx, method, apiURL, payload, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (r *graphRepository) doRaw(ctx context.Context, apiURL string) ([]byte, error) {
+	resp, err := r.do(ctx, http.MethodGet, apiURL, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// do sends the request, retrying once when Graph throttles with 429 or 503
+// and a short Retry-After. Non-2xx responses are returned as errors.
+func (r *graphRepository) do(ctx context.Context, method, apiURL string, payload []byte, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, apiURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Authorization", "Bearer "+r.config.TokenProvider.GetAccessToken())
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return resp, nil
+		}
+
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, entities.ErrNotFound
+		case http.StatusConflict:
+			return nil, errConflict
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			wait := retryAfter(resp.Header.Get("Retry-After"))
+			if attempt == 0 && wait <= maxRetryAfter {
+				select {
+				case <-time.After(wait):
+					continue
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		}
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+}
+
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+type messageList struct {
+	Value     []message `json:"value"`
+	NextLink  string    `json:"@odata.nextLink"`
+	DeltaLink string    `json:"@odata.deltaLink"`
+}
+
+type message struct {
+	ID                string          `json:"id"`
+	ConversationID    string          `json:"conversationId"`
+	InternetMessageID string          `json:"internetMessageId"`
+	Subject           string          `json:"subject"`
+	ReceivedDateTime  string          `json:"receivedDateTime"`
+	IsRead            bool            `json:"isRead"`
+	Categories        []string        `json:"categories"`
+	Flag              *flag           `json:"flag,omitempty"`
+	HasAttachments    bool            `json:"hasAttachments"`
+	ParentFolderID    string          `json:"parentFolderId"`
+	Removed           json.RawMessage `json:"@removed,omitempty"`
+}
+
+type flag struct {
+	FlagStatus string `json:"flagStatus"`
+}
+
+type attachmentList struct {
+	Value []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+		Size        int64  `json:"size"`
+		IsInline    bool   `json:"isInline"`
+	} `json:"value"`
+}
@@ -0,0 +1,278 @@
+package graph
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// messageFields is the $select used for every message listing; the content
+// itself comes from /$value.
+const messageFields = "id,conversationId,internetMessageId,subject,receivedDateTime,isRead,categories,flag,hasAttachments,parentFolderId"
+
+type Config struct {
+	// BaseURL defaults to DefaultBaseURL; tests point it at a local fake.
+	BaseURL string
+	// User is the user ID or principal name of the mailbox. Application
+	// permissions have no /me, so it is always addressed explicitly.
+	User          string
+	Folder        string
+	ArchiveFolder string
+	TokenProvider outgoing.TokenProvider
+	HTTPClient    *http.Client
+	Logger        *slog.Logger
+}
+
+type graphRepository struct {
+	config Config
+	client *http.Client
+}
+
+func NewGraphRepository(config Config) outgoing.EmailRepository {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Folder == "" {
+		config.Folder = "inbox"
+	}
+	if config.ArchiveFolder == "" {
+		config.ArchiveFolder = "archive"
+	}
+	config.Logger = logging.OrDefault(config.Logger)
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &graphRepository{config: config, client: client}
+}
+
+// FetchEmails pages through the folder. Without a query it runs a delta
+// query: the final NextPageToken is the delta link, and passing it back later
+// returns only messages added since. A query is sent as an OData $filter,
+// e.g. "receivedDateTime ge 2024-01-01T00:00:00Z", and pages normally.
+func (r *graphRepository) FetchEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, error) {
+	pageURL, err := r.pageURL(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []entities.EmailMessage
+	failed := 0
+	nextToken := ""
+	for pageURL != "" {
+		var page messageList
+		if err := r.doJSON(ctx, http.MethodGet, pageURL, nil, &page, pageSizeHeader(filter.MaxResults)); err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+		r.config.Logger.DebugContext(ctx, "listed graph messages", "count", len(page.Value))
+
+		for _, msg := range page.Value {
+			// Delta results include deletions and moves out of the folder.
+			if msg.Removed != nil {
+				continue
+			}
+			email, err := r.getEmail(ctx, msg)
+			if err != nil {
+				r.config.Logger.WarnContext(ctx, "failed to get email", "message_id", msg.ID, "error", err)
+				failed++
+				continue
+			}
+			if filter.OnlyPromotional && !email.IsPromotional {
+				continue
+			}
+			emails = append(emails, *email)
+		}
+
+		pageURL = page.NextLink
+		nextToken = encodePageToken(page.NextLink, page.DeltaLink)
+		if filter.MaxResults > 0 && len(emails) >= filter.MaxResults {
+			break
+		}
+	}
+
+	return &entities.EmailList{
+		Emails:        emails,
+		NextPageToken: nextToken,
+		TotalCount:    len(emails),
+		Failed:        failed,
+	}, nil
+}
+
+func (r *graphRepository) pageURL(filter entities.EmailFilter) (string, error) {
+	if filter.PageToken != "" {
+		return r.decodePageToken(filter.PageToken)
+	}
+
+	params := url.Values{}
+	params.Set("$select", messageFields)
+	if filter.Query == "" {
+		return r.folderURL() + "/messages/delta?" + params.Encode(), nil
+	}
+
+	params.Set("$filter", filter.Query)
+	if filter.MaxResults > 0 {
+		params.Set("$top", fmt.Sprint(filter.MaxResults))
+	}
+	return r.folderURL() + "/messages?" + params.Encode(), nil
+}
+
+// FetchThread returns the messages of an Outlook conversation, oldest first.
+func (r *graphRepository) FetchThread(ctx context.Context, threadID string) ([]entities.EmailMessage, error) {
+	params := url.Values{}
+	params.Set("$select", messageFields)
+	params.Set("$filter", fmt.Sprintf("conversationId eq '%s'", strings.ReplaceAll(threadID, "'", "''")))
+	pageURL := r.userURL() + "/messages?" + params.Encode()
+
+	var emails []entities.EmailMessage
+	for pageURL != "" {
+		var page messageList
+		if err := r.doJSON(ctx, http.MethodGet, pageURL, nil, &page, nil); err != nil {
+			return nil, fmt.Errorf("failed to list conversation: %w", err)
+		}
+		for _, msg := range page.Value {
+			email, err := r.getEmail(ctx, msg)
+			if err != nil {
+				r.config.Logger.WarnContext(ctx, "failed to get email", "message_id", msg.ID, "error", err)
+				continue
+			}
+			emails = append(emails, *email)
+		}
+		pageURL = page.NextLink
+	}
+
+	// Graph can't order by receivedDateTime together with this filter.
+	sort.SliceStable(emails, func(i, j int) bool { return emails[i].ReceivedAt.Before(emails[j].ReceivedAt) })
+	return emails, nil
+}
+
+func (r *graphRepository) FetchRawEmail(ctx context.Context, messageID string) ([]byte, error) {
+	raw, err := r.doRaw(ctx, r.messageURL(messageID)+"/$value")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MIME content of %s: %w", messageID, err)
+	}
+	return raw, nil
+}
+
+// getEmail parses the MIME content of msg and overlays what only Graph
+// knows: read state, categories, flag, received time and attachments.
+func (r *graphRepository) getEmail(ctx context.Context, msg message) (*entities.EmailMessage, error) {
+	raw, err := r.FetchRawEmail(ctx, msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	email, err := parser.ParseMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	email.ID = msg.ID
+	email.ThreadID = msg.ConversationID
+	if email.MessageID == "" {
+		email.MessageID = parser.ParseMessageID(msg.InternetMessageID)
+	}
+	if received, err := time.Parse(time.RFC3339, msg.ReceivedDateTime); err == nil {
+		email.ReceivedAt = received.UTC()
+		if email.Date.IsZero() || email.InvalidDate {
+			email.Date = email.ReceivedAt
+		}
+	}
+	email.Labels = labelsOf(msg)
+
+	if msg.HasAttachments {
+		if email.Attachments, err = r.attachments(ctx, msg.ID); err != nil {
+			r.config.Logger.WarnContext(ctx, "failed to list attachments", "message_id", msg.ID, "error", err)
+		}
+	}
+
+	classifier.Default().Apply(email)
+	return email, nil
+}
+
+func (r *graphRepository) attachments(ctx context.Context, messageID string) ([]entities.Attachment, error) {
+	params := url.Values{}
+	params.Set("$select", "id,name,contentType,size,isInline")
+
+	var list attachmentList
+	if err := r.doJSON(ctx, http.MethodGet, r.messageURL(messageID)+"/attachments?"+params.Encode(), nil, &list, nil); err != nil {
+		return nil, err
+	}
+
+	attachments := make([]entities.Attachment, 0, len(list.Value))
+	for _, a := range list.Value {
+		attachments = append(attachments, entities.Attachment{
+			ID:          a.ID,
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			Inline:      a.IsInline,
+		})
+	}
+	return attachments, nil
+}
+
+// labelsOf maps Graph state onto the Gmail-style labels used elsewhere.
+func labelsOf(msg message) []string {
+	var labels []string
+	if !msg.IsRead {
+		labels = append(labels, labelUnread)
+	}
+	if msg.Flag != nil && msg.Flag.FlagStatus == "flagged" {
+		labels = append(labels, labelStarred)
+	}
+	return append(labels, msg.Categories...)
+}
+
+func (r *graphRepository) userURL() string {
+	return r.config.BaseURL + "/users/" + url.PathEscape(r.config.User)
+}
+
+func (r *graphRepository) folderURL() string {
+	return r.userURL() + "/mailFolders/" + url.PathEscape(r.config.Folder)
+}
+
+func (r *graphRepository) messageURL(messageID string) string {
+	return r.userURL() + "/messages/" + url.PathEscape(messageID)
+}
+
+// Page tokens carry Graph's own next/delta links. They are only followed
+// when they point back at the configured API, so a crafted token can't send
+// the bearer token elsewhere.
+func encodePageToken(nextLink, deltaLink string) string {
+	switch {
+	case nextLink != "":
+		return "graph1:" + nextLink
+	case deltaLink != "":
+		return "graph1:" + deltaLink
+	}
+	return ""
+}
+
+func (r *graphRepository) decodePageToken(token string) (string, error) {
+	link, ok := strings.CutPrefix(token, "graph1:")
+	if !ok || !strings.HasPrefix(link, r.config.BaseURL+"/") {
+		return "", fmt.Errorf("invalid Graph page token")
+	}
+	return link, nil
+}
+
+// pageSizeHeader asks Graph for pages of at most n messages; delta queries
+// ignore $top.
+func pageSizeHeader(n int) http.Header {
+	if n <= 0 {
+		return nil
+	}
+	return http.Header{"Prefer": {fmt.Sprintf("odata.maxpagesize=%d", n)}}
+}
@@ -0,0 +1,239 @@
+package graph
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/graph/graphfake"
+	"email-parser-poc/internal/adapters/seondary/token"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const user = "me@example.com"
+
+var start = time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+
+func raw(n int) []byte {
+	return []byte(fmt.Sprintf("From: Shop <deals@shop.example>\r\n"+
+		"To: me@example.com\r\n"+
+		"Subject: Offer %d\r\n"+
+		"Date: Mon, 3 Mar 2025 09:%02d:00 +0000\r\n"+
+		"Message-ID: <offer-%d@shop.example>\r\n"+
+		"List-Unsubscribe: <mailto:u@shop.example>\r\n"+
+		"\r\n"+
+		"Weekly sale: 20%% discount.\r\n", n, n, n))
+}
+
+func newTestRepository(t *testing.T, server *graphfake.Server) *graphRepository {
+	t.Helper()
+	t.Cleanup(server.Close)
+	return NewGraphRepository(Config{
+		BaseURL:       server.BaseURL(),
+		User:          user,
+		TokenProvider: token.NewStaticTokenProvider(server.Token),
+	}).(*graphRepository)
+}
+
+// addOffers stores n messages received a minute apart from start.
+func addOffers(server *graphfake.Server, from, to int) {
+	for n := from; n <= to; n++ {
+		server.AddMessage(graphfake.Message{Raw: raw(n), ReceivedAt: start.Add(time.Duration(n) * time.Minute)})
+	}
+}
+
+func subjects(emails []entities.EmailMessage) []string {
+	var result []string
+	for _, email := range emails {
+		result = append(result, email.Subject)
+	}
+	return result
+}
+
+func TestFetchEmailsDeltaPaging(t *testing.T) {
+	server := graphfake.NewServer()
+	repo := newTestRepository(t, server)
+	ctx := context.Background()
+	addOffers(server, 1, 5)
+	deltaPath := "/v1.0/users/" + user + "/mailFolders/inbox/messages/delta"
+
+	// Page by page: each call stops once it has MaxResults messages and
+	// hands back a token for the rest.
+	list, err := repo.FetchEmails(ctx, entities.EmailFilter{MaxResults: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(list.Emails), []string{"Offer 1", "Offer 2"}; !slices.Equal(got, want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+	list, err = repo.FetchEmails(ctx, entities.EmailFilter{MaxResults: 10, PageToken: list.NextPageToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(list.Emails), []string{"Offer 3", "Offer 4", "Offer 5"}; !slices.Equal(got, want) {
+		t.Errorf("rest = %v, want %v", got, want)
+	}
+	if n := server.Requests(http.MethodGet, deltaPath); n != 2 {
+		t.Errorf("delta requests = %d, want 2", n)
+	}
+
+	// The final token is the delta link: only new messages come back.
+	deltaToken := list.NextPageToken
+	addOffers(server, 6, 6)
+	list, err = repo.FetchEmails(ctx, entities.EmailFilter{PageToken: deltaToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(list.Emails), []string{"Offer 6"}; !slices.Equal(got, want) {
+		t.Errorf("delta = %v, want %v", got, want)
+	}
+
+	email := list.Emails[0]
+	if !email.ReceivedAt.Equal(start.Add(6*time.Minute)) || !slices.Contains(email.Labels, labelUnread) || !email.IsPromotional {
+		t.Errorf("received = %v, labels = %v, promotional = %v", email.ReceivedAt, email.Labels, email.IsPromotional)
+	}
+}
+
+func TestFetchEmailsFilter(t *testing.T) {
+	server := graphfake.NewServer()
+	repo := newTestRepository(t, server)
+	addOffers(server, 1, 4)
+
+	query := "receivedDateTime ge " + start.Add(3*time.Minute).Format(time.RFC3339)
+	list, err := repo.FetchEmails(context.Background(), entities.EmailFilter{Query: query, MaxResults: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(list.Emails), []string{"Offer 3", "Offer 4"}; !slices.Equal(got, want) {
+		t.Errorf("filtered = %v, want %v", got, want)
+	}
+	if n := server.Requests(http.MethodGet, "/v1.0/users/"+user+"/mailFolders/inbox/messages"); n != 1 {
+		t.Errorf("list requests = %d, want 1", n)
+	}
+}
+
+func TestFetchRawEmail(t *testing.T) {
+	server := graphfake.NewServer()
+	repo := newTestRepository(t, server)
+	msg := server.AddMessage(graphfake.Message{Raw: raw(1)})
+
+	got, err := repo.FetchRawEmail(context.Background(), msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(raw(1)) {
+		t.Errorf("raw = %q", got)
+	}
+	if n := server.Requests(http.MethodGet, "/v1.0/users/"+user+"/messages/"+msg.ID+"/$value"); n != 1 {
+		t.Errorf("$value requests = %d, want 1", n)
+	}
+
+	if _, err := repo.FetchRawEmail(context.Background(), "missing"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("missing message: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchThread(t *testing.T) {
+	server := graphfake.NewServer()
+	repo := newTestRepository(t, server)
+	server.AddMessage(graphfake.Message{Raw: raw(2), ConversationID: "conv'1", ReceivedAt: start.Add(2 * time.Minute)})
+	server.AddMessage(graphfake.Message{Raw: raw(1), ConversationID: "conv'1", ReceivedAt: start.Add(time.Minute)})
+	server.AddMessage(graphfake.Message{Raw: raw(3)})
+
+	thread, err := repo.FetchThread(context.Background(), "conv'1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(thread), []string{"Offer 1", "Offer 2"}; !slices.Equal(got, want) {
+		t.Errorf("thread = %v, want %v", got, want)
+	}
+}
+
+func TestRetryOnThrottling(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   graphfake.Fault
+		wantErr string
+	}{
+		{name: "429 once", fault: graphfake.Fault{Path: "/mailFolders", Status: http.StatusTooManyRequests, RetryAfter: 1, Times: 1}},
+		{name: "503 once", fault: graphfake.Fault{Path: "/mailFolders", Status: http.StatusServiceUnavailable, Times: 1}},
+		{name: "429 twice", fault: graphfake.Fault{Path: "/mailFolders", Status: http.StatusTooManyRequests, RetryAfter: 1, Times: 2}, wantErr: "status 429"},
+		{name: "retry after too long", fault: graphfake.Fault{Path: "/mailFolders", Status: http.StatusTooManyRequests, RetryAfter: 120}, wantErr: "status 429"},
+		{name: "500", fault: graphfake.Fault{Path: "/mailFolders", Status: http.StatusInternalServerError}, wantErr: "status 500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := graphfake.NewServer()
+			repo := newTestRepository(t, server)
+			addOffers(server, 1, 1)
+			server.InjectFault(tt.fault)
+
+			list, err := repo.FetchEmails(context.Background(), entities.EmailFilter{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Emails) != 1 {
+				t.Errorf("got %d emails after retry", len(list.Emails))
+			}
+		})
+	}
+}
+
+func TestPageTokenMustPointAtAPI(t *testing.T) {
+	server := graphfake.NewServer()
+	repo := newTestRepository(t, server)
+
+	for _, pageToken := range []string{
+		"graph1:https://attacker.example/v1.0/users/me/messages",
+		// A prefix of the base URL is not enough.
+		"graph1:" + server.BaseURL() + ".attacker.example/messages",
+		server.BaseURL() + "/users/me/messages",
+	} {
+		if _, err := repo.FetchEmails(context.Background(), entities.EmailFilter{PageToken: pageToken}); err == nil {
+			t.Errorf("token %q was accepted", pageToken)
+		}
+	}
+}
+
+func TestMutations(t *testing.T) {
+	server := graphfake.NewServer()
+	repo := newTestRepository(t, server)
+	ctx := context.Background()
+	msg := server.AddMessage(graphfake.Message{Raw: raw(1), Categories: []string{"Old"}})
+
+	if err := repo.ModifyLabels(ctx, msg.ID, []string{"Deals", labelStarred}, []string{"old", labelUnread}); err != nil {
+		t.Fatal(err)
+	}
+	stored := server.Message(msg.ID)
+	if !slices.Equal(stored.Categories, []string{"Deals"}) || !stored.IsRead || !stored.Flagged {
+		t.Errorf("after modify: categories = %v, read = %v, flagged = %v", stored.Categories, stored.IsRead, stored.Flagged)
+	}
+
+	if _, err := repo.CreateLabel(ctx, "Deals"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateLabel(ctx, "deals"); err != nil {
+		t.Errorf("existing category: %v", err)
+	}
+
+	if err := repo.Archive(ctx, msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if server.Message(msg.ID) != nil {
+		t.Errorf("archived message kept its ID")
+	}
+	if err := repo.MarkRead(ctx, msg.ID); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("mark read after move: err = %v, want ErrNotFound", err)
+	}
+}
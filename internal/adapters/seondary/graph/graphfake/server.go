@@ -0,0 +1,529 @@
+// Package graphfake is an in-process stand-in for the Microsoft Graph mail
+// endpoints and the token endpoint, for exercising the graph adapter without
+// a tenant. It implements just enough of Graph's behaviour: delta paging,
+// conversation and received-time filters, MIME content, attachments,
+// categories and moves. Faults can be injected to simulate throttling.
+package graphfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const defaultPageSize = 10
+
+type Attachment struct {
+	ID          string
+	Name        string
+	ContentType string
+	Size        int64
+	IsInline    bool
+}
+
+// Message is one stored message. Moving it gives it a new ID, as in Graph.
+type Message struct {
+	ID             string
+	ConversationID string
+	Folder         string
+	Raw            []byte
+	ReceivedAt     time.Time
+	IsRead         bool
+	Flagged        bool
+	Categories     []string
+	Attachments    []Attachment
+
+	seq int
+}
+
+// Fault makes matching API requests fail before they are handled.
+type Fault struct {
+	// Method and Path select requests; empty matches any. Path is a prefix
+	// of the path below /v1.0/users/{user}, e.g. "/messages".
+	Method string
+	Path   string
+	Status int
+	// RetryAfter is sent as the Retry-After header, in seconds, when set.
+	RetryAfter int
+	// Times limits how often the fault fires; 0 means until ClearFaults.
+	Times int
+}
+
+type Server struct {
+	*httptest.Server
+
+	// Token is what the token endpoint issues and the API accepts.
+	Token        string
+	ClientID     string
+	ClientSecret string
+
+	mu         sync.Mutex
+	seq        int
+	messages   map[string]*Message
+	categories map[string]bool
+	faults     []*Fault
+	// Requests counts calls per "METHOD path" for assertions.
+	requests map[string]int
+}
+
+func NewServer() *Server {
+	s := &Server{
+		Token:        "fake-graph-token",
+		ClientID:     "fake-client",
+		ClientSecret: "fake-secret",
+		messages:     make(map[string]*Message),
+		categories:   make(map[string]bool),
+		requests:     make(map[string]int),
+	}
+
+	r := chi.NewRouter()
+	r.Post("/token", s.issueToken)
+	r.Route("/v1.0/users/{user}", func(r chi.Router) {
+		r.Use(s.authorize)
+		r.Get("/mailFolders/{folder}/messages/delta", s.delta)
+		r.Get("/mailFolders/{folder}/messages", s.list)
+		r.Get("/messages", s.list)
+		r.Get("/messages/{id}", s.get)
+		r.Patch("/messages/{id}", s.patch)
+		r.Get("/messages/{id}/$value", s.mime)
+		r.Get("/messages/{id}/attachments", s.attachments)
+		r.Post("/messages/{id}/move", s.move)
+		r.Post("/outlook/masterCategories", s.createCategory)
+	})
+
+	s.Server = httptest.NewServer(r)
+	return s
+}
+
+// BaseURL is the Graph API root to configure the adapter with.
+func (s *Server) BaseURL() string {
+	return s.URL + "/v1.0"
+}
+
+func (s *Server) TokenURL() string {
+	return s.URL + "/token"
+}
+
+// AddMessage stores a copy of msg, filling in ID, folder, conversation and
+// received time when unset, and returns the stored message.
+func (s *Server) AddMessage(msg Message) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	stored := msg
+	stored.seq = s.seq
+	if stored.ID == "" {
+		stored.ID = fmt.Sprintf("AAMk%04d", s.seq)
+	}
+	if stored.Folder == "" {
+		stored.Folder = "inbox"
+	}
+	if stored.ConversationID == "" {
+		stored.ConversationID = "conv-" + stored.ID
+	}
+	if stored.ReceivedAt.IsZero() {
+		stored.ReceivedAt = time.Now().UTC().Truncate(time.Second)
+	}
+	s.messages[stored.ID] = &stored
+
+	copied := stored
+	return &copied
+}
+
+// Message returns a copy of the stored message, or nil.
+func (s *Server) Message(id string) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[id]
+	if !ok {
+		return nil
+	}
+	copied := *msg
+	return &copied
+}
+
+func (s *Server) Requests(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method+" "+path]
+}
+
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := fault
+	s.faults = append(s.faults, &copied)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.PostForm.Get("grant_type") != "client_credentials" ||
+		r.PostForm.Get("client_id") != s.ClientID ||
+		r.PostForm.Get("client_secret") != s.ClientSecret {
+		writeError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": s.Token,
+		"token_type":   "Bearer",
+		"expires_in":   3599,
+	})
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		fault := s.matchFault(r)
+		s.mu.Unlock()
+
+		if fault != nil {
+			if fault.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+			}
+			writeError(w, fault.Status, "injected fault")
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+s.Token {
+			writeError(w, http.StatusUnauthorized, "InvalidAuthenticationToken")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) matchFault(r *http.Request) *Fault {
+	path := ""
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/v1.0/users/"); ok {
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			path = rest[i:]
+		}
+	}
+
+	for i, fault := range s.faults {
+		if fault.Method != "" && fault.Method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+		fired := *fault
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &fired
+	}
+	return nil
+}
+
+// delta pages through the folder in insertion order. The final page carries
+// a delta link whose token is the last sequence number seen, so the next
+// round only returns messages added (or moved in) since.
+func (s *Server) delta(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folder := chi.URLParam(r, "folder")
+	query := r.URL.Query()
+	after, _ := strconv.Atoi(query.Get("$deltatoken"))
+	skip, _ := strconv.Atoi(query.Get("$skiptoken"))
+	if skip > after {
+		after = skip
+	}
+
+	var matched []*Message
+	for _, msg := range s.sorted() {
+		if msg.Folder == folder && msg.seq > after {
+			matched = append(matched, msg)
+		}
+	}
+
+	pageSize := preferredPageSize(r)
+	page := matched
+	if len(page) > pageSize {
+		page = page[:pageSize]
+	}
+
+	resp := map[string]interface{}{"value": s.render(page)}
+	base := s.URL + r.URL.Path
+	if len(matched) > len(page) {
+		resp["@odata.nextLink"] = base + "?$skiptoken=" + strconv.Itoa(page[len(page)-1].seq)
+	} else {
+		resp["@odata.deltaLink"] = base + "?$deltatoken=" + strconv.Itoa(s.seq)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+var (
+	conversationFilter = regexp.MustCompile(`^conversationId eq '((?:[^']|'')*)'$`)
+	receivedFilter     = regexp.MustCompile(`^receivedDateTime (ge|lt) (\S+)$`)
+)
+
+// list supports no filter, "conversationId eq '...'" or
+// "receivedDateTime ge|lt <RFC 3339 time>", plus $top and $skip paging.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	folder := chi.URLParam(r, "folder")
+	conversation := ""
+	var receivedOp string
+	var receivedAt time.Time
+	if filter := query.Get("$filter"); filter != "" {
+		if m := conversationFilter.FindStringSubmatch(filter); m != nil {
+			conversation = strings.ReplaceAll(m[1], "''", "'")
+		} else if m := receivedFilter.FindStringSubmatch(filter); m != nil {
+			t, err := time.Parse(time.RFC3339, m[2])
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid receivedDateTime")
+				return
+			}
+			receivedOp, receivedAt = m[1], t
+		} else {
+			writeError(w, http.StatusBadRequest, "fake only supports conversationId and receivedDateTime filters")
+			return
+		}
+	}
+
+	var matched []*Message
+	for _, msg := range s.sorted() {
+		if folder != "" && msg.Folder != folder {
+			continue
+		}
+		if conversation != "" && msg.ConversationID != conversation {
+			continue
+		}
+		if receivedOp == "ge" && msg.ReceivedAt.Before(receivedAt) || receivedOp == "lt" && !msg.ReceivedAt.Before(receivedAt) {
+			continue
+		}
+		matched = append(matched, msg)
+	}
+
+	top, _ := strconv.Atoi(query.Get("$top"))
+	if top <= 0 {
+		top = defaultPageSize
+	}
+	skip, _ := strconv.Atoi(query.Get("$skip"))
+	if skip > len(matched) {
+		skip = len(matched)
+	}
+	end := skip + top
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	resp := map[string]interface{}{"value": s.render(matched[skip:end])}
+	if end < len(matched) {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("$skip", strconv.Itoa(end))
+		resp["@odata.nextLink"] = s.URL + r.URL.Path + "?" + next.Encode()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	s.withMessage(w, r, func(msg *Message) {
+		writeJSON(w, http.StatusOK, s.render([]*Message{msg})[0])
+	})
+}
+
+func (s *Server) mime(w http.ResponseWriter, r *http.Request) {
+	s.withMessage(w, r, func(msg *Message) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(msg.Raw)
+	})
+}
+
+func (s *Server) attachments(w http.ResponseWriter, r *http.Request) {
+	s.withMessage(w, r, func(msg *Message) {
+		value := make([]map[string]interface{}, 0, len(msg.Attachments))
+		for i, a := range msg.Attachments {
+			id := a.ID
+			if id == "" {
+				id = fmt.Sprintf("%s-att%d", msg.ID, i)
+			}
+			value = append(value, map[string]interface{}{
+				"id":          id,
+				"name":        a.Name,
+				"contentType": a.ContentType,
+				"size":        a.Size,
+				"isInline":    a.IsInline,
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"value": value})
+	})
+}
+
+func (s *Server) patch(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IsRead     *bool     `json:"isRead"`
+		Categories *[]string `json:"categories"`
+		Flag       *struct {
+			FlagStatus string `json:"flagStatus"`
+		} `json:"flag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.withMessage(w, r, func(msg *Message) {
+		if body.IsRead != nil {
+			msg.IsRead = *body.IsRead
+		}
+		if body.Categories != nil {
+			msg.Categories = *body.Categories
+		}
+		if body.Flag != nil {
+			msg.Flagged = body.Flag.FlagStatus == "flagged"
+		}
+		writeJSON(w, http.StatusOK, s.render([]*Message{msg})[0])
+	})
+}
+
+func (s *Server) move(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DestinationID string `json:"destinationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.DestinationID == "" {
+		writeError(w, http.StatusBadRequest, "destinationId is required")
+		return
+	}
+
+	s.withMessage(w, r, func(msg *Message) {
+		delete(s.messages, msg.ID)
+		s.seq++
+		msg.seq = s.seq
+		msg.ID = fmt.Sprintf("AAMk%04d", s.seq)
+		msg.Folder = strings.ToLower(body.DestinationID)
+		s.messages[msg.ID] = msg
+		writeJSON(w, http.StatusCreated, s.render([]*Message{msg})[0])
+	})
+}
+
+func (s *Server) createCategory(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DisplayName string `json:"displayName"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(body.DisplayName)
+	if s.categories[key] {
+		writeError(w, http.StatusConflict, "category already exists")
+		return
+	}
+	s.categories[key] = true
+	writeJSON(w, http.StatusCreated, map[string]string{"displayName": body.DisplayName})
+}
+
+func (s *Server) withMessage(w http.ResponseWriter, r *http.Request, fn func(msg *Message)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[chi.URLParam(r, "id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound")
+		return
+	}
+	fn(msg)
+}
+
+func (s *Server) sorted() []*Message {
+	list := make([]*Message, 0, len(s.messages))
+	for _, msg := range s.messages {
+		list = append(list, msg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+	return list
+}
+
+// render produces the fields the adapter $selects.
+func (s *Server) render(messages []*Message) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		flagStatus := "notFlagged"
+		if msg.Flagged {
+			flagStatus = "flagged"
+		}
+		categories := msg.Categories
+		if categories == nil {
+			categories = []string{}
+		}
+		out = append(out, map[string]interface{}{
+			"id":                msg.ID,
+			"conversationId":    msg.ConversationID,
+			"internetMessageId": internetMessageID(msg.Raw),
+			"receivedDateTime":  msg.ReceivedAt.UTC().Format(time.RFC3339),
+			"isRead":            msg.IsRead,
+			"categories":        categories,
+			"flag":              map[string]string{"flagStatus": flagStatus},
+			"hasAttachments":    len(msg.Attachments) > 0,
+			"parentFolderId":    msg.Folder,
+		})
+	}
+	return out
+}
+
+func internetMessageID(raw []byte) string {
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Message-ID") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func preferredPageSize(r *http.Request) int {
+	for _, pref := range strings.Split(r.Header.Get("Prefer"), ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(pref), "odata.maxpagesize="); ok {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				return n
+			}
+		}
+	}
+	return defaultPageSize
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"code": http.StatusText(status), "message": message},
+	})
+}
@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Gmail-style labels with a native Graph equivalent.
+const (
+	labelInbox   = "INBOX"
+	labelUnread  = "UNREAD"
+	labelStarred = "STARRED"
+)
+
+// ModifyLabels maps UNREAD to isRead, STARRED to the follow-up flag and
+// removing INBOX to an archive move. Every other label is an Outlook
+// category.
+func (r *graphRepository) ModifyLabels(ctx context.Context, messageID string, add, remove []string) error {
+	patch := map[string]interface{}{}
+	var addCategories, removeCategories []string
+	archive := false
+
+	for _, label := range add {
+		switch strings.ToUpper(label) {
+		case labelUnread:
+			patch["isRead"] = false
+		case labelStarred:
+			patch["flag"] = flag{FlagStatus: "flagged"}
+		case labelInbox:
+		default:
+			addCategories = append(addCategories, label)
+		}
+	}
+	for _, label := range remove {
+		switch strings.ToUpper(label) {
+		case labelUnread:
+			patch["isRead"] = true
+		case labelStarred:
+			patch["flag"] = flag{FlagStatus: "notFlagged"}
+		case labelInbox:
+			archive = true
+		default:
+			removeCategories = append(removeCategories, label)
+		}
+	}
+
+	if len(addCategories) > 0 || len(removeCategories) > 0 {
+		// PATCH replaces the whole list, so merge with what's there.
+		var current message
+		if err := r.doJSON(ctx, http.MethodGet, r.messageURL(messageID)+"?$select=categories", nil, &current, nil); err != nil {
+			return fmt.Errorf("failed to read categories of %s: %w", messageID, err)
+		}
+		patch["categories"] = mergeCategories(current.Categories, addCategories, removeCategories)
+	}
+
+	if len(patch) > 0 {
+		if err := r.doJSON(ctx, http.MethodPatch, r.messageURL(messageID), patch, nil, nil); err != nil {
+			return fmt.Errorf("failed to update %s: %w", messageID, err)
+		}
+	}
+	if archive {
+		return r.Archive(ctx, messageID)
+	}
+	return nil
+}
+
+// CreateLabel adds a master category so the label shows up with a colour in
+// Outlook. Categories can be assigned without one, so an existing category
+// is not an error.
+func (r *graphRepository) CreateLabel(ctx context.Context, name string) (string, error) {
+	body := map[string]string{"displayName": name, "color": "preset0"}
+	err := r.doJSON(ctx, http.MethodPost, r.userURL()+"/outlook/masterCategories", body, nil, nil)
+	if err != nil && !errors.Is(err, errConflict) {
+		return "", fmt.Errorf("failed to create category %q: %w", name, err)
+	}
+	return name, nil
+}
+
+func (r *graphRepository) Archive(ctx context.Context, messageID string) error {
+	return r.move(ctx, messageID, r.config.ArchiveFolder)
+}
+
+func (r *graphRepository) MarkRead(ctx context.Context, messageID string) error {
+	return r.ModifyLabels(ctx, messageID, nil, []string{labelUnread})
+}
+
+func (r *graphRepository) Trash(ctx context.Context, messageID string) error {
+	return r.move(ctx, messageID, "deleteditems")
+}
+
+// move relocates a message. Graph gives the moved copy a new ID.
+func (r *graphRepository) move(ctx context.Context, messageID, folder string) error {
+	body := map[string]string{"destinationId": folder}
+	if err := r.doJSON(ctx, http.MethodPost, r.messageURL(messageID)+"/move", body, nil, nil); err != nil {
+		if errors.Is(err, entities.ErrNotFound) {
+			return fmt.Errorf("message %s: %w", messageID, err)
+		}
+		return fmt.Errorf("failed to move %s to %s: %w", messageID, folder, err)
+	}
+	return nil
+}
+
+func mergeCategories(current, add, remove []string) []string {
+	removed := make(map[string]bool, len(remove))
+	for _, c := range remove {
+		removed[strings.ToLower(c)] = true
+	}
+
+	seen := make(map[string]bool)
+	merged := []string{}
+	for _, c := range append(append([]string{}, current...), add...) {
+		key := strings.ToLower(c)
+		if removed[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, c)
+	}
+	return merged
+}
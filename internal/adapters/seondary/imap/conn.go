@@ -0,0 +1,408 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errEOL marks the end of a response line while reading values.
+var errEOL = errors.New("end of line")
+
+// maxLiteralSize bounds a single literal so a hostile server can't make us
+// allocate without limit.
+const maxLiteralSize = 64 << 20
+
+// response is one server response. Status responses (OK/NO/BAD/BYE/PREAUTH)
+// carry their response code and text; data responses carry parsed fields,
+// where a field is a string (atom or quoted), []byte (literal), nil (NIL) or
+// []interface{} (parenthesised list).
+type response struct {
+	tag    string
+	status string
+	code   string
+	text   string
+	fields []interface{}
+}
+
+type conn struct {
+	netConn net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	tagSeq  int
+	caps    map[string]bool
+}
+
+func newConn(netConn net.Conn) *conn {
+	return &conn{
+		netConn: netConn,
+		r:       bufio.NewReader(netConn),
+		w:       bufio.NewWriter(netConn),
+	}
+}
+
+// bindContext aborts blocked reads and writes when ctx is done.
+func (c *conn) bindContext(ctx context.Context) func() bool {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.netConn.SetDeadline(deadline)
+	}
+	return context.AfterFunc(ctx, func() {
+		c.netConn.SetDeadline(time.Now())
+	})
+}
+
+func (c *conn) close() error {
+	return c.netConn.Close()
+}
+
+func (c *conn) readGreeting() error {
+	resp, err := c.readResponse()
+	if err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if resp.tag != "*" || (resp.status != "OK" && resp.status != "PREAUTH") {
+		return fmt.Errorf("unexpected greeting: %s %s", resp.status, resp.text)
+	}
+	if strings.HasPrefix(resp.code, "CAPABILITY ") {
+		c.setCapabilities(strings.Fields(resp.code)[1:])
+	}
+	return nil
+}
+
+func (c *conn) startTLS(config *tls.Config) error {
+	if _, err := c.execute("STARTTLS"); err != nil {
+		return err
+	}
+	tlsConn := tls.Client(c.netConn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	c.netConn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	c.w = bufio.NewWriter(tlsConn)
+	// Capabilities advertised before STARTTLS must be discarded.
+	c.caps = nil
+	return nil
+}
+
+func (c *conn) capabilities() (map[string]bool, error) {
+	if c.caps != nil {
+		return c.caps, nil
+	}
+	responses, err := c.execute("CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, resp := range responses {
+		if len(resp.fields) > 0 && strings.EqualFold(atom(resp.fields[0]), "CAPABILITY") {
+			for _, f := range resp.fields[1:] {
+				names = append(names, atom(f))
+			}
+		}
+	}
+	c.setCapabilities(names)
+	return c.caps, nil
+}
+
+func (c *conn) setCapabilities(names []string) {
+	c.caps = make(map[string]bool, len(names))
+	for _, name := range names {
+		c.caps[strings.ToUpper(name)] = true
+	}
+}
+
+// execute sends a tagged command and returns the untagged responses received
+// before its completion. NO and BAD completions are returned as errors.
+func (c *conn) execute(command string) ([]*response, error) {
+	return c.executeWithContinuation(command, nil)
+}
+
+// executeWithContinuation is execute for commands that expect "+"
+// continuation requests; onContinue returns the line to send back.
+func (c *conn) executeWithContinuation(command string, onContinue func(text string) (string, error)) ([]*response, error) {
+	c.tagSeq++
+	tag := fmt.Sprintf("A%04d", c.tagSeq)
+
+	if _, err := c.w.WriteString(tag + " " + command + "\r\n"); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	var untagged []*response
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.tag {
+		case "*":
+			if resp.status == "BYE" {
+				return nil, fmt.Errorf("server closed connection: %s", resp.text)
+			}
+			untagged = append(untagged, resp)
+		case "+":
+			reply := ""
+			if onContinue != nil {
+				if reply, err = onContinue(resp.text); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := c.w.WriteString(reply + "\r\n"); err != nil {
+				return nil, err
+			}
+			if err := c.w.Flush(); err != nil {
+				return nil, err
+			}
+		case tag:
+			if resp.status != "OK" {
+				return untagged, &commandError{command: strings.Fields(command)[0], status: resp.status, code: resp.code, text: resp.text}
+			}
+			return untagged, nil
+		}
+	}
+}
+
+type commandError struct {
+	command string
+	status  string
+	code    string
+	text    string
+}
+
+func (e *commandError) Error() string {
+	if e.code != "" {
+		return fmt.Sprintf("%s failed: %s [%s] %s", e.command, e.status, e.code, e.text)
+	}
+	return fmt.Sprintf("%s failed: %s %s", e.command, e.status, e.text)
+}
+
+func (c *conn) readResponse() (*response, error) {
+	tag, endOfLine, err := c.readTag()
+	if err != nil {
+		return nil, err
+	}
+	resp := &response{tag: tag}
+
+	if endOfLine {
+		// Only a bare "+" continuation legitimately ends right after its tag.
+		if tag != "+" {
+			return nil, fmt.Errorf("malformed response %q", tag)
+		}
+		return resp, nil
+	}
+	if resp.tag == "+" {
+		line, err := c.readLine()
+		resp.text = line
+		return resp, err
+	}
+
+	first, err := c.readValue()
+	if err != nil {
+		return nil, err
+	}
+	switch word := strings.ToUpper(atom(first)); word {
+	case "OK", "NO", "BAD", "BYE", "PREAUTH":
+		resp.status = word
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		resp.code, resp.text = splitResponseCode(line)
+		return resp, nil
+	}
+
+	resp.fields = append(resp.fields, first)
+	for {
+		value, err := c.readValue()
+		if err == errEOL {
+			return resp, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp.fields = append(resp.fields, value)
+	}
+}
+
+func (c *conn) readTag() (string, bool, error) {
+	var sb strings.Builder
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", false, err
+		}
+		switch b {
+		case ' ':
+			return sb.String(), false, nil
+		case '\n':
+			return strings.TrimSuffix(sb.String(), "\r"), true, nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimLeft(strings.TrimRight(line, "\r\n"), " "), nil
+}
+
+func splitResponseCode(text string) (string, string) {
+	if !strings.HasPrefix(text, "[") {
+		return "", text
+	}
+	end := strings.Index(text, "]")
+	if end < 0 {
+		return "", text
+	}
+	return text[1:end], strings.TrimSpace(text[end+1:])
+}
+
+// readValue reads the next value on the current line, returning errEOL once
+// the line ends.
+func (c *conn) readValue() (interface{}, error) {
+	b, err := c.skipSpaces()
+	if err != nil {
+		return nil, err
+	}
+
+	switch b {
+	case '\r', '\n':
+		if b == '\r' {
+			c.r.ReadByte()
+		}
+		return nil, errEOL
+	case ')':
+		c.r.UnreadByte()
+		return nil, errors.New("unexpected ')'")
+	case '(':
+		var list []interface{}
+		for {
+			next, err := c.skipSpaces()
+			if err != nil {
+				return nil, err
+			}
+			if next == ')' {
+				return list, nil
+			}
+			c.r.UnreadByte()
+			value, err := c.readValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+	case '"':
+		return c.readQuoted()
+	case '{':
+		return c.readLiteral()
+	}
+
+	c.r.UnreadByte()
+	word, err := c.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(word, "NIL") {
+		return nil, nil
+	}
+	return word, nil
+}
+
+func (c *conn) skipSpaces() (byte, error) {
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' {
+			return b, nil
+		}
+	}
+}
+
+// readAtom reads up to a space, parenthesis or line end. Brackets are read
+// through so section specs like BODY[HEADER.FIELDS (DATE)] stay one atom.
+func (c *conn) readAtom() (string, error) {
+	var sb strings.Builder
+	depth := 0
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case b == '[':
+			depth++
+		case b == ']' && depth > 0:
+			depth--
+		case depth == 0 && (b == ' ' || b == '(' || b == ')' || b == '\r' || b == '\n'):
+			c.r.UnreadByte()
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func (c *conn) readQuoted() (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if b, err = c.r.ReadByte(); err != nil {
+				return "", err
+			}
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func (c *conn) readLiteral() ([]byte, error) {
+	spec, err := c.r.ReadString('}')
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(spec, "}"), "+"))
+	if err != nil || size < 0 || size > maxLiteralSize {
+		return nil, fmt.Errorf("invalid literal size %q", spec)
+	}
+	if _, err := c.readLine(); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// atom returns a field as a string, or "" for lists, literals and NIL.
+func atom(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// quote renders s as an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,716 @@
+package imap
+
+import (
+	"context"
+	"crypto/tls"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SecurityTLS      = "tls"
+	SecurityStartTLS = "starttls"
+	SecurityNone     = "none"
+
+	AuthLogin   = "login"
+	AuthXOAuth2 = "xoauth2"
+)
+
+type Config struct {
+	Host string
+	Port int
+	// Security is "tls" (implicit TLS, usually port 993), "starttls" or
+	// "none". Plaintext is only meant for local test servers.
+	Security string
+	// Auth is "login" (Username/Password) or "xoauth2" (Username plus a
+	// bearer token from TokenProvider).
+	Auth          string
+	Username      string
+	Password      string
+	TokenProvider outgoing.TokenProvider
+
+	Folder        string
+	ArchiveFolder string
+	TrashFolder   string
+
+	TLSConfig   *tls.Config
+	DialTimeout time.Duration
+	// Dial overrides how the TCP connection is made, e.g. to reach an
+	// in-process server over net.Pipe in tests.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	Logger *slog.Logger
+}
+
+type imapRepository struct {
+	config Config
+}
+
+func NewImapRepository(config Config) outgoing.EmailRepository {
+	if config.Folder == "" {
+		config.Folder = "INBOX"
+	}
+	if config.ArchiveFolder == "" {
+		config.ArchiveFolder = "Archive"
+	}
+	if config.TrashFolder == "" {
+		config.TrashFolder = "Trash"
+	}
+	if config.Security == "" {
+		config.Security = SecurityTLS
+	}
+	if config.Auth == "" {
+		config.Auth = AuthLogin
+	}
+	if config.Port == 0 {
+		config.Port = 993
+		if config.Security != SecurityTLS {
+			config.Port = 143
+		}
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = 30 * time.Second
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{ServerName: config.Host}
+	}
+	config.Logger = logging.OrDefault(config.Logger)
+	return &imapRepository{config: config}
+}
+
+// mailboxStatus is what SELECT/EXAMINE tells us about the folder.
+type mailboxStatus struct {
+	uidValidity   uint32
+	uidNext       uint32
+	highestModSeq uint64
+	condstore     bool
+	keywords      bool
+}
+
+// FetchEmails returns messages with a UID above the cursor in
+// filter.PageToken, oldest first, and a NextPageToken to resume from. The
+// cursor is reset when the folder's UIDVALIDITY changes. Messages that fail
+// to parse are logged, counted in Failed and passed over. Messages are
+// fetched with BODY.PEEK so they stay unread.
+func (r *imapRepository) FetchEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, error) {
+	cur, err := parseCursor(filter.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []entities.EmailMessage
+	failed := 0
+	err = r.withMailbox(ctx, r.config.Folder, true, func(c *conn, status mailboxStatus) error {
+		if cur.uidValidity != status.uidValidity {
+			if cur.uidValidity != 0 {
+				r.config.Logger.WarnContext(ctx, "UIDVALIDITY changed, resyncing from scratch", "folder", r.config.Folder)
+			}
+			cur = cursor{uidValidity: status.uidValidity}
+		}
+
+		// With CONDSTORE an unchanged HIGHESTMODSEQ and UIDNEXT means nothing
+		// happened since the cursor, so skip the search entirely.
+		if status.condstore && cur.modSeq != 0 && cur.modSeq == status.highestModSeq && status.uidNext <= cur.lastUID+1 {
+			return nil
+		}
+
+		uids, err := r.searchNewUIDs(c, cur.lastUID, filter.Query)
+		if err != nil {
+			return err
+		}
+		complete := true
+		if filter.MaxResults > 0 && len(uids) > filter.MaxResults {
+			uids, complete = uids[:filter.MaxResults], false
+		}
+
+		messages, err := r.fetchMessages(c, uids, status.condstore)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			email, err := r.toEmail(status.uidValidity, msg)
+			cur.lastUID = msg.uid
+			if err != nil {
+				// Skipped like Graph and import do: stopping here would
+				// stall the folder on one malformed message for good.
+				r.config.Logger.WarnContext(ctx, "failed to parse message", "uid", msg.uid, "error", err)
+				failed++
+				continue
+			}
+			if filter.OnlyPromotional && !email.IsPromotional {
+				continue
+			}
+			emails = append(emails, *email)
+		}
+		if complete {
+			cur.modSeq = status.highestModSeq
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.EmailList{
+		Emails:        emails,
+		NextPageToken: cur.String(),
+		TotalCount:    len(emails),
+		Failed:        failed,
+	}, nil
+}
+
+// FetchThread is not supported: IMAP has no thread IDs of its own. The IDs
+// on fetched messages come from threading.AssignThreadIDs, and the email
+// service rebuilds a thread from headers when it gets ErrNotSupported.
+func (r *imapRepository) FetchThread(ctx context.Context, threadID string) ([]entities.EmailMessage, error) {
+	return nil, fmt.Errorf("imap: fetching threads by ID: %w", entities.ErrNotSupported)
+}
+
+func (r *imapRepository) FetchRawEmail(ctx context.Context, messageID string) ([]byte, error) {
+	uidValidity, uid, err := parseMessageID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	err = r.withMailbox(ctx, r.config.Folder, true, func(c *conn, status mailboxStatus) error {
+		if status.uidValidity != uidValidity {
+			return fmt.Errorf("message %s: %w", messageID, entities.ErrNotFound)
+		}
+		messages, err := r.fetchMessages(c, []uint32{uid}, false)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return fmt.Errorf("message %s: %w", messageID, entities.ErrNotFound)
+		}
+		raw = messages[0].body
+		return nil
+	})
+	return raw, err
+}
+
+// ModifyLabels maps labels onto IMAP flags: the Gmail system labels UNREAD
+// and STARRED become \Seen (inverted) and \Flagged, removing INBOX archives,
+// and anything else is stored as a keyword.
+func (r *imapRepository) ModifyLabels(ctx context.Context, messageID string, add, remove []string) error {
+	var addFlags, removeFlags []string
+	archive := false
+
+	for _, label := range add {
+		switch strings.ToUpper(label) {
+		case "UNREAD":
+			removeFlags = append(removeFlags, `\Seen`)
+		case "STARRED":
+			addFlags = append(addFlags, `\Flagged`)
+		case "INBOX":
+		default:
+			addFlags = append(addFlags, keyword(label))
+		}
+	}
+	for _, label := range remove {
+		switch strings.ToUpper(label) {
+		case "UNREAD":
+			addFlags = append(addFlags, `\Seen`)
+		case "STARRED":
+			removeFlags = append(removeFlags, `\Flagged`)
+		case "INBOX":
+			archive = true
+		default:
+			removeFlags = append(removeFlags, keyword(label))
+		}
+	}
+
+	if len(addFlags) > 0 || len(removeFlags) > 0 {
+		err := r.withMessage(ctx, messageID, func(c *conn, status mailboxStatus, uid uint32) error {
+			if !status.keywords && hasKeyword(addFlags) {
+				return fmt.Errorf("folder %s does not accept custom keywords", r.config.Folder)
+			}
+			if len(addFlags) > 0 {
+				if _, err := c.execute(fmt.Sprintf("UID STORE %d +FLAGS.SILENT (%s)", uid, strings.Join(addFlags, " "))); err != nil {
+					return err
+				}
+			}
+			if len(removeFlags) > 0 {
+				if _, err := c.execute(fmt.Sprintf("UID STORE %d -FLAGS.SILENT (%s)", uid, strings.Join(removeFlags, " "))); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to modify flags of %s: %w", messageID, err)
+		}
+	}
+
+	if archive {
+		return r.Archive(ctx, messageID)
+	}
+	return nil
+}
+
+// CreateLabel returns the keyword a label maps to. Keywords need no creation
+// on IMAP servers that allow them at all.
+func (r *imapRepository) CreateLabel(ctx context.Context, name string) (string, error) {
+	return keyword(name), nil
+}
+
+func (r *imapRepository) Archive(ctx context.Context, messageID string) error {
+	return r.moveTo(ctx, messageID, r.config.ArchiveFolder)
+}
+
+func (r *imapRepository) MarkRead(ctx context.Context, messageID string) error {
+	err := r.withMessage(ctx, messageID, func(c *conn, status mailboxStatus, uid uint32) error {
+		_, err := c.execute(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark %s read: %w", messageID, err)
+	}
+	return nil
+}
+
+func (r *imapRepository) Trash(ctx context.Context, messageID string) error {
+	return r.moveTo(ctx, messageID, r.config.TrashFolder)
+}
+
+// moveTo moves a message with MOVE where supported, falling back to COPY,
+// \Deleted and UID EXPUNGE (or plain EXPUNGE without UIDPLUS).
+func (r *imapRepository) moveTo(ctx context.Context, messageID, folder string) error {
+	err := r.withMessage(ctx, messageID, func(c *conn, status mailboxStatus, uid uint32) error {
+		target := quote(encodeMailbox(folder))
+		if _, err := c.execute("CREATE " + target); err != nil && !isAlreadyExists(err) {
+			return err
+		}
+
+		if c.caps["MOVE"] {
+			_, err := c.execute(fmt.Sprintf("UID MOVE %d %s", uid, target))
+			return err
+		}
+
+		if _, err := c.execute(fmt.Sprintf("UID COPY %d %s", uid, target)); err != nil {
+			return err
+		}
+		if _, err := c.execute(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Deleted)`, uid)); err != nil {
+			return err
+		}
+		if c.caps["UIDPLUS"] {
+			_, err := c.execute(fmt.Sprintf("UID EXPUNGE %d", uid))
+			return err
+		}
+		_, err := c.execute("EXPUNGE")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", messageID, folder, err)
+	}
+	return nil
+}
+
+// withMessage selects the configured folder read-write and checks that the
+// message ID still refers to the same UIDVALIDITY epoch.
+func (r *imapRepository) withMessage(ctx context.Context, messageID string, fn func(c *conn, status mailboxStatus, uid uint32) error) error {
+	uidValidity, uid, err := parseMessageID(messageID)
+	if err != nil {
+		return err
+	}
+	return r.withMailbox(ctx, r.config.Folder, false, func(c *conn, status mailboxStatus) error {
+		if status.uidValidity != uidValidity {
+			return fmt.Errorf("message %s: %w", messageID, entities.ErrNotFound)
+		}
+		return fn(c, status, uid)
+	})
+}
+
+// withMailbox opens an authenticated session, EXAMINEs (readOnly) or SELECTs
+// the folder, runs fn and logs out.
+func (r *imapRepository) withMailbox(ctx context.Context, folder string, readOnly bool, fn func(c *conn, status mailboxStatus) error) error {
+	c, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+	stop := c.bindContext(ctx)
+	defer func() {
+		// LOGOUT runs before stop so a stalled server can't outlive ctx.
+		c.execute("LOGOUT")
+		stop()
+		c.close()
+	}()
+
+	status, err := r.openMailbox(c, folder, readOnly)
+	if err != nil {
+		return err
+	}
+	return fn(c, status)
+}
+
+func (r *imapRepository) connect(ctx context.Context) (*conn, error) {
+	address := net.JoinHostPort(r.config.Host, strconv.Itoa(r.config.Port))
+
+	dial := r.config.Dial
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: r.config.DialTimeout}
+		dial = dialer.DialContext
+	}
+	netConn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	if r.config.Security == SecurityTLS {
+		tlsConn := tls.Client(netConn, r.config.TLSConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+		}
+		netConn = tlsConn
+	}
+
+	c := newConn(netConn)
+	stop := c.bindContext(ctx)
+	defer stop()
+
+	if err := r.handshake(c); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *imapRepository) handshake(c *conn) error {
+	if err := c.readGreeting(); err != nil {
+		return err
+	}
+
+	caps, err := c.capabilities()
+	if err != nil {
+		return err
+	}
+	if r.config.Security == SecurityStartTLS {
+		if !caps["STARTTLS"] {
+			return fmt.Errorf("server does not support STARTTLS")
+		}
+		if err := c.startTLS(r.config.TLSConfig); err != nil {
+			return err
+		}
+		if caps, err = c.capabilities(); err != nil {
+			return err
+		}
+	}
+
+	switch r.config.Auth {
+	case AuthXOAuth2:
+		if !caps["AUTH=XOAUTH2"] {
+			return fmt.Errorf("server does not support XOAUTH2")
+		}
+		if r.config.TokenProvider == nil {
+			return fmt.Errorf("xoauth2 requires a token provider")
+		}
+		ir := base64.StdEncoding.EncodeToString([]byte(
+			"user=" + r.config.Username + "\x01auth=Bearer " + r.config.TokenProvider.GetAccessToken() + "\x01\x01"))
+
+		command := "AUTHENTICATE XOAUTH2"
+		if caps["SASL-IR"] {
+			command += " " + ir
+		}
+		sentIR := caps["SASL-IR"]
+		_, err = c.executeWithContinuation(command, func(text string) (string, error) {
+			if !sentIR {
+				sentIR = true
+				return ir, nil
+			}
+			// A second challenge carries the JSON error; an empty reply
+			// makes the server finish with NO.
+			return "", nil
+		})
+	case AuthLogin:
+		if caps["LOGINDISABLED"] {
+			return fmt.Errorf("server disabled LOGIN on this connection; use TLS or xoauth2")
+		}
+		if strings.ContainsAny(r.config.Username+r.config.Password, "\r\n") {
+			return fmt.Errorf("credentials must not contain line breaks")
+		}
+		_, err = c.execute("LOGIN " + quote(r.config.Username) + " " + quote(r.config.Password))
+	default:
+		return fmt.Errorf("unsupported auth method %q", r.config.Auth)
+	}
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	// Servers may advertise more capabilities once authenticated.
+	c.caps = nil
+	if _, err := c.capabilities(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *imapRepository) openMailbox(c *conn, folder string, readOnly bool) (mailboxStatus, error) {
+	command := "SELECT"
+	if readOnly {
+		command = "EXAMINE"
+	}
+	command += " " + quote(encodeMailbox(folder))
+
+	status := mailboxStatus{condstore: c.caps["CONDSTORE"]}
+	if status.condstore {
+		command += " (CONDSTORE)"
+	}
+
+	responses, err := c.execute(command)
+	if err != nil {
+		return status, fmt.Errorf("failed to open %s: %w", folder, err)
+	}
+
+	for _, resp := range responses {
+		fields := strings.Fields(resp.code)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "UIDVALIDITY":
+			status.uidValidity = parseUint32(fields[1:])
+		case "UIDNEXT":
+			status.uidNext = parseUint32(fields[1:])
+		case "HIGHESTMODSEQ":
+			if len(fields) > 1 {
+				status.highestModSeq, _ = strconv.ParseUint(fields[1], 10, 64)
+			}
+		case "NOMODSEQ":
+			status.condstore = false
+		case "PERMANENTFLAGS":
+			status.keywords = strings.Contains(resp.code, `\*`)
+		}
+	}
+
+	if status.uidValidity == 0 {
+		return status, fmt.Errorf("server did not report UIDVALIDITY for %s", folder)
+	}
+	return status, nil
+}
+
+// searchNewUIDs returns the UIDs above lastUID in ascending order, optionally
+// narrowed by a full-text search.
+func (r *imapRepository) searchNewUIDs(c *conn, lastUID uint32, query string) ([]uint32, error) {
+	command := fmt.Sprintf("UID SEARCH UID %d:*", lastUID+1)
+	switch {
+	case query == "":
+	case isASCII(query):
+		command += " TEXT " + quote(query)
+	case c.caps["LITERAL+"]:
+		// 8-bit text has to go in a literal; LITERAL+ lets us send it inline.
+		command = fmt.Sprintf("UID SEARCH CHARSET UTF-8 UID %d:* TEXT {%d+}\r\n%s", lastUID+1, len(query), query)
+	default:
+		return nil, fmt.Errorf("non-ASCII search needs a server with LITERAL+")
+	}
+
+	responses, err := c.execute(command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	var uids []uint32
+	for _, resp := range responses {
+		if len(resp.fields) == 0 || !strings.EqualFold(atom(resp.fields[0]), "SEARCH") {
+			continue
+		}
+		for _, field := range resp.fields[1:] {
+			uid, err := strconv.ParseUint(atom(field), 10, 32)
+			// "n:*" always matches the highest UID, even when it's below n.
+			if err == nil && uint32(uid) > lastUID {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+type fetchedMessage struct {
+	uid          uint32
+	flags        []string
+	internalDate time.Time
+	modSeq       uint64
+	body         []byte
+}
+
+func (r *imapRepository) fetchMessages(c *conn, uids []uint32, condstore bool) ([]fetchedMessage, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	set := make([]string, len(uids))
+	for i, uid := range uids {
+		set[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	items := "UID FLAGS INTERNALDATE BODY.PEEK[]"
+	if condstore {
+		items += " MODSEQ"
+	}
+
+	responses, err := c.execute(fmt.Sprintf("UID FETCH %s (%s)", strings.Join(set, ","), items))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+
+	var messages []fetchedMessage
+	for _, resp := range responses {
+		if len(resp.fields) < 3 || !strings.EqualFold(atom(resp.fields[1]), "FETCH") {
+			continue
+		}
+		attrs, _ := resp.fields[2].([]interface{})
+
+		var msg fetchedMessage
+		for i := 0; i+1 < len(attrs); i += 2 {
+			value := attrs[i+1]
+			switch name := strings.ToUpper(atom(attrs[i])); {
+			case name == "UID":
+				uid, _ := strconv.ParseUint(atom(value), 10, 32)
+				msg.uid = uint32(uid)
+			case name == "FLAGS":
+				list, _ := value.([]interface{})
+				for _, flag := range list {
+					msg.flags = append(msg.flags, atom(flag))
+				}
+			case name == "INTERNALDATE":
+				msg.internalDate, _ = time.Parse("_2-Jan-2006 15:04:05 -0700", atom(value))
+			case name == "MODSEQ":
+				if list, ok := value.([]interface{}); ok && len(list) > 0 {
+					msg.modSeq, _ = strconv.ParseUint(atom(list[0]), 10, 64)
+				}
+			case strings.HasPrefix(name, "BODY["):
+				switch body := value.(type) {
+				case []byte:
+					msg.body = body
+				case string:
+					msg.body = []byte(body)
+				}
+			}
+		}
+		// Servers may send unsolicited FETCH responses for flag updates.
+		if msg.uid != 0 && msg.body != nil {
+			messages = append(messages, msg)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].uid < messages[j].uid })
+	return messages, nil
+}
+
+func (r *imapRepository) toEmail(uidValidity uint32, msg fetchedMessage) (*entities.EmailMessage, error) {
+	email, err := parser.ParseMessage(msg.body)
+	if err != nil {
+		return nil, err
+	}
+
+	email.ID = formatMessageID(uidValidity, msg.uid)
+	email.Labels = msg.flags
+	if !msg.internalDate.IsZero() {
+		email.ReceivedAt = msg.internalDate.UTC()
+		if email.Date.IsZero() || email.InvalidDate {
+			email.Date = email.ReceivedAt
+		}
+	}
+
+	classifier.Default().Apply(email)
+	return email, nil
+}
+
+// Message IDs are "<uidvalidity>.<uid>", which stays unique per folder even
+// if the server renumbers UIDs.
+func formatMessageID(uidValidity, uid uint32) string {
+	return fmt.Sprintf("%d.%d", uidValidity, uid)
+}
+
+func parseMessageID(messageID string) (uint32, uint32, error) {
+	validity, uid, ok := strings.Cut(messageID, ".")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid IMAP message ID %q", messageID)
+	}
+	v, err1 := strconv.ParseUint(validity, 10, 32)
+	u, err2 := strconv.ParseUint(uid, 10, 32)
+	if err1 != nil || err2 != nil || u == 0 {
+		return 0, 0, fmt.Errorf("invalid IMAP message ID %q", messageID)
+	}
+	return uint32(v), uint32(u), nil
+}
+
+// cursor is the incremental sync position carried in page tokens.
+type cursor struct {
+	uidValidity uint32
+	lastUID     uint32
+	modSeq      uint64
+}
+
+func (c cursor) String() string {
+	return fmt.Sprintf("imap1:%d:%d:%d", c.uidValidity, c.lastUID, c.modSeq)
+}
+
+func parseCursor(token string) (cursor, error) {
+	if token == "" {
+		return cursor{}, nil
+	}
+	var c cursor
+	if _, err := fmt.Sscanf(token, "imap1:%d:%d:%d", &c.uidValidity, &c.lastUID, &c.modSeq); err != nil {
+		return cursor{}, fmt.Errorf("invalid IMAP page token %q", token)
+	}
+	return c, nil
+}
+
+// keyword turns a label name into a valid IMAP flag keyword atom.
+func keyword(label string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= 0x20 || r >= 0x7f || strings.ContainsRune(`(){%*"\]`, r) {
+			return '_'
+		}
+		return r
+	}, label)
+}
+
+func hasKeyword(flags []string) bool {
+	for _, flag := range flags {
+		if !strings.HasPrefix(flag, `\`) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlreadyExists(err error) bool {
+	var cmdErr *commandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	// ALREADYEXISTS is RFC 5530; older servers only say so in the text.
+	return strings.EqualFold(cmdErr.code, "ALREADYEXISTS") || strings.Contains(strings.ToLower(cmdErr.text), "exist")
+}
+
+func parseUint32(fields []string) uint32 {
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.ParseUint(fields[0], 10, 32)
+	return uint32(n)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+package imap
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/imap/imapfake"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func raw(n int) []byte {
+	return []byte(fmt.Sprintf("From: Shop <deals@shop.example>\r\n"+
+		"To: me@example.com\r\n"+
+		"Subject: Offer %d\r\n"+
+		"Date: Mon, 3 Mar 2025 09:%02d:00 +0000\r\n"+
+		"Message-ID: <offer-%d@shop.example>\r\n"+
+		"List-Unsubscribe: <mailto:u@shop.example>\r\n"+
+		"\r\n"+
+		"Weekly sale: 20%% discount.\r\n", n, n, n))
+}
+
+func newTestRepository(server *imapfake.Server) *imapRepository {
+	return NewImapRepository(Config{
+		Host:     "imap.example.com",
+		Security: SecurityNone,
+		Username: server.Username,
+		Password: server.Password,
+		Dial:     server.Dial,
+	}).(*imapRepository)
+}
+
+func addOffers(server *imapfake.Server, from, to int) {
+	for n := from; n <= to; n++ {
+		server.AddMessage("INBOX", imapfake.Message{Raw: raw(n)})
+	}
+}
+
+func subjects(emails []entities.EmailMessage) []string {
+	var result []string
+	for _, email := range emails {
+		result = append(result, email.Subject)
+	}
+	return result
+}
+
+func fetch(t *testing.T, repo *imapRepository, filter entities.EmailFilter) *entities.EmailList {
+	t.Helper()
+	list, err := repo.FetchEmails(context.Background(), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestFetchEmailsResumesFromLastUID(t *testing.T) {
+	server := imapfake.NewServer()
+	repo := newTestRepository(server)
+	addOffers(server, 1, 3)
+
+	list := fetch(t, repo, entities.EmailFilter{MaxResults: 2})
+	if got, want := subjects(list.Emails), []string{"Offer 1", "Offer 2"}; !slices.Equal(got, want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+	if list.NextPageToken != "imap1:1:2:0" {
+		t.Errorf("token after a truncated page = %q", list.NextPageToken)
+	}
+
+	list = fetch(t, repo, entities.EmailFilter{PageToken: list.NextPageToken})
+	if got, want := subjects(list.Emails), []string{"Offer 3"}; !slices.Equal(got, want) {
+		t.Errorf("second page = %v, want %v", got, want)
+	}
+	if list.Emails[0].ID != "1.3" || !list.Emails[0].IsPromotional {
+		t.Errorf("id = %q, promotional = %v", list.Emails[0].ID, list.Emails[0].IsPromotional)
+	}
+
+	// "4:*" matches the highest UID even when it's 3; the cursor must not
+	// hand it back again.
+	list = fetch(t, repo, entities.EmailFilter{PageToken: list.NextPageToken})
+	if len(list.Emails) != 0 {
+		t.Errorf("nothing new, got %v", subjects(list.Emails))
+	}
+}
+
+func TestFetchEmailsUIDValidityReset(t *testing.T) {
+	server := imapfake.NewServer()
+	repo := newTestRepository(server)
+	addOffers(server, 1, 2)
+
+	list := fetch(t, repo, entities.EmailFilter{})
+	token := list.NextPageToken
+
+	server.ResetUIDValidity("INBOX")
+	list = fetch(t, repo, entities.EmailFilter{PageToken: token})
+	if got, want := subjects(list.Emails), []string{"Offer 1", "Offer 2"}; !slices.Equal(got, want) {
+		t.Fatalf("after reset = %v, want %v", got, want)
+	}
+	if want := fmt.Sprintf("%d.1", server.UIDValidity("INBOX")); list.Emails[0].ID != want {
+		t.Errorf("id = %q, want %q", list.Emails[0].ID, want)
+	}
+
+	// IDs from the old epoch no longer resolve.
+	if err := repo.MarkRead(context.Background(), "1.1"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("stale id: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchEmailsCondstoreSkipsSearch(t *testing.T) {
+	server := imapfake.NewServer()
+	repo := newTestRepository(server)
+	addOffers(server, 1, 2)
+
+	token := fetch(t, repo, entities.EmailFilter{}).NextPageToken
+	searches := server.Commands("UID SEARCH")
+
+	list := fetch(t, repo, entities.EmailFilter{PageToken: token})
+	if len(list.Emails) != 0 || list.NextPageToken != token {
+		t.Errorf("unchanged folder: %d emails, token %q -> %q", len(list.Emails), token, list.NextPageToken)
+	}
+	if n := server.Commands("UID SEARCH"); n != searches {
+		t.Errorf("searched an unchanged folder (%d searches, want %d)", n, searches)
+	}
+
+	// A flag change bumps HIGHESTMODSEQ: the folder is searched again but
+	// there's still nothing new to return.
+	if err := repo.MarkRead(context.Background(), "1.1"); err != nil {
+		t.Fatal(err)
+	}
+	list = fetch(t, repo, entities.EmailFilter{PageToken: token})
+	if len(list.Emails) != 0 || server.Commands("UID SEARCH") != searches+1 {
+		t.Errorf("after a flag change: %d emails, %d searches", len(list.Emails), server.Commands("UID SEARCH"))
+	}
+
+	addOffers(server, 3, 3)
+	list = fetch(t, repo, entities.EmailFilter{PageToken: list.NextPageToken})
+	if got, want := subjects(list.Emails), []string{"Offer 3"}; !slices.Equal(got, want) {
+		t.Errorf("after a new message = %v, want %v", got, want)
+	}
+}
+
+func TestFetchEmailsWithoutCondstore(t *testing.T) {
+	server := imapfake.NewServer()
+	server.Capabilities = []string{"IMAP4rev1"}
+	repo := newTestRepository(server)
+	addOffers(server, 1, 1)
+
+	list := fetch(t, repo, entities.EmailFilter{})
+	list = fetch(t, repo, entities.EmailFilter{PageToken: list.NextPageToken})
+	if len(list.Emails) != 0 || server.Commands("UID SEARCH") != 2 {
+		t.Errorf("%d emails, %d searches", len(list.Emails), server.Commands("UID SEARCH"))
+	}
+}
+
+func TestFetchEmailsSkipsParseFailure(t *testing.T) {
+	server := imapfake.NewServer()
+	repo := newTestRepository(server)
+	addOffers(server, 1, 1)
+	broken := server.AddMessage("INBOX", imapfake.Message{Raw: []byte("not a header line\r\n\r\nbody\r\n")})
+	addOffers(server, 3, 3)
+
+	list := fetch(t, repo, entities.EmailFilter{})
+	if got, want := subjects(list.Emails), []string{"Offer 1", "Offer 3"}; !slices.Equal(got, want) || list.Failed != 1 {
+		t.Errorf("emails = %v, failed = %d", got, list.Failed)
+	}
+	if cur, err := parseCursor(list.NextPageToken); err != nil || cur.lastUID <= broken {
+		t.Errorf("token = %q, want the cursor past the broken message", list.NextPageToken)
+	}
+
+	// The broken message is not fetched again.
+	list = fetch(t, repo, entities.EmailFilter{PageToken: list.NextPageToken})
+	if len(list.Emails) != 0 || list.Failed != 0 {
+		t.Errorf("next fetch: %d emails, failed = %d", len(list.Emails), list.Failed)
+	}
+}
+
+func TestFetchEmailsQuery(t *testing.T) {
+	server := imapfake.NewServer()
+	repo := newTestRepository(server)
+	addOffers(server, 1, 3)
+
+	list := fetch(t, repo, entities.EmailFilter{Query: "Offer 2"})
+	if got, want := subjects(list.Emails), []string{"Offer 2"}; !slices.Equal(got, want) {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
+
+func TestFetchThreadNotSupported(t *testing.T) {
+	repo := newTestRepository(imapfake.NewServer())
+	if _, err := repo.FetchThread(context.Background(), "thread"); !errors.Is(err, entities.ErrNotSupported) {
+		t.Errorf("err = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	for _, capabilities := range [][]string{imapfake.DefaultCapabilities, {"IMAP4rev1"}} {
+		t.Run(fmt.Sprint(capabilities), func(t *testing.T) {
+			server := imapfake.NewServer()
+			server.Capabilities = capabilities
+			repo := newTestRepository(server)
+			ctx := context.Background()
+			uid := server.AddMessage("INBOX", imapfake.Message{Raw: raw(1), Flags: []string{`\Seen`}})
+			id := fmt.Sprintf("1.%d", uid)
+
+			if err := repo.ModifyLabels(ctx, id, []string{"UNREAD", "STARRED", "Big Deals"}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := server.Message("INBOX", uid).Flags, []string{`\Flagged`, "Big_Deals"}; !slices.Equal(got, want) {
+				t.Errorf("flags = %v, want %v", got, want)
+			}
+
+			got, err := repo.FetchRawEmail(ctx, id)
+			if err != nil || string(got) != string(raw(1)) {
+				t.Errorf("raw = %q, err = %v", got, err)
+			}
+
+			if err := repo.ModifyLabels(ctx, id, nil, []string{"INBOX"}); err != nil {
+				t.Fatal(err)
+			}
+			if server.Count("INBOX") != 0 || server.Count("Archive") != 1 {
+				t.Errorf("after archive: inbox %d, archive %d", server.Count("INBOX"), server.Count("Archive"))
+			}
+			if _, err := repo.FetchRawEmail(ctx, id); !errors.Is(err, entities.ErrNotFound) {
+				t.Errorf("raw after archive: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,601 @@
+// Package imapfake is an in-process stand-in for an IMAP4rev1 server, for
+// exercising the imap adapter without a mail server. Connections are served
+// over net.Pipe through Dial, which plugs into the adapter's Config.Dial. It
+// implements just enough of the protocol: LOGIN, SELECT/EXAMINE with
+// CONDSTORE, UID SEARCH, UID FETCH, UID STORE, CREATE, UID MOVE and the
+// COPY/EXPUNGE fallback. UIDVALIDITY can be reset to simulate a server that
+// renumbered a folder.
+package imapfake
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCapabilities are advertised unless Capabilities is changed.
+var DefaultCapabilities = []string{"IMAP4rev1", "LITERAL+", "CONDSTORE", "MOVE", "UIDPLUS"}
+
+// Message is one stored message.
+type Message struct {
+	UID          uint32
+	Flags        []string
+	Raw          []byte
+	InternalDate time.Time
+
+	modSeq uint64
+}
+
+type folder struct {
+	uidValidity uint32
+	uidNext     uint32
+	messages    []*Message
+}
+
+type Server struct {
+	Username string
+	Password string
+	// Capabilities is what CAPABILITY reports; set it before dialing.
+	Capabilities []string
+
+	mu            sync.Mutex
+	folders       map[string]*folder
+	highestModSeq uint64
+	// commands counts commands by name, e.g. "UID SEARCH", for assertions.
+	commands map[string]int
+}
+
+// NewServer returns a server with an empty INBOX.
+func NewServer() *Server {
+	s := &Server{
+		Username:      "me@example.com",
+		Password:      "secret",
+		Capabilities:  DefaultCapabilities,
+		folders:       make(map[string]*folder),
+		highestModSeq: 1,
+		commands:      make(map[string]int),
+	}
+	s.folders["INBOX"] = &folder{uidValidity: 1, uidNext: 1}
+	return s
+}
+
+// Dial serves a new connection over net.Pipe. It matches the signature of
+// the adapter's Config.Dial.
+func (s *Server) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go s.serve(server)
+	return client, nil
+}
+
+// AddMessage appends msg to the folder, creating the folder if needed, and
+// returns the UID it was given. Flags default to none and InternalDate to
+// now.
+func (s *Server) AddMessage(name string, msg Message) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.folder(name)
+	stored := msg
+	stored.UID = f.uidNext
+	stored.Flags = append([]string(nil), msg.Flags...)
+	stored.Raw = append([]byte(nil), msg.Raw...)
+	if stored.InternalDate.IsZero() {
+		stored.InternalDate = time.Now().Truncate(time.Second)
+	}
+	s.highestModSeq++
+	stored.modSeq = s.highestModSeq
+	f.uidNext++
+	f.messages = append(f.messages, &stored)
+	return stored.UID
+}
+
+// ResetUIDValidity renumbers the folder from UID 1 under a new UIDVALIDITY,
+// as servers do after rebuilding their index.
+func (s *Server) ResetUIDValidity(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.folder(name)
+	f.uidValidity++
+	f.uidNext = 1
+	for _, msg := range f.messages {
+		msg.UID = f.uidNext
+		f.uidNext++
+	}
+}
+
+// UIDValidity is the folder's current UIDVALIDITY.
+func (s *Server) UIDValidity(name string) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.folder(name).uidValidity
+}
+
+// Message returns a copy of the message with uid in the folder, or nil.
+func (s *Server) Message(name string, uid uint32) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range s.folder(name).messages {
+		if msg.UID == uid {
+			copied := *msg
+			copied.Flags = append([]string(nil), msg.Flags...)
+			return &copied
+		}
+	}
+	return nil
+}
+
+// Count is the number of messages in the folder.
+func (s *Server) Count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.folder(name).messages)
+}
+
+// Commands is how often a command was received, e.g. "LOGIN" or
+// "UID SEARCH".
+func (s *Server) Commands(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[name]
+}
+
+func (s *Server) folder(name string) *folder {
+	if strings.EqualFold(name, "INBOX") {
+		name = "INBOX"
+	}
+	f, ok := s.folders[name]
+	if !ok {
+		f = &folder{uidValidity: 1, uidNext: 1}
+		s.folders[name] = f
+	}
+	return f
+}
+
+// session is the state of one connection.
+type session struct {
+	s        *Server
+	r        *bufio.Reader
+	w        *bufio.Writer
+	loggedIn bool
+	selected string
+	readOnly bool
+}
+
+func (s *Server) serve(netConn net.Conn) {
+	defer netConn.Close()
+	sess := &session{s: s, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+	sess.untagged("OK [CAPABILITY %s] imapfake ready", strings.Join(s.Capabilities, " "))
+	if sess.w.Flush() != nil {
+		return
+	}
+
+	for {
+		tag, args, err := sess.readCommand()
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			sess.w.WriteString(tag + " BAD empty command\r\n")
+			sess.w.Flush()
+			continue
+		}
+		done := sess.handle(tag, args)
+		if sess.w.Flush() != nil || done {
+			return
+		}
+	}
+}
+
+// readCommand reads a tagged command line, following literals, and splits
+// it into arguments. Quoted strings are unquoted and parenthesised lists
+// are kept as a single "(...)" argument.
+func (sess *session) readCommand() (string, []string, error) {
+	var line strings.Builder
+	for {
+		part, err := sess.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		part = strings.TrimRight(part, "\r\n")
+		open := strings.LastIndexByte(part, '{')
+		if open < 0 || !strings.HasSuffix(part, "}") {
+			line.WriteString(part)
+			break
+		}
+		spec := strings.TrimSuffix(part[open+1:len(part)-1], "+")
+		size, err := strconv.Atoi(spec)
+		if err != nil {
+			line.WriteString(part)
+			break
+		}
+		if !strings.HasSuffix(part, "+}") {
+			sess.w.WriteString("+ go ahead\r\n")
+			sess.w.Flush()
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(sess.r, literal); err != nil {
+			return "", nil, err
+		}
+		line.WriteString(part[:open])
+		line.WriteString(quote(string(literal)))
+	}
+
+	tokens := tokenize(line.String())
+	if len(tokens) == 0 {
+		return "", nil, fmt.Errorf("empty line")
+	}
+	return tokens[0], tokens[1:], nil
+}
+
+func tokenize(line string) []string {
+	var tokens []string
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ':
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				sb.WriteByte(line[i])
+				i++
+			}
+			i++
+			tokens = append(tokens, sb.String())
+		case c == '(':
+			depth, start := 0, i
+			for ; i < len(line); i++ {
+				if line[i] == '(' {
+					depth++
+				} else if line[i] == ')' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+			tokens = append(tokens, line[start:i])
+		default:
+			start := i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			tokens = append(tokens, line[start:i])
+		}
+	}
+	return tokens
+}
+
+func (sess *session) untagged(format string, args ...any) {
+	fmt.Fprintf(sess.w, "* "+format+"\r\n", args...)
+}
+
+// handle runs one command and reports whether the connection should close.
+func (sess *session) handle(tag string, args []string) bool {
+	s := sess.s
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	if name == "UID" && len(args) > 0 {
+		name += " " + strings.ToUpper(args[0])
+		args = args[1:]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[name]++
+
+	ok := func(text string) { sess.w.WriteString(tag + " OK " + text + "\r\n") }
+	no := func(text string) { sess.w.WriteString(tag + " NO " + text + "\r\n") }
+	bad := func(text string) { sess.w.WriteString(tag + " BAD " + text + "\r\n") }
+
+	switch name {
+	case "CAPABILITY":
+		sess.untagged("CAPABILITY %s", strings.Join(s.Capabilities, " "))
+		ok("CAPABILITY completed")
+		return false
+	case "NOOP":
+		ok("NOOP completed")
+		return false
+	case "LOGOUT":
+		sess.untagged("BYE logging out")
+		ok("LOGOUT completed")
+		return true
+	case "LOGIN":
+		if len(args) != 2 || args[0] != s.Username || args[1] != s.Password {
+			no("[AUTHENTICATIONFAILED] invalid credentials")
+			return false
+		}
+		sess.loggedIn = true
+		ok("LOGIN completed")
+		return false
+	}
+
+	if !sess.loggedIn {
+		bad("log in first")
+		return false
+	}
+
+	switch name {
+	case "SELECT", "EXAMINE":
+		if len(args) == 0 {
+			bad("mailbox name required")
+			return false
+		}
+		f, exists := s.lookup(args[0])
+		if !exists {
+			no("[NONEXISTENT] no such mailbox")
+			return false
+		}
+		sess.selected, sess.readOnly = args[0], name == "EXAMINE"
+		sess.untagged(`FLAGS (\Answered \Flagged \Deleted \Seen \Draft)`)
+		sess.untagged(`OK [PERMANENTFLAGS (\Answered \Flagged \Deleted \Seen \Draft \*)] flags permitted`)
+		sess.untagged("%d EXISTS", len(f.messages))
+		sess.untagged("OK [UIDVALIDITY %d] UIDs valid", f.uidValidity)
+		sess.untagged("OK [UIDNEXT %d] predicted next UID", f.uidNext)
+		if s.has("CONDSTORE") {
+			sess.untagged("OK [HIGHESTMODSEQ %d] highest", s.highestModSeq)
+		}
+		if sess.readOnly {
+			ok("[READ-ONLY] EXAMINE completed")
+		} else {
+			ok("[READ-WRITE] SELECT completed")
+		}
+	case "CREATE":
+		if len(args) == 0 {
+			bad("mailbox name required")
+			return false
+		}
+		if _, exists := s.lookup(args[0]); exists {
+			no("[ALREADYEXISTS] mailbox exists")
+			return false
+		}
+		s.folder(args[0])
+		ok("CREATE completed")
+	case "UID SEARCH":
+		f := sess.current()
+		if f == nil {
+			bad("no mailbox selected")
+			return false
+		}
+		uids, err := search(f, args)
+		if err != nil {
+			bad(err.Error())
+			return false
+		}
+		sess.untagged("SEARCH%s", joinUIDs(uids))
+		ok("SEARCH completed")
+	case "UID FETCH":
+		f := sess.current()
+		if f == nil || len(args) < 2 {
+			bad("no mailbox selected or missing arguments")
+			return false
+		}
+		set := parseSet(args[0], f)
+		modSeq := strings.Contains(strings.ToUpper(args[1]), "MODSEQ")
+		for i, msg := range f.messages {
+			if !set(msg.UID) {
+				continue
+			}
+			fmt.Fprintf(sess.w, "* %d FETCH (UID %d FLAGS (%s) INTERNALDATE %q", i+1, msg.UID,
+				strings.Join(msg.Flags, " "), msg.InternalDate.Format("02-Jan-2006 15:04:05 -0700"))
+			if modSeq {
+				fmt.Fprintf(sess.w, " MODSEQ (%d)", msg.modSeq)
+			}
+			fmt.Fprintf(sess.w, " BODY[] {%d}\r\n", len(msg.Raw))
+			sess.w.Write(msg.Raw)
+			sess.w.WriteString(")\r\n")
+		}
+		ok("FETCH completed")
+	case "UID STORE":
+		f := sess.current()
+		if f == nil || sess.readOnly || len(args) < 3 {
+			no("mailbox is read-only or not selected")
+			return false
+		}
+		set := parseSet(args[0], f)
+		flags := strings.Fields(strings.Trim(args[2], "()"))
+		for _, msg := range f.messages {
+			if !set(msg.UID) {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(args[1], "+"):
+				for _, flag := range flags {
+					if !slices.Contains(msg.Flags, flag) {
+						msg.Flags = append(msg.Flags, flag)
+					}
+				}
+			case strings.HasPrefix(args[1], "-"):
+				msg.Flags = slices.DeleteFunc(msg.Flags, func(flag string) bool { return slices.Contains(flags, flag) })
+			default:
+				msg.Flags = flags
+			}
+			s.highestModSeq++
+			msg.modSeq = s.highestModSeq
+		}
+		ok("STORE completed")
+	case "UID COPY", "UID MOVE":
+		f := sess.current()
+		if f == nil || sess.readOnly || len(args) < 2 {
+			no("mailbox is read-only or not selected")
+			return false
+		}
+		if name == "UID MOVE" && !s.has("MOVE") {
+			bad("MOVE not supported")
+			return false
+		}
+		target, exists := s.lookup(args[1])
+		if !exists {
+			no("[TRYCREATE] no such mailbox")
+			return false
+		}
+		set := parseSet(args[0], f)
+		var kept []*Message
+		for _, msg := range f.messages {
+			if !set(msg.UID) {
+				kept = append(kept, msg)
+				continue
+			}
+			copied := *msg
+			copied.Flags = slices.DeleteFunc(append([]string(nil), msg.Flags...), func(flag string) bool { return flag == `\Deleted` })
+			copied.UID = target.uidNext
+			target.uidNext++
+			s.highestModSeq++
+			copied.modSeq = s.highestModSeq
+			target.messages = append(target.messages, &copied)
+			if name == "UID COPY" {
+				kept = append(kept, msg)
+			}
+		}
+		f.messages = kept
+		ok(strings.TrimPrefix(name, "UID ") + " completed")
+	case "EXPUNGE", "UID EXPUNGE":
+		f := sess.current()
+		if f == nil || sess.readOnly {
+			no("mailbox is read-only or not selected")
+			return false
+		}
+		if name == "UID EXPUNGE" && !s.has("UIDPLUS") {
+			bad("UIDPLUS not supported")
+			return false
+		}
+		set := func(uint32) bool { return true }
+		if name == "UID EXPUNGE" && len(args) > 0 {
+			set = parseSet(args[0], f)
+		}
+		f.messages = slices.DeleteFunc(f.messages, func(msg *Message) bool {
+			return set(msg.UID) && slices.Contains(msg.Flags, `\Deleted`)
+		})
+		s.highestModSeq++
+		ok("EXPUNGE completed")
+	default:
+		bad("unknown command " + name)
+	}
+	return false
+}
+
+func (s *Server) has(capability string) bool {
+	for _, c := range s.Capabilities {
+		if strings.EqualFold(c, capability) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) lookup(name string) (*folder, bool) {
+	if strings.EqualFold(name, "INBOX") {
+		name = "INBOX"
+	}
+	f, ok := s.folders[name]
+	return f, ok
+}
+
+func (sess *session) current() *folder {
+	if sess.selected == "" {
+		return nil
+	}
+	f, _ := sess.s.lookup(sess.selected)
+	return f
+}
+
+// search supports "[CHARSET x] UID <set> [TEXT <string>]".
+func search(f *folder, args []string) ([]uint32, error) {
+	if len(args) >= 2 && strings.EqualFold(args[0], "CHARSET") {
+		args = args[2:]
+	}
+	set := func(uint32) bool { return true }
+	var text string
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "UID":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("UID needs a set")
+			}
+			i++
+			set = parseSet(args[i], f)
+		case "TEXT":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("TEXT needs a string")
+			}
+			i++
+			text = strings.ToLower(args[i])
+		default:
+			return nil, fmt.Errorf("unsupported search key %s", args[i])
+		}
+	}
+
+	var uids []uint32
+	for _, msg := range f.messages {
+		if set(msg.UID) && strings.Contains(strings.ToLower(string(msg.Raw)), text) {
+			uids = append(uids, msg.UID)
+		}
+	}
+	return uids, nil
+}
+
+// parseSet parses a UID set such as "1,3:5,7:*". As in IMAP, "*" is the
+// highest UID in use, so "n:*" always includes it even when it's below n.
+func parseSet(spec string, f *folder) func(uint32) bool {
+	var highest uint32
+	for _, msg := range f.messages {
+		highest = max(highest, msg.UID)
+	}
+	value := func(s string) uint32 {
+		if s == "*" {
+			return highest
+		}
+		n, _ := strconv.ParseUint(s, 10, 32)
+		return uint32(n)
+	}
+
+	type span struct{ lo, hi uint32 }
+	var spans []span
+	for _, part := range strings.Split(spec, ",") {
+		lo, hi, isRange := strings.Cut(part, ":")
+		a := value(lo)
+		b := a
+		if isRange {
+			b = value(hi)
+		}
+		if a > b {
+			a, b = b, a
+		}
+		spans = append(spans, span{a, b})
+	}
+	return func(uid uint32) bool {
+		for _, sp := range spans {
+			if uid >= sp.lo && uid <= sp.hi {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func joinUIDs(uids []uint32) string {
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	var sb strings.Builder
+	for _, uid := range uids {
+		sb.WriteString(" " + strconv.FormatUint(uint64(uid), 10))
+	}
+	return sb.String()
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
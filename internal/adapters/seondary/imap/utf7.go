@@ -0,0 +1,47 @@
+package imap
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf16"
+)
+
+// utf7Encoding is the modified base64 alphabet of RFC 3501 section 5.1.3,
+// which uses "," instead of "/" and no padding.
+var utf7Encoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+,").WithPadding(base64.NoPadding)
+
+// encodeMailbox converts a UTF-8 mailbox name to IMAP's modified UTF-7.
+func encodeMailbox(name string) string {
+	var sb strings.Builder
+	var pending []rune
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		units := utf16.Encode(pending)
+		buf := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			buf = append(buf, byte(u>>8), byte(u))
+		}
+		sb.WriteByte('&')
+		sb.WriteString(utf7Encoding.EncodeToString(buf))
+		sb.WriteByte('-')
+		pending = pending[:0]
+	}
+
+	for _, r := range name {
+		switch {
+		case r == '&':
+			flush()
+			sb.WriteString("&-")
+		case r >= 0x20 && r <= 0x7e:
+			flush()
+			sb.WriteRune(r)
+		default:
+			pending = append(pending, r)
+		}
+	}
+	flush()
+	return sb.String()
+}
@@ -0,0 +1,156 @@
+package mailfile
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maildirSource reads the messages in cur/ and new/ in unique name order.
+// Deliveries and clients moving files from new/ to cur/ or changing their
+// flags shift any index into that list, so messages carry a SourceKey built
+// from the unique name (without the ":2," info) and the import skips the
+// ones it already handled rather than resuming at a position.
+type maildirSource struct {
+	id       string
+	files    []maildirFile
+	position int
+}
+
+type maildirFile struct {
+	path   string
+	unique string
+	isNew  bool
+}
+
+func openMaildir(id, path string) (*maildirSource, error) {
+	source := &maildirSource{id: id}
+	found := false
+	for _, sub := range []string{"cur", "new"} {
+		entries, err := os.ReadDir(filepath.Join(path, sub))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read maildir: %w", err)
+		}
+		found = true
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			source.files = append(source.files, maildirFile{
+				path:   filepath.Join(path, sub, entry.Name()),
+				unique: uniqueName(entry.Name()),
+				isNew:  sub == "new",
+			})
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s is not a maildir: no cur/ or new/ directory", path)
+	}
+	sort.SliceStable(source.files, func(i, j int) bool { return source.files[i].unique < source.files[j].unique })
+	return source, nil
+}
+
+func (s *maildirSource) ID() string {
+	return s.id
+}
+
+// Resume starts over: the files already handled are skipped by SourceKey,
+// which unlike the position survives files being delivered and moved.
+func (s *maildirSource) Resume(position int64) error {
+	s.position = 0
+	return nil
+}
+
+func (s *maildirSource) Progress() (int64, int64) {
+	return int64(s.position), int64(len(s.files))
+}
+
+func (s *maildirSource) Close() error {
+	return nil
+}
+
+func (s *maildirSource) Next() (*entities.RawMessage, error) {
+	if s.position >= len(s.files) {
+		return nil, io.EOF
+	}
+	file := s.files[s.position]
+	s.position++
+
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.path, err)
+	}
+
+	name := filepath.Base(file.path)
+	return &entities.RawMessage{
+		Name:       name,
+		SourceKey:  s.id + "#" + file.unique,
+		Data:       data,
+		Labels:     maildirLabels(name, file.isNew),
+		ReceivedAt: deliveryTime(file.path, name),
+	}, nil
+}
+
+// infoSeparators start the info part of a file name; ":" is replaced on
+// filesystems that don't allow it.
+var infoSeparators = []string{":2,", "!2,", ";2,"}
+
+// uniqueName strips the info part, which clients rewrite as flags change.
+func uniqueName(name string) string {
+	for _, sep := range infoSeparators {
+		if i := strings.LastIndex(name, sep); i >= 0 {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// maildirLabels maps the info flags after ":2," to Gmail-style labels.
+// Messages in new/ have not been seen by any client yet.
+func maildirLabels(name string, isNew bool) []string {
+	flags := ""
+	for _, sep := range infoSeparators {
+		if i := strings.LastIndex(name, sep); i >= 0 {
+			flags = name[i+len(sep):]
+			break
+		}
+	}
+
+	var labels []string
+	if isNew || !strings.ContainsRune(flags, 'S') {
+		labels = append(labels, "UNREAD")
+	}
+	if strings.ContainsRune(flags, 'F') {
+		labels = append(labels, "STARRED")
+	}
+	if strings.ContainsRune(flags, 'D') {
+		labels = append(labels, "DRAFT")
+	}
+	if strings.ContainsRune(flags, 'T') {
+		labels = append(labels, "TRASH")
+	}
+	return labels
+}
+
+// deliveryTime uses the Unix timestamp maildir file names start with, or the
+// file's modification time.
+func deliveryTime(path, name string) time.Time {
+	prefix, _, _ := strings.Cut(name, ".")
+	if secs, err := strconv.ParseInt(prefix, 10, 64); err == nil && secs > 0 {
+		return time.Unix(secs, 0).UTC()
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime().UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,352 @@
+package mailfile
+
+import (
+	"bufio"
+	"bytes"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	VariantMboxrd  = "mboxrd"
+	VariantMboxo   = "mboxo"
+	VariantMboxcl  = "mboxcl"
+	VariantMboxcl2 = "mboxcl2"
+)
+
+// mboxSource reads an mbox file message by message. Positions are byte
+// offsets of "From " separator lines, so an import can resume mid-file.
+type mboxSource struct {
+	id      string
+	variant string
+	file    *os.File
+	size    int64
+	r       *bufio.Reader
+	offset  int64
+
+	// next is the separator line of the following message, already read
+	// while looking for the end of the current one.
+	next       []byte
+	nextOffset int64
+}
+
+func openMbox(id, path, variant string) (*mboxSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mbox: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat mbox: %w", err)
+	}
+	return &mboxSource{
+		id:      id,
+		variant: variant,
+		file:    file,
+		size:    info.Size(),
+		r:       bufio.NewReaderSize(file, 64<<10),
+	}, nil
+}
+
+func (s *mboxSource) ID() string {
+	return s.id
+}
+
+func (s *mboxSource) Resume(position int64) error {
+	if position < 0 || position > s.size {
+		return fmt.Errorf("offset %d is outside %s (%d bytes)", position, s.id, s.size)
+	}
+	if _, err := s.file.Seek(position, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek mbox: %w", err)
+	}
+	s.r.Reset(s.file)
+	s.offset = position
+	s.next = nil
+
+	// The checkpoint must point at a separator, otherwise the file changed.
+	if position < s.size {
+		peek, _ := s.r.Peek(5)
+		if string(peek) != "From " {
+			return fmt.Errorf("offset %d of %s is not the start of a message", position, s.id)
+		}
+	}
+	return nil
+}
+
+func (s *mboxSource) Progress() (int64, int64) {
+	if s.next != nil {
+		return s.nextOffset, s.size
+	}
+	return s.offset, s.size
+}
+
+func (s *mboxSource) Close() error {
+	return s.file.Close()
+}
+
+func (s *mboxSource) Next() (*entities.RawMessage, error) {
+	separator := s.next
+	s.next = nil
+	for separator == nil {
+		start := s.offset
+		line, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if isBlank(line) {
+			continue
+		}
+		if !isSeparator(line) {
+			return nil, fmt.Errorf("%s: expected \"From \" line at offset %d", s.id, start)
+		}
+		separator = line
+	}
+
+	var buf bytes.Buffer
+	contentLength, done, err := s.readHeaders(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if !done {
+		if contentLength >= 0 {
+			done, err = s.readCounted(&buf, contentLength)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !done {
+			if err := s.readUntilSeparator(&buf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	data := buf.Bytes()
+	// The blank line before the next separator belongs to the mbox framing.
+	if bytes.HasSuffix(data, []byte("\r\n\r\n")) {
+		data = data[:len(data)-2]
+	} else if bytes.HasSuffix(data, []byte("\n\n")) {
+		data = data[:len(data)-1]
+	}
+
+	headers, _ := parser.ParseHeaders(data)
+	return &entities.RawMessage{
+		Data:       data,
+		Labels:     gmailLabels(headers),
+		ReceivedAt: separatorDate(separator),
+	}, nil
+}
+
+// readHeaders copies the header block and returns the Content-Length for the
+// mboxcl variants (-1 otherwise). done is set when the message ended early.
+func (s *mboxSource) readHeaders(buf *bytes.Buffer) (int, bool, error) {
+	contentLength := -1
+	for {
+		line, err := s.readLine()
+		if err == io.EOF {
+			return -1, true, nil
+		}
+		if err != nil {
+			return -1, false, err
+		}
+		if isSeparator(line) {
+			s.setNext(line)
+			return -1, true, nil
+		}
+		buf.Write(line)
+		if isBlank(line) {
+			return contentLength, false, nil
+		}
+
+		if s.variant == VariantMboxcl || s.variant == VariantMboxcl2 {
+			name, value, ok := strings.Cut(string(line), ":")
+			if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+				if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 0 {
+					contentLength = n
+				}
+			}
+		}
+	}
+}
+
+// readCounted reads a Content-Length body and checks that a separator or the
+// end of file follows. If not, the length was wrong and the caller falls
+// back to scanning for the next separator.
+func (s *mboxSource) readCounted(buf *bytes.Buffer, length int) (bool, error) {
+	body := make([]byte, length)
+	n, err := io.ReadFull(s.r, body)
+	s.offset += int64(n)
+	if s.variant == VariantMboxcl {
+		buf.Write(unescapeFrom(body[:n], false))
+	} else {
+		buf.Write(body[:n])
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read mbox: %w", err)
+	}
+
+	var blanks [][]byte
+	for {
+		line, err := s.readLine()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case isBlank(line):
+			blanks = append(blanks, line)
+		case isSeparator(line):
+			s.setNext(line)
+			return true, nil
+		default:
+			for _, blank := range blanks {
+				buf.Write(blank)
+			}
+			buf.Write(s.unescape(line))
+			return false, nil
+		}
+	}
+}
+
+func (s *mboxSource) readUntilSeparator(buf *bytes.Buffer) error {
+	for {
+		line, err := s.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if isSeparator(line) {
+			s.setNext(line)
+			return nil
+		}
+		buf.Write(s.unescape(line))
+	}
+}
+
+func (s *mboxSource) setNext(line []byte) {
+	s.next = line
+	s.nextOffset = s.offset - int64(len(line))
+}
+
+// readLine returns the next line including its terminator, or io.EOF.
+func (s *mboxSource) readLine() ([]byte, error) {
+	line, err := s.r.ReadBytes('\n')
+	s.offset += int64(len(line))
+	if err == io.EOF && len(line) > 0 {
+		return line, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read mbox: %w", err)
+	}
+	return line, err
+}
+
+// unescape undoes "From " quoting: mboxrd strips one '>' from any run of
+// them, mboxo and mboxcl only from a single one, mboxcl2 doesn't quote.
+func (s *mboxSource) unescape(line []byte) []byte {
+	switch s.variant {
+	case VariantMboxcl2:
+		return line
+	case VariantMboxrd:
+		return unescapeFrom(line, true)
+	default:
+		return unescapeFrom(line, false)
+	}
+}
+
+func unescapeFrom(data []byte, rd bool) []byte {
+	if !bytes.Contains(data, []byte(">From ")) {
+		return data
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		quoted := bytes.TrimLeft(line, ">")
+		depth := len(line) - len(quoted)
+		if depth == 0 || !bytes.HasPrefix(quoted, []byte("From ")) {
+			continue
+		}
+		if rd || depth == 1 {
+			lines[i] = line[1:]
+		}
+	}
+	return bytes.Join(lines, nil)
+}
+
+func isSeparator(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("From "))
+}
+
+func isBlank(line []byte) bool {
+	return len(bytes.TrimRight(line, "\r\n")) == 0
+}
+
+// separatorDate reads the asctime() date after the envelope sender, e.g.
+// "From alice@example.com Tue Jan  2 10:00:00 2024".
+func separatorDate(line []byte) time.Time {
+	fields := strings.Fields(string(line))
+	if len(fields) < 3 {
+		return time.Time{}
+	}
+	t, err := parser.ParseDate(strings.Join(fields[2:], " "))
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// takeoutLabels maps the system label names Google Takeout writes into
+// X-Gmail-Labels back to Gmail label IDs.
+var takeoutLabels = map[string]string{
+	"inbox":     "INBOX",
+	"unread":    "UNREAD",
+	"starred":   "STARRED",
+	"important": "IMPORTANT",
+	"sent":      "SENT",
+	"drafts":    "DRAFT",
+	"draft":     "DRAFT",
+	"spam":      "SPAM",
+	"trash":     "TRASH",
+	"opened":    "",
+}
+
+func gmailLabels(headers []parser.Header) []string {
+	var labels []string
+	for _, header := range headers {
+		if !strings.EqualFold(header.Name, "X-Gmail-Labels") {
+			continue
+		}
+		for _, label := range strings.Split(header.Value, ",") {
+			label = strings.Trim(strings.TrimSpace(label), `"`)
+			if label == "" {
+				continue
+			}
+			lower := strings.ToLower(label)
+			if id, ok := takeoutLabels[lower]; ok {
+				if id != "" {
+					labels = append(labels, id)
+				}
+				continue
+			}
+			if category, ok := strings.CutPrefix(lower, "category "); ok {
+				labels = append(labels, "CATEGORY_"+strings.ToUpper(category))
+				continue
+			}
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
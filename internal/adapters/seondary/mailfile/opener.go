@@ -0,0 +1,59 @@
+package mailfile
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type opener struct{}
+
+// NewOpener returns a MailSourceOpener for mbox files and Maildir
+// directories on the local filesystem.
+func NewOpener() outgoing.MailSourceOpener {
+	return opener{}
+}
+
+// Open opens path as the given format, detecting it when format is empty: a
+// directory is a Maildir, a file an mbox.
+func (opener) Open(path, format, variant string) (outgoing.MailSource, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	if format == "" {
+		format = entities.ImportFormatMbox
+		if info.IsDir() {
+			format = entities.ImportFormatMaildir
+		}
+	}
+
+	switch format {
+	case entities.ImportFormatMbox:
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory, not an mbox file", path)
+		}
+		switch variant {
+		case "":
+			variant = VariantMboxrd
+		case VariantMboxrd, VariantMboxo, VariantMboxcl, VariantMboxcl2:
+		default:
+			return nil, fmt.Errorf("unknown mbox variant %q", variant)
+		}
+		return openMbox("mbox:"+abs, abs, variant)
+	case entities.ImportFormatMaildir:
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is a file, not a maildir", path)
+		}
+		return openMaildir("maildir:"+abs, abs)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
@@ -0,0 +1,110 @@
+package s3bucket
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var _ outgoing.ArchiveStore = (*Storage)(nil)
+
+func (s *Storage) ListObjects(ctx context.Context, prefix, startAfter string, fn func(entities.StoredObject) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.BucketName),
+		Prefix: aws.String(s.Prefix + prefix),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+
+	datePattern := keyDatePattern(s.Prefix, s.KeyTemplate)
+	paginator := s3.NewListObjectsV2Paginator(s.Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects in %s: %w", s.BucketName, err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			kind := s.kind(key)
+			from, to := s.keyDates(key, kind, datePattern)
+			err := fn(entities.StoredObject{
+				Key:          key,
+				Kind:         kind,
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+				DateFrom:     from,
+				DateTo:       to,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Storage) GetObject(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("object %s: %w", key, entities.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// GetEmails reads a batch or per-message object in the format its key's
+// extension names.
+func (s *Storage) GetEmails(ctx context.Context, key string) (*entities.EmailList, error) {
+	format, ok := s.formatOf(key)
+	if !ok {
+		return nil, fmt.Errorf("no storage format for key %s", key)
+	}
+	data, err := s.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	emails, err := format.decode(data, s.kind(key) == entities.StoredMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return emails, nil
+}
+
+// ReplaceEmails overwrites a batch written by UploadEmails in place, keeping
+// its format.
+func (s *Storage) ReplaceEmails(ctx context.Context, key string, emails *entities.EmailList) error {
+	format, ok := s.formatOf(key)
+	if !ok {
+		return fmt.Errorf("no storage format for key %s", key)
+	}
+	if err := s.putBatch(ctx, key, format, emails); err != nil {
+		return fmt.Errorf("failed to replace %s in S3: %w", key, err)
+	}
+	return nil
+}
+
+// ReplaceEmail overwrites one per-message object in place, updating its
+// metadata and tags with it.
+func (s *Storage) ReplaceEmail(ctx context.Context, key string, email *entities.EmailMessage) error {
+	return s.putEmail(ctx, key, email)
+}
@@ -0,0 +1,243 @@
+package s3bucket
+
+import (
+	"bufio"
+	"bytes"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/parquet"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Formats of stored messages.
+const (
+	// FormatJSON is the indented JSON of an EmailList per batch or an
+	// EmailMessage per message.
+	FormatJSON = "json"
+	// FormatNDJSON is one EmailMessage per line.
+	FormatNDJSON = "ndjson"
+	// FormatParquet is one row per message in the schema of emailSchema.
+	FormatParquet = "parquet"
+)
+
+// Compressions of stored messages. JSON and NDJSON objects are compressed as
+// a whole and get a Content-Encoding; Parquet compresses its pages.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// ValidateFormat checks a format and compression pair.
+func ValidateFormat(format, compression string) error {
+	switch format {
+	case FormatJSON, FormatNDJSON, FormatParquet:
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+	return nil
+}
+
+// objectFormat is how one object is encoded. It is chosen by configuration
+// on write and recovered from the key's extension on read, so objects
+// written under an earlier configuration stay readable.
+type objectFormat struct {
+	format      string
+	compression string
+}
+
+// extension is what keys of the format end in: .json, .ndjson or .parquet,
+// plus .gz or .zst for compressed JSON so that Athena and other readers
+// detect the compression.
+func (f objectFormat) extension() string {
+	ext := "." + f.format
+	if f.format == FormatParquet {
+		return ext
+	}
+	switch f.compression {
+	case CompressionGzip:
+		ext += ".gz"
+	case CompressionZstd:
+		ext += ".zst"
+	}
+	return ext
+}
+
+// formatOf recovers the format from a key's extension.
+func formatOf(key string) (objectFormat, bool) {
+	compression := CompressionNone
+	if rest, ok := strings.CutSuffix(key, ".gz"); ok {
+		key, compression = rest, CompressionGzip
+	} else if rest, ok := strings.CutSuffix(key, ".zst"); ok {
+		key, compression = rest, CompressionZstd
+	}
+	for _, format := range []string{FormatJSON, FormatNDJSON, FormatParquet} {
+		if strings.HasSuffix(key, "."+format) {
+			if format == FormatParquet && compression != CompressionNone {
+				return objectFormat{}, false
+			}
+			return objectFormat{format: format, compression: compression}, true
+		}
+	}
+	return objectFormat{}, false
+}
+
+// formatOf is the format to read and rewrite key in. A Parquet key does not
+// name its page codec, so rewrites use the configured compression.
+func (s *Storage) formatOf(key string) (objectFormat, bool) {
+	format, ok := formatOf(key)
+	if ok && format.format == FormatParquet {
+		format.compression = s.Compression
+	}
+	return format, ok
+}
+
+func (f objectFormat) contentType() string {
+	switch f.format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/json"
+}
+
+// contentEncoding is the Content-Encoding of the object, empty when it is
+// not compressed as a whole.
+func (f objectFormat) contentEncoding() string {
+	if f.format == FormatParquet || f.compression == CompressionNone {
+		return ""
+	}
+	return f.compression
+}
+
+// encodeBatch encodes the messages of a batch. JSON keeps the whole
+// EmailList; NDJSON and Parquet keep only the messages.
+func (f objectFormat) encodeBatch(emails *entities.EmailList) ([]byte, error) {
+	switch f.format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(emails, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return f.compress(data)
+	case FormatNDJSON:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for i := range emails.Emails {
+			if err := encoder.Encode(&emails.Emails[i]); err != nil {
+				return nil, err
+			}
+		}
+		return f.compress(buf.Bytes())
+	case FormatParquet:
+		return encodeParquet(emails.Emails, f.codec())
+	}
+	return nil, fmt.Errorf("unknown format %q", f.format)
+}
+
+// encodeEmail encodes a single per-message object.
+func (f objectFormat) encodeEmail(email *entities.EmailMessage) ([]byte, error) {
+	if f.format == FormatJSON {
+		data, err := json.MarshalIndent(email, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return f.compress(data)
+	}
+	return f.encodeBatch(&entities.EmailList{Emails: []entities.EmailMessage{*email}, TotalCount: 1})
+}
+
+// decode reads an object written by encodeBatch or, when single, by
+// encodeEmail.
+func (f objectFormat) decode(data []byte, single bool) (*entities.EmailList, error) {
+	data, err := f.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var list entities.EmailList
+	switch f.format {
+	case FormatJSON:
+		if single {
+			list.Emails = make([]entities.EmailMessage, 1)
+			err = json.Unmarshal(data, &list.Emails[0])
+		} else {
+			err = json.Unmarshal(data, &list)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &list, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var email entities.EmailMessage
+			if err := json.Unmarshal(line, &email); err != nil {
+				return nil, fmt.Errorf("line %d: %w", len(list.Emails)+1, err)
+			}
+			list.Emails = append(list.Emails, email)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case FormatParquet:
+		if list.Emails, err = decodeParquet(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", f.format)
+	}
+	list.TotalCount = len(list.Emails)
+	return &list, nil
+}
+
+func (f objectFormat) codec() parquet.Codec {
+	switch f.compression {
+	case CompressionGzip:
+		return parquet.Gzip
+	case CompressionZstd:
+		return parquet.Zstd
+	}
+	return parquet.Uncompressed
+}
+
+func (f objectFormat) compress(data []byte) ([]byte, error) {
+	return f.codec().Compress(data)
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress undoes compress. Data without the compression's magic number is
+// returned as is, since a client honouring Content-Encoding may already have
+// decoded it.
+func (f objectFormat) decompress(data []byte) ([]byte, error) {
+	if f.format == FormatParquet {
+		return data, nil
+	}
+	switch f.compression {
+	case CompressionGzip:
+		if !bytes.HasPrefix(data, gzipMagic) {
+			return data, nil
+		}
+	case CompressionZstd:
+		if !bytes.HasPrefix(data, zstdMagic) {
+			return data, nil
+		}
+	}
+	return f.codec().Decompress(data)
+}
@@ -0,0 +1,194 @@
+package s3bucket
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testEmails() []entities.EmailMessage {
+	date := time.Date(2025, 3, 4, 10, 0, 0, 123456000, time.UTC)
+	return []entities.EmailMessage{
+		{
+			ID:         "full",
+			ThreadID:   "thread",
+			MessageID:  "<full@example.com>",
+			InReplyTo:  []string{"<parent@example.com>"},
+			References: []string{"<root@example.com>", "<parent@example.com>"},
+			Subject:    "Weekly sale",
+			From:       "Shop <deals@shop.example>",
+			To:         "me@example.com",
+			Date:       date,
+			ReceivedAt: date.Add(time.Minute),
+			Body:       "Everything must go",
+			Headers:    map[string]string{"Subject": "Weekly sale", "List-Unsubscribe": "<mailto:u@shop.example>"},
+			Addresses: entities.AddressHeaders{
+				From: []entities.Address{{Name: "Shop", LocalPart: "deals", Domain: "shop.example", Address: "deals@shop.example"}},
+				To: []entities.Address{
+					{LocalPart: "me", Domain: "example.com", Address: "me@example.com"},
+					{LocalPart: "you", Domain: "example.com", Address: "you@example.com", Group: "friends"},
+				},
+			},
+			Labels: []string{"INBOX", "CATEGORY_PROMOTIONS"},
+			Attachments: []entities.Attachment{
+				{ID: "a1", Name: "flyer.pdf", ContentType: "application/pdf", Size: 2048, Inline: true},
+				{Name: "terms.txt", Size: 12},
+			},
+			RawKey:        "raw/full.eml",
+			IsPromotional: true,
+			Classification: entities.Classification{
+				Category:   "promotional",
+				Score:      7,
+				Confidence: 0.75,
+				Signals:    []string{"keyword:sale", "header:List-Unsubscribe"},
+			},
+		},
+		{
+			ID:          "empty-lists",
+			InvalidDate: true,
+			InReplyTo:   []string{},
+			Headers:     map[string]string{},
+			Addresses:   entities.AddressHeaders{To: []entities.Address{}},
+			Labels:      []string{},
+			Attachments: []entities.Attachment{},
+			Classification: entities.Classification{
+				Signals: []string{},
+			},
+		},
+		{ID: "nil-lists", ReceivedAt: date},
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	formats := []string{FormatJSON, FormatNDJSON, FormatParquet}
+	compressions := []string{CompressionNone, CompressionGzip, CompressionZstd}
+	batches := []struct {
+		name   string
+		emails []entities.EmailMessage
+	}{
+		{"batch", testEmails()},
+		{"empty batch", nil},
+	}
+
+	for _, format := range formats {
+		for _, compression := range compressions {
+			f := objectFormat{format: format, compression: compression}
+			t.Run(format+"/"+compression, func(t *testing.T) {
+				key := "emails/key" + f.extension()
+				s := &Storage{Compression: compression}
+				if got, ok := s.formatOf(key); !ok || got != f {
+					t.Fatalf("formatOf(%q) = %v, %v", key, got, ok)
+				}
+
+				for _, batch := range batches {
+					data, err := f.encodeBatch(&entities.EmailList{Emails: batch.emails, TotalCount: len(batch.emails)})
+					if err != nil {
+						t.Fatalf("%s: encode: %v", batch.name, err)
+					}
+					list, err := f.decode(data, false)
+					if err != nil {
+						t.Fatalf("%s: decode: %v", batch.name, err)
+					}
+					assertEmails(t, f, list.Emails, batch.emails)
+				}
+
+				for _, email := range testEmails() {
+					data, err := f.encodeEmail(&email)
+					if err != nil {
+						t.Fatalf("%s: encode: %v", email.ID, err)
+					}
+					list, err := f.decode(data, true)
+					if err != nil {
+						t.Fatalf("%s: decode: %v", email.ID, err)
+					}
+					assertEmails(t, f, list.Emails, []entities.EmailMessage{email})
+				}
+			})
+		}
+	}
+}
+
+// assertEmails compares Parquet results field for field, nil and empty lists
+// included. JSON drops empty lists through omitempty, so JSON and NDJSON are
+// compared by their encoding.
+func assertEmails(t *testing.T, f objectFormat, got, want []entities.EmailMessage) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d emails, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if f.format == FormatParquet {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("email %s:\n got %+v\nwant %+v", want[i].ID, got[i], want[i])
+			}
+			continue
+		}
+		gotJSON, _ := json.Marshal(got[i])
+		wantJSON, _ := json.Marshal(want[i])
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("email %s:\n got %s\nwant %s", want[i].ID, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestParquetNormalizesTimes(t *testing.T) {
+	zone := time.FixedZone("CET", 3600)
+	email := entities.EmailMessage{ID: "tz", Date: time.Date(2025, 3, 4, 10, 0, 0, 123456789, zone)}
+
+	data, err := encodeParquet([]entities.EmailMessage{email}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emails, err := decodeParquet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2025, 3, 4, 9, 0, 0, 123456000, time.UTC)
+	if got := emails[0].Date; !got.Equal(want) || got.Location() != time.UTC || got.Nanosecond() != want.Nanosecond() {
+		t.Errorf("date = %v, want %v", got, want)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		key  string
+		want objectFormat
+		ok   bool
+	}{
+		{"a/b.json", objectFormat{FormatJSON, CompressionNone}, true},
+		{"a/b.ndjson.gz", objectFormat{FormatNDJSON, CompressionGzip}, true},
+		{"a/b.json.zst", objectFormat{FormatJSON, CompressionZstd}, true},
+		{"a/b.parquet", objectFormat{FormatParquet, CompressionNone}, true},
+		{"a/b.parquet.gz", objectFormat{}, false},
+		{"raw/b.eml", objectFormat{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := formatOf(tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("formatOf(%q) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestContentHeaders(t *testing.T) {
+	tests := []struct {
+		format             objectFormat
+		contentType, encod string
+	}{
+		{objectFormat{FormatJSON, CompressionNone}, "application/json", ""},
+		{objectFormat{FormatNDJSON, CompressionGzip}, "application/x-ndjson", "gzip"},
+		{objectFormat{FormatJSON, CompressionZstd}, "application/json", "zstd"},
+		{objectFormat{FormatParquet, CompressionZstd}, "application/vnd.apache.parquet", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.format.contentType(); got != tt.contentType {
+			t.Errorf("%v content type = %q, want %q", tt.format, got, tt.contentType)
+		}
+		if got := tt.format.contentEncoding(); got != tt.encod {
+			t.Errorf("%v content encoding = %q, want %q", tt.format, got, tt.encod)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package s3bucket
+
+import (
+	"context"
+	"email-parser-poc/internal/ports/outgoing"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var _ outgoing.HealthChecker = (*Storage)(nil)
+
+func (s *Storage) Name() string {
+	return "s3"
+}
+
+func (s *Storage) Critical() bool {
+	return true
+}
+
+// Check confirms the bucket exists and is reachable with our credentials.
+func (s *Storage) Check(ctx context.Context) error {
+	if _, err := s.Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.BucketName)}); err != nil {
+		return fmt.Errorf("bucket %s is not reachable: %w", s.BucketName, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package s3bucket
+
+import (
+	"crypto/rand"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Layouts of stored messages.
+const (
+	// LayoutMessage stores every message as its own object at the key
+	// template, so storing the same message again overwrites it.
+	LayoutMessage = "message"
+	// LayoutBatch stores each request as one emails_<timestamp>.json.
+	LayoutBatch = "batch"
+)
+
+// DefaultKeyTemplate partitions messages by mailbox and day. The .json
+// extension is replaced by that of the configured format.
+const DefaultKeyTemplate = "account={account}/date={date}/{id}.json"
+
+const (
+	manifestDir = "manifests/"
+	rawDir      = "raw/"
+)
+
+var placeholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// ValidateKeyTemplate checks a per-message key template. It may use
+// {account}, {date} (YYYY/MM/DD) and {id}, and must use {id} so that
+// different messages never share a key. Nothing else that can change over a
+// message's life may go into the key, or overwrites stop being idempotent.
+func ValidateKeyTemplate(template string) error {
+	if !strings.Contains(template, "{id}") {
+		return fmt.Errorf("key template %q must contain {id}", template)
+	}
+	if strings.HasPrefix(template, "/") {
+		return fmt.Errorf("key template %q must not start with /", template)
+	}
+	for _, name := range placeholder.FindAllString(template, -1) {
+		switch name {
+		case "{account}", "{date}", "{id}":
+		default:
+			return fmt.Errorf("key template %q: unknown placeholder %s", template, name)
+		}
+	}
+	for _, reserved := range []string{manifestDir, rawDir} {
+		if strings.HasPrefix(template, reserved) {
+			return fmt.Errorf("key template %q must not start with %s", template, reserved)
+		}
+	}
+	return nil
+}
+
+// messageKey renders the template for email. Values are path-escaped so an
+// ID or account cannot add key segments. A message without any date goes to
+// date=unknown rather than the current day, which would change on every
+// store. The key ends in the extension of the configured format.
+func (s *Storage) messageKey(email *entities.EmailMessage) string {
+	date := "unknown"
+	if day := email.Date; !day.IsZero() {
+		date = day.UTC().Format("2006/01/02")
+	} else if day := email.ReceivedAt; !day.IsZero() {
+		date = day.UTC().Format("2006/01/02")
+	}
+	account := s.Account
+	if account == "" {
+		account = "unknown"
+	}
+
+	key := strings.NewReplacer(
+		"{account}", url.PathEscape(account),
+		"{date}", date,
+		"{id}", url.PathEscape(email.ID),
+	).Replace(s.KeyTemplate)
+	return s.Prefix + strings.TrimSuffix(key, ".json") + s.format().extension()
+}
+
+// batchName is unique per call: the timestamp has microseconds and a random
+// suffix separates calls within the same one.
+func batchName(now time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return now.UTC().Format(batchTimeLayout) + "_" + hex.EncodeToString(suffix)
+}
+
+// batchTimeLayout is the timestamp in emails_<ts> batch keys.
+const batchTimeLayout = "20060102_150405.000000"
+
+// keyDatePattern matches keys rendered from template and captures their
+// {date}, or is nil when the template has none.
+func keyDatePattern(prefix, template string) *regexp.Regexp {
+	if !strings.Contains(template, "{date}") {
+		return nil
+	}
+	var pattern strings.Builder
+	pattern.WriteString("^" + regexp.QuoteMeta(prefix))
+	template = strings.TrimSuffix(template, ".json")
+	end := 0
+	for _, loc := range placeholder.FindAllStringIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[end:loc[0]]))
+		if template[loc[0]:loc[1]] == "{date}" {
+			pattern.WriteString(`(\d{4}/\d{2}/\d{2})`)
+		} else {
+			pattern.WriteString(`[^/]*`)
+		}
+		end = loc[1]
+	}
+	// Any extension, since the format and compression may have changed.
+	pattern.WriteString(regexp.QuoteMeta(template[end:]) + `\.[^/]+$`)
+	return regexp.MustCompile(pattern.String())
+}
+
+// keyDates reads the range of message dates an object holds from its key:
+// the whole UTC day of a per-message object's {date}, or the moment a batch
+// was stored.
+func (s *Storage) keyDates(key, kind string, datePattern *regexp.Regexp) (time.Time, time.Time) {
+	switch kind {
+	case entities.StoredBatch:
+		name := strings.TrimPrefix(key, s.Prefix+"emails_")
+		if len(name) < len(batchTimeLayout) {
+			return time.Time{}, time.Time{}
+		}
+		stored, err := time.Parse(batchTimeLayout, name[:len(batchTimeLayout)])
+		if err != nil {
+			return time.Time{}, time.Time{}
+		}
+		return stored, stored
+	case entities.StoredMessage:
+		if datePattern == nil {
+			return time.Time{}, time.Time{}
+		}
+		match := datePattern.FindStringSubmatch(key)
+		if match == nil {
+			return time.Time{}, time.Time{}
+		}
+		day, err := time.Parse("2006/01/02", match[1])
+		if err != nil {
+			return time.Time{}, time.Time{}
+		}
+		return day, day.Add(24*time.Hour - time.Nanosecond)
+	}
+	return time.Time{}, time.Time{}
+}
+
+// kind tells the objects this adapter writes apart by their key.
+func (s *Storage) kind(key string) string {
+	relative, ok := strings.CutPrefix(key, s.Prefix)
+	if !ok {
+		return ""
+	}
+	_, stored := formatOf(relative)
+	switch {
+	case strings.HasPrefix(relative, rawDir):
+		return entities.StoredRaw
+	case strings.HasPrefix(relative, manifestDir):
+		return entities.StoredManifest
+	case strings.HasPrefix(relative, "emails_") && stored && !strings.Contains(relative, "/"):
+		return entities.StoredBatch
+	case stored:
+		return entities.StoredMessage
+	}
+	return ""
+}
+
+// objectTags are stored as both user metadata and object tags: metadata
+// comes back with the object, tags can drive lifecycle rules and filters.
+func objectTags(email *entities.EmailMessage) map[string]string {
+	tags := map[string]string{}
+	if email.Classification.Category != "" {
+		tags["category"] = email.Classification.Category
+	}
+	if domain := email.SenderDomain(); domain != "" {
+		tags["sender-domain"] = domain
+	}
+	return tags
+}
@@ -0,0 +1,52 @@
+package s3bucket
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"testing"
+	"time"
+)
+
+func TestKeyDates(t *testing.T) {
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	endOfDay := day.Add(24*time.Hour - time.Nanosecond)
+	batchTime := time.Date(2025, 3, 3, 9, 30, 15, 123456000, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		key      string
+		from, to time.Time
+	}{
+		{name: "default template", key: "mail/account=me%40example.com/date=2025/03/03/abc.json", from: day, to: endOfDay},
+		{name: "compressed", key: "mail/account=me/date=2025/03/03/abc.ndjson.zst", from: day, to: endOfDay},
+		{name: "unknown date", key: "mail/account=me/date=unknown/abc.json"},
+		{name: "custom template", template: "{date}/{account}/{id}.json", key: "mail/2025/03/03/me/abc.parquet", from: day, to: endOfDay},
+		{name: "template without date", template: "{account}/{id}.json", key: "mail/me/abc.json"},
+		{name: "batch", key: "mail/emails_" + batchName(batchTime) + ".json.gz", from: batchTime, to: batchTime},
+		{name: "raw copy", key: "mail/raw/2025/03/03/abc.eml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := tt.template
+			if template == "" {
+				template = DefaultKeyTemplate
+			}
+			s := &Storage{Prefix: "mail/", KeyTemplate: template}
+			from, to := s.keyDates(tt.key, s.kind(tt.key), keyDatePattern(s.Prefix, s.KeyTemplate))
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("dates = %v .. %v, want %v .. %v", from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestKeyDatesMatchMessageKey(t *testing.T) {
+	s := &Storage{Prefix: "mail/", KeyTemplate: DefaultKeyTemplate, Account: "me@example.com", Format: FormatJSON}
+	email := &entities.EmailMessage{ID: "a.b/c", Date: time.Date(2025, 12, 31, 23, 59, 0, 0, time.FixedZone("", -3600))}
+
+	from, _ := s.keyDates(s.messageKey(email), entities.StoredMessage, keyDatePattern(s.Prefix, s.KeyTemplate))
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("from = %v, want %v", from, want)
+	}
+}
@@ -0,0 +1,423 @@
+package s3bucket
+
+import (
+	"bytes"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/parquet"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// emailSchema is the Parquet schema of EmailMessage. Columns are named after
+// the JSON fields, nested fields become groups, lists and maps. The schema is
+// part of the stored format: tables defined over it and decodeParquet rely
+// on it, so it changes only together with them. Timestamps are stored in UTC
+// with microsecond precision and are null when unset; headers are sorted by
+// name.
+var emailSchema = parquet.Node{
+	Name: "email",
+	Fields: []parquet.Node{
+		stringNode("id"),
+		stringNode("thread_id"),
+		stringNode("message_id"),
+		listNode("in_reply_to", stringNode("")),
+		listNode("references", stringNode("")),
+		stringNode("subject"),
+		stringNode("from"),
+		stringNode("to"),
+		timestampNode("date"),
+		timestampNode("received_at"),
+		boolNode("invalid_date"),
+		stringNode("body"),
+		{Name: "headers", Repetition: parquet.Optional, Logical: parquet.Map, Fields: []parquet.Node{
+			{Name: "key_value", Repetition: parquet.Repeated, Fields: []parquet.Node{
+				stringNode("key"),
+				stringNode("value"),
+			}},
+		}},
+		{Name: "addresses", Repetition: parquet.Required, Fields: []parquet.Node{
+			addressListNode("from"),
+			addressListNode("sender"),
+			addressListNode("reply_to"),
+			addressListNode("to"),
+			addressListNode("cc"),
+			addressListNode("bcc"),
+		}},
+		listNode("labels", stringNode("")),
+		listNode("attachments", parquet.Node{Fields: []parquet.Node{
+			stringNode("id"),
+			stringNode("name"),
+			stringNode("content_type"),
+			{Name: "size", Repetition: parquet.Required, Type: parquet.Int64},
+			boolNode("inline"),
+		}}),
+		stringNode("raw_key"),
+		boolNode("is_promotional"),
+		{Name: "classification", Repetition: parquet.Required, Fields: []parquet.Node{
+			stringNode("category"),
+			{Name: "score", Repetition: parquet.Required, Type: parquet.Int64},
+			{Name: "confidence", Repetition: parquet.Required, Type: parquet.Double},
+			listNode("signals", stringNode("")),
+		}},
+	},
+}
+
+func stringNode(name string) parquet.Node {
+	return parquet.Node{Name: name, Repetition: parquet.Required, Type: parquet.ByteArray, Logical: parquet.String}
+}
+
+func boolNode(name string) parquet.Node {
+	return parquet.Node{Name: name, Repetition: parquet.Required, Type: parquet.Boolean}
+}
+
+func timestampNode(name string) parquet.Node {
+	return parquet.Node{Name: name, Repetition: parquet.Optional, Type: parquet.Int64, Logical: parquet.TimestampMicros}
+}
+
+// listNode is a nullable list of required elements, so that a nil and an
+// empty slice read back as they were written.
+func listNode(name string, element parquet.Node) parquet.Node {
+	element.Name = "element"
+	element.Repetition = parquet.Required
+	return parquet.Node{Name: name, Repetition: parquet.Optional, Logical: parquet.List, Fields: []parquet.Node{
+		{Name: "list", Repetition: parquet.Repeated, Fields: []parquet.Node{element}},
+	}}
+}
+
+func addressListNode(name string) parquet.Node {
+	return listNode(name, parquet.Node{Fields: []parquet.Node{
+		stringNode("name"),
+		stringNode("local_part"),
+		stringNode("domain"),
+		stringNode("address"),
+		stringNode("group"),
+	}})
+}
+
+// shredder appends a record to the columns one leaf at a time, in the order
+// of emailSchema.Leaves.
+type shredder struct {
+	columns []parquet.Column
+	next    int
+}
+
+func (s *shredder) column() *parquet.Column {
+	c := &s.columns[s.next]
+	s.next++
+	return c
+}
+
+func (s *shredder) required(value any) {
+	c := s.column()
+	c.Values = append(c.Values, value)
+}
+
+func (s *shredder) timestamp(t time.Time) {
+	c := s.column()
+	if t.IsZero() {
+		c.Def = append(c.Def, 0)
+		return
+	}
+	c.Def = append(c.Def, 1)
+	c.Values = append(c.Values, t.UnixMicro())
+}
+
+// list writes one leaf of a list of n elements; isNil tells a nil list from
+// an empty one.
+func (s *shredder) list(n int, isNil bool, value func(i int) any) {
+	c := s.column()
+	switch {
+	case isNil:
+		c.Def, c.Rep = append(c.Def, 0), append(c.Rep, 0)
+	case n == 0:
+		c.Def, c.Rep = append(c.Def, 1), append(c.Rep, 0)
+	}
+	for i := range n {
+		rep := 1
+		if i == 0 {
+			rep = 0
+		}
+		c.Def, c.Rep = append(c.Def, 2), append(c.Rep, rep)
+		c.Values = append(c.Values, value(i))
+	}
+}
+
+func (s *shredder) strings(values []string) {
+	s.list(len(values), values == nil, func(i int) any { return values[i] })
+}
+
+func (s *shredder) addresses(addresses []entities.Address) {
+	for _, field := range []func(a entities.Address) string{
+		func(a entities.Address) string { return a.Name },
+		func(a entities.Address) string { return a.LocalPart },
+		func(a entities.Address) string { return a.Domain },
+		func(a entities.Address) string { return a.Address },
+		func(a entities.Address) string { return a.Group },
+	} {
+		s.list(len(addresses), addresses == nil, func(i int) any { return field(addresses[i]) })
+	}
+}
+
+func encodeParquet(emails []entities.EmailMessage, codec parquet.Codec) ([]byte, error) {
+	s := &shredder{columns: make([]parquet.Column, len(emailSchema.Leaves()))}
+	for i := range emails {
+		email := &emails[i]
+		s.next = 0
+		s.required(email.ID)
+		s.required(email.ThreadID)
+		s.required(email.MessageID)
+		s.strings(email.InReplyTo)
+		s.strings(email.References)
+		s.required(email.Subject)
+		s.required(email.From)
+		s.required(email.To)
+		s.timestamp(email.Date)
+		s.timestamp(email.ReceivedAt)
+		s.required(email.InvalidDate)
+		s.required(email.Body)
+
+		names := make([]string, 0, len(email.Headers))
+		for name := range email.Headers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		s.list(len(names), email.Headers == nil, func(i int) any { return names[i] })
+		s.list(len(names), email.Headers == nil, func(i int) any { return email.Headers[names[i]] })
+
+		s.addresses(email.Addresses.From)
+		s.addresses(email.Addresses.Sender)
+		s.addresses(email.Addresses.ReplyTo)
+		s.addresses(email.Addresses.To)
+		s.addresses(email.Addresses.Cc)
+		s.addresses(email.Addresses.Bcc)
+		s.strings(email.Labels)
+
+		attachments := email.Attachments
+		isNil := attachments == nil
+		s.list(len(attachments), isNil, func(i int) any { return attachments[i].ID })
+		s.list(len(attachments), isNil, func(i int) any { return attachments[i].Name })
+		s.list(len(attachments), isNil, func(i int) any { return attachments[i].ContentType })
+		s.list(len(attachments), isNil, func(i int) any { return attachments[i].Size })
+		s.list(len(attachments), isNil, func(i int) any { return attachments[i].Inline })
+
+		s.required(email.RawKey)
+		s.required(email.IsPromotional)
+		s.required(email.Classification.Category)
+		s.required(int64(email.Classification.Score))
+		s.required(email.Classification.Confidence)
+		s.strings(email.Classification.Signals)
+	}
+
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, emailSchema, len(emails), s.columns, codec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// assembler reads records back from the columns in the order they were
+// shredded.
+type assembler struct {
+	file    *parquet.File
+	cursors []columnCursor
+	next    int
+	err     error
+}
+
+type columnCursor struct {
+	entry, value int
+}
+
+func (a *assembler) column() (parquet.Leaf, parquet.Column, *columnCursor) {
+	i := a.next
+	a.next++
+	return a.file.Leaves[i], a.file.Columns[i], &a.cursors[i]
+}
+
+func (a *assembler) value(c parquet.Column, cursor *columnCursor) any {
+	if cursor.value >= len(c.Values) {
+		a.err = fmt.Errorf("column %s ends early", a.file.Leaves[a.next-1].Name())
+		return nil
+	}
+	v := c.Values[cursor.value]
+	cursor.value++
+	return v
+}
+
+func (a *assembler) string() string {
+	_, c, cursor := a.column()
+	v, _ := a.value(c, cursor).(string)
+	return v
+}
+
+func (a *assembler) bool() bool {
+	_, c, cursor := a.column()
+	v, _ := a.value(c, cursor).(bool)
+	return v
+}
+
+func (a *assembler) int64() int64 {
+	_, c, cursor := a.column()
+	v, _ := a.value(c, cursor).(int64)
+	return v
+}
+
+func (a *assembler) float64() float64 {
+	_, c, cursor := a.column()
+	v, _ := a.value(c, cursor).(float64)
+	return v
+}
+
+func (a *assembler) timestamp() time.Time {
+	_, c, cursor := a.column()
+	if cursor.entry >= len(c.Def) {
+		a.err = fmt.Errorf("column %s ends early", a.file.Leaves[a.next-1].Name())
+		return time.Time{}
+	}
+	def := c.Def[cursor.entry]
+	cursor.entry++
+	if def == 0 {
+		return time.Time{}
+	}
+	v, _ := a.value(c, cursor).(int64)
+	return time.UnixMicro(v).UTC()
+}
+
+// list reads the elements of one record's list; nil when the list is null.
+func (a *assembler) list() []any {
+	leaf, c, cursor := a.column()
+	if cursor.entry >= len(c.Def) {
+		a.err = fmt.Errorf("column %s ends early", leaf.Name())
+		return nil
+	}
+	values := []any{}
+	switch c.Def[cursor.entry] {
+	case 0:
+		cursor.entry++
+		return nil
+	case 1:
+		cursor.entry++
+		return values
+	}
+	for cursor.entry < len(c.Def) && (len(values) == 0 || c.Rep[cursor.entry] == 1) {
+		cursor.entry++
+		values = append(values, a.value(c, cursor))
+	}
+	return values
+}
+
+func (a *assembler) strings() []string {
+	values := a.list()
+	if values == nil {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i], _ = v.(string)
+	}
+	return result
+}
+
+func (a *assembler) addresses() []entities.Address {
+	names, locals, domains, addresses, groups := a.strings(), a.strings(), a.strings(), a.strings(), a.strings()
+	if names == nil {
+		return nil
+	}
+	result := make([]entities.Address, len(names))
+	for i := range result {
+		result[i] = entities.Address{
+			Name:      names[i],
+			LocalPart: at(locals, i),
+			Domain:    at(domains, i),
+			Address:   at(addresses, i),
+			Group:     at(groups, i),
+		}
+	}
+	return result
+}
+
+func at[T any](values []T, i int) T {
+	var zero T
+	if i >= len(values) {
+		return zero
+	}
+	return values[i]
+}
+
+func decodeParquet(data []byte) ([]entities.EmailMessage, error) {
+	file, err := parquet.Read(data)
+	if err != nil {
+		return nil, err
+	}
+	if got, want := leafNames(file.Leaves), leafNames(emailSchema.Leaves()); !slices.Equal(got, want) {
+		return nil, fmt.Errorf("parquet schema does not match the email schema")
+	}
+
+	a := &assembler{file: file, cursors: make([]columnCursor, len(file.Leaves))}
+	emails := make([]entities.EmailMessage, file.Rows)
+	for i := range emails {
+		a.next = 0
+		email := &emails[i]
+		email.ID = a.string()
+		email.ThreadID = a.string()
+		email.MessageID = a.string()
+		email.InReplyTo = a.strings()
+		email.References = a.strings()
+		email.Subject = a.string()
+		email.From = a.string()
+		email.To = a.string()
+		email.Date = a.timestamp()
+		email.ReceivedAt = a.timestamp()
+		email.InvalidDate = a.bool()
+		email.Body = a.string()
+
+		names, values := a.strings(), a.strings()
+		if names != nil {
+			email.Headers = make(map[string]string, len(names))
+			for j, name := range names {
+				email.Headers[name] = at(values, j)
+			}
+		}
+
+		email.Addresses.From = a.addresses()
+		email.Addresses.Sender = a.addresses()
+		email.Addresses.ReplyTo = a.addresses()
+		email.Addresses.To = a.addresses()
+		email.Addresses.Cc = a.addresses()
+		email.Addresses.Bcc = a.addresses()
+		email.Labels = a.strings()
+
+		ids, attachmentNames, types, sizes, inline := a.list(), a.list(), a.list(), a.list(), a.list()
+		if ids != nil {
+			email.Attachments = make([]entities.Attachment, len(ids))
+			for j := range ids {
+				attachment := &email.Attachments[j]
+				attachment.ID, _ = ids[j].(string)
+				attachment.Name, _ = at(attachmentNames, j).(string)
+				attachment.ContentType, _ = at(types, j).(string)
+				attachment.Size, _ = at(sizes, j).(int64)
+				attachment.Inline, _ = at(inline, j).(bool)
+			}
+		}
+
+		email.RawKey = a.string()
+		email.IsPromotional = a.bool()
+		email.Classification.Category = a.string()
+		email.Classification.Score = int(a.int64())
+		email.Classification.Confidence = a.float64()
+		email.Classification.Signals = a.strings()
+		if a.err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, a.err)
+		}
+	}
+	return emails, nil
+}
+
+func leafNames(leaves []parquet.Leaf) []string {
+	names := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		names[i] = leaf.Name()
+	}
+	return names
+}
@@ -5,64 +5,230 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"time"
 
 	"email-parser-poc/internal/domain/entities"
 	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/metrics"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type Storage struct {
-	Client     *s3.Client
-	BucketName string
+var tracer = otel.Tracer("email-parser-poc/internal/adapters/seondary/s3bucket")
+
+type Config struct {
+	AWS    aws.Config
+	Bucket string
+	// Prefix is prepended to every object key, e.g. "emails/".
+	Prefix string
+	// Endpoint overrides the S3 endpoint from the AWS config.
+	Endpoint string
+	// UsePathStyle addresses buckets as path segments, which LocalStack and
+	// most S3-compatible stores need.
+	UsePathStyle bool
+	// Layout is LayoutMessage (default) or LayoutBatch. KeyTemplate places
+	// messages below Prefix in the message layout, see ValidateKeyTemplate;
+	// Account fills its {account}. Manifests adds a manifest per call.
+	Layout      string
+	KeyTemplate string
+	Account     string
+	Manifests   bool
+	// Format is FormatJSON (default), FormatNDJSON or FormatParquet and
+	// Compression CompressionNone (default), CompressionGzip or
+	// CompressionZstd. They apply to new objects; existing ones are read
+	// in the format their key's extension names.
+	Format      string
+	Compression string
+	Metrics     *metrics.Metrics
 }
 
-func NewS3Storage(bucketName string, localstackEndpoint string) (outgoing.StorageService, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			"test-key", "test-secret", "test-session",
-		)),
-		config.WithRegion("us-east-1"),
-		config.WithBaseEndpoint(localstackEndpoint),
-	)
+type Storage struct {
+	Client      *s3.Client
+	BucketName  string
+	Prefix      string
+	Layout      string
+	KeyTemplate string
+	Account     string
+	Manifests   bool
+	Format      string
+	Compression string
+	Metrics     *metrics.Metrics
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+func NewS3Storage(config Config) (outgoing.StorageService, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket name is required")
+	}
+	switch config.Layout {
+	case "":
+		config.Layout = LayoutMessage
+	case LayoutMessage, LayoutBatch:
+	default:
+		return nil, fmt.Errorf("unknown s3 layout %q", config.Layout)
+	}
+	if config.KeyTemplate == "" {
+		config.KeyTemplate = DefaultKeyTemplate
+	}
+	if err := ValidateKeyTemplate(config.KeyTemplate); err != nil {
+		return nil, err
+	}
+	if config.Format == "" {
+		config.Format = FormatJSON
+	}
+	if config.Compression == "" {
+		config.Compression = CompressionNone
+	}
+	if err := ValidateFormat(config.Format, config.Compression); err != nil {
+		return nil, err
 	}
 
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.UsePathStyle = true
+	client := s3.NewFromConfig(config.AWS, func(o *s3.Options) {
+		o.UsePathStyle = config.UsePathStyle
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
 	})
 
 	return &Storage{
-		Client:     client,
-		BucketName: bucketName,
+		Client:      client,
+		BucketName:  config.Bucket,
+		Prefix:      config.Prefix,
+		Layout:      config.Layout,
+		KeyTemplate: config.KeyTemplate,
+		Account:     config.Account,
+		Manifests:   config.Manifests,
+		Format:      config.Format,
+		Compression: config.Compression,
+		Metrics:     config.Metrics,
 	}, nil
 }
 
-// Add the required methods to implement the StorageService interface
+// UploadEmails stores the messages in the configured layout. It returns the
+// batch key, the manifest key, or the prefix when the message layout writes
+// no manifest.
 func (s *Storage) UploadEmails(ctx context.Context, emails *entities.EmailList) (string, error) {
-	emailsJSON, err := json.MarshalIndent(emails, "", "  ")
+	if s.Layout == LayoutBatch {
+		return s.uploadBatch(ctx, emails)
+	}
+
+	manifest := entities.BatchManifest{CreatedAt: time.Now().UTC(), Count: len(emails.Emails)}
+	for i := range emails.Emails {
+		key := s.messageKey(&emails.Emails[i])
+		if err := s.putEmail(ctx, key, &emails.Emails[i]); err != nil {
+			return "", err
+		}
+		manifest.Emails = append(manifest.Emails, entities.ManifestEntry{ID: emails.Emails[i].ID, Key: key})
+	}
+	if !s.Manifests {
+		return s.Prefix, nil
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal emails to JSON: %w", err)
+		return "", fmt.Errorf("failed to marshal manifest to JSON: %w", err)
+	}
+	key := s.Prefix + manifestDir + batchName(manifest.CreatedAt) + ".json"
+	if err := s.putObject(ctx, metrics.ObjectManifest, key, manifestJSON, "application/json", "", nil); err != nil {
+		return "", fmt.Errorf("failed to upload manifest to S3: %w", err)
 	}
+	return key, nil
+}
 
-	filename := fmt.Sprintf("emails/emails_%s.json", time.Now().Format("20060102_150405"))
+func (s *Storage) uploadBatch(ctx context.Context, emails *entities.EmailList) (string, error) {
+	filename := fmt.Sprintf("%semails_%s%s", s.Prefix, batchName(time.Now()), s.format().extension())
+	if err := s.putBatch(ctx, filename, s.format(), emails); err != nil {
+		return "", fmt.Errorf("failed to upload emails to S3: %w", err)
+	}
+	return filename, nil
+}
 
-	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(s.BucketName),
-		Key:         aws.String(filename),
-		Body:        bytes.NewReader(emailsJSON),
-		ContentType: aws.String("application/json"),
-	})
+func (s *Storage) putBatch(ctx context.Context, key string, format objectFormat, emails *entities.EmailList) error {
+	body, err := format.encodeBatch(emails)
+	if err != nil {
+		return fmt.Errorf("failed to encode emails as %s: %w", format.format, err)
+	}
+	return s.putObject(ctx, metrics.ObjectBatch, key, body, format.contentType(), format.contentEncoding(), nil)
+}
 
+// putEmail writes one message with its category and sender domain as
+// metadata and tags, in the format the key's extension names.
+func (s *Storage) putEmail(ctx context.Context, key string, email *entities.EmailMessage) error {
+	format, ok := s.formatOf(key)
+	if !ok {
+		return fmt.Errorf("no storage format for key %s", key)
+	}
+	body, err := format.encodeEmail(email)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload emails to S3: %w", err)
+		return fmt.Errorf("failed to encode email %s as %s: %w", email.ID, format.format, err)
+	}
+	if err := s.putObject(ctx, metrics.ObjectMessage, key, body, format.contentType(), format.contentEncoding(), objectTags(email)); err != nil {
+		return fmt.Errorf("failed to upload email %s to S3: %w", email.ID, err)
 	}
+	return nil
+}
 
-	return filename, nil
+// format is the configured format of new objects.
+func (s *Storage) format() objectFormat {
+	return objectFormat{format: s.Format, compression: s.Compression}
+}
+
+// UploadRawEmail stores the original RFC 822 message as <prefix>raw/<id>.eml.
+// The key depends only on the message ID, so re-archiving is idempotent.
+func (s *Storage) UploadRawEmail(ctx context.Context, messageID string, raw []byte) (string, error) {
+	key := fmt.Sprintf("%sraw/%s.eml", s.Prefix, url.PathEscape(messageID))
+
+	if err := s.putObject(ctx, metrics.ObjectRaw, key, raw, "message/rfc822", "", nil); err != nil {
+		return "", fmt.Errorf("failed to upload raw email to S3: %w", err)
+	}
+
+	return key, nil
+}
+
+// putObject uploads body under key in its own span, recording size and
+// latency for the given object kind. contentEncoding is set when not empty;
+// tags become both user metadata and object tags.
+func (s *Storage) putObject(ctx context.Context, kind, key string, body []byte, contentType, contentEncoding string, tags map[string]string) error {
+	ctx, span := tracer.Start(ctx, "S3.PutObject",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.AWSS3Bucket(s.BucketName),
+			semconv.AWSS3Key(key),
+			attribute.Int("aws.s3.object_size", len(body)),
+		),
+	)
+	defer span.End()
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.BucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	}
+	if contentEncoding != "" {
+		input.ContentEncoding = aws.String(contentEncoding)
+	}
+	if len(tags) > 0 {
+		tagging := url.Values{}
+		for name, value := range tags {
+			tagging.Set(name, value)
+		}
+		input.Metadata = tags
+		input.Tagging = aws.String(tagging.Encode())
+	}
+
+	start := time.Now()
+	_, err := s.Client.PutObject(ctx, input)
+	s.Metrics.ObserveS3Upload(kind, len(body), time.Since(start), err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
 }
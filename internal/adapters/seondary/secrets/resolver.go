@@ -0,0 +1,136 @@
+// Package secrets resolves secret references in the configuration, so
+// tokens and keys can come from mounted files, the environment or a local
+// encrypted store instead of the YAML itself.
+package secrets
+
+import (
+	"context"
+	"email-parser-poc/internal/ports/outgoing"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reference prefixes. Any other value is used as it is, so existing
+// configurations keep working.
+const (
+	// PrefixFile reads the file at the path, e.g. file:///run/secrets/gmail.
+	// A trailing newline is dropped.
+	PrefixFile = "file://"
+	// PrefixEnv reads an environment variable, e.g. env:GMAIL_TOKEN.
+	PrefixEnv = "env:"
+	// PrefixStore reads a secret from the encrypted store, e.g.
+	// secret:gmail_token.
+	PrefixStore = "secret:"
+)
+
+// Config locates the encrypted store. The key is read from the KeyEnv
+// variable or, if that is unset, from KeyFile.
+type Config struct {
+	StoreFile string
+	KeyEnv    string
+	KeyFile   string
+}
+
+type resolver struct {
+	config Config
+
+	mu       sync.Mutex
+	store    *Store
+	storeMod time.Time
+}
+
+// NewResolver resolves references on every call, so a rotated file or store
+// entry is picked up without a restart. The store is decrypted once and only
+// reopened when its file's modification time changes.
+func NewResolver(config Config) outgoing.SecretResolver {
+	return &resolver{config: config}
+}
+
+func (r *resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	prefix, name := splitReference(ref)
+	switch prefix {
+	case PrefixFile:
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case PrefixEnv:
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return value, nil
+
+	case PrefixStore:
+		store, err := r.openStore()
+		if err != nil {
+			return "", err
+		}
+		return store.Get(name)
+	}
+	return ref, nil
+}
+
+func (r *resolver) openStore() (*Store, error) {
+	if r.config.StoreFile == "" {
+		return nil, fmt.Errorf("secrets.file is not set")
+	}
+
+	// A missing file has a zero modification time, so the store is reopened
+	// once it is created.
+	var modTime time.Time
+	info, err := os.Stat(r.config.StoreFile)
+	switch {
+	case err == nil:
+		modTime = info.ModTime()
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.store != nil && r.storeMod.Equal(modTime) {
+		return r.store, nil
+	}
+
+	key, err := LoadKey(r.config.KeyEnv, r.config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	store, err := OpenStore(r.config.StoreFile, key)
+	if err != nil {
+		return nil, err
+	}
+	r.store, r.storeMod = store, modTime
+	return store, nil
+}
+
+// ValidateReference checks the syntax of ref without resolving it.
+func ValidateReference(ref string) error {
+	if prefix, name := splitReference(ref); prefix != "" && name == "" {
+		return fmt.Errorf("secret reference %q is empty", ref)
+	}
+	return nil
+}
+
+// IsReference reports whether value is a reference rather than a literal.
+func IsReference(value string) bool {
+	prefix, _ := splitReference(value)
+	return prefix != ""
+}
+
+func splitReference(ref string) (prefix, name string) {
+	for _, prefix := range []string{PrefixFile, PrefixEnv, PrefixStore} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			return prefix, name
+		}
+	}
+	return "", ref
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testKeyEnv = "SECRETS_TEST_KEY"
+
+// newTestStore writes a store holding secrets and sets its key in the
+// environment.
+func newTestStore(t *testing.T, secrets map[string]string) string {
+	t.Helper()
+	t.Setenv(testKeyEnv, base64.StdEncoding.EncodeToString(testKey(1)))
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range secrets {
+		if err := store.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestResolve(t *testing.T) {
+	storeFile := newTestStore(t, map[string]string{"gmail_token": "from-store"})
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	writeFile(t, tokenFile, "from-file\n")
+	t.Setenv("SECRETS_TEST_TOKEN", "from-env")
+
+	tests := []struct {
+		name    string
+		config  Config
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{name: "literal", ref: "plain-token", want: "plain-token"},
+		{name: "file", ref: PrefixFile + tokenFile, want: "from-file"},
+		{name: "missing file", ref: PrefixFile + tokenFile + ".missing", wantErr: true},
+		{name: "env", ref: PrefixEnv + "SECRETS_TEST_TOKEN", want: "from-env"},
+		{name: "unset env", ref: PrefixEnv + "SECRETS_TEST_UNSET", wantErr: true},
+		{name: "store", config: Config{StoreFile: storeFile, KeyEnv: testKeyEnv}, ref: PrefixStore + "gmail_token", want: "from-store"},
+		{name: "store missing name", config: Config{StoreFile: storeFile, KeyEnv: testKeyEnv}, ref: PrefixStore + "graph_secret", wantErr: true},
+		{name: "store not configured", ref: PrefixStore + "gmail_token", wantErr: true},
+		{name: "store without key", config: Config{StoreFile: storeFile, KeyEnv: "SECRETS_TEST_UNSET"}, ref: PrefixStore + "gmail_token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewResolver(tt.config).Resolve(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveReopensStoreOnlyWhenModified(t *testing.T) {
+	path := newTestStore(t, map[string]string{"gmail_token": "v1"})
+	resolver := NewResolver(Config{StoreFile: path, KeyEnv: testKeyEnv})
+	resolve := func() string {
+		t.Helper()
+		value, err := resolver.Resolve(context.Background(), PrefixStore+"gmail_token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	if got := resolve(); got != "v1" {
+		t.Fatalf("first resolve = %q", got)
+	}
+
+	// Rotate the token but keep the old modification time: the cached
+	// store is still used.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("gmail_token", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(); got != "v1" {
+		t.Errorf("resolve with unchanged mtime = %q, want the cached v1", got)
+	}
+
+	// Once the modification time moves the store is reopened.
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(); got != "v2" {
+		t.Errorf("resolve after rotation = %q, want v2", got)
+	}
+}
+
+func TestValidateReference(t *testing.T) {
+	for _, ref := range []string{"", "literal", "file:///run/secrets/token", "env:TOKEN", "secret:token"} {
+		if err := ValidateReference(ref); err != nil {
+			t.Errorf("ValidateReference(%q) = %v", ref, err)
+		}
+	}
+	for _, ref := range []string{"file://", "env:", "secret:"} {
+		if err := ValidateReference(ref); err == nil {
+			t.Errorf("ValidateReference(%q) accepted an empty reference", ref)
+		}
+	}
+}
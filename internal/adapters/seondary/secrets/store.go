@@ -0,0 +1,181 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	storeVersion = 1
+	keySize      = 32
+)
+
+// Store is a local file of secrets, each sealed with AES-256-GCM under the
+// store key. Names are stored in the clear and bound to their value as
+// additional data, so a value cannot be moved to another name.
+type Store struct {
+	path    string
+	aead    cipher.AEAD
+	secrets map[string]string
+}
+
+type storeFile struct {
+	Version int               `json:"version"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// OpenStore reads the store at path; a missing file is an empty store that
+// is created by the first Set.
+func OpenStore(path string, key []byte) (*Store, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	store := &Store{path: path, aead: aead, secrets: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets file %s: %w", path, err)
+	}
+	if file.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported secrets file version %d", file.Version)
+	}
+	if file.Secrets != nil {
+		store.secrets = file.Secrets
+	}
+	return store, nil
+}
+
+func (s *Store) Get(name string) (string, error) {
+	sealed, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q: %w", name, entities.ErrNotFound)
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return "", fmt.Errorf("secret %q is corrupt", name)
+	}
+	nonce, ciphertext := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	value, err := s.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %q, wrong key?: %w", name, err)
+	}
+	return string(value), nil
+}
+
+// Set encrypts value under name and writes the store.
+func (s *Store) Set(name, value string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	s.secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+	return s.save()
+}
+
+// Names lists the stored secrets in order.
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// save replaces the file atomically, so a crash never leaves half a store.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(storeFile{Version: storeVersion, Secrets: s.secrets}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets file: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".secrets-*")
+	if err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+// LoadKey reads the base64 store key from the environment variable keyEnv,
+// falling back to keyFile.
+func LoadKey(keyEnv, keyFile string) ([]byte, error) {
+	encoded, source := "", ""
+	if keyEnv != "" {
+		encoded, source = os.Getenv(keyEnv), keyEnv
+	}
+	if encoded == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secrets key: %w", err)
+		}
+		encoded, source = string(data), keyFile
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("no secrets key: set %s or secrets.key_file", keyEnv)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secrets key in %s is not base64: %w", source, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("secrets key in %s must be %d bytes, got %d", source, keySize, len(key))
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random store key, base64 encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
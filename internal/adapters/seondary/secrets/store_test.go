@@ -0,0 +1,169 @@
+package secrets
+
+import (
+	"bytes"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("gmail_token", "ya29.token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("graph_secret", "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("ya29.token")) {
+		t.Error("secret value stored in the clear")
+	}
+
+	reopened, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := reopened.Get("gmail_token"); err != nil || value != "ya29.token" {
+		t.Errorf("Get = %q, %v", value, err)
+	}
+	if names := reopened.Names(); !slices.Equal(names, []string{"gmail_token", "graph_secret"}) {
+		t.Errorf("names = %v", names)
+	}
+	if _, err := reopened.Get("missing"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("Get(missing) err = %v", err)
+	}
+}
+
+func TestStoreWrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("gmail_token", "ya29.token"); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := OpenStore(path, testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Get("gmail_token"); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("Get with the wrong key err = %v", err)
+	}
+}
+
+// TestStoreBindsValueToName moves a sealed value to another name in the file;
+// the name is the additional data, so opening it must fail.
+func TestStoreBindsValueToName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("graph_secret", "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Secrets["gmail_token"] = file.Secrets["graph_secret"]
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := tampered.Get("gmail_token"); err == nil {
+		t.Errorf("moved value decrypted as %q", value)
+	}
+	if value, err := tampered.Get("graph_secret"); err != nil || value != "s3cr3t" {
+		t.Errorf("Get(graph_secret) = %q, %v", value, err)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "v2.enc"), `{"version": 2, "secrets": {}}`)
+	writeFile(t, filepath.Join(dir, "garbage.enc"), "not json")
+
+	tests := []struct {
+		name    string
+		path    string
+		key     []byte
+		wantErr string
+	}{
+		{name: "missing file", path: filepath.Join(dir, "missing.enc"), key: testKey(1)},
+		{name: "short key", path: filepath.Join(dir, "missing.enc"), key: []byte("short"), wantErr: "must be 32 bytes"},
+		{name: "unknown version", path: filepath.Join(dir, "v2.enc"), key: testKey(1), wantErr: "version 2"},
+		{name: "not JSON", path: filepath.Join(dir, "garbage.enc"), key: testKey(1), wantErr: "failed to decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := OpenStore(tt.path, tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(store.Names()) != 0 {
+				t.Errorf("names = %v", store.Names())
+			}
+		})
+	}
+
+	// The missing file is created by the first Set.
+	store, err := OpenStore(filepath.Join(dir, "new", "secrets.enc"), testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("bad name", "x"); err == nil {
+		t.Error("Set accepted a name with a space")
+	}
+	if err := store.Set("token", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new", "secrets.enc")); err != nil {
+		t.Errorf("store not written: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,87 @@
+package syncstate
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStore keeps watch state in a small JSON file so the `watch` command and
+// the server see the same history checkpoints.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(path string) outgoing.SyncStateStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) GetWatchState(ctx context.Context, emailAddress string) (*entities.WatchState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	state, ok := states[strings.ToLower(emailAddress)]
+	if !ok {
+		return nil, fmt.Errorf("watch state for %s: %w", emailAddress, entities.ErrNotFound)
+	}
+	return state, nil
+}
+
+func (s *FileStore) SaveWatchState(ctx context.Context, state *entities.WatchState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+	states[strings.ToLower(state.EmailAddress)] = state
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create sync state directory: %w", err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves half a file.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) read() (map[string]*entities.WatchState, error) {
+	states := make(map[string]*entities.WatchState)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("failed to decode sync state: %w", err)
+	}
+	return states, nil
+}
@@ -0,0 +1,157 @@
+package syncstate
+
+import (
+	"bufio"
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ImportStore keeps import checkpoints in checkpoints.json and the imported
+// Message-IDs in message_ids.txt, one per line. The ID file is append-only
+// and loaded into memory once, which stays cheap for millions of messages.
+type ImportStore struct {
+	dir string
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func NewImportStore(dir string) outgoing.ImportStateStore {
+	return &ImportStore{dir: dir}
+}
+
+func (s *ImportStore) GetCheckpoint(ctx context.Context, source string) (*entities.ImportCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.readCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, ok := checkpoints[source]
+	if !ok {
+		return nil, fmt.Errorf("import checkpoint for %s: %w", source, entities.ErrNotFound)
+	}
+	return checkpoint, nil
+}
+
+func (s *ImportStore) SaveCheckpoint(ctx context.Context, checkpoint *entities.ImportCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.readCheckpoints()
+	if err != nil {
+		return err
+	}
+	checkpoints[checkpoint.Source] = checkpoint
+
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal import checkpoints: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create import state directory: %w", err)
+	}
+
+	path := filepath.Join(s.dir, "checkpoints.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write import checkpoints: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write import checkpoints: %w", err)
+	}
+	return nil
+}
+
+func (s *ImportStore) HasMessageID(ctx context.Context, messageID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadIDs(); err != nil {
+		return false, err
+	}
+	return s.ids[messageID], nil
+}
+
+func (s *ImportStore) AddMessageIDs(ctx context.Context, messageIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadIDs(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create import state directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.dir, "message_ids.txt"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open message ID index: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	for _, id := range messageIDs {
+		if s.ids[id] {
+			continue
+		}
+		s.ids[id] = true
+		w.WriteString(id + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write message ID index: %w", err)
+	}
+	return file.Close()
+}
+
+func (s *ImportStore) loadIDs() error {
+	if s.ids != nil {
+		return nil
+	}
+	ids := make(map[string]bool)
+
+	file, err := os.Open(filepath.Join(s.dir, "message_ids.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		s.ids = ids
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read message ID index: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			ids[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read message ID index: %w", err)
+	}
+	s.ids = ids
+	return nil
+}
+
+func (s *ImportStore) readCheckpoints() (map[string]*entities.ImportCheckpoint, error) {
+	checkpoints := make(map[string]*entities.ImportCheckpoint)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "checkpoints.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import checkpoints: %w", err)
+	}
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to decode import checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
@@ -0,0 +1,120 @@
+package token
+
+import (
+	"context"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCredentialsConfig configures the OAuth 2.0 client credentials grant
+// (RFC 6749 section 4.4), as used by daemon apps against Microsoft Graph.
+type ClientCredentialsConfig struct {
+	TokenURL string
+	ClientID string
+	// ClientSecret is resolved through Secrets when set, so it may be a
+	// reference such as file:///run/secrets/graph.
+	ClientSecret string
+	Secrets      outgoing.SecretResolver
+	Scopes       []string
+	HTTPClient   *http.Client
+	Logger       *slog.Logger
+}
+
+// MicrosoftTokenURL returns the v2.0 token endpoint of an Entra ID tenant.
+func MicrosoftTokenURL(tenantID string) string {
+	return fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenantID))
+}
+
+type clientCredentialsProvider struct {
+	config ClientCredentialsConfig
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewClientCredentialsProvider(config ClientCredentialsConfig) outgoing.TokenProvider {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	config.Logger = logging.OrDefault(config.Logger)
+	return &clientCredentialsProvider{config: config}
+}
+
+// GetAccessToken returns the cached token, requesting a new one a minute
+// before it expires. Failures are logged and yield an empty token, which the
+// API then rejects with 401.
+func (p *clientCredentialsProvider) GetAccessToken() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Until(p.expiresAt) > time.Minute {
+		return p.token
+	}
+
+	token, expiresIn, err := p.requestToken(context.Background())
+	if err != nil {
+		p.config.Logger.Error("failed to get access token", "token_url", p.config.TokenURL, "error", err)
+		return ""
+	}
+	p.token = token
+	p.expiresAt = time.Now().Add(expiresIn)
+	return p.token
+}
+
+func (p *clientCredentialsProvider) requestToken(ctx context.Context) (string, time.Duration, error) {
+	clientSecret := p.config.ClientSecret
+	if p.config.Secrets != nil {
+		var err error
+		if clientSecret, err = p.config.Secrets.Resolve(ctx, clientSecret); err != nil {
+			return "", 0, fmt.Errorf("failed to resolve client secret: %w", err)
+		}
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", clientSecret)
+	if len(p.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", 0, fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response has no access_token")
+	}
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,35 @@
+package token
+
+import (
+	"context"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"log/slog"
+)
+
+type secretTokenProvider struct {
+	secrets outgoing.SecretResolver
+	ref     string
+	logger  *slog.Logger
+}
+
+// NewSecretTokenProvider returns the access token ref resolves to, e.g. a
+// file that an external refresher rewrites. ref is resolved on every call.
+func NewSecretTokenProvider(secrets outgoing.SecretResolver, ref string, logger *slog.Logger) outgoing.TokenProvider {
+	return &secretTokenProvider{
+		secrets: secrets,
+		ref:     ref,
+		logger:  logging.OrDefault(logger),
+	}
+}
+
+// GetAccessToken logs a failed lookup and yields an empty token, which the
+// API then rejects with 401.
+func (p *secretTokenProvider) GetAccessToken() string {
+	token, err := p.secrets.Resolve(context.Background(), p.ref)
+	if err != nil {
+		p.logger.Error("failed to resolve access token", "error", err)
+		return ""
+	}
+	return token
+}
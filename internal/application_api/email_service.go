@@ -3,41 +3,217 @@ package application_api
 import (
 	"context"
 	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/threading"
 	"email-parser-poc/internal/ports/incoming"
 	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
+	"errors"
 	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("email-parser-poc/internal/application_api")
+
 type EmailServie struct {
 	EmailRepo      outgoing.EmailRepository
 	StorageService outgoing.StorageService
 	Dbservice      outgoing.DbService
+	PostProcessor  *PostProcessor
+	ArchiveRaw     bool
+	Logger         *slog.Logger
+	Metrics        *metrics.Metrics
 }
 
-func NewEmailService(emailRepo outgoing.EmailRepository, storageService outgoing.StorageService, dbservice outgoing.DbService) incoming.EmailService {
+func NewEmailService(emailRepo outgoing.EmailRepository, storageService outgoing.StorageService, dbservice outgoing.DbService, postProcessor *PostProcessor, archiveRaw bool, logger *slog.Logger, m *metrics.Metrics) incoming.EmailService {
 	return &EmailServie{
 		EmailRepo:      emailRepo,
 		StorageService: storageService,
 		Dbservice:      dbservice,
+		PostProcessor:  postProcessor,
+		ArchiveRaw:     archiveRaw,
+		Logger:         logging.OrDefault(logger),
+		Metrics:        m,
 	}
 }
-func (s EmailServie) GetEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, string, error) {
-	emailList, err := s.EmailRepo.FetchEmails(ctx, filter)
+func (s EmailServie) GetEmails(ctx context.Context, filter entities.EmailFilter) (_ *entities.EmailList, _ string, err error) {
+	ctx, span := tracer.Start(ctx, "EmailService.GetEmails", trace.WithAttributes(
+		attribute.Int("email.max_results", filter.MaxResults),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
+	emailList, err := s.FetchEmails(ctx, filter)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to fetch emails: %w", err)
+		return nil, "", err
 	}
-	fmt.Println("emaillist got")
-	if err := s.Dbservice.UploadHeaders(ctx, emailList); err != nil {
-		return nil, "", fmt.Errorf("failed to store emails-headers in db: %w", err)
+
+	if s.ArchiveRaw {
+		if err := archiveRawEmails(ctx, s.EmailRepo, s.StorageService, emailList); err != nil {
+			return nil, "", err
+		}
 	}
-	filename, err := s.StorageService.UploadEmails(ctx, emailList)
+
+	filename, err := storeEmails(ctx, s.Dbservice, s.StorageService, emailList)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to store emails: %w", err)
+		return nil, "", err
+	}
+	s.Metrics.MessagesStored(metrics.PipelineFetch, len(emailList.Emails))
+
+	s.PostProcessor.Apply(ctx, emailList.Emails)
+
+	s.Logger.InfoContext(ctx, "stored emails", "count", len(emailList.Emails), "file", filename)
+
+	return emailList, filename, nil
+
+}
+
+func (s EmailServie) FetchEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, error) {
+	emailList, err := s.EmailRepo.FetchEmails(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch emails: %w", err)
+	}
+	s.Logger.DebugContext(ctx, "fetched emails", "count", len(emailList.Emails), "failed", emailList.Failed)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("email.fetched", len(emailList.Emails)))
+	recordReceived(s.Metrics, metrics.PipelineFetch, emailList.Emails)
+	threading.AssignThreadIDs(emailList.Emails)
+	return emailList, nil
+}
+
+// threadScanPage and threadScanLimit bound how much of the mailbox GetThread
+// reads to rebuild a thread for a source without native threads.
+const (
+	threadScanPage  = 200
+	threadScanLimit = 2000
+)
+
+func (s EmailServie) GetThread(ctx context.Context, threadID string) (*entities.Thread, error) {
+	messages, err := s.EmailRepo.FetchThread(ctx, threadID)
+	if errors.Is(err, entities.ErrNotSupported) {
+		messages, err = s.scanThread(ctx, threadID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch thread: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("thread %s: %w", threadID, entities.ErrNotFound)
 	}
 
-	fmt.Println("all function called")
+	return threading.Conversation(threadID, messages), nil
+}
+
+// scanThread rebuilds a thread from headers for sources such as IMAP that
+// can't look one up by ID: it reads the mailbox page by page, assigns thread
+// IDs with threading.AssignThreadIDs as fetches do, and keeps the messages of
+// threadID.
+func (s EmailServie) scanThread(ctx context.Context, threadID string) ([]entities.EmailMessage, error) {
+	var all []entities.EmailMessage
+	pageToken := ""
+	for scanned := 0; scanned < threadScanLimit; {
+		page, err := s.EmailRepo.FetchEmails(ctx, entities.EmailFilter{MaxResults: threadScanPage, PageToken: pageToken})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Emails...)
+		scanned += len(page.Emails) + page.Failed
+		if len(page.Emails) == 0 || page.NextPageToken == "" || page.NextPageToken == pageToken {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	threading.AssignThreadIDs(all)
+	var messages []entities.EmailMessage
+	for _, email := range all {
+		if email.ThreadID == threadID {
+			messages = append(messages, email)
+		}
+	}
+	return messages, nil
+}
 
+// IngestRawEmails parses, classifies and stores messages that did not come
+// from the mailbox, e.g. uploaded .eml files. Any unparseable message rejects
+// the whole batch with entities.ErrInvalidMessage before anything is stored.
+func (s EmailServie) IngestRawEmails(ctx context.Context, messages []entities.RawMessage) (*entities.EmailList, string, error) {
+	emails := make([]entities.EmailMessage, 0, len(messages))
+	for _, raw := range messages {
+		email, _, err := parseRawEmail(raw)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", raw.Name, err)
+		}
+		emails = append(emails, *email)
+	}
+	recordReceived(s.Metrics, metrics.PipelineIngest, emails)
+	threading.AssignThreadIDs(emails)
+
+	if s.ArchiveRaw {
+		for i := range emails {
+			key, err := s.StorageService.UploadRawEmail(ctx, emails[i].ID, messages[i].Data)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to archive raw email %s: %w", emails[i].ID, err)
+			}
+			emails[i].RawKey = key
+		}
+	}
+
+	emailList := &entities.EmailList{Emails: emails, TotalCount: len(emails)}
+	filename, err := storeEmails(ctx, s.Dbservice, s.StorageService, emailList)
+	if err != nil {
+		return nil, "", err
+	}
+	s.Metrics.MessagesStored(metrics.PipelineIngest, len(emails))
 	return emailList, filename, nil
+}
+
+// archiveRawEmails stores the original RFC 822 bytes of every message next to
+// the parsed JSON and records the object key on the message. Any failure fails
+// the batch, since the raw copy is what legal hold relies on.
+func archiveRawEmails(ctx context.Context, repo outgoing.EmailRepository, storage outgoing.StorageService, emails *entities.EmailList) error {
+	for i := range emails.Emails {
+		email := &emails.Emails[i]
+
+		raw, err := repo.FetchRawEmail(ctx, email.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch raw email %s: %w", email.ID, err)
+		}
+		key, err := storage.UploadRawEmail(ctx, email.ID, raw)
+		if err != nil {
+			return fmt.Errorf("failed to archive raw email %s: %w", email.ID, err)
+		}
+		email.RawKey = key
+	}
+	return nil
+}
 
+// storeEmails writes headers to the database and the full messages to object
+// storage, returning the storage key.
+func storeEmails(ctx context.Context, db outgoing.DbService, storage outgoing.StorageService, emails *entities.EmailList) (string, error) {
+	if err := db.UploadHeaders(ctx, emails); err != nil {
+		return "", fmt.Errorf("failed to store emails-headers in db: %w", err)
+	}
+	filename, err := storage.UploadEmails(ctx, emails)
+	if err != nil {
+		return "", fmt.Errorf("failed to store emails: %w", err)
+	}
+	return filename, nil
+}
+
+// recordReceived counts messages entering a pipeline along with the
+// classification each was given on the way in.
+func recordReceived(m *metrics.Metrics, pipeline string, emails []entities.EmailMessage) {
+	m.MessagesFetched(pipeline, len(emails))
+	for i := range emails {
+		m.MessageClassified(emails[i].Classification.Category, emails[i].IsPromotional)
+	}
 }
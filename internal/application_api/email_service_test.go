@@ -0,0 +1,61 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/imap"
+	"email-parser-poc/internal/adapters/seondary/imap/imapfake"
+	"email-parser-poc/internal/domain/entities"
+	"slices"
+	"testing"
+)
+
+func rawMessage(subject, messageID, inReplyTo string) []byte {
+	raw := "From: Ann <ann@example.com>\r\n" +
+		"To: me@example.com\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: Mon, 3 Mar 2025 10:00:00 +0000\r\n" +
+		"Message-ID: <" + messageID + ">\r\n"
+	if inReplyTo != "" {
+		raw += "In-Reply-To: <" + inReplyTo + ">\r\n"
+	}
+	return []byte(raw + "\r\nHello\r\n")
+}
+
+// TestGetThreadRebuildsFromHeaders checks that sources without thread
+// lookups, such as IMAP, still serve threads by the IDs fetches assigned.
+func TestGetThreadRebuildsFromHeaders(t *testing.T) {
+	server := imapfake.NewServer()
+	server.AddMessage("INBOX", imapfake.Message{Raw: rawMessage("Plans", "plans@example.com", "")})
+	server.AddMessage("INBOX", imapfake.Message{Raw: rawMessage("Lunch", "lunch@example.com", "")})
+	server.AddMessage("INBOX", imapfake.Message{Raw: rawMessage("Re: Plans", "re-plans@example.com", "plans@example.com")})
+	repo := imap.NewImapRepository(imap.Config{
+		Security: imap.SecurityNone,
+		Username: server.Username,
+		Password: server.Password,
+		Dial:     server.Dial,
+	})
+	service := NewEmailService(repo, nil, nil, nil, false, nil, nil)
+	ctx := context.Background()
+
+	list, err := service.FetchEmails(ctx, entities.EmailFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	threadID := list.Emails[0].ThreadID
+
+	thread, err := service.GetThread(ctx, threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, msg := range thread.Messages {
+		subjects = append(subjects, msg.Subject)
+	}
+	if want := []string{"Plans", "Re: Plans"}; !slices.Equal(subjects, want) {
+		t.Errorf("thread = %v, want %v", subjects, want)
+	}
+
+	if _, err := service.GetThread(ctx, "missing"); err == nil {
+		t.Error("missing thread: no error")
+	}
+}
@@ -3,27 +3,169 @@ package application_api
 import (
 	"context"
 	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultHealthCheckTimeout = 2 * time.Second
+	defaultHealthCacheTTL     = 5 * time.Second
+)
+
 type HealthService struct {
 	StartTime time.Time
 	Version   string
+	Checkers  []outgoing.HealthChecker
+	// Timeout bounds each check; CacheTTL is how long a readiness result is
+	// reused, so frequent probes don't hammer the dependencies.
+	Timeout  time.Duration
+	CacheTTL time.Duration
+
+	mu       sync.Mutex
+	cached   *entities.Health
+	cachedAt time.Time
 }
 
-func NewHealthService(version string) *HealthService {
+func NewHealthService(version string, timeout, cacheTTL time.Duration, checkers ...outgoing.HealthChecker) *HealthService {
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	if cacheTTL < 0 {
+		cacheTTL = defaultHealthCacheTTL
+	}
 	return &HealthService{
 		StartTime: time.Now(),
 		Version:   version,
+		Checkers:  checkers,
+		Timeout:   timeout,
+		CacheTTL:  cacheTTL,
 	}
 }
 
+func (s *HealthService) CheckLiveness(ctx context.Context) (*entities.Health, error) {
+	return s.newHealth(), nil
+}
+
+// CheckHealth runs every checker concurrently, each under its own timeout.
+// Callers arriving while a run is in flight wait for it and share the result.
 func (s *HealthService) CheckHealth(ctx context.Context) (*entities.Health, error) {
-	health := &entities.Health{
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.cachedAt) < s.CacheTTL {
+		health := *s.cached
+		health.Uptime = time.Since(s.StartTime)
+		return &health, nil
+	}
+
+	results := make([]entities.HealthCheck, len(s.Checkers))
+	var wg sync.WaitGroup
+	for i, checker := range s.Checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.runCheck(ctx, checker)
+		}()
+	}
+	wg.Wait()
+
+	health := s.newHealth()
+	for i, checker := range s.Checkers {
+		health.Addcheck(checker.Name(), results[i])
+	}
+
+	s.cached, s.cachedAt = health, time.Now()
+	return health, nil
+}
+
+func (s *HealthService) newHealth() *entities.Health {
+	return &entities.Health{
 		Status:    entities.HealthStatusHealthy,
 		TimeStamp: time.Now(),
 		Version:   s.Version,
 		Uptime:    time.Since(s.StartTime),
 	}
-	return health, nil
+}
+
+func (s *HealthService) runCheck(ctx context.Context, checker outgoing.HealthChecker) entities.HealthCheck {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checkSafely(ctx, checker)
+	result := entities.HealthCheck{
+		Status:     entities.HealthStatusHealthy,
+		TimeStamps: start,
+		Duration:   time.Since(start),
+		Critical:   checker.Critical(),
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, entities.ErrDegraded):
+		result.Status = entities.HealthStatusDegraded
+		result.Error = err.Error()
+	case ctx.Err() != nil:
+		result.Status = entities.HealthStatusUnhealthy
+		result.Error = fmt.Sprintf("timed out after %s", s.Timeout)
+	default:
+		result.Status = entities.HealthStatusUnhealthy
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkSafely turns a panicking checker into a failed check instead of
+// taking the server down.
+func checkSafely(ctx context.Context, checker outgoing.HealthChecker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+	}()
+	return checker.Check(ctx)
+}
+
+// Heartbeat is a health checker for background loops: the loop calls Beat
+// on every iteration and the check degrades once MaxAge passes without one.
+type Heartbeat struct {
+	name   string
+	maxAge atomic.Int64
+	last   atomic.Int64
+}
+
+func NewHeartbeat(name string, maxAge time.Duration) *Heartbeat {
+	h := &Heartbeat{name: name}
+	h.SetMaxAge(maxAge)
+	h.Beat()
+	return h
+}
+
+// SetMaxAge changes the allowed gap between beats, e.g. when the loop's
+// interval is reloaded.
+func (h *Heartbeat) SetMaxAge(maxAge time.Duration) {
+	h.maxAge.Store(int64(maxAge))
+}
+
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+func (h *Heartbeat) Name() string {
+	return h.name
+}
+
+func (h *Heartbeat) Critical() bool {
+	return false
+}
+
+func (h *Heartbeat) Check(ctx context.Context) error {
+	since := time.Since(time.Unix(0, h.last.Load()))
+	if since > time.Duration(h.maxAge.Load()) {
+		return fmt.Errorf("no heartbeat for %s: %w", since.Round(time.Second), entities.ErrDegraded)
+	}
+	return nil
 }
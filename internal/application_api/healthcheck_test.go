@@ -0,0 +1,191 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeChecker runs check, or passes when check is nil.
+type fakeChecker struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+	calls    atomic.Int32
+}
+
+func (c *fakeChecker) Name() string   { return c.name }
+func (c *fakeChecker) Critical() bool { return c.critical }
+
+func (c *fakeChecker) Check(ctx context.Context) error {
+	c.calls.Add(1)
+	if c.check == nil {
+		return nil
+	}
+	return c.check(ctx)
+}
+
+func failing(err error) func(context.Context) error {
+	return func(context.Context) error { return err }
+}
+
+func TestCheckHealthStatus(t *testing.T) {
+	down := errors.New("connection refused")
+	slow := fmt.Errorf("throttled: %w", entities.ErrDegraded)
+
+	tests := []struct {
+		name     string
+		checkers []*fakeChecker
+		want     entities.HealthStatus
+	}{
+		{name: "no checkers", want: entities.HealthStatusHealthy},
+		{
+			name:     "all pass",
+			checkers: []*fakeChecker{{name: "s3", critical: true}, {name: "gmail"}},
+			want:     entities.HealthStatusHealthy,
+		},
+		{
+			name:     "critical fails",
+			checkers: []*fakeChecker{{name: "s3", critical: true, check: failing(down)}, {name: "gmail"}},
+			want:     entities.HealthStatusUnhealthy,
+		},
+		{
+			name:     "non-critical fails",
+			checkers: []*fakeChecker{{name: "s3", critical: true}, {name: "gmail", check: failing(down)}},
+			want:     entities.HealthStatusDegraded,
+		},
+		{
+			name:     "critical degraded",
+			checkers: []*fakeChecker{{name: "s3", critical: true, check: failing(slow)}},
+			want:     entities.HealthStatusDegraded,
+		},
+		{
+			// A degraded or non-critical failure seen after the critical
+			// failure must not mask it, whatever the map order.
+			name: "critical fails among degraded",
+			checkers: []*fakeChecker{
+				{name: "a", check: failing(slow)},
+				{name: "b", check: failing(down)},
+				{name: "c", critical: true, check: failing(down)},
+				{name: "d", check: failing(slow)},
+				{name: "e"},
+			},
+			want: entities.HealthStatusUnhealthy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewHealthService("test", time.Second, 0, checkers(tt.checkers)...)
+			health, err := service.CheckHealth(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if health.Status != tt.want {
+				t.Errorf("status = %s, want %s; checks %+v", health.Status, tt.want, health.Checks)
+			}
+			if health.IsReady() != (tt.want != entities.HealthStatusUnhealthy) {
+				t.Errorf("ready = %v with status %s", health.IsReady(), health.Status)
+			}
+			if len(health.Checks) != len(tt.checkers) {
+				t.Errorf("%d checks, want %d", len(health.Checks), len(tt.checkers))
+			}
+		})
+	}
+}
+
+func TestCheckHealthTimesOutEachCheck(t *testing.T) {
+	hung := &fakeChecker{name: "dynamodb", critical: true, check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	fast := &fakeChecker{name: "s3", critical: true}
+	service := NewHealthService("test", 50*time.Millisecond, 0, hung, fast)
+
+	start := time.Now()
+	health, err := service.CheckHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("check took %s", elapsed)
+	}
+
+	check := health.Checks["dynamodb"]
+	if check.Status != entities.HealthStatusUnhealthy || !strings.Contains(check.Error, "timed out after 50ms") {
+		t.Errorf("hung check = %+v", check)
+	}
+	if check := health.Checks["s3"]; check.Status != entities.HealthStatusHealthy {
+		t.Errorf("fast check = %+v", check)
+	}
+	if health.Status != entities.HealthStatusUnhealthy {
+		t.Errorf("status = %s", health.Status)
+	}
+}
+
+func TestCheckHealthRecoversPanics(t *testing.T) {
+	panicking := &fakeChecker{name: "gmail", check: func(context.Context) error { panic("nil token") }}
+	service := NewHealthService("test", time.Second, 0, panicking, &fakeChecker{name: "s3", critical: true})
+
+	health, err := service.CheckHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := health.Checks["gmail"]
+	if check.Status != entities.HealthStatusUnhealthy || !strings.Contains(check.Error, "panicked: nil token") {
+		t.Errorf("panicking check = %+v", check)
+	}
+	if health.Status != entities.HealthStatusDegraded {
+		t.Errorf("status = %s, want degraded", health.Status)
+	}
+}
+
+func TestCheckHealthCache(t *testing.T) {
+	checker := &fakeChecker{name: "s3", critical: true}
+	service := NewHealthService("test", time.Second, time.Hour, checker)
+
+	for range 3 {
+		if _, err := service.CheckHealth(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := checker.calls.Load(); calls != 1 {
+		t.Errorf("checker ran %d times within the TTL, want 1", calls)
+	}
+
+	// Once the TTL has passed the checks run again.
+	service.mu.Lock()
+	service.cachedAt = time.Now().Add(-2 * time.Hour)
+	service.mu.Unlock()
+	checker.check = failing(errors.New("bucket gone"))
+	health, err := service.CheckHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := checker.calls.Load(); calls != 2 || health.Status != entities.HealthStatusUnhealthy {
+		t.Errorf("after TTL: %d calls, status %s", calls, health.Status)
+	}
+
+	// A zero TTL disables the cache.
+	uncached := &fakeChecker{name: "s3"}
+	service = NewHealthService("test", time.Second, 0, uncached)
+	service.CheckHealth(context.Background())
+	service.CheckHealth(context.Background())
+	if calls := uncached.calls.Load(); calls != 2 {
+		t.Errorf("uncached checker ran %d times, want 2", calls)
+	}
+}
+
+func checkers(fakes []*fakeChecker) []outgoing.HealthChecker {
+	out := make([]outgoing.HealthChecker, len(fakes))
+	for i, fake := range fakes {
+		out[i] = fake
+	}
+	return out
+}
@@ -0,0 +1,243 @@
+package application_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"email-parser-poc/internal/domain/threading"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const defaultImportBatchSize = 100
+
+// ImportService feeds exported archives through the same parse, classify and
+// store steps as the Gmail path, checkpointing after every stored batch.
+type ImportService struct {
+	Sources        outgoing.MailSourceOpener
+	StateStore     outgoing.ImportStateStore
+	StorageService outgoing.StorageService
+	Dbservice      outgoing.DbService
+	ArchiveRaw     bool
+	Logger         *slog.Logger
+	Metrics        *metrics.Metrics
+}
+
+func NewImportService(sources outgoing.MailSourceOpener, stateStore outgoing.ImportStateStore, storageService outgoing.StorageService, dbservice outgoing.DbService, archiveRaw bool, logger *slog.Logger, m *metrics.Metrics) incoming.ImportService {
+	return &ImportService{
+		Sources:        sources,
+		StateStore:     stateStore,
+		StorageService: storageService,
+		Dbservice:      dbservice,
+		ArchiveRaw:     archiveRaw,
+		Logger:         logging.OrDefault(logger),
+		Metrics:        m,
+	}
+}
+
+// Import reads req.Path from its last checkpoint and stores every message
+// whose Message-ID (or content hash, without one) hasn't been imported yet.
+// Messages that fail to parse are counted and skipped.
+func (s *ImportService) Import(ctx context.Context, req entities.ImportRequest, progress func(entities.ImportProgress)) (*entities.ImportProgress, error) {
+	source, err := s.Sources.Open(req.Path, req.Format, req.Variant)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	result := &entities.ImportProgress{Source: source.ID()}
+	if !req.Restart {
+		checkpoint, err := s.StateStore.GetCheckpoint(ctx, source.ID())
+		switch {
+		case err == nil:
+			if err := source.Resume(checkpoint.Position); err != nil {
+				return nil, fmt.Errorf("cannot resume from checkpoint, restart the import: %w", err)
+			}
+			result.Resumed = true
+		case !errors.Is(err, entities.ErrNotFound):
+			return nil, err
+		}
+	}
+
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	batch := &importBatch{seen: make(map[string]bool)}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		raw, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		if raw.SourceKey != "" {
+			if !req.Restart {
+				handled, err := s.StateStore.HasMessageID(ctx, raw.SourceKey)
+				if err != nil {
+					return result, err
+				}
+				if handled {
+					continue
+				}
+			}
+			batch.handled = append(batch.handled, raw.SourceKey)
+		}
+		s.Metrics.MessagesFetched(metrics.PipelineImport, 1)
+
+		email, key, err := parseRawEmail(*raw)
+		if err != nil {
+			position, _ := source.Progress()
+			s.Logger.WarnContext(ctx, "failed to parse message", "source", source.ID(), "position", position, "error", err)
+			result.Failed++
+			continue
+		}
+		s.Metrics.MessageClassified(email.Classification.Category, email.IsPromotional)
+
+		duplicate := batch.seen[key]
+		if !duplicate {
+			if duplicate, err = s.StateStore.HasMessageID(ctx, key); err != nil {
+				return result, err
+			}
+		}
+		if duplicate {
+			result.Duplicates++
+			continue
+		}
+
+		batch.add(key, *email, raw.Data)
+
+		if len(batch.emails) >= batchSize {
+			if err := s.flush(ctx, source, batch, result, progress); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if err := s.flush(ctx, source, batch, result, progress); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// flush stores the batch, records its Message-IDs and SourceKeys and then
+// the checkpoint, in that order, so a crash at worst re-reads messages that
+// dedupe skips.
+func (s *ImportService) flush(ctx context.Context, source outgoing.MailSource, batch *importBatch, result *entities.ImportProgress, progress func(entities.ImportProgress)) error {
+	if len(batch.emails) > 0 {
+		threading.AssignThreadIDs(batch.emails)
+
+		if s.ArchiveRaw {
+			for i := range batch.emails {
+				key, err := s.StorageService.UploadRawEmail(ctx, batch.emails[i].ID, batch.raw[i])
+				if err != nil {
+					return fmt.Errorf("failed to archive raw email %s: %w", batch.emails[i].ID, err)
+				}
+				batch.emails[i].RawKey = key
+			}
+		}
+
+		list := &entities.EmailList{Emails: batch.emails, TotalCount: len(batch.emails)}
+		if _, err := storeEmails(ctx, s.Dbservice, s.StorageService, list); err != nil {
+			return err
+		}
+		s.Metrics.MessagesStored(metrics.PipelineImport, len(batch.emails))
+		if err := s.StateStore.AddMessageIDs(ctx, batch.keys); err != nil {
+			return err
+		}
+		result.Imported += len(batch.emails)
+	}
+	if len(batch.handled) > 0 {
+		if err := s.StateStore.AddMessageIDs(ctx, batch.handled); err != nil {
+			return err
+		}
+	}
+
+	position, total := source.Progress()
+	checkpoint := &entities.ImportCheckpoint{Source: source.ID(), Position: position, UpdatedAt: time.Now().UTC()}
+	if err := s.StateStore.SaveCheckpoint(ctx, checkpoint); err != nil {
+		return err
+	}
+	result.Position, result.Total = position, total
+
+	if progress != nil {
+		progress(*result)
+	}
+	batch.reset()
+	return nil
+}
+
+type importBatch struct {
+	emails []entities.EmailMessage
+	raw    [][]byte
+	keys   []string
+	seen   map[string]bool
+	// handled are the SourceKeys of every message read, imported or not.
+	handled []string
+}
+
+func (b *importBatch) add(key string, email entities.EmailMessage, raw []byte) {
+	b.emails = append(b.emails, email)
+	b.raw = append(b.raw, raw)
+	b.keys = append(b.keys, key)
+	b.seen[key] = true
+}
+
+func (b *importBatch) reset() {
+	b.emails, b.raw, b.keys, b.handled = nil, nil, nil, nil
+	b.seen = make(map[string]bool)
+}
+
+// parseRawEmail runs a raw message through the MIME parser and classifier.
+// It returns the deduplication key and derives the ID from it, so the same
+// message always lands on the same storage keys.
+func parseRawEmail(raw entities.RawMessage) (*entities.EmailMessage, string, error) {
+	email, err := parser.ParseMessage(raw.Data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", entities.ErrInvalidMessage, err)
+	}
+
+	key := dedupeKey(email, raw.Data)
+	email.ID = importID(key)
+	email.Labels = raw.Labels
+	if email.ReceivedAt.IsZero() && !raw.ReceivedAt.IsZero() {
+		email.ReceivedAt = raw.ReceivedAt
+		if email.InvalidDate {
+			email.Date = raw.ReceivedAt
+		}
+	}
+	classifier.Default().Apply(email)
+	return email, key, nil
+}
+
+// dedupeKey is the Message-ID, or a hash of the raw bytes for messages
+// without one.
+func dedupeKey(email *entities.EmailMessage, raw []byte) string {
+	if email.MessageID != "" {
+		return email.MessageID
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// importID derives a stable, Gmail-sized ID from a deduplication key.
+func importID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
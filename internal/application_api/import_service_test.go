@@ -0,0 +1,111 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/mailfile"
+	"email-parser-poc/internal/adapters/seondary/syncstate"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// memoryStorage records stored emails in place of S3 and DynamoDB.
+type memoryStorage struct {
+	emails []entities.EmailMessage
+}
+
+func (m *memoryStorage) UploadEmails(_ context.Context, emails *entities.EmailList) (string, error) {
+	m.emails = append(m.emails, emails.Emails...)
+	return "emails/batch.json", nil
+}
+
+func (m *memoryStorage) UploadRawEmail(_ context.Context, messageID string, _ []byte) (string, error) {
+	return "raw/" + messageID + ".eml", nil
+}
+
+func (m *memoryStorage) UploadHeaders(context.Context, *entities.EmailList) error {
+	return nil
+}
+
+func (m *memoryStorage) subjects() []string {
+	var subjects []string
+	for _, email := range m.emails {
+		subjects = append(subjects, email.Subject)
+	}
+	return subjects
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestImportMaildirResumesByName moves and delivers files between runs,
+// which shifts every position in the listing; the resumed import must still
+// pick up exactly the files it hasn't read.
+func TestImportMaildirResumesByName(t *testing.T) {
+	dir := t.TempDir()
+	maildir := filepath.Join(dir, "Maildir")
+	writeFile(t, filepath.Join(maildir, "new", "1700000002.M2.host"), rawMessage("B", "b@example.com", ""))
+	writeFile(t, filepath.Join(maildir, "cur", "1700000003.M3.host:2,S"), rawMessage("C", "c@example.com", ""))
+	writeFile(t, filepath.Join(maildir, "new", "1700000004.M4.host"), rawMessage("D", "d@example.com", ""))
+
+	storage := &memoryStorage{}
+	service := NewImportService(mailfile.NewOpener(), syncstate.NewImportStore(filepath.Join(dir, "state")), storage, storage, false, nil, nil)
+	req := entities.ImportRequest{Path: maildir, BatchSize: 1}
+
+	// Stop after the first batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := service.Import(ctx, req, func(entities.ImportProgress) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if got, want := storage.subjects(), []string{"B"}; !slices.Equal(got, want) {
+		t.Fatalf("first run imported %v, want %v", got, want)
+	}
+
+	// A client reads B, which moves it to cur/ with flags, and A is
+	// delivered with a name that sorts first.
+	if err := os.Rename(filepath.Join(maildir, "new", "1700000002.M2.host"), filepath.Join(maildir, "cur", "1700000002.M2.host:2,S")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(maildir, "new", "1700000001.M1.host"), rawMessage("A", "a@example.com", ""))
+
+	result, err := service.Import(context.Background(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storage.subjects(), []string{"B", "A", "C", "D"}; !slices.Equal(got, want) {
+		t.Errorf("after resuming imported %v, want %v", got, want)
+	}
+	if !result.Resumed || result.Imported != 3 || result.Duplicates != 0 {
+		t.Errorf("result = %+v", result)
+	}
+
+	result, err = service.Import(context.Background(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 0 || result.Duplicates != 0 {
+		t.Errorf("re-run result = %+v", result)
+	}
+
+	// A restart reads everything again; dedupe skips what's stored.
+	req.Restart = true
+	result, err = service.Import(context.Background(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 0 || result.Duplicates != 4 {
+		t.Errorf("restart result = %+v", result)
+	}
+}
@@ -0,0 +1,175 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PostProcessor applies mailbox rules to processed messages, e.g. labelling
+// confident promotions and archiving them. In dry-run mode every mutation is
+// logged but none is sent to the source.
+type PostProcessor struct {
+	EmailRepo outgoing.EmailRepository
+	Logger    *slog.Logger
+
+	policy atomic.Pointer[postProcessingPolicy]
+
+	labelsMu sync.Mutex
+	// labelIDs caches the source ID of every rule label, by lower-case name.
+	labelIDs map[string]string
+}
+
+type postProcessingPolicy struct {
+	rules  []entities.MailboxRule
+	dryRun bool
+}
+
+func NewPostProcessor(emailRepo outgoing.EmailRepository, rules []entities.MailboxRule, dryRun bool, logger *slog.Logger) *PostProcessor {
+	p := &PostProcessor{
+		EmailRepo: emailRepo,
+		Logger:    logging.OrDefault(logger),
+	}
+	p.SetRules(rules, dryRun)
+	return p
+}
+
+// SetRules swaps the rules and dry-run flag atomically; a batch already
+// being processed finishes with the rules it started with.
+func (p *PostProcessor) SetRules(rules []entities.MailboxRule, dryRun bool) {
+	p.policy.Store(&postProcessingPolicy{rules: rules, dryRun: dryRun})
+}
+
+// Apply runs every matching rule against every message. Failures are recorded
+// on the returned actions rather than aborting the batch, since the messages
+// are already stored by the time post-processing runs.
+func (p *PostProcessor) Apply(ctx context.Context, emails []entities.EmailMessage) []entities.MailboxAction {
+	if p == nil {
+		return nil
+	}
+
+	policy := p.policy.Load()
+	var actions []entities.MailboxAction
+	for i := range emails {
+		email := &emails[i]
+		for _, rule := range policy.rules {
+			if !rule.Matches(email) {
+				continue
+			}
+			actions = append(actions, p.applyRule(ctx, rule, email, policy.dryRun)...)
+		}
+	}
+	return actions
+}
+
+func (p *PostProcessor) applyRule(ctx context.Context, rule entities.MailboxRule, email *entities.EmailMessage, dryRun bool) []entities.MailboxAction {
+	var actions []entities.MailboxAction
+
+	run := func(action string, labels []string, mutate func() error) {
+		record := entities.MailboxAction{
+			MessageID: email.ID,
+			Rule:      rule.Name,
+			Action:    action,
+			Labels:    labels,
+			DryRun:    dryRun,
+		}
+
+		if !dryRun {
+			if err := mutate(); err != nil {
+				record.Error = err.Error()
+			}
+		}
+
+		attrs := []any{"action", action, "message_id", email.ID, "rule", rule.Name, "dry_run", dryRun}
+		if len(labels) > 0 {
+			attrs = append(attrs, "labels", labels)
+		}
+		if record.Error != "" {
+			p.Logger.WarnContext(ctx, "mailbox action failed", append(attrs, "error", record.Error)...)
+		} else {
+			p.Logger.InfoContext(ctx, "mailbox action applied", attrs...)
+		}
+		actions = append(actions, record)
+	}
+
+	if rule.Trash {
+		// Trashing supersedes every other action for the message.
+		run("trash", nil, func() error { return p.EmailRepo.Trash(ctx, email.ID) })
+		return actions
+	}
+
+	// Archiving, and on Graph removing INBOX, moves the message and can give
+	// it a new ID, so the moves come after the changes made in place.
+	if rule.MarkRead {
+		run("mark_read", nil, func() error { return p.EmailRepo.MarkRead(ctx, email.ID) })
+	}
+	add := p.missingLabels(ctx, email.Labels, rule.AddLabels, dryRun)
+	if len(add) > 0 || len(rule.RemoveLabels) > 0 {
+		labels := append(append([]string(nil), add...), prefixed("-", rule.RemoveLabels)...)
+		run("modify_labels", labels, func() error {
+			return p.EmailRepo.ModifyLabels(ctx, email.ID, add, rule.RemoveLabels)
+		})
+	}
+	if rule.Archive {
+		run("archive", nil, func() error { return p.EmailRepo.Archive(ctx, email.ID) })
+	}
+	return actions
+}
+
+// missingLabels drops labels the message already carries, so reprocessing
+// doesn't issue no-op modifications. Sources report labels by ID, e.g.
+// Gmail's "Label_12", while rules name them, so names are resolved to IDs
+// first. A dry run compares names only, since resolving may create labels.
+func (p *PostProcessor) missingLabels(ctx context.Context, have, want []string, dryRun bool) []string {
+	var missing []string
+	for _, label := range want {
+		id := label
+		if !dryRun {
+			id = p.labelID(ctx, label)
+		}
+		found := slices.ContainsFunc(have, func(existing string) bool {
+			return strings.EqualFold(existing, label) || strings.EqualFold(existing, id)
+		})
+		if !found {
+			missing = append(missing, label)
+		}
+	}
+	return missing
+}
+
+// labelID resolves a label name to its source ID through CreateLabel, which
+// adding the label would do anyway. On failure the name is returned and the
+// error left to surface from ModifyLabels.
+func (p *PostProcessor) labelID(ctx context.Context, name string) string {
+	p.labelsMu.Lock()
+	defer p.labelsMu.Unlock()
+
+	key := strings.ToLower(name)
+	if id, ok := p.labelIDs[key]; ok {
+		return id
+	}
+	id, err := p.EmailRepo.CreateLabel(ctx, name)
+	if err != nil {
+		p.Logger.WarnContext(ctx, "failed to resolve label", "label", name, "error", err)
+		return name
+	}
+	if p.labelIDs == nil {
+		p.labelIDs = make(map[string]string)
+	}
+	p.labelIDs[key] = id
+	return id
+}
+
+func prefixed(prefix string, values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = prefix + v
+	}
+	return out
+}
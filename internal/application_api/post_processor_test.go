@@ -0,0 +1,123 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// movingRepo archives and trashes by moving, like Graph and IMAP: the moved
+// message gets a new ID and the old one is gone.
+type movingRepo struct {
+	messages map[string][]string // ID -> labels
+	labelIDs map[string]string   // name -> ID, like Gmail's Label_N
+	calls    []string
+	moves    int
+}
+
+func (r *movingRepo) FetchEmails(context.Context, entities.EmailFilter) (*entities.EmailList, error) {
+	return nil, entities.ErrNotSupported
+}
+
+func (r *movingRepo) FetchThread(context.Context, string) ([]entities.EmailMessage, error) {
+	return nil, entities.ErrNotSupported
+}
+
+func (r *movingRepo) FetchRawEmail(context.Context, string) ([]byte, error) {
+	return nil, entities.ErrNotSupported
+}
+
+func (r *movingRepo) ModifyLabels(_ context.Context, messageID string, add, remove []string) error {
+	r.calls = append(r.calls, "modify_labels")
+	labels, ok := r.messages[messageID]
+	if !ok {
+		return entities.ErrNotFound
+	}
+	labels = slices.DeleteFunc(labels, func(l string) bool { return slices.Contains(remove, l) })
+	r.messages[messageID] = append(labels, add...)
+	return nil
+}
+
+func (r *movingRepo) CreateLabel(_ context.Context, name string) (string, error) {
+	r.calls = append(r.calls, "create_label")
+	if id, ok := r.labelIDs[name]; ok {
+		return id, nil
+	}
+	return name, nil
+}
+
+func (r *movingRepo) Archive(_ context.Context, messageID string) error {
+	r.calls = append(r.calls, "archive")
+	labels, ok := r.messages[messageID]
+	if !ok {
+		return entities.ErrNotFound
+	}
+	delete(r.messages, messageID)
+	r.moves++
+	r.messages[fmt.Sprintf("moved-%d", r.moves)] = labels
+	return nil
+}
+
+func (r *movingRepo) MarkRead(ctx context.Context, messageID string) error {
+	r.calls = append(r.calls, "mark_read")
+	return r.ModifyLabels(ctx, messageID, nil, []string{"UNREAD"})
+}
+
+func (r *movingRepo) Trash(context.Context, string) error {
+	return entities.ErrNotSupported
+}
+
+func TestPostProcessorArchivesLast(t *testing.T) {
+	repo := &movingRepo{messages: map[string][]string{"1": {"INBOX", "UNREAD"}}}
+	rules := []entities.MailboxRule{{
+		Name:      "promotions",
+		Category:  "promotions",
+		AddLabels: []string{"Deals"},
+		Archive:   true,
+		MarkRead:  true,
+	}}
+	emails := []entities.EmailMessage{{
+		ID:             "1",
+		Labels:         []string{"INBOX", "UNREAD"},
+		Classification: entities.Classification{Category: "promotions", Confidence: 0.9},
+	}}
+
+	actions := NewPostProcessor(repo, rules, false, nil).Apply(context.Background(), emails)
+
+	for _, action := range actions {
+		if action.Error != "" {
+			t.Errorf("%s failed: %s", action.Action, action.Error)
+		}
+	}
+	if want := []string{"mark_read", "modify_labels", "create_label", "modify_labels", "archive"}; !slices.Equal(repo.calls, want) {
+		t.Errorf("calls = %v, want %v", repo.calls, want)
+	}
+	if got := repo.messages["moved-1"]; !slices.Equal(got, []string{"INBOX", "Deals"}) {
+		t.Errorf("archived message labels = %v", got)
+	}
+}
+
+func TestPostProcessorComparesLabelsByID(t *testing.T) {
+	repo := &movingRepo{
+		messages: map[string][]string{"1": {"INBOX", "Label_7"}, "2": {"INBOX"}},
+		labelIDs: map[string]string{"Deals": "Label_7"},
+	}
+	rules := []entities.MailboxRule{{Name: "promotions", Category: "promotions", AddLabels: []string{"Deals"}}}
+	emails := []entities.EmailMessage{
+		{ID: "1", Labels: []string{"INBOX", "Label_7"}, Classification: entities.Classification{Category: "promotions", Confidence: 0.9}},
+		{ID: "2", Labels: []string{"INBOX"}, Classification: entities.Classification{Category: "promotions", Confidence: 0.9}},
+	}
+
+	actions := NewPostProcessor(repo, rules, false, nil).Apply(context.Background(), emails)
+
+	// Message 1 already has the label under its ID; the name is resolved
+	// once for both messages.
+	if len(actions) != 1 || actions[0].MessageID != "2" {
+		t.Errorf("actions = %+v", actions)
+	}
+	if want := []string{"create_label", "modify_labels"}; !slices.Equal(repo.calls, want) {
+		t.Errorf("calls = %v, want %v", repo.calls, want)
+	}
+}
@@ -0,0 +1,393 @@
+package application_api
+
+import (
+	"bytes"
+	"context"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/parser"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+const defaultReprocessConcurrency = 4
+
+// ReprocessService runs stored messages through the current parser and
+// classifier again and writes back the ones that changed. Messages with an
+// archived raw copy are parsed from it; the rest can only be reclassified.
+type ReprocessService struct {
+	Archive     outgoing.ArchiveStore
+	Dbservice   outgoing.DbService
+	StateStore  outgoing.ImportStateStore
+	Concurrency int
+	Logger      *slog.Logger
+}
+
+func NewReprocessService(archive outgoing.ArchiveStore, dbservice outgoing.DbService, stateStore outgoing.ImportStateStore, concurrency int, logger *slog.Logger) incoming.ReprocessService {
+	return &ReprocessService{
+		Archive:     archive,
+		Dbservice:   dbservice,
+		StateStore:  stateStore,
+		Concurrency: concurrency,
+		Logger:      logging.OrDefault(logger),
+	}
+}
+
+// reprocessObject is an object handed to a worker; seq is its position in
+// the listing, which the checkpoint advances along.
+type reprocessObject struct {
+	seq  int
+	key  string
+	kind string
+}
+
+// objectResult is what reprocessing one object changed.
+type objectResult struct {
+	messages, changed, failed int
+	rewritten                 bool
+	fields, categories        map[string]int
+	err                       error
+}
+
+// Reprocess walks the stored messages below req.Prefix in key order,
+// resuming after the last checkpoint. Objects are processed concurrently but
+// the checkpoint only moves past an object once it and every object before it
+// are done, and never past one that failed, so resuming retries failures and
+// repeats at most the work that was in flight.
+func (s *ReprocessService) Reprocess(ctx context.Context, req entities.ReprocessRequest, progress func(entities.ReprocessProgress)) (*entities.ReprocessProgress, error) {
+	result := &entities.ReprocessProgress{Source: reprocessSource(req)}
+
+	startAfter := ""
+	if !req.Restart && !req.DryRun {
+		checkpoint, err := s.StateStore.GetCheckpoint(ctx, result.Source)
+		switch {
+		case err == nil:
+			startAfter = checkpoint.Key
+			result.LastKey = checkpoint.Key
+			result.Resumed = true
+		case !errors.Is(err, entities.ErrNotFound):
+			return nil, err
+		}
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = s.Concurrency
+	}
+	if concurrency <= 0 {
+		concurrency = defaultReprocessConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		keys     []string
+		done     = map[int]bool{}
+		failed   = map[int]bool{}
+		next     int
+		blocked  bool
+		stateErr error
+	)
+	// finish records an object and moves the checkpoint as far as the
+	// contiguous run of finished objects allows.
+	finish := func(object reprocessObject, r objectResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		result.Objects++
+		result.Messages += r.messages
+		result.Changed += r.changed
+		result.Failed += r.failed
+		if r.rewritten {
+			result.Rewritten++
+		}
+		if r.err != nil {
+			result.FailedObjects++
+			failed[object.seq] = true
+			s.Logger.WarnContext(ctx, "failed to reprocess object", "key", object.key, "error", r.err)
+		}
+		result.Fields = mergeCounts(result.Fields, r.fields)
+		result.Categories = mergeCounts(result.Categories, r.categories)
+
+		done[object.seq] = true
+		advanced := false
+		for !blocked && done[next] {
+			if failed[next] {
+				blocked = true
+				break
+			}
+			delete(done, next)
+			result.LastKey = keys[next]
+			next++
+			advanced = true
+		}
+		if advanced && !req.DryRun && stateErr == nil {
+			checkpoint := &entities.ImportCheckpoint{Source: result.Source, Key: result.LastKey, UpdatedAt: time.Now().UTC()}
+			if err := s.StateStore.SaveCheckpoint(ctx, checkpoint); err != nil {
+				stateErr = err
+				cancel()
+			}
+		}
+
+		if progress != nil {
+			snapshot := *result
+			snapshot.Fields = maps.Clone(result.Fields)
+			snapshot.Categories = maps.Clone(result.Categories)
+			progress(snapshot)
+		}
+	}
+
+	objects := make(chan reprocessObject)
+	var workers sync.WaitGroup
+	for range concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for object := range objects {
+				finish(object, s.reprocessObject(ctx, object, req.DryRun))
+			}
+		}()
+	}
+
+	listErr := s.Archive.ListObjects(ctx, req.Prefix, startAfter, func(object entities.StoredObject) error {
+		if !reprocessable(object.Kind) || !inRange(object, req.Since, req.Until) {
+			mu.Lock()
+			result.Skipped++
+			// A skipped object needs no work, so the checkpoint may pass it.
+			keys = append(keys, object.Key)
+			done[len(keys)-1] = true
+			mu.Unlock()
+			return nil
+		}
+		mu.Lock()
+		keys = append(keys, object.Key)
+		seq := len(keys) - 1
+		mu.Unlock()
+
+		select {
+		case objects <- reprocessObject{seq: seq, key: object.Key, kind: object.Kind}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(objects)
+	workers.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	switch {
+	case stateErr != nil:
+		return result, stateErr
+	case listErr != nil:
+		return result, listErr
+	}
+	return result, ctx.Err()
+}
+
+// reprocessObject re-runs every message in one batch or per-message object
+// and, unless dryRun, writes the object and the changed messages' headers
+// back.
+func (s *ReprocessService) reprocessObject(ctx context.Context, object reprocessObject, dryRun bool) objectResult {
+	var r objectResult
+	key := object.key
+	list, err := s.Archive.GetEmails(ctx, key)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if object.kind == entities.StoredMessage && len(list.Emails) != 1 {
+		r.err = fmt.Errorf("%s holds %d messages, expected one", key, len(list.Emails))
+		return r
+	}
+
+	var changed []entities.EmailMessage
+	for i := range list.Emails {
+		r.messages++
+		before := &list.Emails[i]
+		after, err := s.reprocessEmail(ctx, *before)
+		if err != nil {
+			s.Logger.WarnContext(ctx, "failed to reprocess message", "key", key, "message_id", before.ID, "error", err)
+			r.failed++
+			continue
+		}
+
+		fields := changedFields(before, after)
+		if len(fields) == 0 {
+			continue
+		}
+		r.changed++
+		r.fields = mergeCounts(r.fields, countOnce(fields))
+		if before.Classification.Category != after.Classification.Category {
+			r.categories = mergeCounts(r.categories, map[string]int{before.Classification.Category + " -> " + after.Classification.Category: 1})
+		}
+		*before = *after
+		changed = append(changed, *after)
+	}
+
+	if len(changed) == 0 || dryRun {
+		return r
+	}
+	if object.kind == entities.StoredMessage {
+		err = s.Archive.ReplaceEmail(ctx, key, &list.Emails[0])
+	} else {
+		err = s.Archive.ReplaceEmails(ctx, key, list)
+	}
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.rewritten = true
+	if err := s.Dbservice.UploadHeaders(ctx, &entities.EmailList{Emails: changed, TotalCount: len(changed)}); err != nil {
+		r.err = fmt.Errorf("failed to store emails-headers in db: %w", err)
+	}
+	return r
+}
+
+// reprocessEmail parses the archived raw copy when there is one and
+// classifies the result. Identity and mailbox state (ID, thread, labels, raw
+// key) are kept from the stored message.
+func (s *ReprocessService) reprocessEmail(ctx context.Context, stored entities.EmailMessage) (*entities.EmailMessage, error) {
+	email := stored
+	if stored.RawKey != "" {
+		raw, err := s.Archive.GetObject(ctx, stored.RawKey)
+		switch {
+		case err == nil:
+			parsed, err := parser.ParseMessage(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", entities.ErrInvalidMessage, err)
+			}
+			parsed.ID = stored.ID
+			parsed.ThreadID = stored.ThreadID
+			parsed.Labels = stored.Labels
+			parsed.RawKey = stored.RawKey
+			if parsed.ReceivedAt.IsZero() && !stored.ReceivedAt.IsZero() {
+				parsed.ReceivedAt = stored.ReceivedAt
+				if parsed.InvalidDate {
+					parsed.Date = stored.ReceivedAt
+				}
+			}
+			email = *parsed
+		case errors.Is(err, entities.ErrNotFound):
+			s.Logger.DebugContext(ctx, "raw copy missing, reclassifying only", "message_id", stored.ID, "raw_key", stored.RawKey)
+		default:
+			return nil, err
+		}
+	}
+	classifier.Default().Apply(&email)
+	return &email, nil
+}
+
+// changedFields names the JSON fields of EmailMessage that differ. Times are
+// compared as instants at microsecond precision, which is what every storage
+// format keeps; Parquet stores them in UTC.
+func changedFields(before, after *entities.EmailMessage) []string {
+	a, errA := fieldValues(forComparison(before))
+	b, errB := fieldValues(forComparison(after))
+	if errA != nil || errB != nil {
+		return []string{"*"}
+	}
+	var fields []string
+	for name, value := range b {
+		if !bytes.Equal(a[name], value) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func forComparison(email *entities.EmailMessage) *entities.EmailMessage {
+	normalized := *email
+	normalized.Date = normalizeTime(email.Date)
+	normalized.ReceivedAt = normalizeTime(email.ReceivedAt)
+	return &normalized
+}
+
+func normalizeTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func fieldValues(email *entities.EmailMessage) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(email)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	err = json.Unmarshal(data, &values)
+	return values, err
+}
+
+func countOnce(names []string) map[string]int {
+	counts := make(map[string]int, len(names))
+	for _, name := range names {
+		counts[name] = 1
+	}
+	return counts
+}
+
+func mergeCounts(into, from map[string]int) map[string]int {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = make(map[string]int, len(from))
+	}
+	for key, n := range from {
+		into[key] += n
+	}
+	return into
+}
+
+// reprocessable picks the stored messages out of a listing; raw .eml copies
+// are read through the message that refers to them.
+func reprocessable(kind string) bool {
+	return kind == entities.StoredBatch || kind == entities.StoredMessage
+}
+
+// inRange reports whether an object may hold messages dated in [since,
+// until). Objects whose key carries no date only match an open range.
+func inRange(object entities.StoredObject, since, until time.Time) bool {
+	if since.IsZero() && until.IsZero() {
+		return true
+	}
+	if object.DateFrom.IsZero() {
+		return false
+	}
+	if !since.IsZero() && object.DateTo.Before(since) {
+		return false
+	}
+	if !until.IsZero() && !object.DateFrom.Before(until) {
+		return false
+	}
+	return true
+}
+
+// reprocessSource names a run's checkpoint after the objects it selects, so
+// runs over different ranges keep separate checkpoints.
+func reprocessSource(req entities.ReprocessRequest) string {
+	bound := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("reprocess:%s:%s..%s", req.Prefix, bound(req.Since), bound(req.Until))
+}
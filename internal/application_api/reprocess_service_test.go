@@ -0,0 +1,257 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInRangeUsesKeyDates(t *testing.T) {
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	partition := entities.StoredObject{
+		Kind:         entities.StoredMessage,
+		DateFrom:     day,
+		DateTo:       day.Add(24*time.Hour - time.Nanosecond),
+		LastModified: day.AddDate(0, 6, 0),
+	}
+	batchTime := day.Add(9 * time.Hour)
+	batch := entities.StoredObject{Kind: entities.StoredBatch, DateFrom: batchTime, DateTo: batchTime, LastModified: day.AddDate(0, 6, 0)}
+	undated := entities.StoredObject{Kind: entities.StoredMessage, LastModified: day}
+
+	tests := []struct {
+		name         string
+		object       entities.StoredObject
+		since, until time.Time
+		want         bool
+	}{
+		{name: "open range", object: undated, want: true},
+		{name: "undated with a bound", object: undated, since: day.AddDate(0, 0, -1), want: false},
+		// Rewritten months later; the message date still decides.
+		{name: "partition on the day", object: partition, since: day, until: day.AddDate(0, 0, 1), want: true},
+		{name: "partition overlapping since", object: partition, since: day.Add(12 * time.Hour), want: true},
+		{name: "partition before since", object: partition, since: day.AddDate(0, 0, 1), want: false},
+		{name: "partition at until", object: partition, until: day, want: false},
+		{name: "partition by last modified", object: partition, since: day.AddDate(0, 5, 0), want: false},
+		{name: "batch inside", object: batch, since: day, until: day.Add(10 * time.Hour), want: true},
+		{name: "batch at until", object: batch, until: batchTime, want: false},
+		{name: "batch at since", object: batch, since: batchTime, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := inRange(tt.object, tt.since, tt.until); got != tt.want {
+			t.Errorf("%s: inRange = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// memoryArchive holds one batch object per key. Objects listed in fail
+// error on read; delay slows reading an object down.
+type memoryArchive struct {
+	mu       sync.Mutex
+	keys     []string
+	kinds    map[string]string
+	emails   map[string][]entities.EmailMessage
+	fail     map[string]bool
+	delay    map[string]time.Duration
+	read     []string
+	replaced []string
+}
+
+func newMemoryArchive(n int) *memoryArchive {
+	a := &memoryArchive{
+		kinds:  map[string]string{},
+		emails: map[string][]entities.EmailMessage{},
+		fail:   map[string]bool{},
+		delay:  map[string]time.Duration{},
+	}
+	for i := range n {
+		key := fmt.Sprintf("emails_%02d.json", i)
+		a.keys = append(a.keys, key)
+		a.kinds[key] = entities.StoredBatch
+		// Stored before classification, so reprocessing changes it.
+		a.emails[key] = []entities.EmailMessage{{ID: key, Subject: "Huge sale, 50% off", Body: "Limited time offer."}}
+	}
+	return a
+}
+
+func (a *memoryArchive) ListObjects(ctx context.Context, prefix, startAfter string, fn func(entities.StoredObject) error) error {
+	for _, key := range a.keys {
+		if key <= startAfter {
+			continue
+		}
+		if err := fn(entities.StoredObject{Key: key, Kind: a.kinds[key]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *memoryArchive) GetObject(context.Context, string) ([]byte, error) {
+	return nil, entities.ErrNotFound
+}
+
+func (a *memoryArchive) GetEmails(_ context.Context, key string) (*entities.EmailList, error) {
+	a.mu.Lock()
+	a.read = append(a.read, key)
+	delay, fail := a.delay[key], a.fail[key]
+	emails := slices.Clone(a.emails[key])
+	a.mu.Unlock()
+
+	time.Sleep(delay)
+	if fail {
+		return nil, errors.New("connection reset")
+	}
+	return &entities.EmailList{Emails: emails, TotalCount: len(emails)}, nil
+}
+
+func (a *memoryArchive) ReplaceEmails(_ context.Context, key string, emails *entities.EmailList) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.replaced = append(a.replaced, key)
+	a.emails[key] = emails.Emails
+	return nil
+}
+
+func (a *memoryArchive) ReplaceEmail(ctx context.Context, key string, email *entities.EmailMessage) error {
+	return a.ReplaceEmails(ctx, key, &entities.EmailList{Emails: []entities.EmailMessage{*email}})
+}
+
+func (a *memoryArchive) reads() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	reads := slices.Clone(a.read)
+	a.read = nil
+	return reads
+}
+
+// memoryState records every checkpoint saved.
+type memoryState struct {
+	mu    sync.Mutex
+	saved []string
+}
+
+func (m *memoryState) GetCheckpoint(_ context.Context, source string) (*entities.ImportCheckpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.saved) == 0 {
+		return nil, entities.ErrNotFound
+	}
+	return &entities.ImportCheckpoint{Source: source, Key: m.saved[len(m.saved)-1]}, nil
+}
+
+func (m *memoryState) SaveCheckpoint(_ context.Context, checkpoint *entities.ImportCheckpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved = append(m.saved, checkpoint.Key)
+	return nil
+}
+
+func (m *memoryState) HasMessageID(context.Context, string) (bool, error) { return false, nil }
+
+func (m *memoryState) AddMessageIDs(context.Context, []string) error { return nil }
+
+func newTestReprocessService(archive *memoryArchive, state *memoryState) *ReprocessService {
+	return &ReprocessService{
+		Archive:    archive,
+		Dbservice:  &memoryStorage{},
+		StateStore: state,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestReprocessResumesAfterFailure(t *testing.T) {
+	archive := newMemoryArchive(5)
+	archive.fail["emails_02.json"] = true
+	state := &memoryState{}
+	service := newTestReprocessService(archive, state)
+
+	result, err := service.Reprocess(context.Background(), entities.ReprocessRequest{Concurrency: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FailedObjects != 1 || result.LastKey != "emails_01.json" {
+		t.Errorf("first run: %+v", result)
+	}
+	if checkpoint, _ := state.GetCheckpoint(context.Background(), result.Source); checkpoint.Key != "emails_01.json" {
+		t.Errorf("checkpoint = %s, want the object before the failure", checkpoint.Key)
+	}
+
+	// The retry starts at the failed object.
+	archive.reads()
+	delete(archive.fail, "emails_02.json")
+	result, err = service.Reprocess(context.Background(), entities.ReprocessRequest{Concurrency: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := archive.reads()
+	slices.Sort(reads)
+	if want := []string{"emails_02.json", "emails_03.json", "emails_04.json"}; !slices.Equal(reads, want) {
+		t.Errorf("second run read %v, want %v", reads, want)
+	}
+	if !result.Resumed || result.FailedObjects != 0 || result.LastKey != "emails_04.json" {
+		t.Errorf("second run: %+v", result)
+	}
+}
+
+func TestReprocessDryRunLeavesNoCheckpoint(t *testing.T) {
+	archive := newMemoryArchive(3)
+	state := &memoryState{saved: []string{"emails_00.json"}}
+	service := newTestReprocessService(archive, state)
+
+	result, err := service.Reprocess(context.Background(), entities.ReprocessRequest{DryRun: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A dry run reads everything, whatever the checkpoint says.
+	if result.Resumed || result.Objects != 3 || result.Changed != 3 || result.Rewritten != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if len(archive.replaced) != 0 {
+		t.Errorf("dry run rewrote %v", archive.replaced)
+	}
+	if !slices.Equal(state.saved, []string{"emails_00.json"}) {
+		t.Errorf("checkpoints = %v", state.saved)
+	}
+}
+
+func TestReprocessConcurrentCheckpointInKeyOrder(t *testing.T) {
+	archive := newMemoryArchive(12)
+	// Earlier objects take longer, so workers finish out of key order.
+	for i, key := range archive.keys {
+		archive.delay[key] = time.Duration(len(archive.keys)-i) * time.Millisecond
+	}
+	// Raw copies are skipped, and the checkpoint moves past them.
+	archive.keys = append(archive.keys, "raw/x.eml")
+	archive.kinds["raw/x.eml"] = entities.StoredRaw
+	state := &memoryState{}
+	service := newTestReprocessService(archive, state)
+
+	var progress []entities.ReprocessProgress
+	result, err := service.Reprocess(context.Background(), entities.ReprocessRequest{Concurrency: 4}, func(p entities.ReprocessProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Objects != 12 || result.Skipped != 1 || result.Rewritten != 12 || result.LastKey != "raw/x.eml" {
+		t.Errorf("result = %+v", result)
+	}
+	if len(progress) != 12 {
+		t.Errorf("%d progress reports, want 12", len(progress))
+	}
+	for i := 1; i < len(state.saved); i++ {
+		if state.saved[i] <= state.saved[i-1] {
+			t.Errorf("checkpoint moved from %s to %s", state.saved[i-1], state.saved[i])
+		}
+	}
+	if n := len(state.saved); n == 0 || state.saved[n-1] != "raw/x.eml" {
+		t.Errorf("checkpoints = %v, want them to end at the last key", state.saved)
+	}
+}
@@ -0,0 +1,175 @@
+package application_api
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/domain/threading"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resyncLimit bounds the catch-up fetch when Gmail no longer has the history
+// checkpoint we asked for.
+const resyncLimit = 100
+
+type SyncService struct {
+	Watcher        outgoing.MailboxWatcher
+	EmailRepo      outgoing.EmailRepository
+	StateStore     outgoing.SyncStateStore
+	StorageService outgoing.StorageService
+	Dbservice      outgoing.DbService
+	SyncOptions
+
+	// mu serialises syncs so overlapping notifications don't replay the same
+	// history range twice.
+	mu sync.Mutex
+}
+
+type SyncOptions struct {
+	PostProcessor *PostProcessor
+	ArchiveRaw    bool
+	WatchRequest  entities.WatchRequest
+	RenewBefore   time.Duration
+	Logger        *slog.Logger
+	Metrics       *metrics.Metrics
+}
+
+func NewSyncService(watcher outgoing.MailboxWatcher, emailRepo outgoing.EmailRepository, stateStore outgoing.SyncStateStore, storageService outgoing.StorageService, dbservice outgoing.DbService, options SyncOptions) incoming.SyncService {
+	options.Logger = logging.OrDefault(options.Logger)
+	return &SyncService{
+		Watcher:        watcher,
+		EmailRepo:      emailRepo,
+		StateStore:     stateStore,
+		StorageService: storageService,
+		Dbservice:      dbservice,
+		SyncOptions:    options,
+	}
+}
+
+// HandlePushNotification syncs every message added since the last stored
+// checkpoint and advances it. Notifications for other mailboxes, or for
+// history we've already processed, are skipped.
+func (s *SyncService) HandlePushNotification(ctx context.Context, notification entities.PushNotification) (*entities.SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx = logging.WithAccount(ctx, notification.EmailAddress)
+	result := &entities.SyncResult{
+		EmailAddress: notification.EmailAddress,
+		HistoryID:    notification.HistoryID,
+	}
+
+	mailbox, err := s.Watcher.EmailAddress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mailbox: %w", err)
+	}
+	if !strings.EqualFold(mailbox, notification.EmailAddress) {
+		s.Logger.WarnContext(ctx, "ignoring notification for unconfigured mailbox")
+		result.Skipped = true
+		return result, nil
+	}
+
+	state, err := s.StateStore.GetWatchState(ctx, notification.EmailAddress)
+	if errors.Is(err, entities.ErrNotFound) {
+		// Without a checkpoint there is nothing to replay from; start here.
+		state = &entities.WatchState{EmailAddress: notification.EmailAddress, Topic: s.WatchRequest.Topic}
+		state.HistoryID = notification.HistoryID
+		state.UpdatedAt = time.Now().UTC()
+		result.Skipped = true
+		return result, s.StateStore.SaveWatchState(ctx, state)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync state: %w", err)
+	}
+
+	result.StartHistoryID = state.HistoryID
+	if notification.HistoryID <= state.HistoryID {
+		result.Skipped = true
+		return result, nil
+	}
+
+	emailList, latest, err := s.Watcher.FetchHistory(ctx, state.HistoryID)
+	if errors.Is(err, entities.ErrNotFound) {
+		s.Logger.WarnContext(ctx, "history checkpoint no longer available, resyncing", "history_id", state.HistoryID)
+		emailList, err = s.EmailRepo.FetchEmails(ctx, entities.EmailFilter{MaxResults: resyncLimit})
+		latest, result.Resynced = notification.HistoryID, true
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch changes: %w", err)
+	}
+
+	if len(emailList.Emails) > 0 {
+		recordReceived(s.Metrics, metrics.PipelineSync, emailList.Emails)
+		threading.AssignThreadIDs(emailList.Emails)
+		if s.ArchiveRaw {
+			if err := archiveRawEmails(ctx, s.EmailRepo, s.StorageService, emailList); err != nil {
+				return nil, err
+			}
+		}
+		filename, err := storeEmails(ctx, s.Dbservice, s.StorageService, emailList)
+		if err != nil {
+			return nil, err
+		}
+		s.Metrics.MessagesStored(metrics.PipelineSync, len(emailList.Emails))
+		result.S3Filename = filename
+		s.PostProcessor.Apply(ctx, emailList.Emails)
+	}
+
+	result.MessagesFetched = len(emailList.Emails)
+	result.HistoryID = max(latest, notification.HistoryID)
+
+	state.HistoryID = result.HistoryID
+	state.UpdatedAt = time.Now().UTC()
+	if err := s.StateStore.SaveWatchState(ctx, state); err != nil {
+		return nil, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	s.Logger.InfoContext(ctx, "synced mailbox", "messages", result.MessagesFetched, "history_id", result.HistoryID, "resynced", result.Resynced)
+	return result, nil
+}
+
+// EnsureWatch registers users.watch when there is no registration for the
+// configured topic or it expires within RenewBefore. force re-registers
+// unconditionally.
+func (s *SyncService) EnsureWatch(ctx context.Context, force bool) (*entities.WatchState, error) {
+	if s.WatchRequest.Topic == "" {
+		return nil, fmt.Errorf("no Pub/Sub topic configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mailbox, err := s.Watcher.EmailAddress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mailbox: %w", err)
+	}
+
+	state, err := s.StateStore.GetWatchState(ctx, mailbox)
+	if err != nil && !errors.Is(err, entities.ErrNotFound) {
+		return nil, fmt.Errorf("failed to load sync state: %w", err)
+	}
+	if !force && state != nil && state.Topic == s.WatchRequest.Topic && !state.ExpiresWithin(s.RenewBefore) {
+		return state, nil
+	}
+
+	watch, err := s.Watcher.Watch(ctx, s.WatchRequest)
+	if err != nil {
+		return nil, err
+	}
+	// An existing checkpoint wins so renewing never skips unsynced history.
+	if state != nil && state.HistoryID != 0 {
+		watch.HistoryID = state.HistoryID
+	}
+
+	if err := s.StateStore.SaveWatchState(ctx, watch); err != nil {
+		return nil, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return watch, nil
+}
@@ -0,0 +1,276 @@
+// Package bootstrap is the composition root: it turns a loaded config.Config
+// into wired adapters and application services. Every failure is returned,
+// so commands decide how to report it.
+package bootstrap
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/primary/http/handlers"
+	"email-parser-poc/internal/adapters/primary/http/pushauth"
+	"email-parser-poc/internal/adapters/seondary/awsconfig"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/adapters/seondary/dynamodb"
+	"email-parser-poc/internal/adapters/seondary/gmail"
+	"email-parser-poc/internal/adapters/seondary/graph"
+	"email-parser-poc/internal/adapters/seondary/imap"
+	"email-parser-poc/internal/adapters/seondary/s3bucket"
+	"email-parser-poc/internal/adapters/seondary/secrets"
+	"email-parser-poc/internal/adapters/seondary/syncstate"
+	"email-parser-poc/internal/adapters/seondary/token"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/internal/ports/incoming"
+	"email-parser-poc/internal/ports/outgoing"
+	"email-parser-poc/pkg/logging"
+	"email-parser-poc/pkg/metrics"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+type Options struct {
+	Logger *slog.Logger
+	// Metrics may be nil when no admin listener is running.
+	Metrics *metrics.Metrics
+	// HealthCheckers are added to the readiness check alongside the
+	// adapters', e.g. heartbeats of background loops.
+	HealthCheckers []outgoing.HealthChecker
+}
+
+// Services is everything the HTTP router and the listeners need.
+type Services struct {
+	EmailRepo     outgoing.EmailRepository
+	Storage       outgoing.StorageService
+	DB            outgoing.DbService
+	EmailService  incoming.EmailService
+	HealthService incoming.HealthPort
+	// SyncService and PushVerifier are nil unless push is enabled; the
+	// verifier is also nil when JWT verification is off.
+	SyncService  incoming.SyncService
+	PushVerifier handlers.PushVerifier
+	// PostProcessor is shared by the email and sync services; reloads swap
+	// its rules.
+	PostProcessor    *application_api.PostProcessor
+	ReprocessService incoming.ReprocessService
+}
+
+// New builds the services behind `serve`.
+func New(ctx context.Context, cfg *config.Config, options Options) (*Services, error) {
+	logger := logging.OrDefault(options.Logger)
+	ApplyClassifier(cfg)
+
+	storage, db, err := NewStorage(ctx, cfg, logger, options.Metrics)
+	if err != nil {
+		return nil, err
+	}
+	emailRepo, err := NewEmailRepository(ctx, cfg, logger, options.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	postProcessor := application_api.NewPostProcessor(emailRepo, cfg.MailboxRules(), cfg.PostProcessing.DryRun, logger)
+	reprocessService, err := NewReprocessService(cfg, storage, db, logger)
+	if err != nil {
+		return nil, err
+	}
+	services := &Services{
+		EmailRepo:        emailRepo,
+		Storage:          storage,
+		DB:               db,
+		EmailService:     application_api.NewEmailService(emailRepo, storage, db, postProcessor, cfg.Archive.RawEML, logger, options.Metrics),
+		PostProcessor:    postProcessor,
+		ReprocessService: reprocessService,
+	}
+
+	// Adapters that can probe their backend take part in the readiness
+	// check.
+	checkers := options.HealthCheckers
+	for _, dependency := range []any{emailRepo, storage, db} {
+		if checker, ok := dependency.(outgoing.HealthChecker); ok {
+			checkers = append(checkers, checker)
+		}
+	}
+	services.HealthService = application_api.NewHealthService(cfg.App.Version, cfg.Health.Timeout, cfg.Health.CacheTTL, checkers...)
+
+	if cfg.Push.Enabled {
+		watcher := gmail.NewGmailWatcher(gmailConfig(cfg, logger, options.Metrics))
+		services.SyncService = application_api.NewSyncService(watcher, emailRepo, syncstate.NewFileStore(cfg.Push.StateFile), storage, db, application_api.SyncOptions{
+			PostProcessor: postProcessor,
+			ArchiveRaw:    cfg.Archive.RawEML,
+			WatchRequest:  entities.WatchRequest{Topic: cfg.Push.Topic, LabelIDs: cfg.Push.LabelIDs},
+			RenewBefore:   cfg.Push.RenewBefore,
+			Logger:        logger,
+			Metrics:       options.Metrics,
+		})
+
+		if cfg.Push.VerifyJWT {
+			verifier, err := pushauth.NewVerifier(pushauth.Config{
+				Audience:            cfg.Push.Audience,
+				ServiceAccountEmail: cfg.Push.ServiceAccountEmail,
+				JWKSURL:             cfg.Push.JWKSURL,
+				KeyFiles:            cfg.Push.KeyFiles,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize push verifier: %w", err)
+			}
+			services.PushVerifier = handlers.PushVerifierFunc(func(ctx context.Context, token string) error {
+				_, err := verifier.Verify(ctx, token)
+				return err
+			})
+		}
+	}
+	return services, nil
+}
+
+// ApplyClassifier installs the configured rules on the process-wide
+// classifier used by the source adapters.
+func ApplyClassifier(cfg *config.Config) {
+	classifier.Default().SetRules(cfg.Classifier.Rules())
+}
+
+// NewStorage connects the object storage and header database. m may be nil.
+func NewStorage(ctx context.Context, cfg *config.Config, logger *slog.Logger, m *metrics.Metrics) (outgoing.StorageService, outgoing.DbService, error) {
+	sdkConfig := cfg.AWS.SDK()
+	sdkConfig.Secrets = NewSecretResolver(cfg)
+	awsConfig, err := awsconfig.Load(ctx, sdkConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to configure AWS: %w", err)
+	}
+
+	storage, err := s3bucket.NewS3Storage(s3bucket.Config{
+		AWS:          awsConfig,
+		Bucket:       cfg.S3.Bucket,
+		Prefix:       cfg.S3.Prefix,
+		Endpoint:     cfg.S3.Endpoint,
+		UsePathStyle: cfg.S3.UsePathStyle,
+		Layout:       cfg.S3.Layout,
+		KeyTemplate:  cfg.S3.KeyTemplate,
+		Account:      storageAccount(cfg),
+		Manifests:    cfg.S3.Manifests,
+		Format:       cfg.S3.Format,
+		Compression:  cfg.S3.Compression,
+		Metrics:      m,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize S3 storage: %w", err)
+	}
+	db, err := dynamodb.NewDynamoDbInstance(dynamodb.Config{
+		AWS:          awsConfig,
+		HeadersTable: cfg.DynamoDB.HeadersTable,
+		Endpoint:     cfg.DynamoDB.Endpoint,
+		Logger:       logger,
+		Metrics:      m,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize Db storage: %w", err)
+	}
+	return storage, db, nil
+}
+
+// storageAccount is s3.account, or else the mailbox the source reads.
+func storageAccount(cfg *config.Config) string {
+	if cfg.S3.Account != "" {
+		return cfg.S3.Account
+	}
+	switch cfg.Source {
+	case config.SourceIMAP:
+		return cfg.IMAP.Username
+	case config.SourceGraph:
+		return cfg.Graph.User
+	default:
+		return cfg.Gmail.UserID
+	}
+}
+
+// NewReprocessService reprocesses the batches in storage, which must be able
+// to read them back.
+func NewReprocessService(cfg *config.Config, storage outgoing.StorageService, db outgoing.DbService, logger *slog.Logger) (incoming.ReprocessService, error) {
+	archive, ok := storage.(outgoing.ArchiveStore)
+	if !ok {
+		return nil, fmt.Errorf("storage adapter %T cannot read back stored objects", storage)
+	}
+	return application_api.NewReprocessService(archive, db, syncstate.NewImportStore(cfg.Import.StateDir), cfg.Reprocess.Concurrency, logger), nil
+}
+
+// NewSecretResolver resolves the secret references in cfg.
+func NewSecretResolver(cfg *config.Config) outgoing.SecretResolver {
+	return secrets.NewResolver(cfg.Secrets.Resolver())
+}
+
+// NewEmailRepository builds the adapter for the configured source.
+func NewEmailRepository(ctx context.Context, cfg *config.Config, logger *slog.Logger, m *metrics.Metrics) (outgoing.EmailRepository, error) {
+	secretResolver := NewSecretResolver(cfg)
+	switch cfg.Source {
+	case config.SourceGmail:
+		return gmail.NewGmailRepository(gmailConfig(cfg, logger, m)), nil
+	case config.SourceIMAP:
+		// The IMAP password is only read on connect, so it is resolved once.
+		password, err := secretResolver.Resolve(ctx, cfg.IMAP.Password)
+		if err != nil {
+			return nil, fmt.Errorf("imap.password: %w", err)
+		}
+		return imap.NewImapRepository(imap.Config{
+			Host:          cfg.IMAP.Host,
+			Port:          cfg.IMAP.Port,
+			Security:      cfg.IMAP.Security,
+			Auth:          cfg.IMAP.Auth,
+			Username:      cfg.IMAP.Username,
+			Password:      password,
+			TokenProvider: token.NewSecretTokenProvider(secretResolver, cfg.GetAccessToken(), logger),
+			Folder:        cfg.IMAP.Folder,
+			ArchiveFolder: cfg.IMAP.ArchiveFolder,
+			TrashFolder:   cfg.IMAP.TrashFolder,
+			Logger:        logger,
+		}), nil
+	case config.SourceGraph:
+		tokenURL := cfg.Graph.TokenURL
+		if tokenURL == "" {
+			tokenURL = token.MicrosoftTokenURL(cfg.Graph.TenantID)
+		}
+		return graph.NewGraphRepository(graph.Config{
+			BaseURL:       cfg.Graph.BaseURL,
+			User:          cfg.Graph.User,
+			Folder:        cfg.Graph.Folder,
+			ArchiveFolder: cfg.Graph.ArchiveFolder,
+			TokenProvider: token.NewClientCredentialsProvider(token.ClientCredentialsConfig{
+				TokenURL:     tokenURL,
+				ClientID:     cfg.Graph.ClientID,
+				ClientSecret: cfg.Graph.ClientSecret,
+				Secrets:      secretResolver,
+				Scopes:       []string{"https://graph.microsoft.com/.default"},
+				Logger:       logger,
+			}),
+			Logger: logger,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown source %q", cfg.Source)
+}
+
+// NewWatchService builds a SyncService for watch registration only; it has
+// no storage wired and must not be used to handle notifications.
+func NewWatchService(cfg *config.Config, logger *slog.Logger) incoming.SyncService {
+	return application_api.NewSyncService(
+		gmail.NewGmailWatcher(gmailConfig(cfg, logger, nil)),
+		nil,
+		syncstate.NewFileStore(cfg.Push.StateFile),
+		nil,
+		nil,
+		application_api.SyncOptions{
+			WatchRequest: entities.WatchRequest{Topic: cfg.Push.Topic, LabelIDs: cfg.Push.LabelIDs},
+			RenewBefore:  cfg.Push.RenewBefore,
+			Logger:       logger,
+		},
+	)
+}
+
+func gmailConfig(cfg *config.Config, logger *slog.Logger, m *metrics.Metrics) gmail.Config {
+	return gmail.Config{
+		BaseURL:       cfg.Gmail.BaseURL,
+		UserID:        cfg.Gmail.UserID,
+		TokenProvider: token.NewSecretTokenProvider(NewSecretResolver(cfg), cfg.GetAccessToken(), logger),
+		HTTPClient:    &http.Client{Timeout: cfg.Gmail.Timeout},
+		Logger:        logger,
+		Metrics:       m,
+	}
+}
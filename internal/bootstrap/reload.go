@@ -0,0 +1,170 @@
+package bootstrap
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/domain/entities"
+	"email-parser-poc/pkg/logging"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ReloaderOptions struct {
+	// LogLevel is the level of the running logger; nil leaves log settings
+	// unreloadable.
+	LogLevel      *slog.LevelVar
+	PostProcessor *application_api.PostProcessor
+	Logger        *slog.Logger
+}
+
+// Reloader applies configuration changes to a running server. Classifier
+// rules, mailbox rules, the log level and the watch renewal interval are
+// swapped in place; any other change is logged and left for the next
+// restart, since listeners and clients were built from the old values.
+type Reloader struct {
+	options ReloaderOptions
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	current config.Config
+
+	renewInterval atomic.Int64
+}
+
+// NewReloader starts from cfg as loaded from the file, before any command
+// line overrides.
+func NewReloader(cfg *config.Config, options ReloaderOptions) *Reloader {
+	r := &Reloader{
+		options: options,
+		logger:  logging.OrDefault(options.Logger),
+		current: *cfg,
+	}
+	r.renewInterval.Store(int64(cfg.Push.RenewInterval))
+	return r
+}
+
+// RenewInterval is the current push.renew_interval.
+func (r *Reloader) RenewInterval() time.Duration {
+	return time.Duration(r.renewInterval.Load())
+}
+
+func (r *Reloader) Reload(ctx context.Context) (*entities.ConfigReload, error) {
+	next, err := config.Reload()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "config reload failed, keeping the running configuration", "error", err)
+		return nil, err
+	}
+	return r.Apply(ctx, next), nil
+}
+
+// Watch reloads whenever the config file changes, until stop is called.
+func (r *Reloader) Watch() (stop func(), err error) {
+	stop, err = config.Watch(func(next *config.Config, err error) {
+		ctx := context.Background()
+		if err != nil {
+			r.logger.ErrorContext(ctx, "config reload failed, keeping the running configuration", "error", err)
+			return
+		}
+		r.Apply(ctx, next)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch configuration: %w", err)
+	}
+	return stop, nil
+}
+
+// Apply swaps in the reloadable settings of next, which must be valid.
+func (r *Reloader) Apply(ctx context.Context, next *config.Config) *entities.ConfigReload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &entities.ConfigReload{
+		Applied:    []string{},
+		Rejected:   []string{},
+		ReloadedAt: time.Now(),
+	}
+
+	changed := r.current.Changes(next)
+	if len(changed) == 0 {
+		r.logger.InfoContext(ctx, "configuration unchanged")
+		return result
+	}
+
+	logLevel, logReloadable := r.logLevel(next)
+	var classifierChanged, postProcessingChanged bool
+	for _, key := range changed {
+		switch {
+		case strings.HasPrefix(key, "classifier."):
+			classifierChanged = true
+		case strings.HasPrefix(key, "post_processing."):
+			postProcessingChanged = true
+		case key == "push.renew_interval":
+		case logReloadable && isLogSetting(key):
+		default:
+			result.Rejected = append(result.Rejected, key)
+			continue
+		}
+		result.Applied = append(result.Applied, key)
+	}
+
+	// Each subsystem swaps its whole state in one step, so work in flight
+	// sees either the old settings or the new ones, never a mix.
+	if classifierChanged {
+		ApplyClassifier(next)
+		r.current.Classifier = next.Classifier
+	}
+	if postProcessingChanged {
+		if r.options.PostProcessor != nil {
+			r.options.PostProcessor.SetRules(next.MailboxRules(), next.PostProcessing.DryRun)
+		}
+		r.current.PostProcessing = next.PostProcessing
+	}
+	if r.current.Push.RenewInterval != next.Push.RenewInterval {
+		r.renewInterval.Store(int64(next.Push.RenewInterval))
+		r.current.Push.RenewInterval = next.Push.RenewInterval
+	}
+	if logReloadable {
+		r.options.LogLevel.Set(logLevel)
+		r.current.App.Debug = next.App.Debug
+		r.current.App.LogLevel = next.App.LogLevel
+		r.current.App.LogFormat = next.App.LogFormat
+	}
+
+	if len(result.Applied) > 0 {
+		r.logger.InfoContext(ctx, "configuration reloaded", "applied", result.Applied)
+	}
+	if len(result.Rejected) > 0 {
+		r.logger.WarnContext(ctx, "configuration changes ignored until restart: these settings cannot be reloaded", "rejected", result.Rejected)
+	}
+	return result
+}
+
+// logLevel resolves next's log level. Only the level can change at runtime;
+// it is reloadable when the format and PII setting stay the same.
+func (r *Reloader) logLevel(next *config.Config) (slog.Level, bool) {
+	if r.options.LogLevel == nil {
+		return 0, false
+	}
+	current, err := r.current.App.Logging()
+	if err != nil {
+		return 0, false
+	}
+	updated, err := next.App.Logging()
+	if err != nil || updated.Format != current.Format || updated.PIIDebug != current.PIIDebug {
+		return 0, false
+	}
+	return updated.Level.Level(), true
+}
+
+func isLogSetting(key string) bool {
+	switch key {
+	case "app.debug", "app.log_level", "app.log_format":
+		return true
+	}
+	return false
+}
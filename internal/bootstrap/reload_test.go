@@ -0,0 +1,91 @@
+package bootstrap
+
+import (
+	"context"
+	"email-parser-poc/internal/adapters/seondary/config"
+	"email-parser-poc/internal/application_api"
+	"email-parser-poc/internal/domain/classifier"
+	"email-parser-poc/internal/domain/entities"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestReloader(t *testing.T) (*Reloader, *slog.LevelVar, *application_api.PostProcessor, config.Config) {
+	t.Helper()
+	t.Cleanup(func() { classifier.Default().SetRules(classifier.DefaultRules()) })
+
+	cfg := config.DefaultConfig()
+	cfg.App.LogLevel = "info"
+	cfg.Push.RenewInterval = time.Hour
+
+	level := &slog.LevelVar{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	postProcessor := application_api.NewPostProcessor(nil, cfg.MailboxRules(), true, logger)
+	reloader := NewReloader(&cfg, ReloaderOptions{LogLevel: level, PostProcessor: postProcessor, Logger: logger})
+	return reloader, level, postProcessor, cfg
+}
+
+func TestReloaderAppliesReloadableSettings(t *testing.T) {
+	reloader, level, postProcessor, cfg := newTestReloader(t)
+
+	next := cfg
+	next.App.LogLevel = "debug"
+	next.Push.RenewInterval = 10 * time.Minute
+	next.Classifier.Keywords = []string{"bargain"}
+	next.PostProcessing.Rules = []config.MailboxRuleConfig{{Name: "read-news", Category: "newsletters", MarkRead: true}}
+
+	result := reloader.Apply(context.Background(), &next)
+	want := []string{"app.log_level", "push.renew_interval", "classifier.keywords", "post_processing.rules"}
+	if !slices.Equal(result.Applied, want) || len(result.Rejected) != 0 {
+		t.Errorf("applied %v, rejected %v; want applied %v", result.Applied, result.Rejected, want)
+	}
+
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("log level = %v", level.Level())
+	}
+	if reloader.RenewInterval() != 10*time.Minute {
+		t.Errorf("renew interval = %v", reloader.RenewInterval())
+	}
+	if keywords := classifier.Default().Rules().Keywords; !slices.Equal(keywords, []string{"bargain"}) {
+		t.Errorf("classifier keywords = %v", keywords)
+	}
+	news := entities.EmailMessage{ID: "1", Classification: entities.Classification{Category: "newsletters", Confidence: 1}}
+	if actions := postProcessor.Apply(context.Background(), []entities.EmailMessage{news}); len(actions) != 1 || actions[0].Rule != "read-news" {
+		t.Errorf("post-processing actions = %+v", actions)
+	}
+
+	// Applying the same configuration again changes nothing.
+	if result := reloader.Apply(context.Background(), &next); len(result.Applied) != 0 || len(result.Rejected) != 0 {
+		t.Errorf("second apply: %+v", result)
+	}
+}
+
+func TestReloaderRejectsSettingsNeedingRestart(t *testing.T) {
+	reloader, level, _, cfg := newTestReloader(t)
+	level.Set(slog.LevelInfo)
+
+	next := cfg
+	next.Server.Port = "9000"
+	next.S3.Bucket = "other-bucket"
+	// The level can't change together with the format: the handler is
+	// built once.
+	next.App.LogLevel = "debug"
+	next.App.LogFormat = "json"
+
+	result := reloader.Apply(context.Background(), &next)
+	want := []string{"app.log_level", "app.log_format", "server.port", "s3.bucket"}
+	if !slices.Equal(result.Rejected, want) || len(result.Applied) != 0 {
+		t.Errorf("applied %v, rejected %v; want rejected %v", result.Applied, result.Rejected, want)
+	}
+	if level.Level() != slog.LevelInfo {
+		t.Errorf("log level changed to %v", level.Level())
+	}
+
+	// Rejected settings stay pending: they are reported again.
+	if result := reloader.Apply(context.Background(), &next); !slices.Equal(result.Rejected, want) {
+		t.Errorf("second apply rejected %v", result.Rejected)
+	}
+}
@@ -0,0 +1,128 @@
+// Package classifier scores messages against keyword and header rules to
+// decide whether they are promotional.
+package classifier
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	CategoryPromotions = "promotions"
+	CategoryPrimary    = "primary"
+)
+
+type Rules struct {
+	Keywords      []string       `json:"keywords"`
+	KeywordWeight int            `json:"keyword_weight"`
+	HeaderWeights map[string]int `json:"header_weights"`
+	Threshold     int            `json:"threshold"`
+}
+
+func DefaultRules() Rules {
+	return Rules{
+		Keywords: []string{
+			"sale", "discount", "off", "deal", "offer", "save", "free",
+			"limited time", "expires", "ending soon", "last chance",
+			"25%", "50%", "percent", "promo", "coupon", "unsubscribe",
+			"marketing email", "promotional", "newsletter",
+		},
+		KeywordWeight: 1,
+		HeaderWeights: map[string]int{
+			"List-Unsubscribe": 3,
+		},
+		Threshold: 2,
+	}
+}
+
+type Classifier struct {
+	rules atomic.Pointer[Rules]
+}
+
+func New(rules Rules) *Classifier {
+	c := &Classifier{}
+	c.SetRules(rules)
+	return c
+}
+
+var defaultClassifier = New(DefaultRules())
+
+// Default returns the process-wide classifier used by the source adapters.
+func Default() *Classifier {
+	return defaultClassifier
+}
+
+// SetRules swaps the rule set atomically; in-flight classifications finish
+// with the rules they started with.
+func (c *Classifier) SetRules(rules Rules) {
+	if rules.KeywordWeight == 0 {
+		rules.KeywordWeight = 1
+	}
+	c.rules.Store(&rules)
+}
+
+func (c *Classifier) Rules() Rules {
+	return *c.rules.Load()
+}
+
+// Classify scores the subject, body and From header against the keyword list
+// and adds header weights. Confidence is score/(score+1) for promotions and
+// shrinks towards 0.5 as a non-promotional message nears the threshold.
+func (c *Classifier) Classify(email *entities.EmailMessage) entities.Classification {
+	rules := c.rules.Load()
+
+	content := strings.ToLower(email.Subject + " " + email.Body + " " + email.From)
+
+	var signals []string
+	score := 0
+	for _, keyword := range rules.Keywords {
+		if strings.Contains(content, strings.ToLower(keyword)) {
+			score += rules.KeywordWeight
+			signals = append(signals, "keyword:"+keyword)
+		}
+	}
+	headers := make([]string, 0, len(rules.HeaderWeights))
+	for header := range rules.HeaderWeights {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		if hasHeader(email.Headers, header) {
+			score += rules.HeaderWeights[header]
+			signals = append(signals, "header:"+header)
+		}
+	}
+
+	classification := entities.Classification{
+		Category: CategoryPrimary,
+		Score:    score,
+		Signals:  signals,
+	}
+	if score >= rules.Threshold {
+		classification.Category = CategoryPromotions
+		classification.Confidence = float64(score) / float64(score+1)
+	} else {
+		classification.Confidence = 1 - 0.5*float64(score)/float64(max(rules.Threshold, 1))
+	}
+	return classification
+}
+
+// Apply classifies email and records the verdict on it.
+func (c *Classifier) Apply(email *entities.EmailMessage) {
+	email.Classification = c.Classify(email)
+	email.IsPromotional = email.Classification.Category == CategoryPromotions
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	if _, ok := headers[name]; ok {
+		return true
+	}
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
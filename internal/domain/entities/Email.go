@@ -2,20 +2,99 @@ package entities
 
 import "time"
 
+// EmailMessage is a parsed message. Date is taken from the Date header and
+// falls back to ReceivedAt, the time the source received the message, when the
+// header is missing; InvalidDate is set when the header was present but could
+// not be parsed.
 type EmailMessage struct {
-	ID            string            `json:"id"`
-	Subject       string            `json:"subject"`
-	From          string            `json:"from"`
-	To            string            `json:"to"`
-	Date          time.Time         `json:"date"`
-	Body          string            `json:"body"`
-	Headers       map[string]string `json:"headers"`
-	IsPromotional bool              `json:"is_promotional"`
+	ID             string            `json:"id"`
+	ThreadID       string            `json:"thread_id,omitempty"`
+	MessageID      string            `json:"message_id,omitempty"`
+	InReplyTo      []string          `json:"in_reply_to,omitempty"`
+	References     []string          `json:"references,omitempty"`
+	Subject        string            `json:"subject"`
+	From           string            `json:"from"`
+	To             string            `json:"to"`
+	Date           time.Time         `json:"date"`
+	ReceivedAt     time.Time         `json:"received_at"`
+	InvalidDate    bool              `json:"invalid_date,omitempty"`
+	Body           string            `json:"body"`
+	Headers        map[string]string `json:"headers"`
+	Addresses      AddressHeaders    `json:"addresses"`
+	Labels         []string          `json:"labels,omitempty"`
+	Attachments    []Attachment      `json:"attachments,omitempty"`
+	RawKey         string            `json:"raw_key,omitempty"`
+	IsPromotional  bool              `json:"is_promotional"`
+	Classification Classification    `json:"classification"`
 }
+
+// Attachment describes a file attached to a message, as reported by the
+// source. The content itself stays in the raw message.
+type Attachment struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	Inline      bool   `json:"inline,omitempty"`
+}
+
+// Classification is the classifier's verdict. Signals lists the rules that
+// fired, e.g. "keyword:sale" or "header:List-Unsubscribe".
+type Classification struct {
+	Category   string   `json:"category"`
+	Score      int      `json:"score"`
+	Confidence float64  `json:"confidence"`
+	Signals    []string `json:"signals,omitempty"`
+}
+
+// Address is a single RFC 5322 mailbox. Name is the RFC 2047 decoded display
+// name and Domain is lower-cased with IDN labels in their ASCII (xn--) form.
+type Address struct {
+	Name      string `json:"name,omitempty"`
+	LocalPart string `json:"local_part"`
+	Domain    string `json:"domain"`
+	Address   string `json:"address"`
+	Group     string `json:"group,omitempty"`
+}
+
+// AddressHeaders holds the parsed mailboxes of every address header.
+type AddressHeaders struct {
+	From    []Address `json:"from,omitempty"`
+	Sender  []Address `json:"sender,omitempty"`
+	ReplyTo []Address `json:"reply_to,omitempty"`
+	To      []Address `json:"to,omitempty"`
+	Cc      []Address `json:"cc,omitempty"`
+	Bcc     []Address `json:"bcc,omitempty"`
+}
+
+// SenderDomain returns the domain of the first From mailbox, falling back to
+// Sender when From could not be parsed.
+func (e *EmailMessage) SenderDomain() string {
+	if len(e.Addresses.From) > 0 {
+		return e.Addresses.From[0].Domain
+	}
+	if len(e.Addresses.Sender) > 0 {
+		return e.Addresses.Sender[0].Domain
+	}
+	return ""
+}
+
+// Recipients returns every To, Cc and Bcc mailbox in header order.
+func (e *EmailMessage) Recipients() []Address {
+	recipients := make([]Address, 0, len(e.Addresses.To)+len(e.Addresses.Cc)+len(e.Addresses.Bcc))
+	recipients = append(recipients, e.Addresses.To...)
+	recipients = append(recipients, e.Addresses.Cc...)
+	recipients = append(recipients, e.Addresses.Bcc...)
+	return recipients
+}
+
 type EmailList struct {
 	Emails        []EmailMessage `json:"emails"`
 	NextPageToken string         `json:"next_page_token,omitempty"`
 	TotalCount    int            `json:"total_count"`
+	// Failed counts messages the source listed but could not fetch or
+	// parse. They are logged and left out of Emails.
+	Failed int `json:"failed,omitempty"`
 }
 
 type EmailFilter struct {
@@ -23,4 +102,8 @@ type EmailFilter struct {
 	Query           string `json:"query,omitempty"`
 	PageToken       string `json:"page_token,omitempty"`
 	OnlyPromotional bool   `json:"only_promotional"`
+	// ExpandThreads returns the whole thread of each matching message.
+	// MaxResults then limits the matching messages, not the threads' size,
+	// so the result can hold more than MaxResults messages.
+	ExpandThreads bool `json:"expand_threads"`
 }
@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// ConfigReload reports the outcome of a configuration reload. Applied lists
+// the settings now in effect; Rejected lists changed settings that only take
+// effect after a restart, e.g. the listen address or credentials.
+type ConfigReload struct {
+	Applied    []string  `json:"applied"`
+	Rejected   []string  `json:"rejected"`
+	ReloadedAt time.Time `json:"reloaded_at"`
+}
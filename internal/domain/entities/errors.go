@@ -0,0 +1,22 @@
+package entities
+
+import "errors"
+
+// ErrNotFound is returned by repositories when the requested resource does not
+// exist at the source.
+var ErrNotFound = errors.New("not found")
+
+// ErrNotSupported is returned when a source cannot perform an operation, e.g.
+// fetching threads by ID from a source without native threads.
+var ErrNotSupported = errors.New("not supported")
+
+// ErrInvalidMessage is returned for input that is not an RFC 822 message.
+var ErrInvalidMessage = errors.New("invalid RFC 822 message")
+
+// ErrDegraded is wrapped by health checks that still work but not fully, e.g.
+// a table that is being updated. It marks the check degraded, not unhealthy.
+var ErrDegraded = errors.New("degraded")
+
+// ErrInvalidConfig is returned when a configuration fails validation, e.g.
+// on a reload, where the running configuration stays in place.
+var ErrInvalidConfig = errors.New("invalid configuration")
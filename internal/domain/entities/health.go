@@ -7,6 +7,7 @@ type HealthStatus string
 
 const (
 	HealthStatusHealthy   HealthStatus = "healthy"
+	HealthStatusDegraded  HealthStatus = "degraded"
 	HealthStatusUnhealthy HealthStatus = "unhealthy"
 )
 
@@ -24,12 +25,21 @@ type HealthCheck struct {
 	Error      string        `json:"error,omitempty"`
 	TimeStamps time.Time     `json:"timestamps"`
 	Duration   time.Duration `json:"duration"`
+	// Critical checks make the service unhealthy when they fail; failures of
+	// the others only degrade it.
+	Critical bool `json:"critical"`
 }
 
 func (h *Health) IsHealthy() bool {
 	return h.Status == HealthStatusHealthy
 }
 
+// IsReady reports whether the service can take traffic, which it can while
+// degraded.
+func (h *Health) IsReady() bool {
+	return h.Status != HealthStatusUnhealthy
+}
+
 func (h *Health) Addcheck(name string, check HealthCheck) {
 	if h.Checks == nil {
 		h.Checks = make(map[string]HealthCheck)
@@ -40,22 +50,18 @@ func (h *Health) Addcheck(name string, check HealthCheck) {
 }
 
 func (h *Health) updateOverallStatus() {
-	if h.Checks == nil {
-		h.Status = HealthStatusHealthy
-		return
-	}
-	hasUnhealthy := false
+	h.Status = HealthStatusHealthy
 
-	for _, checks := range h.Checks {
-		switch checks.Status {
-		case HealthStatusHealthy:
-			hasUnhealthy = true
+	for _, check := range h.Checks {
+		switch check.Status {
+		case HealthStatusUnhealthy:
+			if check.Critical {
+				h.Status = HealthStatusUnhealthy
+				return
+			}
+			h.Status = HealthStatusDegraded
+		case HealthStatusDegraded:
+			h.Status = HealthStatusDegraded
 		}
 	}
-
-	if hasUnhealthy {
-		h.Status = HealthStatusUnhealthy
-	} else {
-		h.Status = HealthStatusHealthy
-	}
 }
@@ -0,0 +1,65 @@
+package entities
+
+import "time"
+
+const (
+	ImportFormatMbox    = "mbox"
+	ImportFormatMaildir = "maildir"
+)
+
+// ImportRequest describes a bulk import from an exported mail archive.
+type ImportRequest struct {
+	Path string `json:"path"`
+	// Format is "mbox" or "maildir"; empty detects it from the path.
+	Format string `json:"format,omitempty"`
+	// Variant is the mbox flavour: "mboxrd" (default), "mboxo", "mboxcl" or
+	// "mboxcl2".
+	Variant   string `json:"variant,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+	// Restart ignores a saved checkpoint and starts from the beginning.
+	// Already imported messages are still skipped as duplicates.
+	Restart bool `json:"restart,omitempty"`
+}
+
+// RawMessage is one message read from an archive or uploaded, before
+// parsing. Name identifies it in errors, e.g. the uploaded file name.
+// SourceKey is set by archives whose positions aren't stable, such as
+// Maildir: imports record it once the message is handled and skip it on the
+// next run.
+type RawMessage struct {
+	Name       string
+	SourceKey  string
+	Data       []byte
+	Labels     []string
+	ReceivedAt time.Time
+}
+
+// ImportCheckpoint records how far an archive has been imported. Position is
+// a byte offset for mbox files; Maildir imports skip files by
+// RawMessage.SourceKey instead, so theirs only reports progress.
+// Reprocess runs use Key instead, the last object key they finished.
+type ImportCheckpoint struct {
+	Source    string    `json:"source"`
+	Position  int64     `json:"position"`
+	Key       string    `json:"key,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ImportProgress is reported after every batch and returned at the end.
+type ImportProgress struct {
+	Source     string `json:"source"`
+	Position   int64  `json:"position"`
+	Total      int64  `json:"total"`
+	Imported   int    `json:"imported"`
+	Duplicates int    `json:"duplicates"`
+	Failed     int    `json:"failed"`
+	Resumed    bool   `json:"resumed,omitempty"`
+}
+
+// Percent returns how much of the source has been read.
+func (p ImportProgress) Percent() float64 {
+	if p.Total <= 0 {
+		return 100
+	}
+	return 100 * float64(p.Position) / float64(p.Total)
+}
@@ -0,0 +1,33 @@
+package entities
+
+// MailboxRule describes what to do in the source mailbox with a message once
+// it has been processed. A rule matches when the category matches (or is
+// empty) and the classification confidence is strictly above MinConfidence.
+type MailboxRule struct {
+	Name          string   `json:"name"`
+	Category      string   `json:"category,omitempty"`
+	MinConfidence float64  `json:"min_confidence"`
+	AddLabels     []string `json:"add_labels,omitempty"`
+	RemoveLabels  []string `json:"remove_labels,omitempty"`
+	Archive       bool     `json:"archive,omitempty"`
+	MarkRead      bool     `json:"mark_read,omitempty"`
+	Trash         bool     `json:"trash,omitempty"`
+}
+
+func (r MailboxRule) Matches(email *EmailMessage) bool {
+	if r.Category != "" && r.Category != email.Classification.Category {
+		return false
+	}
+	return email.Classification.Confidence > r.MinConfidence
+}
+
+// MailboxAction records a mutation applied, or in dry-run mode planned, by a
+// MailboxRule.
+type MailboxAction struct {
+	MessageID string   `json:"message_id"`
+	Rule      string   `json:"rule"`
+	Action    string   `json:"action"`
+	Labels    []string `json:"labels,omitempty"`
+	DryRun    bool     `json:"dry_run"`
+	Error     string   `json:"error,omitempty"`
+}
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// BatchManifest lists the per-message objects stored by one request, so the
+// batch can still be found as a whole.
+type BatchManifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Count     int             `json:"count"`
+	Emails    []ManifestEntry `json:"emails"`
+}
+
+type ManifestEntry struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
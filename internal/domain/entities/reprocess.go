@@ -0,0 +1,82 @@
+package entities
+
+import "time"
+
+// Reprocess job states.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// ReprocessRequest selects the stored batches to run through the current
+// parser and classifier again.
+type ReprocessRequest struct {
+	// Prefix narrows the listing below the storage prefix, e.g.
+	// "emails_202509" for the batches stored in September 2025.
+	Prefix string `json:"prefix,omitempty"`
+	// Since and Until bound the dates of the stored messages as their keys
+	// record them: the date=YYYY/MM/DD partition of per-message objects,
+	// which selects whole days, or the storage time of emails_<ts> batches.
+	// Zero leaves that side open. With either set, objects whose key carries
+	// no date are skipped.
+	Since       time.Time `json:"since,omitzero"`
+	Until       time.Time `json:"until,omitzero"`
+	Concurrency int       `json:"concurrency,omitempty"`
+	// DryRun reports what would change without writing anything, including
+	// the checkpoint.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Restart ignores a saved checkpoint and starts from the first object.
+	Restart bool `json:"restart,omitempty"`
+}
+
+// ReprocessProgress is reported after every object and returned at the end.
+// Fields counts the changed messages per JSON field of EmailMessage and
+// Categories the category moves, e.g. "primary -> promotions".
+type ReprocessProgress struct {
+	Source        string         `json:"source"`
+	Resumed       bool           `json:"resumed,omitempty"`
+	Objects       int            `json:"objects"`
+	Skipped       int            `json:"skipped"`
+	FailedObjects int            `json:"failed_objects"`
+	Rewritten     int            `json:"rewritten"`
+	Messages      int            `json:"messages"`
+	Changed       int            `json:"changed"`
+	Failed        int            `json:"failed"`
+	Fields        map[string]int `json:"fields,omitempty"`
+	Categories    map[string]int `json:"categories,omitempty"`
+	// LastKey is the checkpoint: every object up to it has been processed.
+	LastKey string `json:"last_key,omitempty"`
+}
+
+// ReprocessJob is a reprocess run started through the admin API.
+type ReprocessJob struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	Request    ReprocessRequest  `json:"request"`
+	Progress   ReprocessProgress `json:"progress"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at,omitzero"`
+}
+
+// Kinds of stored objects.
+const (
+	StoredBatch    = "batch"
+	StoredMessage  = "message"
+	StoredManifest = "manifest"
+	StoredRaw      = "raw"
+)
+
+// StoredObject is one object listed from storage. Kind is one of the Stored
+// constants, or empty for objects the storage adapter did not write.
+// DateFrom and DateTo are the earliest and latest date the object's
+// messages can have, as far as its key tells; both are zero when it doesn't.
+type StoredObject struct {
+	Key          string
+	Kind         string
+	Size         int64
+	LastModified time.Time
+	DateFrom     time.Time
+	DateTo       time.Time
+}
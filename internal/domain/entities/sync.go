@@ -0,0 +1,40 @@
+package entities
+
+import "time"
+
+// PushNotification is the payload Gmail publishes to Pub/Sub when a watched
+// mailbox changes.
+type PushNotification struct {
+	EmailAddress string `json:"emailAddress"`
+	HistoryID    uint64 `json:"historyId"`
+}
+
+// WatchState tracks a users.watch registration and how far the mailbox has
+// been synced.
+type WatchState struct {
+	EmailAddress string    `json:"email_address"`
+	Topic        string    `json:"topic"`
+	HistoryID    uint64    `json:"history_id"`
+	Expiration   time.Time `json:"expiration"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ExpiresWithin reports whether the watch lapses within d of now.
+func (w *WatchState) ExpiresWithin(d time.Duration) bool {
+	return w.Expiration.IsZero() || time.Until(w.Expiration) < d
+}
+
+type WatchRequest struct {
+	Topic    string   `json:"topic"`
+	LabelIDs []string `json:"label_ids,omitempty"`
+}
+
+type SyncResult struct {
+	EmailAddress    string `json:"email_address"`
+	StartHistoryID  uint64 `json:"start_history_id"`
+	HistoryID       uint64 `json:"history_id"`
+	MessagesFetched int    `json:"messages_fetched"`
+	S3Filename      string `json:"s3_filename,omitempty"`
+	Skipped         bool   `json:"skipped,omitempty"`
+	Resynced        bool   `json:"resynced,omitempty"`
+}
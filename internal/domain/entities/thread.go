@@ -0,0 +1,16 @@
+package entities
+
+// Thread is a conversation ordered depth-first, replies following the message
+// they answer and siblings sorted by date.
+type Thread struct {
+	ID           string          `json:"id"`
+	Subject      string          `json:"subject"`
+	MessageCount int             `json:"message_count"`
+	Messages     []ThreadMessage `json:"messages"`
+}
+
+type ThreadMessage struct {
+	EmailMessage
+	Depth    int    `json:"depth"`
+	ParentID string `json:"parent_id,omitempty"`
+}
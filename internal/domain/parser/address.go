@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var addressParser = &mail.AddressParser{WordDecoder: wordDecoder}
+
+type addressSegment struct {
+	text  string
+	group string
+}
+
+// ParseAddressList parses an RFC 5322 address header such as From, To or Cc.
+// Group syntax ("Team: a@example.com, b@example.com;") is flattened and the
+// group name recorded on every member. Mailboxes that cannot be parsed are
+// skipped and reported in the returned error alongside the ones that could.
+func ParseAddressList(value string) ([]entities.Address, error) {
+	segments := mergeUnquotedNames(splitAddressList(value))
+
+	var addresses []entities.Address
+	var errs []error
+	for _, segment := range segments {
+		address, err := parseMailbox(segment.text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid address %q: %w", segment.text, err))
+			continue
+		}
+		address.Group = segment.group
+		addresses = append(addresses, address)
+	}
+	return addresses, errors.Join(errs...)
+}
+
+// splitAddressList splits a header on top-level commas while honouring quoted
+// strings, comments, angle-addr brackets and group delimiters.
+func splitAddressList(value string) []addressSegment {
+	var (
+		segments     []addressSegment
+		current      strings.Builder
+		group        string
+		inGroup      bool
+		inQuote      bool
+		escaped      bool
+		commentDepth int
+		angleDepth   int
+	)
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			segments = append(segments, addressSegment{text: text, group: group})
+		}
+		current.Reset()
+	}
+
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && (inQuote || commentDepth > 0):
+			escaped = true
+		case inQuote:
+			if r == '"' {
+				inQuote = false
+			}
+		case commentDepth > 0:
+			if r == '(' {
+				commentDepth++
+			} else if r == ')' {
+				commentDepth--
+			}
+		case r == '"':
+			inQuote = true
+		case r == '(':
+			commentDepth++
+		case r == '<':
+			angleDepth++
+		case r == '>' && angleDepth > 0:
+			angleDepth--
+		case angleDepth > 0:
+		case r == ',':
+			flush()
+			continue
+		case r == ':' && !inGroup:
+			group = DecodeHeader(strings.Trim(strings.TrimSpace(current.String()), `"`))
+			inGroup = true
+			current.Reset()
+			continue
+		case r == ';' && inGroup:
+			flush()
+			group, inGroup = "", false
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+
+	return segments
+}
+
+// mergeUnquotedNames rejoins display names that contain an unquoted comma,
+// e.g. `Doe, John <john@example.com>`, which many clients still emit.
+func mergeUnquotedNames(segments []addressSegment) []addressSegment {
+	merged := make([]addressSegment, 0, len(segments))
+	for i := 0; i < len(segments); i++ {
+		segment := segments[i]
+		for !strings.Contains(segment.text, "@") && i+1 < len(segments) && segments[i+1].group == segment.group {
+			i++
+			segment.text += ", " + segments[i].text
+			if strings.Contains(segments[i].text, "<") {
+				break
+			}
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+func parseMailbox(text string) (entities.Address, error) {
+	var name, spec string
+
+	if parsed, err := addressParser.Parse(text); err == nil {
+		name, spec = parsed.Name, parsed.Address
+	} else {
+		name, spec = lenientMailbox(text)
+		if spec == "" {
+			return entities.Address{}, err
+		}
+	}
+
+	at := strings.LastIndex(spec, "@")
+	if at <= 0 || at == len(spec)-1 {
+		return entities.Address{}, fmt.Errorf("missing local part or domain")
+	}
+
+	domain, err := NormalizeDomain(spec[at+1:])
+	if err != nil {
+		return entities.Address{}, err
+	}
+	local := spec[:at]
+
+	return entities.Address{
+		Name:      name,
+		LocalPart: local,
+		Domain:    domain,
+		Address:   local + "@" + domain,
+	}, nil
+}
+
+// lenientMailbox recovers a mailbox from input net/mail rejects, such as
+// unquoted specials in the display name or stray whitespace in the addr-spec.
+func lenientMailbox(text string) (string, string) {
+	if open := strings.LastIndex(text, "<"); open >= 0 {
+		if end := strings.Index(text[open:], ">"); end > 0 {
+			spec := strings.Join(strings.Fields(text[open+1:open+end]), "")
+			name := strings.Trim(strings.TrimSpace(text[:open]), `"'`)
+			return DecodeHeader(name), spec
+		}
+	}
+
+	for _, field := range strings.Fields(text) {
+		field = strings.Trim(field, `<>"'(),;`)
+		if strings.Contains(field, "@") {
+			return "", field
+		}
+	}
+	return "", ""
+}
+
+// domainProfile applies the UTS #46 lookup mapping without STD3's hostname
+// rules, which real mail domains such as those with underscores break.
+var domainProfile = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// NormalizeDomain lower-cases a domain and converts any internationalized
+// labels to their ASCII compatible (xn--) form so that "BÜCHER.example" and
+// "xn--bcher-kva.example" compare equal.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	ascii, err := domainProfile.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+	return ascii, nil
+}
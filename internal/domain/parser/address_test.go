@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"reflect"
+	"testing"
+)
+
+func TestParseAddressList(t *testing.T) {
+	ann := entities.Address{LocalPart: "ann", Domain: "example.com", Address: "ann@example.com"}
+	bob := entities.Address{LocalPart: "bob", Domain: "example.com", Address: "bob@example.com"}
+	named := func(a entities.Address, name string) entities.Address { a.Name = name; return a }
+	grouped := func(a entities.Address, group string) entities.Address { a.Group = group; return a }
+
+	tests := []struct {
+		name    string
+		value   string
+		want    []entities.Address
+		wantErr bool
+	}{
+		{name: "bare", value: "ann@example.com", want: []entities.Address{ann}},
+		{name: "list", value: "ann@example.com, Bob <bob@example.com>", want: []entities.Address{ann, named(bob, "Bob")}},
+		{name: "quoted comma", value: `"Doe, Ann" <ann@example.com>, bob@example.com`, want: []entities.Address{named(ann, "Doe, Ann"), bob}},
+		{name: "unquoted comma", value: "Doe, Ann <ann@example.com>", want: []entities.Address{named(ann, "Doe, Ann")}},
+		{name: "quoted specials", value: `"Ann (work) <x>" <ann@example.com>`, want: []entities.Address{named(ann, "Ann (work) <x>")}},
+		{name: "comment", value: "ann@example.com (Ann, at work)", want: []entities.Address{named(ann, "Ann, at work")}},
+		{name: "encoded word", value: "=?UTF-8?B?QW5uIEfDvG50ZXI=?= <ann@example.com>", want: []entities.Address{named(ann, "Ann Günter")}},
+		{name: "encoded word with comma", value: "=?ISO-8859-1?Q?M=FCller=2C_Ann?= <ann@example.com>", want: []entities.Address{named(ann, "Müller, Ann")}},
+		{
+			name:  "group",
+			value: "Team: ann@example.com, Bob <bob@example.com>;, carl@example.org",
+			want: []entities.Address{
+				grouped(ann, "Team"),
+				grouped(named(bob, "Bob"), "Team"),
+				{LocalPart: "carl", Domain: "example.org", Address: "carl@example.org"},
+			},
+		},
+		{name: "encoded group name", value: "=?UTF-8?Q?=C3=89quipe?=: ann@example.com;", want: []entities.Address{grouped(ann, "Équipe")}},
+		{name: "empty group", value: "undisclosed-recipients:;"},
+		{name: "upper-case domain", value: "ann@EXAMPLE.COM", want: []entities.Address{ann}},
+		{
+			name:  "IDN domain",
+			value: "Ann <ann@BÜCHER.example>",
+			want:  []entities.Address{{Name: "Ann", LocalPart: "ann", Domain: "xn--bcher-kva.example", Address: "ann@xn--bcher-kva.example"}},
+		},
+		{name: "lenient spec", value: "Ann <ann @ example.com>", want: []entities.Address{named(ann, "Ann")}},
+		{name: "invalid skipped", value: "bob@, ann@example.com", want: []entities.Address{ann}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddressList(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addresses =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		domain  string
+		want    string
+		wantErr bool
+	}{
+		{domain: "Example.COM", want: "example.com"},
+		{domain: "example.com.", want: "example.com"},
+		{domain: "BÜCHER.example", want: "xn--bcher-kva.example"},
+		{domain: "xn--bcher-kva.example", want: "xn--bcher-kva.example"},
+		{domain: "例え.テスト", want: "xn--r8jz45g.xn--zckzah"},
+		{domain: "faß.de", want: "xn--fa-hia.de"},
+		{domain: "mail_relay.example.com", want: "mail_relay.example.com"},
+		{domain: "xn--a.example", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := NormalizeDomain(tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeDomain(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// wordDecoder decodes RFC 2047 encoded-words in any charset known to the
+// WHATWG encoding index, not just the utf-8/iso-8859-1 pair the stdlib handles.
+var wordDecoder = &mime.WordDecoder{CharsetReader: CharsetReader}
+
+// CharsetReader returns a reader that converts input from charset to UTF-8.
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %w", charset, err)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// DecodeHeader decodes any RFC 2047 encoded-words in a header value. Values
+// that cannot be decoded are returned unchanged.
+func DecodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
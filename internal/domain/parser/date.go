@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March,
+	"apr": time.April, "may": time.May, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September,
+	"oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var weekdays = map[string]bool{
+	"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true,
+}
+
+// zoneOffsets covers the obsolete zone names of RFC 5322 section 4.3 plus a
+// few that commonly appear in the wild. Offsets are in seconds east of UTC.
+var zoneOffsets = map[string]int{
+	"ut": 0, "utc": 0, "gmt": 0, "z": 0,
+	"est": -5 * 3600, "edt": -4 * 3600,
+	"cst": -6 * 3600, "cdt": -5 * 3600,
+	"mst": -7 * 3600, "mdt": -6 * 3600,
+	"pst": -8 * 3600, "pdt": -7 * 3600,
+	"bst": 1 * 3600, "cet": 1 * 3600, "cest": 2 * 3600,
+	"eet": 2 * 3600, "eest": 3 * 3600, "wet": 0, "west": 1 * 3600,
+	"jst": 9 * 3600, "kst": 9 * 3600, "hkt": 8 * 3600, "sgt": 8 * 3600,
+	"aest": 10 * 3600, "aedt": 11 * 3600,
+}
+
+// strictLayouts are tried before the lenient tokenizer for values that are
+// not RFC 5322 at all but still turn up in Date headers.
+var strictLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// ParseDate parses an RFC 5322 Date header leniently. Besides the standard
+// syntax it accepts obsolete and named zones ("EST", "UT", military letters),
+// comments such as "(UTC)", missing weekdays or seconds, single-digit days,
+// two- and three-digit years, RFC 850 ("Sunday, 4-Mar-25 10:00:00 GMT"),
+// ANSI C asctime() output and ISO 8601.
+func ParseDate(value string) (time.Time, error) {
+	cleaned := strings.TrimSpace(stripComments(value))
+	if cleaned == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	for _, layout := range strictLayouts {
+		if t, err := time.Parse(layout, cleaned); err == nil {
+			return t, nil
+		}
+	}
+
+	var (
+		day, year     = -1, -1
+		yearDigits    int
+		month         time.Month
+		hour, minute  int
+		sec           int
+		haveTime      bool
+		offset        int
+		haveZone      bool
+		unknownTokens []string
+	)
+
+	tokens := strings.FieldsFunc(cleaned, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ',' || r == '\r' || r == '\n'
+	})
+	tokens = splitDashedDates(tokens)
+	for _, token := range tokens {
+		lower := strings.ToLower(strings.TrimSuffix(token, "."))
+
+		switch {
+		case strings.Contains(token, ":") && !haveTime && isDigit(token[0]):
+			var err error
+			hour, minute, sec, err = parseClock(token)
+			if err != nil {
+				return time.Time{}, err
+			}
+			haveTime = true
+		case (token[0] == '+' || token[0] == '-') && len(token) > 1 && !haveZone:
+			o, err := parseNumericZone(token)
+			if err != nil {
+				return time.Time{}, err
+			}
+			offset, haveZone = o, true
+		case isNumber(token):
+			n, _ := strconv.Atoi(token)
+			switch {
+			case day < 0 && len(token) <= 2 && !(month != 0 && year >= 0):
+				day = n
+			case year < 0:
+				year, yearDigits = n, len(token)
+			default:
+				unknownTokens = append(unknownTokens, token)
+			}
+		case len(lower) >= 3 && months[lower[:3]] != 0 && month == 0 && isLetters(lower):
+			month = months[lower[:3]]
+		case len(lower) >= 3 && weekdays[lower[:3]] && isLetters(lower):
+		case isLetters(lower) && !haveZone:
+			if o, ok := zoneOffsets[lower]; ok {
+				offset, haveZone = o, true
+			} else if len(lower) == 1 {
+				// Military zones were specified with inverted signs, so RFC
+				// 5322 says to treat them as -0000 (unknown local time).
+				haveZone = true
+			} else {
+				unknownTokens = append(unknownTokens, token)
+			}
+		default:
+			unknownTokens = append(unknownTokens, token)
+		}
+	}
+
+	if day < 1 || month == 0 || year < 0 {
+		return time.Time{}, fmt.Errorf("unparseable date %q", value)
+	}
+	if len(unknownTokens) > 1 {
+		return time.Time{}, fmt.Errorf("unparseable date %q: unexpected %v", value, unknownTokens)
+	}
+
+	switch {
+	case yearDigits <= 2 && year < 50:
+		year += 2000
+	case yearDigits <= 3 && year < 1000:
+		year += 1900
+	}
+
+	t := time.Date(year, month, day, hour, minute, sec, 0, time.FixedZone("", offset))
+	if t.Day() != day || t.Month() != month {
+		return time.Time{}, fmt.Errorf("invalid calendar date %q", value)
+	}
+	return t, nil
+}
+
+// splitDashedDates expands RFC 850 day-month-year tokens such as "4-Mar-25"
+// into separate day, month and year tokens.
+func splitDashedDates(tokens []string) []string {
+	out := make([]string, 0, len(tokens)+2)
+	for _, token := range tokens {
+		parts := strings.Split(token, "-")
+		if len(parts) == 3 && isNumber(parts[0]) && len(parts[0]) <= 2 && isLetters(parts[1]) && isNumber(parts[2]) {
+			out = append(out, parts...)
+			continue
+		}
+		out = append(out, token)
+	}
+	return out
+}
+
+func stripComments(value string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range value {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func parseClock(token string) (int, int, int, error) {
+	parts := strings.Split(token, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, 0, fmt.Errorf("invalid time %q", token)
+	}
+
+	values := make([]int, 3)
+	for i, part := range parts {
+		// Drop fractional seconds, which some MTAs append.
+		if dot := strings.IndexByte(part, '.'); dot >= 0 && i == 2 {
+			part = part[:dot]
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid time %q", token)
+		}
+		values[i] = n
+	}
+	// Leap seconds are clamped rather than rejected.
+	if values[2] == 60 {
+		values[2] = 59
+	}
+	if values[0] > 23 || values[1] > 59 || values[2] > 59 {
+		return 0, 0, 0, fmt.Errorf("invalid time %q", token)
+	}
+	return values[0], values[1], values[2], nil
+}
+
+func parseNumericZone(token string) (int, error) {
+	sign := 1
+	if token[0] == '-' {
+		sign = -1
+	}
+	digits := strings.ReplaceAll(token[1:], ":", "")
+	if !isNumber(digits) || (len(digits) != 4 && len(digits) != 2) {
+		return 0, fmt.Errorf("invalid zone %q", token)
+	}
+	if len(digits) == 2 {
+		digits += "00"
+	}
+
+	hours, _ := strconv.Atoi(digits[:2])
+	minutes, _ := strconv.Atoi(digits[2:])
+	if hours > 23 || minutes > 59 {
+		return 0, fmt.Errorf("invalid zone %q", token)
+	}
+	return sign * (hours*3600 + minutes*60), nil
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < 'a' || s[i] > 'z') && (s[i] < 'A' || s[i] > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	zone := func(hours int) *time.Location { return time.FixedZone("", hours*3600) }
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "Tue, 4 Mar 2025 10:00:00 +0000", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "4 Mar 2025 10:00 -0500", want: time.Date(2025, 3, 4, 10, 0, 0, 0, zone(-5))},
+		{value: "Tue,  4 Mar 2025 10:00:60 +0000", want: time.Date(2025, 3, 4, 10, 0, 59, 0, time.UTC)},
+
+		// Obsolete zones.
+		{value: "Tue, 4 Mar 2025 10:00:00 UT", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "Tue, 4 Mar 2025 10:00:00 GMT", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "Tue, 4 Mar 2025 10:00:00 EST", want: time.Date(2025, 3, 4, 10, 0, 0, 0, zone(-5))},
+		{value: "Tue, 4 Mar 2025 10:00:00 pdt", want: time.Date(2025, 3, 4, 10, 0, 0, 0, zone(-7))},
+		{value: "Tue, 4 Mar 2025 10:00:00 Q", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+
+		// Two- and three-digit years.
+		{value: "4 Mar 25 10:00:00 +0000", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "4 Mar 99 10:00:00 +0000", want: time.Date(1999, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "4 Mar 125 10:00:00 +0000", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+
+		// RFC 850 and asctime().
+		{value: "Sunday, 4-Mar-25 10:00:00 GMT", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "Tuesday, 04-Mar-2025 10:00:00 GMT", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "Tue Mar  4 10:00:00 2025", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+
+		// Comments and ISO 8601.
+		{value: "Tue, 4 Mar 2025 10:00:00 +0100 (CET)", want: time.Date(2025, 3, 4, 10, 0, 0, 0, zone(1))},
+		{value: "(sent) Tue, 4 Mar 2025 (a (nested) comment) 10:00:00 +0000", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{value: "2025-03-04T10:00:00Z", want: time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)},
+
+		{value: "", wantErr: true},
+		{value: "(only a comment)", wantErr: true},
+		{value: "next Tuesday", wantErr: true},
+		{value: "31 Feb 2025 10:00:00 +0000", wantErr: true},
+		{value: "4 Mar 2025 24:00:00 +0000", wantErr: true},
+		{value: "4 Mar 2025 10:00:00 +2500", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			// Compare the formatted time so the zone offset counts too.
+			if got, want := got.Format(time.RFC3339), tt.want.Format(time.RFC3339); got != want {
+				t.Errorf("ParseDate(%q) = %s, want %s", tt.value, got, want)
+			}
+		})
+	}
+}
+
+func TestNewMessageDateFallback(t *testing.T) {
+	received := time.Date(2025, 3, 5, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		headers     []Header
+		want        time.Time
+		invalidDate bool
+	}{
+		{
+			name:    "valid",
+			headers: []Header{{Name: "Date", Value: "Sunday, 4-Mar-25 10:00:00 GMT"}},
+			want:    time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC),
+		},
+		{name: "missing", want: received},
+		{
+			name:        "invalid",
+			headers:     []Header{{Name: "Date", Value: "sometime last week"}},
+			want:        received,
+			invalidDate: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := NewMessage("1", tt.headers, "", received)
+			if !email.Date.Equal(tt.want) || email.InvalidDate != tt.invalidDate {
+				t.Errorf("date = %v, invalid %v; want %v, invalid %v", email.Date, email.InvalidDate, tt.want, tt.invalidDate)
+			}
+		})
+	}
+}
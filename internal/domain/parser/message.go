@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"strings"
+	"time"
+)
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+// NewMessage builds an EmailMessage from its header fields and decoded body.
+// Every source adapter goes through here so a message parsed from the Gmail
+// API and the same message parsed from raw RFC 822 bytes come out identical.
+// Headers keep their original name casing; for repeated fields the last one
+// wins. Classification is left to the caller.
+func NewMessage(id string, headers []Header, body string, receivedAt time.Time) entities.EmailMessage {
+	email := entities.EmailMessage{
+		ID:         id,
+		Headers:    make(map[string]string, len(headers)),
+		Body:       body,
+		ReceivedAt: receivedAt,
+	}
+
+	var dateStr string
+	for _, header := range headers {
+		email.Headers[header.Name] = header.Value
+
+		switch strings.ToLower(header.Name) {
+		case "subject":
+			email.Subject = DecodeHeader(header.Value)
+		case "from":
+			email.From = header.Value
+			email.Addresses.From, _ = ParseAddressList(header.Value)
+		case "to":
+			email.To = header.Value
+			email.Addresses.To, _ = ParseAddressList(header.Value)
+		case "cc":
+			email.Addresses.Cc, _ = ParseAddressList(header.Value)
+		case "bcc":
+			email.Addresses.Bcc, _ = ParseAddressList(header.Value)
+		case "reply-to":
+			email.Addresses.ReplyTo, _ = ParseAddressList(header.Value)
+		case "sender":
+			email.Addresses.Sender, _ = ParseAddressList(header.Value)
+		case "message-id":
+			email.MessageID = ParseMessageID(header.Value)
+		case "in-reply-to":
+			email.InReplyTo = ParseMessageIDs(header.Value)
+		case "references":
+			email.References = ParseMessageIDs(header.Value)
+		case "date":
+			dateStr = header.Value
+		}
+	}
+
+	// The Date header falls back to the received time when it is missing or
+	// unparseable; InvalidDate flags the latter.
+	email.Date = receivedAt
+	if dateStr != "" {
+		if date, err := ParseDate(dateStr); err == nil {
+			email.Date = date
+		} else {
+			email.InvalidDate = true
+		}
+	}
+	return email
+}
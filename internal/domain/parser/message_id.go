@@ -0,0 +1,42 @@
+package parser
+
+import "strings"
+
+// ParseMessageIDs extracts the msg-ids from a Message-ID, In-Reply-To or
+// References header, without their angle brackets. Headers that omit the
+// brackets entirely fall back to whitespace separated tokens.
+func ParseMessageIDs(value string) []string {
+	var ids []string
+	rest := value
+	for {
+		open := strings.Index(rest, "<")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(rest[open:], ">")
+		if end < 0 {
+			break
+		}
+		if id := strings.TrimSpace(rest[open+1 : open+end]); id != "" {
+			ids = append(ids, id)
+		}
+		rest = rest[open+end+1:]
+	}
+
+	if len(ids) == 0 {
+		for _, field := range strings.Fields(value) {
+			if strings.Contains(field, "@") {
+				ids = append(ids, strings.Trim(field, "<>,"))
+			}
+		}
+	}
+	return ids
+}
+
+// ParseMessageID returns the first msg-id in a header value.
+func ParseMessageID(value string) string {
+	if ids := ParseMessageIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
@@ -0,0 +1,375 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxMIMEDepth bounds multipart nesting so hostile messages can't recurse
+// without limit.
+const maxMIMEDepth = 20
+
+// ParseMessage parses raw RFC 822 bytes into an EmailMessage using the same
+// rules as the API-based adapters. The ID is left empty for the caller to
+// assign, and ReceivedAt is taken from the topmost Received header.
+func ParseMessage(raw []byte) (*entities.EmailMessage, error) {
+	headers, body, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, transferEncoding := "", ""
+	var receivedAt time.Time
+	for _, header := range headers {
+		switch strings.ToLower(header.Name) {
+		case "content-type":
+			contentType = header.Value
+		case "content-transfer-encoding":
+			transferEncoding = header.Value
+		case "received":
+			if receivedAt.IsZero() {
+				receivedAt = receivedDate(header.Value)
+			}
+		}
+	}
+
+	text, err := extractText(contentType, transferEncoding, body, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	email := NewMessage("", headers, text, receivedAt)
+	return &email, nil
+}
+
+// ParseHeaders returns the header fields of a raw message in order, with
+// folded lines unfolded and names kept as written.
+func ParseHeaders(raw []byte) ([]Header, error) {
+	headers, _, err := splitMessage(raw)
+	return headers, err
+}
+
+// DecodeBody converts a part body already stripped of its transfer encoding
+// to UTF-8 using the charset parameter of contentType.
+func DecodeBody(data []byte, contentType string) string {
+	_, params, _ := mime.ParseMediaType(contentType)
+	charset := params["charset"]
+	if charset == "" {
+		return string(data)
+	}
+
+	reader, err := CharsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return string(data)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return string(data)
+	}
+	return string(decoded)
+}
+
+// Part is a node of a message's MIME tree. Multipart nodes have Parts;
+// leaves have Body with the transfer encoding removed.
+type Part struct {
+	Headers   []Header
+	MediaType string
+	Params    map[string]string
+	// Disposition is the raw Content-Disposition header.
+	Disposition string
+	Body        []byte
+	Parts       []Part
+}
+
+// Filename is the name of an attached file, from Content-Disposition or the
+// Content-Type name parameter.
+func (p *Part) Filename() string {
+	if _, params, err := mime.ParseMediaType(p.Disposition); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return p.Params["name"]
+}
+
+// ParseMIME splits raw RFC 822 bytes into their MIME tree, reading parts the
+// way ParseMessage does. It is lenient where ParseMessage has no choice but
+// to be: a leaf whose transfer encoding does not decode keeps its raw body,
+// and a multipart nested too deeply or without a boundary becomes a leaf.
+func ParseMIME(raw []byte) (*Part, error) {
+	headers, body, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	part := buildPart(headers, body, 0)
+	return &part, nil
+}
+
+func buildPart(headers []Header, body []byte, depth int) Part {
+	contentType, transferEncoding := "", ""
+	part := Part{Headers: headers}
+	for _, header := range headers {
+		switch strings.ToLower(header.Name) {
+		case "content-type":
+			contentType = header.Value
+		case "content-disposition":
+			part.Disposition = header.Value
+		case "content-transfer-encoding":
+			transferEncoding = header.Value
+		}
+	}
+
+	var err error
+	part.MediaType, part.Params, err = mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		part.MediaType, part.Params = "text/plain", map[string]string{}
+	}
+
+	boundary := part.Params["boundary"]
+	if strings.HasPrefix(part.MediaType, "multipart/") && boundary != "" && depth < maxMIMEDepth {
+		reader := multipart.NewReader(bytes.NewReader(body), boundary)
+		for {
+			child, err := reader.NextRawPart()
+			if err != nil {
+				// io.EOF, or a truncated multipart: keep what was found.
+				break
+			}
+			data, err := io.ReadAll(child)
+			if err != nil {
+				break
+			}
+			part.Parts = append(part.Parts, buildPart(partHeaders(child.Header), data, depth+1))
+		}
+		return part
+	}
+
+	part.Body = body
+	if decoded, err := decodeTransfer(transferEncoding, body); err == nil {
+		part.Body = decoded
+	}
+	return part
+}
+
+// partHeaders lists a part's header fields sorted by name, since the
+// multipart reader does not keep their order.
+func partHeaders(header textproto.MIMEHeader) []Header {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers []Header
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, Header{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func splitMessage(raw []byte) ([]Header, []byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(raw))
+
+	var headers []Header
+	var current *Header
+	consumed := 0
+	for {
+		line, err := reader.ReadString('\n')
+		consumed += len(line)
+		trimmed := strings.TrimRight(line, "\r\n")
+
+		if trimmed == "" {
+			break
+		}
+		if (trimmed[0] == ' ' || trimmed[0] == '\t') && current != nil {
+			// Unfolding removes only the line break (RFC 5322 section
+			// 2.2.3); the folding whitespace is part of the value.
+			if current.Value == "" {
+				trimmed = strings.TrimLeft(trimmed, " \t")
+			}
+			current.Value += trimmed
+		} else if colon := strings.IndexByte(trimmed, ':'); colon > 0 && isFieldName(strings.TrimRight(trimmed[:colon], " \t")) {
+			headers = append(headers, Header{
+				Name:  strings.TrimRight(trimmed[:colon], " \t"),
+				Value: strings.TrimLeft(trimmed[colon+1:], " \t"),
+			})
+			current = &headers[len(headers)-1]
+		} else if len(headers) == 0 && strings.HasPrefix(trimmed, "From ") {
+			// mbox "From " separator line left on the message.
+		} else {
+			return nil, nil, fmt.Errorf("malformed header line %q", trimmed)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read headers: %w", err)
+		}
+	}
+
+	if len(headers) == 0 {
+		return nil, nil, fmt.Errorf("message has no headers")
+	}
+	for i := range headers {
+		headers[i].Value = strings.TrimRight(headers[i].Value, " \t")
+	}
+	if !hasIdentifyingHeader(headers) {
+		return nil, nil, fmt.Errorf("message has no From, Date or Message-ID header")
+	}
+	if consumed > len(raw) {
+		consumed = len(raw)
+	}
+	return headers, raw[consumed:], nil
+}
+
+// isFieldName reports whether name is an RFC 5322 field name: one or more
+// printable US-ASCII characters other than the colon.
+func isFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 33 || name[i] > 126 || name[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// hasIdentifyingHeader reports whether any header marks the input as a mail
+// message rather than arbitrary "key: value" text.
+func hasIdentifyingHeader(headers []Header) bool {
+	for _, header := range headers {
+		switch strings.ToLower(header.Name) {
+		case "from", "date", "message-id":
+			return true
+		}
+	}
+	return false
+}
+
+// extractText mirrors the Gmail adapter's body selection: a single-part
+// message yields its body, otherwise the first text/plain or text/html leaf
+// in depth-first order.
+func extractText(contentType, transferEncoding string, body []byte, depth int) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		decoded, err := decodeTransfer(transferEncoding, body)
+		if err != nil {
+			return "", err
+		}
+		return DecodeBody(decoded, contentType), nil
+	}
+
+	if depth >= maxMIMEDepth {
+		return "", fmt.Errorf("MIME nesting deeper than %d", maxMIMEDepth)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return "", fmt.Errorf("multipart message without boundary")
+	}
+
+	return firstTextPart(multipart.NewReader(bytes.NewReader(body), boundary), depth)
+}
+
+func firstTextPart(reader *multipart.Reader, depth int) (string, error) {
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return "", nil
+		}
+		if err != nil {
+			// Truncated multiparts are common; keep what we found so far.
+			return "", nil
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return "", fmt.Errorf("failed to read MIME part: %w", err)
+		}
+
+		contentType := part.Header.Get("Content-Type")
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if contentType == "" {
+			mediaType = "text/plain"
+		}
+		transferEncoding := part.Header.Get("Content-Transfer-Encoding")
+
+		switch {
+		case mediaType == "text/plain" || mediaType == "text/html":
+			decoded, err := decodeTransfer(transferEncoding, data)
+			if err != nil {
+				continue
+			}
+			if len(decoded) > 0 {
+				return DecodeBody(decoded, contentType), nil
+			}
+		case strings.HasPrefix(mediaType, "multipart/"):
+			text, err := extractText(contentType, transferEncoding, data, depth+1)
+			if err != nil {
+				return "", err
+			}
+			if text != "" {
+				return text, nil
+			}
+		}
+	}
+}
+
+func decodeTransfer(encoding string, data []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		cleaned := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, data)
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(len(cleaned)))
+		n, err := base64.StdEncoding.Decode(decoded, cleaned)
+		if err != nil {
+			// Accept missing padding, which some senders omit.
+			n, err = base64.RawStdEncoding.Decode(decoded, bytes.TrimRight(cleaned, "="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 body: %w", err)
+			}
+		}
+		return decoded[:n], nil
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted-printable body: %w", err)
+		}
+		return decoded, nil
+	default:
+		return data, nil
+	}
+}
+
+// receivedDate returns the date after the final ";" of a Received header.
+func receivedDate(value string) time.Time {
+	semicolon := strings.LastIndex(value, ";")
+	if semicolon < 0 {
+		return time.Time{}
+	}
+	t, err := ParseDate(value[semicolon+1:])
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
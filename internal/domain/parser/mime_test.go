@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMessageHeaderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{name: "minimal", raw: "From: ann@example.com\r\n\r\nhi\r\n"},
+		{name: "only Date", raw: "Date: Mon, 3 Mar 2025 10:00:00 +0000\r\n\r\nhi\r\n"},
+		{name: "only Message-ID", raw: "message-id: <a@example.com>\r\n\r\nhi\r\n"},
+		{name: "space before colon", raw: "From : ann@example.com\r\n\r\nhi\r\n"},
+		{name: "mbox separator", raw: "From ann@example.com Mon Mar  3 10:00:00 2025\r\nFrom: ann@example.com\r\n\r\nhi\r\n"},
+		{name: "no headers", raw: "\r\nhi\r\n", wantErr: "no headers"},
+		{name: "prose", raw: "Hello there\r\n", wantErr: "malformed header line"},
+		{name: "space in name", raw: "From: ann@example.com\r\nReply To: bob@example.com\r\n\r\n", wantErr: "malformed header line"},
+		{name: "non-ASCII name", raw: "From: ann@example.com\r\nBetreffä: hi\r\n\r\n", wantErr: "malformed header line"},
+		{name: "control character", raw: "From: ann@example.com\r\nX-\x01: hi\r\n\r\n", wantErr: "malformed header line"},
+		{name: "no identifying header", raw: "Subject: hi\r\nTo: bob@example.com\r\n\r\nhi\r\n", wantErr: "no From, Date or Message-ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, err := ParseMessage([]byte(tt.raw))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				if email.Body != "hi\r\n" && email.Body != "hi" {
+					t.Errorf("body = %q", email.Body)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseHeadersUnfolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		want  string
+	}{
+		{name: "single line", field: "Subject: Spring sale\r\n", want: "Spring sale"},
+		{name: "space fold", field: "Subject: Spring\r\n sale\r\n", want: "Spring sale"},
+		{name: "tab fold", field: "Subject: Spring\r\n\tsale\r\n", want: "Spring\tsale"},
+		{name: "whitespace kept", field: "Subject: Spring \r\n   sale\r\n", want: "Spring    sale"},
+		{name: "bare LF", field: "Subject: Spring\n  sale\n", want: "Spring  sale"},
+		{name: "empty first line", field: "Subject:\r\n  Spring sale\r\n", want: "Spring sale"},
+		{name: "trailing whitespace", field: "Subject:  Spring sale \t\r\n", want: "Spring sale"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, err := ParseHeaders([]byte("From: ann@example.com\r\n" + tt.field + "\r\nhi\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(headers) != 2 || headers[1].Value != tt.want {
+				t.Errorf("headers = %q, want Subject %q", headers, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+// Package threading reconstructs conversations from Message-ID, In-Reply-To
+// and References headers using Jamie Zawinski's threading algorithm
+// (https://www.jwz.org/doc/threading.html).
+package threading
+
+import (
+	"crypto/sha1"
+	"email-parser-poc/internal/domain/entities"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Container is a node in the thread tree. Containers without a Message stand
+// in for referenced messages we never saw.
+type Container struct {
+	ID       string
+	Message  *entities.EmailMessage
+	Parent   *Container
+	Children []*Container
+
+	// threadKey identifies the conversation by its topmost container before
+	// placeholders are pruned, which is the same whichever of its messages
+	// happen to be in the batch.
+	threadKey string
+}
+
+var replyPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv|antw)(\[\d+\])?\s*:\s*)+`)
+
+// Build threads messages and returns the root of every conversation, oldest
+// first. The messages slice is referenced, not copied.
+func Build(messages []entities.EmailMessage) []*Container {
+	table := make(map[string]*Container)
+
+	container := func(id string) *Container {
+		c, ok := table[id]
+		if !ok {
+			c = &Container{ID: id}
+			table[id] = c
+		}
+		return c
+	}
+
+	for i := range messages {
+		msg := &messages[i]
+
+		id := msg.MessageID
+		if id == "" || (table[id] != nil && table[id].Message != nil) {
+			// Missing or duplicate Message-IDs get a container of their own.
+			id = fmt.Sprintf("\x00%d:%s", i, msg.ID)
+		}
+		c := container(id)
+		c.Message = msg
+
+		refs := references(msg)
+		var prev *Container
+		for _, ref := range refs {
+			r := container(ref)
+			if prev != nil && r.Parent == nil && !reachable(r, prev) {
+				link(prev, r)
+			}
+			prev = r
+		}
+
+		if prev != nil && (prev == c || reachable(c, prev)) {
+			prev = nil
+		}
+		if c.Parent != nil {
+			unlink(c)
+		}
+		if prev != nil {
+			link(prev, c)
+		}
+	}
+
+	var roots []*Container
+	for _, c := range table {
+		if c.Parent == nil {
+			roots = append(roots, c)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].ID < roots[j].ID })
+	for _, root := range roots {
+		key := rootKey(root)
+		walk(root, func(c *Container, _ int) { c.threadKey = key })
+	}
+	roots = pruneEmpty(roots, true)
+	roots = groupBySubject(roots)
+
+	for _, root := range roots {
+		sortChildren(root)
+	}
+	sortByDate(roots)
+	return roots
+}
+
+// AssignThreadIDs fills in ThreadID for messages whose source did not supply
+// one, using a stable ID derived from the conversation root.
+func AssignThreadIDs(messages []entities.EmailMessage) {
+	for _, root := range Build(messages) {
+		threadID := existingThreadID(root)
+		if threadID == "" {
+			threadID = syntheticThreadID(root)
+		}
+		walk(root, func(c *Container, _ int) {
+			if c.Message != nil && c.Message.ThreadID == "" {
+				c.Message.ThreadID = threadID
+			}
+		})
+	}
+}
+
+// Conversation flattens the threads of messages into a single ordered view.
+// All messages are expected to belong to the same conversation; stray roots
+// are appended in date order.
+func Conversation(threadID string, messages []entities.EmailMessage) *entities.Thread {
+	thread := &entities.Thread{ID: threadID}
+
+	for _, root := range Build(messages) {
+		walk(root, func(c *Container, depth int) {
+			if c.Message == nil {
+				return
+			}
+			entry := entities.ThreadMessage{EmailMessage: *c.Message, Depth: depth}
+			if parent := nearestMessage(c.Parent); parent != nil {
+				entry.ParentID = parent.ID
+			}
+			thread.Messages = append(thread.Messages, entry)
+		})
+	}
+
+	thread.MessageCount = len(thread.Messages)
+	if len(thread.Messages) > 0 {
+		thread.Subject = thread.Messages[0].Subject
+	}
+	return thread
+}
+
+// BaseSubject strips reply and forward prefixes such as "Re:", "Fwd:" and
+// "AW:" so replies can be matched to the message they answer.
+func BaseSubject(subject string) string {
+	return strings.TrimSpace(replyPrefix.ReplaceAllString(subject, ""))
+}
+
+func references(msg *entities.EmailMessage) []string {
+	refs := append([]string(nil), msg.References...)
+	// In-Reply-To only contributes when it names a parent References lacks.
+	if len(msg.InReplyTo) > 0 {
+		if id := msg.InReplyTo[0]; len(refs) == 0 || refs[len(refs)-1] != id {
+			refs = append(refs, id)
+		}
+	}
+	return refs
+}
+
+func link(parent, child *Container) {
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+func unlink(c *Container) {
+	siblings := c.Parent.Children
+	for i, s := range siblings {
+		if s == c {
+			c.Parent.Children = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	c.Parent = nil
+}
+
+// reachable reports whether target is c or one of its descendants, which
+// would make linking target above c a loop.
+func reachable(c, target *Container) bool {
+	for p := target; p != nil; p = p.Parent {
+		if p == c {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneEmpty removes placeholder containers, promoting their children. At the
+// root level a placeholder is only dropped when it has a single child, so
+// siblings that share a missing parent stay grouped.
+func pruneEmpty(containers []*Container, atRoot bool) []*Container {
+	var out []*Container
+	for _, c := range containers {
+		c.Children = pruneEmpty(c.Children, false)
+		for _, child := range c.Children {
+			child.Parent = c
+		}
+
+		switch {
+		case c.Message == nil && len(c.Children) == 0:
+			continue
+		case c.Message == nil && (!atRoot || len(c.Children) == 1):
+			for _, child := range c.Children {
+				child.Parent = c.Parent
+			}
+			out = append(out, c.Children...)
+		default:
+			out = append(out, c)
+		}
+	}
+	if atRoot {
+		for _, c := range out {
+			c.Parent = nil
+		}
+	}
+	return out
+}
+
+// groupBySubject merges root threads that share a base subject, the fallback
+// for clients that drop References entirely.
+func groupBySubject(roots []*Container) []*Container {
+	sortByDate(roots)
+
+	bySubject := make(map[string]*Container)
+	var out []*Container
+	for _, root := range roots {
+		subject := BaseSubject(subjectOf(root))
+		if subject == "" {
+			out = append(out, root)
+			continue
+		}
+
+		existing, ok := bySubject[subject]
+		if !ok {
+			bySubject[subject] = root
+			out = append(out, root)
+			continue
+		}
+
+		switch {
+		case existing.Message == nil && root.Message == nil:
+			for _, child := range root.Children {
+				link(existing, child)
+			}
+		case existing.Message == nil, isReply(root) && !isReply(existing):
+			link(existing, root)
+		case !isReply(root):
+			// Unrelated messages that merely share a subject, such as two
+			// newsletters, stay in separate threads.
+			out = append(out, root)
+		default:
+			placeholder := &Container{ID: "\x00subject:" + subject}
+			for i, c := range out {
+				if c == existing {
+					out[i] = placeholder
+				}
+			}
+			link(placeholder, existing)
+			link(placeholder, root)
+			bySubject[subject] = placeholder
+		}
+	}
+	return out
+}
+
+func subjectOf(c *Container) string {
+	if c.Message != nil {
+		return c.Message.Subject
+	}
+	for _, child := range c.Children {
+		if child.Message != nil {
+			return child.Message.Subject
+		}
+	}
+	return ""
+}
+
+func isReply(c *Container) bool {
+	return c.Message != nil && replyPrefix.MatchString(c.Message.Subject)
+}
+
+func sortChildren(c *Container) {
+	sortByDate(c.Children)
+	for _, child := range c.Children {
+		sortChildren(child)
+	}
+}
+
+func sortByDate(containers []*Container) {
+	sort.SliceStable(containers, func(i, j int) bool {
+		return earliest(containers[i]).Before(earliest(containers[j]))
+	})
+}
+
+func earliest(c *Container) time.Time {
+	if c.Message != nil {
+		return c.Message.Date
+	}
+	var t time.Time
+	for _, child := range c.Children {
+		if ct := earliest(child); !ct.IsZero() && (t.IsZero() || ct.Before(t)) {
+			t = ct
+		}
+	}
+	return t
+}
+
+func walk(c *Container, fn func(c *Container, depth int)) {
+	var visit func(c *Container, depth int)
+	visit = func(c *Container, depth int) {
+		fn(c, depth)
+		next := depth
+		if c.Message != nil {
+			next++
+		}
+		for _, child := range c.Children {
+			visit(child, next)
+		}
+	}
+	visit(c, 0)
+}
+
+func nearestMessage(c *Container) *entities.EmailMessage {
+	for ; c != nil; c = c.Parent {
+		if c.Message != nil {
+			return c.Message
+		}
+	}
+	return nil
+}
+
+func existingThreadID(root *Container) string {
+	var threadID string
+	walk(root, func(c *Container, _ int) {
+		if threadID == "" && c.Message != nil {
+			threadID = c.Message.ThreadID
+		}
+	})
+	return threadID
+}
+
+// syntheticThreadID hashes the key of the conversation's topmost
+// container: the first References entry, else In-Reply-To, else the root's
+// own Message-ID. A reply fetched without its parent gets the same ID as the
+// parent, so a conversation keeps its ID across fetches and batches.
+func syntheticThreadID(root *Container) string {
+	key := ""
+	walk(root, func(c *Container, _ int) {
+		if key == "" {
+			key = c.threadKey
+		}
+	})
+	sum := sha1.Sum([]byte(key))
+	return "jwz-" + hex.EncodeToString(sum[:8])
+}
+
+// rootKey is the Message-ID c stands for. A message without a Message-ID,
+// or sharing it with an earlier one, has a container ID that holds its
+// position in the batch, so it is keyed by its source ID instead.
+func rootKey(c *Container) string {
+	switch msg := c.Message; {
+	case msg == nil:
+		return c.ID
+	case msg.MessageID == "":
+		return msg.ID
+	case c.ID != msg.MessageID:
+		return msg.MessageID + "\x00" + msg.ID
+	}
+	return c.ID
+}
@@ -0,0 +1,234 @@
+package threading
+
+import (
+	"email-parser-poc/internal/domain/entities"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssignThreadIDsStableForDuplicateMessageIDs(t *testing.T) {
+	date := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	original := entities.EmailMessage{ID: "a", MessageID: "dup@example.com", Subject: "Notes", Date: date}
+	duplicate := entities.EmailMessage{ID: "b", MessageID: "dup@example.com", Subject: "Minutes", Date: date.Add(time.Hour)}
+	other := entities.EmailMessage{ID: "c", MessageID: "other@example.com", Subject: "Lunch", Date: date.Add(2 * time.Hour)}
+
+	threadOf := func(batch []entities.EmailMessage, id string) string {
+		AssignThreadIDs(batch)
+		for _, msg := range batch {
+			if msg.ID == id {
+				return msg.ThreadID
+			}
+		}
+		t.Fatalf("message %s not in batch", id)
+		return ""
+	}
+
+	// The duplicate sits at a different index in each batch.
+	first := threadOf([]entities.EmailMessage{original, duplicate}, "b")
+	second := threadOf([]entities.EmailMessage{other, original, duplicate}, "b")
+	if first == "" || first != second {
+		t.Errorf("duplicate's thread ID changed with its position: %q vs %q", first, second)
+	}
+	if first == threadOf([]entities.EmailMessage{original, duplicate}, "a") {
+		t.Errorf("duplicate shares the original's thread ID %q", first)
+	}
+
+	// Without a Message-ID the source ID keys the thread.
+	noID := entities.EmailMessage{ID: "d", Subject: "Hello", Date: date}
+	if threadOf([]entities.EmailMessage{noID}, "d") != threadOf([]entities.EmailMessage{other, noID}, "d") {
+		t.Error("thread ID of a message without Message-ID changed with its position")
+	}
+}
+
+var day = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+
+// msg builds a message dated hours after day.
+func msg(id, subject string, hours int, references ...string) entities.EmailMessage {
+	return entities.EmailMessage{
+		ID:         id,
+		MessageID:  id + "@example.com",
+		Subject:    subject,
+		Date:       day.Add(time.Duration(hours) * time.Hour),
+		References: references,
+	}
+}
+
+// shape renders threads as "id(child,child)" for comparison.
+func shape(roots []*Container) string {
+	var render func(c *Container) string
+	render = func(c *Container) string {
+		s := "_"
+		if c.Message != nil {
+			s = c.Message.ID
+		}
+		if len(c.Children) > 0 {
+			s += "("
+			for i, child := range c.Children {
+				if i > 0 {
+					s += ","
+				}
+				s += render(child)
+			}
+			s += ")"
+		}
+		return s
+	}
+	var out []string
+	for _, root := range roots {
+		out = append(out, render(root))
+	}
+	return strings.Join(out, " ")
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []entities.EmailMessage
+		want     string
+	}{
+		{
+			name: "references",
+			messages: []entities.EmailMessage{
+				msg("c", "Re: Plan", 2, "a@example.com", "b@example.com"),
+				msg("a", "Plan", 0),
+				msg("b", "Re: Plan", 1, "a@example.com"),
+			},
+			want: "a(b(c))",
+		},
+		{
+			name: "in-reply-to",
+			messages: []entities.EmailMessage{
+				msg("a", "Plan", 0),
+				{ID: "b", MessageID: "b@example.com", Subject: "Other", Date: day.Add(time.Hour), InReplyTo: []string{"a@example.com"}},
+			},
+			want: "a(b)",
+		},
+		{
+			name: "missing parent inside a thread is pruned",
+			messages: []entities.EmailMessage{
+				msg("a", "Plan", 0),
+				msg("c", "Re: Plan", 2, "a@example.com", "b@example.com"),
+			},
+			want: "a(c)",
+		},
+		{
+			name: "siblings of a missing root stay grouped",
+			messages: []entities.EmailMessage{
+				msg("b", "Re: Plan", 1, "a@example.com"),
+				msg("c", "Re: Other", 2, "a@example.com"),
+			},
+			want: "_(b,c)",
+		},
+		{
+			name: "lone reply to a missing root becomes the root",
+			messages: []entities.EmailMessage{
+				msg("b", "Re: Plan", 1, "a@example.com"),
+			},
+			want: "b",
+		},
+		{
+			name: "reference loops are broken",
+			messages: []entities.EmailMessage{
+				msg("a", "Loop", 0, "b@example.com"),
+				msg("b", "Re: Loop", 1, "a@example.com"),
+			},
+			want: "b(a)",
+		},
+		{
+			name: "self reference",
+			messages: []entities.EmailMessage{
+				msg("a", "Self", 0, "a@example.com"),
+			},
+			want: "a",
+		},
+		{
+			name: "replies grouped by subject",
+			messages: []entities.EmailMessage{
+				msg("b", "RE: Lunch", 1),
+				msg("a", "Lunch", 0),
+				msg("c", "AW: Fwd: Lunch", 2),
+			},
+			want: "a(b,c)",
+		},
+		{
+			name: "unrelated messages sharing a subject stay apart",
+			messages: []entities.EmailMessage{
+				msg("a", "Newsletter", 0),
+				msg("b", "Newsletter", 1),
+			},
+			want: "a b",
+		},
+		{
+			name: "replies without the original share a placeholder",
+			messages: []entities.EmailMessage{
+				msg("a", "Re: Lunch", 0),
+				msg("b", "Re: Lunch", 1),
+			},
+			want: "_(a,b)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shape(Build(tt.messages)); got != tt.want {
+				t.Errorf("threads = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationDepthAndParents(t *testing.T) {
+	thread := Conversation("t", []entities.EmailMessage{
+		msg("b", "Re: Plan", 1, "a@example.com"),
+		msg("a", "Plan", 0),
+		msg("c", "Re: Plan", 2, "a@example.com", "missing@example.com"),
+	})
+	if thread.MessageCount != 3 || thread.Subject != "Plan" {
+		t.Fatalf("thread = %+v", thread)
+	}
+	for i, want := range []struct {
+		id, parent string
+		depth      int
+	}{{"a", "", 0}, {"b", "a", 1}, {"c", "a", 1}} {
+		got := thread.Messages[i]
+		if got.ID != want.id || got.ParentID != want.parent || got.Depth != want.depth {
+			t.Errorf("message %d = %s parent %q depth %d, want %+v", i, got.ID, got.ParentID, got.Depth, want)
+		}
+	}
+}
+
+// TestAssignThreadIDsAcrossBatches ingests a conversation one message at a
+// time, as SMTP does, and in one batch, as a full fetch does.
+func TestAssignThreadIDsAcrossBatches(t *testing.T) {
+	conversation := []entities.EmailMessage{
+		msg("a", "Plan", 0),
+		msg("b", "Re: Plan", 1, "a@example.com"),
+		msg("c", "Re: Plan", 2, "a@example.com", "b@example.com"),
+		{ID: "d", MessageID: "d@example.com", Subject: "Re: Plan", Date: day.Add(3 * time.Hour), InReplyTo: []string{"a@example.com"}},
+	}
+
+	batch := append([]entities.EmailMessage(nil), conversation...)
+	AssignThreadIDs(batch)
+	want := batch[0].ThreadID
+	for _, m := range batch {
+		if m.ThreadID != want {
+			t.Errorf("%s: thread %q in one batch, want %q", m.ID, m.ThreadID, want)
+		}
+	}
+
+	for _, m := range conversation {
+		single := []entities.EmailMessage{m}
+		AssignThreadIDs(single)
+		if single[0].ThreadID != want {
+			t.Errorf("%s: thread %q on its own, want %q", m.ID, single[0].ThreadID, want)
+		}
+	}
+
+	// A thread ID from the source wins over the synthetic one.
+	sourced := []entities.EmailMessage{conversation[0], conversation[1]}
+	sourced[0].ThreadID = "source-thread"
+	AssignThreadIDs(sourced)
+	if sourced[1].ThreadID != "source-thread" {
+		t.Errorf("reply thread = %q, want the source's", sourced[1].ThreadID)
+	}
+}
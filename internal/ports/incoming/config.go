@@ -0,0 +1,12 @@
+package incoming
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+)
+
+type ConfigReloader interface {
+	// Reload reads the configuration again and applies the settings that can
+	// change at runtime. An invalid configuration is rejected as a whole.
+	Reload(ctx context.Context) (*entities.ConfigReload, error)
+}
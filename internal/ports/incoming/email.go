@@ -6,5 +6,10 @@ import (
 )
 
 type EmailService interface {
+	// FetchEmails reads, parses and classifies messages without storing
+	// them or changing the mailbox.
+	FetchEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, error)
 	GetEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, string, error)
+	GetThread(ctx context.Context, threadID string) (*entities.Thread, error)
+	IngestRawEmails(ctx context.Context, messages []entities.RawMessage) (*entities.EmailList, string, error)
 }
@@ -0,0 +1,10 @@
+package incoming
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+)
+
+type ImportService interface {
+	Import(ctx context.Context, req entities.ImportRequest, progress func(entities.ImportProgress)) (*entities.ImportProgress, error)
+}
@@ -0,0 +1,10 @@
+package incoming
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+)
+
+type ReprocessService interface {
+	Reprocess(ctx context.Context, req entities.ReprocessRequest, progress func(entities.ReprocessProgress)) (*entities.ReprocessProgress, error)
+}
@@ -6,5 +6,9 @@ import (
 )
 
 type HealthPort interface {
+	// CheckLiveness reports whether the process is up, without touching any
+	// dependency.
+	CheckLiveness(ctx context.Context) (*entities.Health, error)
+	// CheckHealth runs the dependency checks and reports readiness.
 	CheckHealth(ctx context.Context) (*entities.Health, error)
 }
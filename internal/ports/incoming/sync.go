@@ -0,0 +1,11 @@
+package incoming
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+)
+
+type SyncService interface {
+	HandlePushNotification(ctx context.Context, notification entities.PushNotification) (*entities.SyncResult, error)
+	EnsureWatch(ctx context.Context, force bool) (*entities.WatchState, error)
+}
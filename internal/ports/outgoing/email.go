@@ -7,7 +7,15 @@ import (
 
 type EmailRepository interface {
 	FetchEmails(ctx context.Context, filter entities.EmailFilter) (*entities.EmailList, error)
-	
+	FetchThread(ctx context.Context, threadID string) ([]entities.EmailMessage, error)
+	FetchRawEmail(ctx context.Context, messageID string) ([]byte, error)
+
+	// Mailbox mutations. Label arguments accept names or source IDs.
+	ModifyLabels(ctx context.Context, messageID string, add, remove []string) error
+	CreateLabel(ctx context.Context, name string) (string, error)
+	Archive(ctx context.Context, messageID string) error
+	MarkRead(ctx context.Context, messageID string) error
+	Trash(ctx context.Context, messageID string) error
 }
 
 type TokenProvider interface {
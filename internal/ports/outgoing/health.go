@@ -0,0 +1,15 @@
+package outgoing
+
+import "context"
+
+// HealthChecker is a dependency probe run by the readiness check. Check
+// returns nil when the dependency is usable, an error wrapping
+// entities.ErrDegraded when it only partly is, and any other error when it
+// is not.
+type HealthChecker interface {
+	Name() string
+	// Critical checkers make the service unready when they fail; the
+	// others only mark it degraded.
+	Critical() bool
+	Check(ctx context.Context) error
+}
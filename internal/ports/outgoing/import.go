@@ -0,0 +1,35 @@
+package outgoing
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+)
+
+// MailSource streams messages out of an exported archive. Next returns io.EOF
+// once the archive is exhausted.
+type MailSource interface {
+	// ID identifies the archive across runs, e.g. its absolute path.
+	ID() string
+	// Resume continues reading at a position previously reported by
+	// Progress.
+	Resume(position int64) error
+	Next() (*entities.RawMessage, error)
+	// Progress returns the position just after the last message returned by
+	// Next and the total size in the same unit.
+	Progress() (position, total int64)
+	Close() error
+}
+
+type MailSourceOpener interface {
+	Open(path, format, variant string) (MailSource, error)
+}
+
+// ImportStateStore keeps import checkpoints and the Message-IDs already
+// imported, so re-running an import neither restarts nor duplicates. The
+// same index holds the RawMessage.SourceKey of archive files already read.
+type ImportStateStore interface {
+	GetCheckpoint(ctx context.Context, source string) (*entities.ImportCheckpoint, error)
+	SaveCheckpoint(ctx context.Context, checkpoint *entities.ImportCheckpoint) error
+	HasMessageID(ctx context.Context, messageID string) (bool, error)
+	AddMessageIDs(ctx context.Context, messageIDs []string) error
+}
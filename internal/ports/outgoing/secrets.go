@@ -0,0 +1,9 @@
+package outgoing
+
+import "context"
+
+// SecretResolver turns a secret reference from the configuration, such as
+// "file:///run/secrets/gmail" or "env:GMAIL_TOKEN", into its value.
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
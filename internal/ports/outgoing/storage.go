@@ -7,4 +7,22 @@ import (
 
 type StorageService interface {
 	UploadEmails(ctx context.Context, emails *entities.EmailList) (string, error)
+	UploadRawEmail(ctx context.Context, messageID string, raw []byte) (string, error)
+}
+
+// ArchiveStore reads back and rewrites what StorageService stored, for
+// reprocessing.
+type ArchiveStore interface {
+	// ListObjects calls fn for every object below prefix, which is relative
+	// to the storage prefix, in key order starting after the full key
+	// startAfter.
+	ListObjects(ctx context.Context, prefix, startAfter string, fn func(entities.StoredObject) error) error
+	// GetObject returns entities.ErrNotFound for a missing key.
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// GetEmails decodes a batch or per-message object in whichever format
+	// it was stored.
+	GetEmails(ctx context.Context, key string) (*entities.EmailList, error)
+	// ReplaceEmails overwrites a batch object, ReplaceEmail a per-message one.
+	ReplaceEmails(ctx context.Context, key string, emails *entities.EmailList) error
+	ReplaceEmail(ctx context.Context, key string, email *entities.EmailMessage) error
 }
@@ -0,0 +1,19 @@
+package outgoing
+
+import (
+	"context"
+	"email-parser-poc/internal/domain/entities"
+)
+
+// MailboxWatcher is implemented by sources that can push change notifications
+// and replay changes since a history checkpoint.
+type MailboxWatcher interface {
+	EmailAddress(ctx context.Context) (string, error)
+	Watch(ctx context.Context, req entities.WatchRequest) (*entities.WatchState, error)
+	FetchHistory(ctx context.Context, startHistoryID uint64) (*entities.EmailList, uint64, error)
+}
+
+type SyncStateStore interface {
+	GetWatchState(ctx context.Context, emailAddress string) (*entities.WatchState, error)
+	SaveWatchState(ctx context.Context, state *entities.WatchState) error
+}
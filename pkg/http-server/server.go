@@ -2,8 +2,9 @@ package httpserver
 
 import (
 	"context"
+	"email-parser-poc/pkg/logging"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,6 +15,7 @@ import (
 type Server struct {
 	Server          *http.Server
 	ShutdownTimeout time.Duration
+	Logger          *slog.Logger
 }
 
 type Config struct {
@@ -24,6 +26,7 @@ type Config struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
+	Logger          *slog.Logger
 }
 
 func NewConfig(config Config) *Server {
@@ -57,12 +60,13 @@ func NewConfig(config Config) *Server {
 	return &Server{
 		Server:          &httpServer,
 		ShutdownTimeout: config.ShutdownTimeout,
+		Logger:          logging.OrDefault(config.Logger),
 	}
 
 }
 
 func (s *Server) Start() error {
-	fmt.Printf("Starting server on the %s\n", s.Server.Addr)
+	s.Logger.Info("HTTP server starting", "addr", s.Server.Addr)
 
 	if err := s.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %w", err)
@@ -79,10 +83,9 @@ func (s *Server) StaertWithGracefulShutdown() error {
 	serverError := make(chan error, 1)
 
 	go func() {
-		log.Default().Output(1, "HTTP server starting")
+		s.Logger.Info("HTTP server starting", "addr", s.Server.Addr)
 
 		if err := s.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Default().Output(1, "HTTP server starting")
 			serverError <- fmt.Errorf("failed to start server: %w", err)
 		}
 	}()
@@ -103,7 +106,7 @@ func (s *Server) StaertWithGracefulShutdown() error {
 
 		shutdownDuration := time.Since(shutdownStart)
 
-		fmt.Println(shutdownDuration)
+		s.Logger.Info("HTTP server stopped", "shutdown", shutdownDuration)
 		return nil
 	}
 
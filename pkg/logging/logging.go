@@ -0,0 +1,137 @@
+// Package logging builds the application's slog logger. Every record passes
+// through a redaction layer (see redact.go) and picks up the request-scoped
+// fields stored in its context with WithRequestID, WithAccount and
+// WithJobID, along with the current trace and span IDs.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Keys of the request-scoped fields.
+const (
+	KeyRequestID = "request_id"
+	KeyAccount   = "account"
+	KeyJobID     = "job_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+)
+
+type Config struct {
+	// Level may be a *slog.LevelVar so the level can change at runtime.
+	Level  slog.Leveler
+	Format string
+	// PIIDebug lets message bodies and addresses through unmasked. Secrets
+	// are redacted regardless.
+	PIIDebug bool
+	// Output defaults to stderr.
+	Output io.Writer
+}
+
+func New(config Config) *slog.Logger {
+	output := config.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	options := &slog.HandlerOptions{
+		Level:       config.Level,
+		ReplaceAttr: newRedactor(config.PIIDebug).replaceAttr,
+	}
+
+	var handler slog.Handler
+	if config.Format == FormatText {
+		handler = slog.NewTextHandler(output, options)
+	} else {
+		handler = slog.NewJSONHandler(output, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// ParseLevel accepts debug, info, warn or error.
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", value)
+	}
+	return level, nil
+}
+
+// ValidFormat reports whether format is "json" or "text".
+func ValidFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatJSON, FormatText:
+		return true
+	}
+	return false
+}
+
+// OrDefault returns logger, or slog.Default() when it is nil, so
+// constructors can treat the logger as optional.
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+type ctxKey struct{}
+
+// With returns a context whose log records carry attrs in addition to any
+// already stored.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, ctxKey{}, merged)
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return With(ctx, slog.String(KeyRequestID, id))
+}
+
+// WithAccount tags records with the mailbox being worked on. The address is
+// masked like any other unless PII debugging is on.
+func WithAccount(ctx context.Context, account string) context.Context {
+	return With(ctx, slog.String(KeyAccount, account))
+}
+
+func WithJobID(ctx context.Context, id string) context.Context {
+	return With(ctx, slog.String(KeyJobID, id))
+}
+
+// contextHandler adds the fields stored by With to every record, plus the
+// trace and span IDs when the context carries a recorded span.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String(KeyTraceID, span.TraceID().String()), slog.String(KeySpanID, span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
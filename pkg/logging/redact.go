@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeyParts marks an attribute as a credential when its key contains
+// one of them. Credentials are never logged, PII debugging or not.
+var secretKeyParts = []string{"token", "authorization", "password", "secret", "api_key", "apikey", "cookie", "credential"}
+
+// contentKeys hold message content, logged only as a length.
+var contentKeys = map[string]bool{
+	"body": true, "text": true, "html": true, "raw": true, "snippet": true, "subject": true,
+}
+
+var (
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`),
+		// Google OAuth access and refresh tokens.
+		regexp.MustCompile(`\bya29\.[A-Za-z0-9._-]+`),
+		regexp.MustCompile(`\b1//[A-Za-z0-9._-]{20,}`),
+		// JWTs, e.g. Pub/Sub push tokens.
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	}
+	addressPattern = regexp.MustCompile(`[A-Za-z0-9._%+'-]+@([A-Za-z0-9-]+\.)+[A-Za-z0-9-]+`)
+)
+
+type redactor struct {
+	piiDebug bool
+}
+
+func newRedactor(piiDebug bool) redactor {
+	return redactor{piiDebug: piiDebug}
+}
+
+// replaceAttr is the slog ReplaceAttr hook. It sees every attribute,
+// including the message, after LogValuers have been resolved.
+func (r redactor) replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && (attr.Key == slog.TimeKey || attr.Key == slog.LevelKey) {
+		return attr
+	}
+
+	key := strings.ToLower(attr.Key)
+	if isSecretKey(key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	var value string
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		value = attr.Value.String()
+	case slog.KindAny:
+		switch v := attr.Value.Any().(type) {
+		case error:
+			value = v.Error()
+		case fmt.Stringer:
+			value = v.String()
+		case []byte:
+			value = string(v)
+		case []string:
+			value = strings.Join(v, ", ")
+		default:
+			return slog.String(attr.Key, r.redactAny(key, v))
+		}
+	default:
+		return attr
+	}
+
+	if !r.piiDebug && contentKeys[key] {
+		return slog.String(attr.Key, fmt.Sprintf("[REDACTED %d bytes]", len(value)))
+	}
+	return slog.String(attr.Key, r.scrub(value))
+}
+
+// redactAny renders any other value, such as a struct or map, as JSON with
+// the same rules applied to every field by its key. Values that can't be
+// marshalled are replaced by their type.
+func (r redactor) redactAny(key string, value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("[%T]", value)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return fmt.Sprintf("[%T]", value)
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(r.redactTree(key, tree)); err != nil {
+		return fmt.Sprintf("[%T]", value)
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// redactTree applies replaceAttr's rules to decoded JSON, treating object
+// keys like attribute keys. Array elements inherit the key of the array.
+func (r redactor) redactTree(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, child := range v {
+			lower := strings.ToLower(name)
+			if isSecretKey(lower) {
+				v[name] = redacted
+				continue
+			}
+			v[name] = r.redactTree(lower, child)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = r.redactTree(key, child)
+		}
+		return v
+	case string:
+		if !r.piiDebug && contentKeys[key] {
+			return fmt.Sprintf("[REDACTED %d bytes]", len(v))
+		}
+		return r.scrub(v)
+	}
+	return value
+}
+
+// scrub removes credentials from free text and, unless PII debugging is on,
+// masks email addresses down to their first letter and domain.
+func (r redactor) scrub(value string) string {
+	for _, pattern := range secretPatterns {
+		value = pattern.ReplaceAllStringFunc(value, func(match string) string {
+			if strings.HasPrefix(strings.ToLower(match), "bearer") {
+				return "Bearer " + redacted
+			}
+			return redacted
+		})
+	}
+	if r.piiDebug {
+		return value
+	}
+	return addressPattern.ReplaceAllStringFunc(value, maskAddress)
+}
+
+func maskAddress(address string) string {
+	local, domain, _ := strings.Cut(address, "@")
+	if local == "" {
+		return "***@" + domain
+	}
+	return local[:1] + "***@" + domain
+}
+
+func isSecretKey(key string) bool {
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
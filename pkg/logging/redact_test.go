@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+type account struct {
+	Name        string
+	AccessToken string
+	Body        string
+	Count       int
+	Headers     map[string]string
+}
+
+func TestRedaction(t *testing.T) {
+	message := account{
+		Name:        "Ann <ann@example.com>",
+		AccessToken: "ya29.secret",
+		Body:        "hello",
+		Count:       3,
+		Headers:     map[string]string{"Subject": "Invoice", "Reply-To": "billing@shop.example"},
+	}
+
+	tests := []struct {
+		name     string
+		attr     slog.Attr
+		piiDebug bool
+		want     string
+	}{
+		{name: "secret key", attr: slog.String("access_token", "abc"), want: "[REDACTED]"},
+		{name: "secret key is case-insensitive", attr: slog.String("Authorization", "Basic abc"), want: "[REDACTED]"},
+		{name: "secret key with PII debugging", attr: slog.String("password", "hunter2"), piiDebug: true, want: "[REDACTED]"},
+		{name: "bearer token in text", attr: slog.String("error", "sent Bearer abc.def to the API"), want: "sent Bearer [REDACTED] to the API"},
+		{name: "OAuth token in error", attr: slog.Any("error", errors.New("token ya29.a0AfH6 expired")), want: "token [REDACTED] expired"},
+		{name: "JWT", attr: slog.String("detail", "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"), want: "[REDACTED]"},
+		{name: "content key", attr: slog.String("body", "hello"), want: "[REDACTED 5 bytes]"},
+		{name: "content key with PII debugging", attr: slog.String("subject", "Invoice"), piiDebug: true, want: "Invoice"},
+		{name: "address", attr: slog.String("from", "Ann <ann@example.com>"), want: "Ann <a***@example.com>"},
+		{name: "address with PII debugging", attr: slog.String("from", "ann@example.com"), piiDebug: true, want: "ann@example.com"},
+		{name: "string list", attr: slog.Any("to", []string{"ann@example.com", "bob@example.com"}), want: "a***@example.com, b***@example.com"},
+		{
+			name: "struct",
+			attr: slog.Any("account", message),
+			want: `{"AccessToken":"[REDACTED]","Body":"[REDACTED 5 bytes]","Count":3,"Headers":{"Reply-To":"b***@shop.example","Subject":"[REDACTED 7 bytes]"},"Name":"Ann <a***@example.com>"}`,
+		},
+		{
+			name:     "struct with PII debugging",
+			attr:     slog.Any("account", map[string]any{"token": "abc", "to": []string{"ann@example.com"}}),
+			piiDebug: true,
+			want:     `{"to":["ann@example.com"],"token":"[REDACTED]"}`,
+		},
+		{name: "value that can't be marshalled", attr: slog.Any("done", make(chan int)), want: "[chan int]"},
+		{name: "number", attr: slog.Int("count", 3), want: "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			New(Config{Output: &out, PIIDebug: tt.piiDebug}).Info("test", tt.attr)
+
+			decoder := json.NewDecoder(&out)
+			decoder.UseNumber()
+			var record map[string]any
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(record[tt.attr.Key]); got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.attr.Key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactionInGroups(t *testing.T) {
+	var out bytes.Buffer
+	New(Config{Output: &out}).Info("test", slog.Group("request", slog.String("cookie", "session=abc"), slog.String("user", "ann@example.com")))
+
+	var record struct {
+		Request map[string]string `json:"request"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Request["cookie"] != "[REDACTED]" || record.Request["user"] != "a***@example.com" {
+		t.Errorf("request = %v", record.Request)
+	}
+}
@@ -0,0 +1,234 @@
+// Package metrics holds the Prometheus collectors for ingestion and HTTP
+// traffic. All methods are safe on a nil *Metrics and do nothing, so
+// instrumented components work unchanged when metrics are not wired.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "email_parser"
+
+// Pipelines label where messages entered the system.
+const (
+	PipelineFetch  = "fetch"
+	PipelineSync   = "sync"
+	PipelineImport = "import"
+	PipelineIngest = "ingest"
+)
+
+// Object kinds written to S3.
+const (
+	ObjectBatch    = "batch"
+	ObjectMessage  = "message"
+	ObjectManifest = "manifest"
+	ObjectRaw      = "raw"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	gmailRequests      *prometheus.CounterVec
+	gmailDuration      *prometheus.HistogramVec
+	messagesFetched    *prometheus.CounterVec
+	messagesClassified *prometheus.CounterVec
+	messagesStored     *prometheus.CounterVec
+	s3Uploads          *prometheus.CounterVec
+	s3UploadBytes      *prometheus.CounterVec
+	s3UploadDuration   *prometheus.HistogramVec
+	dynamoBatchWrites  *prometheus.CounterVec
+	dynamoUnprocessed  prometheus.Counter
+	httpDuration       *prometheus.HistogramVec
+}
+
+// New registers every collector, plus the Go runtime and process
+// collectors, on a registry of its own.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		gmailRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "gmail_api_requests_total",
+			Help:      "Gmail API calls by endpoint and HTTP status; status is \"error\" when no response arrived.",
+		}, []string{"endpoint", "status"}),
+		gmailDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "gmail_api_request_duration_seconds",
+			Help:      "Gmail API call latency by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		messagesFetched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_fetched_total",
+			Help:      "Messages received from a source, by pipeline.",
+		}, []string{"pipeline"}),
+		messagesClassified: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_classified_total",
+			Help:      "Classification outcomes by category and whether the message counts as promotional.",
+		}, []string{"category", "promotional"}),
+		messagesStored: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_stored_total",
+			Help:      "Messages written to storage, by pipeline.",
+		}, []string{"pipeline"}),
+		s3Uploads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "s3_uploads_total",
+			Help:      "S3 PutObject calls by object kind and result.",
+		}, []string{"kind", "result"}),
+		s3UploadBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "s3_upload_bytes_total",
+			Help:      "Bytes successfully uploaded to S3, by object kind.",
+		}, []string{"kind"}),
+		s3UploadDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "s3_upload_duration_seconds",
+			Help:      "S3 PutObject latency by object kind.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"kind"}),
+		dynamoBatchWrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dynamodb_batch_writes_total",
+			Help:      "DynamoDB BatchWriteItem calls by result.",
+		}, []string{"result"}),
+		dynamoUnprocessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dynamodb_unprocessed_items_total",
+			Help:      "Items DynamoDB returned as unprocessed from BatchWriteItem.",
+		}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, chi route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.gmailRequests,
+		m.gmailDuration,
+		m.messagesFetched,
+		m.messagesClassified,
+		m.messagesStored,
+		m.s3Uploads,
+		m.s3UploadBytes,
+		m.s3UploadDuration,
+		m.dynamoBatchWrites,
+		m.dynamoUnprocessed,
+		m.httpDuration,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveGmailRequest(endpoint string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	statusLabel := "error"
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	m.gmailRequests.WithLabelValues(endpoint, statusLabel).Inc()
+	m.gmailDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+}
+
+func (m *Metrics) MessagesFetched(pipeline string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.messagesFetched.WithLabelValues(pipeline).Add(float64(n))
+}
+
+func (m *Metrics) MessageClassified(category string, promotional bool) {
+	if m == nil {
+		return
+	}
+	if category == "" {
+		category = "unknown"
+	}
+	m.messagesClassified.WithLabelValues(category, strconv.FormatBool(promotional)).Inc()
+}
+
+func (m *Metrics) MessagesStored(pipeline string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.messagesStored.WithLabelValues(pipeline).Add(float64(n))
+}
+
+func (m *Metrics) ObserveS3Upload(kind string, bytes int, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.s3UploadDuration.WithLabelValues(kind).Observe(duration.Seconds())
+	if err != nil {
+		m.s3Uploads.WithLabelValues(kind, "error").Inc()
+		return
+	}
+	m.s3Uploads.WithLabelValues(kind, "ok").Inc()
+	m.s3UploadBytes.WithLabelValues(kind).Add(float64(bytes))
+}
+
+func (m *Metrics) ObserveDynamoBatchWrite(unprocessed int, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.dynamoBatchWrites.WithLabelValues("error").Inc()
+		return
+	}
+	m.dynamoBatchWrites.WithLabelValues("ok").Inc()
+	m.dynamoUnprocessed.Add(float64(unprocessed))
+}
+
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// Transport wraps base so every request is recorded with ObserveGmailRequest
+// under the endpoint name returned by endpoint.
+func (m *Metrics) Transport(base http.RoundTripper, endpoint func(*http.Request) string) http.RoundTripper {
+	if m == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		m.ObserveGmailRequest(endpoint(req), status, time.Since(start))
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
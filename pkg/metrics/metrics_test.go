@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the text exposition served by m's handler.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := New()
+	m.ObserveGmailRequest("messages.get", 200, 10*time.Millisecond)
+	m.ObserveGmailRequest("messages.get", 0, time.Millisecond)
+	m.MessagesFetched(PipelineSync, 3)
+	m.MessagesFetched(PipelineSync, 0)
+	m.MessageClassified("promotions", true)
+	m.MessageClassified("", false)
+	m.MessagesStored(PipelineImport, 2)
+	m.ObserveS3Upload(ObjectBatch, 512, time.Millisecond, nil)
+	m.ObserveS3Upload(ObjectRaw, 100, time.Millisecond, errors.New("denied"))
+	m.ObserveDynamoBatchWrite(4, nil)
+	m.ObserveDynamoBatchWrite(0, errors.New("throttled"))
+	m.ObserveHTTPRequest(http.MethodGet, "/threads/{id}", 200, time.Millisecond)
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`email_parser_gmail_api_requests_total{endpoint="messages.get",status="200"} 1`,
+		`email_parser_gmail_api_requests_total{endpoint="messages.get",status="error"} 1`,
+		`email_parser_gmail_api_request_duration_seconds_count{endpoint="messages.get"} 2`,
+		`email_parser_messages_fetched_total{pipeline="sync"} 3`,
+		`email_parser_messages_classified_total{category="promotions",promotional="true"} 1`,
+		`email_parser_messages_classified_total{category="unknown",promotional="false"} 1`,
+		`email_parser_messages_stored_total{pipeline="import"} 2`,
+		`email_parser_s3_uploads_total{kind="batch",result="ok"} 1`,
+		`email_parser_s3_uploads_total{kind="raw",result="error"} 1`,
+		`email_parser_s3_upload_bytes_total{kind="batch"} 512`,
+		`email_parser_dynamodb_batch_writes_total{result="ok"} 1`,
+		`email_parser_dynamodb_batch_writes_total{result="error"} 1`,
+		`email_parser_dynamodb_unprocessed_items_total 4`,
+		`email_parser_http_request_duration_seconds_count{method="GET",route="/threads/{id}",status="200"} 1`,
+		`go_goroutines`,
+		`process_start_time_seconds`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+	// Failed uploads count no bytes.
+	if strings.Contains(body, `email_parser_s3_upload_bytes_total{kind="raw"}`) {
+		t.Error("failed upload counted bytes")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	m := New()
+	endpoint := func(*http.Request) string { return "messages.list" }
+	client := &http.Client{Transport: m.Transport(nil, endpoint)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// A request that gets no response is recorded with status "error".
+	unreachable := &http.Client{Transport: m.Transport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}), endpoint)}
+	if _, err := unreachable.Get(server.URL); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`email_parser_gmail_api_requests_total{endpoint="messages.list",status="429"} 1`,
+		`email_parser_gmail_api_requests_total{endpoint="messages.list",status="error"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %s", want)
+		}
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.ObserveGmailRequest("messages.get", 200, time.Millisecond)
+	m.MessagesFetched(PipelineFetch, 1)
+	m.MessageClassified("promotions", true)
+	m.MessagesStored(PipelineFetch, 1)
+	m.ObserveS3Upload(ObjectBatch, 1, time.Millisecond, nil)
+	m.ObserveDynamoBatchWrite(1, nil)
+	m.ObserveHTTPRequest(http.MethodGet, "/", 200, time.Millisecond)
+
+	base := http.DefaultTransport
+	if got := m.Transport(base, nil); got != base {
+		t.Error("nil Metrics wrapped the transport")
+	}
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("nil Metrics handler status = %d, want 404", rec.Code)
+	}
+}
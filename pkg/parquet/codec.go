@@ -0,0 +1,60 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compress compresses data with the codec. It is exported so that other
+// formats stored next to Parquet files compress the same way.
+func (c Codec) Compress(data []byte) ([]byte, error) {
+	switch c {
+	case Uncompressed:
+		return data, nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(data, nil), nil
+	}
+	return nil, fmt.Errorf("parquet: unsupported codec %d", c)
+}
+
+// Decompress undoes Compress.
+func (c Codec) Decompress(data []byte) ([]byte, error) {
+	switch c {
+	case Uncompressed:
+		return data, nil
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case Zstd:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(data, nil)
+	}
+	return nil, fmt.Errorf("parquet: unsupported codec %d", c)
+}
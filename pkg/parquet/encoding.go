@@ -0,0 +1,214 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+var errShortPage = errors.New("parquet: page data too short")
+
+// levelWidth is the bit width of levels up to max.
+func levelWidth(max int) int {
+	return bits.Len(uint(max))
+}
+
+// appendLevels writes levels as a length-prefixed RLE/bit-packed hybrid run
+// list. The writer only produces RLE runs.
+func appendLevels(buf []byte, levels []int, max int) []byte {
+	width := (levelWidth(max) + 7) / 8
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		buf = binary.AppendUvarint(buf, uint64(j-i)<<1)
+		for b := range width {
+			buf = append(buf, byte(levels[i]>>(8*b)))
+		}
+		i = j
+	}
+	binary.LittleEndian.PutUint32(buf[start:], uint32(len(buf)-start-4))
+	return buf
+}
+
+// readLevels decodes n levels written by appendLevels or any other writer
+// of the hybrid encoding, returning the rest of data.
+func readLevels(data []byte, n, max int) ([]int, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errShortPage
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > len(data)-4 {
+		return nil, nil, errShortPage
+	}
+	runs, rest := data[4:4+size], data[4+size:]
+
+	width := levelWidth(max)
+	levels := make([]int, 0, n)
+	for len(levels) < n {
+		header, k := binary.Uvarint(runs)
+		if k <= 0 {
+			return nil, nil, errShortPage
+		}
+		runs = runs[k:]
+		if header&1 == 0 {
+			count := int(header >> 1)
+			byteWidth := (width + 7) / 8
+			if len(runs) < byteWidth {
+				return nil, nil, errShortPage
+			}
+			value := 0
+			for b := range byteWidth {
+				value |= int(runs[b]) << (8 * b)
+			}
+			runs = runs[byteWidth:]
+			for range min(count, n-len(levels)) {
+				levels = append(levels, value)
+			}
+			continue
+		}
+
+		count := int(header>>1) * 8
+		size := int(header>>1) * width
+		if len(runs) < size {
+			return nil, nil, errShortPage
+		}
+		for i := range count {
+			if len(levels) == n {
+				break
+			}
+			value := 0
+			for b := range width {
+				bit := i*width + b
+				value |= int(runs[bit/8]>>(bit%8)&1) << b
+			}
+			levels = append(levels, value)
+		}
+		runs = runs[size:]
+	}
+	if max > 0 {
+		for _, level := range levels {
+			if level > max {
+				return nil, nil, fmt.Errorf("parquet: level %d above maximum %d", level, max)
+			}
+		}
+	}
+	return levels, rest, nil
+}
+
+// appendPlain writes values in the PLAIN encoding of typ.
+func appendPlain(buf []byte, typ Type, values []any) ([]byte, error) {
+	if typ == Boolean {
+		packed := make([]byte, (len(values)+7)/8)
+		for i, value := range values {
+			v, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("parquet: boolean column holds %T", value)
+			}
+			if v {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append(buf, packed...), nil
+	}
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case int32:
+			if typ != Int32 {
+				return nil, fmt.Errorf("parquet: %s column holds int32", typeName(typ))
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+		case int64:
+			if typ != Int64 {
+				return nil, fmt.Errorf("parquet: %s column holds int64", typeName(typ))
+			}
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+		case float64:
+			if typ != Double {
+				return nil, fmt.Errorf("parquet: %s column holds float64", typeName(typ))
+			}
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		case string:
+			if typ != ByteArray {
+				return nil, fmt.Errorf("parquet: %s column holds string", typeName(typ))
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(v)))
+			buf = append(buf, v...)
+		default:
+			return nil, fmt.Errorf("parquet: cannot encode %T", value)
+		}
+	}
+	return buf, nil
+}
+
+// readPlain decodes n values of typ.
+func readPlain(data []byte, typ Type, n int) ([]any, error) {
+	values := make([]any, 0, n)
+	switch typ {
+	case Boolean:
+		if len(data) < (n+7)/8 {
+			return nil, errShortPage
+		}
+		for i := range n {
+			values = append(values, data[i/8]>>(i%8)&1 == 1)
+		}
+	case Int32:
+		if len(data) < 4*n {
+			return nil, errShortPage
+		}
+		for i := range n {
+			values = append(values, int32(binary.LittleEndian.Uint32(data[4*i:])))
+		}
+	case Int64:
+		if len(data) < 8*n {
+			return nil, errShortPage
+		}
+		for i := range n {
+			values = append(values, int64(binary.LittleEndian.Uint64(data[8*i:])))
+		}
+	case Double:
+		if len(data) < 8*n {
+			return nil, errShortPage
+		}
+		for i := range n {
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:])))
+		}
+	case ByteArray:
+		for range n {
+			if len(data) < 4 {
+				return nil, errShortPage
+			}
+			size := binary.LittleEndian.Uint32(data)
+			if uint64(size) > uint64(len(data)-4) {
+				return nil, errShortPage
+			}
+			values = append(values, string(data[4:4+size]))
+			data = data[4+size:]
+		}
+	default:
+		return nil, fmt.Errorf("parquet: unsupported type %s", typeName(typ))
+	}
+	return values, nil
+}
+
+func typeName(typ Type) string {
+	switch typ {
+	case Boolean:
+		return "BOOLEAN"
+	case Int32:
+		return "INT32"
+	case Int64:
+		return "INT64"
+	case Double:
+		return "DOUBLE"
+	case ByteArray:
+		return "BYTE_ARRAY"
+	}
+	return fmt.Sprintf("type(%d)", typ)
+}
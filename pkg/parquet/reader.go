@@ -0,0 +1,210 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// File is a Parquet file read back into memory.
+type File struct {
+	Schema  Node
+	Rows    int
+	Leaves  []Leaf
+	Columns []Column
+}
+
+// Column returns the column of the leaf with the dotted name.
+func (f *File) Column(name string) (Leaf, Column, bool) {
+	for i, leaf := range f.Leaves {
+		if leaf.Name() == name {
+			return leaf, f.Columns[i], true
+		}
+	}
+	return Leaf{}, Column{}, false
+}
+
+var errNotParquet = errors.New("parquet: not a parquet file")
+
+// Read decodes a file with PLAIN encoded v1 data pages, as Write produces,
+// concatenating the column chunks of every row group.
+func Read(data []byte) (*File, error) {
+	if len(data) < 12 || string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		return nil, errNotParquet
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if size > len(data)-12 {
+		return nil, errNotParquet
+	}
+	footer := thriftReader{data: data[len(data)-8-size : len(data)-8]}
+	meta, err := footer.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("parquet: failed to read footer: %w", err)
+	}
+
+	elements := meta.list(2)
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("parquet: footer has no schema")
+	}
+	next := 1
+	schema, err := buildNode(elements, &next, element(elements[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{Schema: schema, Rows: int(meta.int(3)), Leaves: schema.Leaves()}
+	file.Columns = make([]Column, len(file.Leaves))
+	for _, group := range meta.list(4) {
+		chunks := element(group).list(1)
+		if len(chunks) != len(file.Leaves) {
+			return nil, fmt.Errorf("parquet: row group has %d columns for %d leaves", len(chunks), len(file.Leaves))
+		}
+		for i, chunk := range chunks {
+			leaf := file.Leaves[i]
+			if err := readChunk(data, leaf, element(chunk).strct(3), &file.Columns[i]); err != nil {
+				return nil, fmt.Errorf("parquet: column %s: %w", leaf.Name(), err)
+			}
+		}
+	}
+	return file, nil
+}
+
+func element(v any) fields {
+	f, _ := v.(fields)
+	return f
+}
+
+// buildNode rebuilds the schema tree from its depth-first flattening.
+func buildNode(elements []any, next *int, e fields) (Node, error) {
+	node := Node{
+		Name:       string(e.bytes(4)),
+		Repetition: Repetition(e.int(3)),
+		Type:       Type(e.int(1)),
+		Logical:    logicalOf(e),
+	}
+	for range e.int(5) {
+		if *next >= len(elements) {
+			return Node{}, fmt.Errorf("parquet: schema ends inside %s", node.Name)
+		}
+		child := element(elements[*next])
+		*next++
+		field, err := buildNode(elements, next, child)
+		if err != nil {
+			return Node{}, err
+		}
+		node.Fields = append(node.Fields, field)
+	}
+	return node, nil
+}
+
+func logicalOf(e fields) Logical {
+	if logical := e.strct(10); logical != nil {
+		switch {
+		case logical.has(1):
+			return String
+		case logical.has(2):
+			return Map
+		case logical.has(3):
+			return List
+		case logical.has(8):
+			if unit := logical.strct(8).strct(2); unit.has(2) {
+				return TimestampMicros
+			}
+		case logical.has(12):
+			return JSON
+		}
+	}
+	if !e.has(6) {
+		return NoLogical
+	}
+	switch e.int(6) {
+	case convertedUTF8:
+		return String
+	case convertedMap:
+		return Map
+	case convertedList:
+		return List
+	case convertedTimestampMicros:
+		return TimestampMicros
+	case convertedJSON:
+		return JSON
+	}
+	return NoLogical
+}
+
+// readChunk appends the pages of one column chunk to column.
+func readChunk(data []byte, leaf Leaf, meta fields, column *Column) error {
+	if meta == nil {
+		return fmt.Errorf("missing column metadata")
+	}
+	if meta.has(11) {
+		return fmt.Errorf("dictionary pages are not supported")
+	}
+	codec := Codec(meta.int(4))
+	remaining := meta.int(5)
+	offset := meta.int(9)
+	for remaining > 0 {
+		if offset < 0 || offset >= int64(len(data)) {
+			return errShortPage
+		}
+		r := thriftReader{data: data[offset:]}
+		header, err := r.readStruct()
+		if err != nil {
+			return err
+		}
+		size := header.int(3)
+		start := offset + int64(r.pos)
+		if size < 0 || start+size > int64(len(data)) {
+			return errShortPage
+		}
+		offset = start + size
+		if header.int(1) != pageData {
+			return fmt.Errorf("page type %d is not supported", header.int(1))
+		}
+		page := header.strct(5)
+		if page.int(2) != encodingPlain {
+			return fmt.Errorf("encoding %d is not supported", page.int(2))
+		}
+
+		body, err := codec.Decompress(data[start : start+size])
+		if err != nil {
+			return err
+		}
+		n := int(page.int(1))
+		if err := readPage(body, leaf, n, column); err != nil {
+			return err
+		}
+		remaining -= int64(n)
+	}
+	return nil
+}
+
+func readPage(body []byte, leaf Leaf, n int, column *Column) error {
+	var err error
+	var rep, def []int
+	if leaf.MaxRep > 0 {
+		if rep, body, err = readLevels(body, n, leaf.MaxRep); err != nil {
+			return err
+		}
+	}
+	values := n
+	if leaf.MaxDef > 0 {
+		if def, body, err = readLevels(body, n, leaf.MaxDef); err != nil {
+			return err
+		}
+		values = 0
+		for _, level := range def {
+			if level == leaf.MaxDef {
+				values++
+			}
+		}
+	}
+	decoded, err := readPlain(body, leaf.Type, values)
+	if err != nil {
+		return err
+	}
+	column.Values = append(column.Values, decoded...)
+	column.Def = append(column.Def, def...)
+	column.Rep = append(column.Rep, rep...)
+	return nil
+}
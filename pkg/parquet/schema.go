@@ -0,0 +1,126 @@
+// Package parquet writes and reads Parquet files with a single row group,
+// one PLAIN encoded data page per column and optional GZIP or ZSTD page
+// compression. It does not map Go types: callers describe the schema as a
+// tree of nodes and shred their records into leaf columns with definition
+// and repetition levels themselves.
+package parquet
+
+import "strings"
+
+// Type is the physical type of a leaf column.
+type Type int32
+
+const (
+	Boolean   Type = 0
+	Int32     Type = 1
+	Int64     Type = 2
+	Double    Type = 5
+	ByteArray Type = 6
+)
+
+// Repetition says whether a node must, may or can repeatedly be present.
+type Repetition int32
+
+const (
+	Required Repetition = 0
+	Optional Repetition = 1
+	Repeated Repetition = 2
+)
+
+// Logical annotates how a node should be read beyond its physical type.
+type Logical int
+
+const (
+	NoLogical Logical = iota
+	// String is UTF-8 text in a ByteArray.
+	String
+	// JSON is a JSON document in a ByteArray.
+	JSON
+	// TimestampMicros is microseconds since the Unix epoch, UTC, in an Int64.
+	TimestampMicros
+	// List is the three-level list group: <list> (LIST) { repeated group
+	// list { element } }.
+	List
+	// Map is the map group: <map> (MAP) { repeated group key_value { key;
+	// value } }.
+	Map
+)
+
+// Codec compresses data pages.
+type Codec int32
+
+const (
+	Uncompressed Codec = 0
+	Gzip         Codec = 2
+	Zstd         Codec = 6
+)
+
+// Node is a field of the schema. Groups have Fields, leaves a Type.
+type Node struct {
+	Name       string
+	Repetition Repetition
+	Type       Type
+	Logical    Logical
+	Fields     []Node
+}
+
+// Leaf is a column of the schema: the path to a leaf node and the highest
+// definition and repetition levels its values can have.
+type Leaf struct {
+	Path   []string
+	Type   Type
+	MaxDef int
+	MaxRep int
+}
+
+// Name is the dotted path of the leaf, e.g. "classification.category".
+func (l Leaf) Name() string {
+	return strings.Join(l.Path, ".")
+}
+
+// Leaves lists the columns of a schema root in file order. The root itself
+// is the message and contributes no path segment or level.
+func (n Node) Leaves() []Leaf {
+	var leaves []Leaf
+	for _, field := range n.Fields {
+		leaves = field.appendLeaves(leaves, nil, 0, 0)
+	}
+	return leaves
+}
+
+func (n Node) appendLeaves(leaves []Leaf, path []string, def, rep int) []Leaf {
+	path = append(path[:len(path):len(path)], n.Name)
+	switch n.Repetition {
+	case Optional:
+		def++
+	case Repeated:
+		def++
+		rep++
+	}
+	if len(n.Fields) == 0 {
+		return append(leaves, Leaf{Path: path, Type: n.Type, MaxDef: def, MaxRep: rep})
+	}
+	for _, field := range n.Fields {
+		leaves = field.appendLeaves(leaves, path, def, rep)
+	}
+	return leaves
+}
+
+// Column holds the values of one leaf and, for leaves below optional or
+// repeated nodes, a definition and repetition level per entry. Values only
+// has entries whose definition level is the leaf's MaxDef; they are bool,
+// int32, int64, float64 or, for ByteArray, string.
+type Column struct {
+	Values []any
+	Def    []int
+	Rep    []int
+}
+
+// entries is the number of level entries, which is the number of values for
+// a required column.
+func (c Column) entries(leaf Leaf) int {
+	if leaf.MaxDef == 0 {
+		return len(c.Values)
+	}
+	return len(c.Def)
+}
@@ -0,0 +1,321 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Parquet metadata is serialised with the Thrift compact protocol. Only the
+// handful of structs the writer and reader need are modelled, as field
+// lists on the way out and as generic field maps on the way in.
+
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// tfield is one field of a struct being encoded. A nil value leaves the
+// field out, which is how optional fields are omitted.
+type tfield struct {
+	id    int16
+	value any
+}
+
+type tstruct []tfield
+
+// tlist is a homogeneous list; elem is the Thrift type of its items.
+type tlist struct {
+	elem  byte
+	items []any
+}
+
+type thriftWriter struct {
+	buf []byte
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) writeStruct(s tstruct) {
+	last := int16(0)
+	for _, f := range s {
+		if f.value == nil {
+			continue
+		}
+		kind := thriftType(f.value)
+		if b, ok := f.value.(bool); ok && !b {
+			kind = thriftFalse
+		}
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			w.buf = append(w.buf, byte(delta)<<4|kind)
+		} else {
+			w.buf = append(w.buf, kind)
+			w.zigzag(int64(f.id))
+		}
+		last = f.id
+		if kind != thriftTrue && kind != thriftFalse {
+			w.writeValue(f.value)
+		}
+	}
+	w.buf = append(w.buf, thriftStop)
+}
+
+func (w *thriftWriter) writeValue(v any) {
+	switch v := v.(type) {
+	case int32:
+		w.zigzag(int64(v))
+	case int64:
+		w.zigzag(v)
+	case float64:
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+	case string:
+		w.varint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case []byte:
+		w.varint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case bool:
+		if v {
+			w.buf = append(w.buf, thriftTrue)
+		} else {
+			w.buf = append(w.buf, thriftFalse)
+		}
+	case tstruct:
+		w.writeStruct(v)
+	case tlist:
+		if len(v.items) < 15 {
+			w.buf = append(w.buf, byte(len(v.items))<<4|v.elem)
+		} else {
+			w.buf = append(w.buf, 0xf0|v.elem)
+			w.varint(uint64(len(v.items)))
+		}
+		for _, item := range v.items {
+			w.writeValue(item)
+		}
+	default:
+		panic(fmt.Sprintf("parquet: cannot encode %T as thrift", v))
+	}
+}
+
+func thriftType(v any) byte {
+	switch v.(type) {
+	case int32:
+		return thriftI32
+	case int64:
+		return thriftI64
+	case float64:
+		return thriftDouble
+	case string, []byte:
+		return thriftBinary
+	case bool:
+		return thriftTrue
+	case tstruct:
+		return thriftStruct
+	case tlist:
+		return thriftList
+	}
+	panic(fmt.Sprintf("parquet: cannot encode %T as thrift", v))
+}
+
+func encodeStruct(s tstruct) []byte {
+	var w thriftWriter
+	w.writeStruct(s)
+	return w.buf
+}
+
+var errTruncated = errors.New("parquet: truncated thrift data")
+
+// thriftReader decodes compact protocol data into generic values: integers
+// as int64, binary as []byte, lists as []any and structs as fields.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+// fields is a decoded struct keyed by field ID.
+type fields map[int16]any
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) readStruct() (fields, error) {
+	result := fields{}
+	last := int16(0)
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == thriftStop {
+			return result, nil
+		}
+		kind := header & 0x0f
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		var value any
+		switch kind {
+		case thriftTrue:
+			value = true
+		case thriftFalse:
+			value = false
+		default:
+			if value, err = r.readValue(kind); err != nil {
+				return nil, err
+			}
+		}
+		result[id] = value
+	}
+}
+
+func (r *thriftReader) readValue(kind byte) (any, error) {
+	switch kind {
+	case thriftTrue, thriftFalse:
+		b, err := r.byte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftDouble:
+		if r.pos+8 > len(r.data) {
+			return nil, errTruncated
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return v, nil
+	case thriftBinary:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(r.data)-r.pos) < n {
+			return nil, errTruncated
+		}
+		v := r.data[r.pos : r.pos+int(n)]
+		r.pos += int(n)
+		return v, nil
+	case thriftList, thriftSet:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		if size > uint64(len(r.data)) {
+			return nil, errTruncated
+		}
+		items := make([]any, 0, size)
+		for range size {
+			item, err := r.readValue(header & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case thriftMap:
+		size, err := r.varint()
+		if err != nil || size == 0 {
+			return []any(nil), err
+		}
+		types, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(len(r.data)) {
+			return nil, errTruncated
+		}
+		pairs := make([]any, 0, 2*size)
+		for range size {
+			key, err := r.readValue(types >> 4)
+			if err != nil {
+				return nil, err
+			}
+			value, err := r.readValue(types & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, key, value)
+		}
+		return pairs, nil
+	case thriftStruct:
+		return r.readStruct()
+	}
+	return nil, fmt.Errorf("parquet: unknown thrift type %d", kind)
+}
+
+func (f fields) int(id int16) int64 {
+	v, _ := f[id].(int64)
+	return v
+}
+
+func (f fields) has(id int16) bool {
+	_, ok := f[id]
+	return ok
+}
+
+func (f fields) bytes(id int16) []byte {
+	v, _ := f[id].([]byte)
+	return v
+}
+
+func (f fields) list(id int16) []any {
+	v, _ := f[id].([]any)
+	return v
+}
+
+func (f fields) strct(id int16) fields {
+	v, _ := f[id].(fields)
+	return v
+}
@@ -0,0 +1,222 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const magic = "PAR1"
+
+// CreatedBy is recorded in the footer of every file written.
+const CreatedBy = "email-parser-poc"
+
+const (
+	pageData = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	convertedUTF8            = 0
+	convertedMap             = 1
+	convertedList            = 3
+	convertedTimestampMicros = 10
+	convertedJSON            = 19
+)
+
+// Write writes rows records, shredded into one column per leaf of schema in
+// Leaves order, as a Parquet file.
+func Write(w io.Writer, schema Node, rows int, columns []Column, codec Codec) error {
+	leaves := schema.Leaves()
+	if len(columns) != len(leaves) {
+		return fmt.Errorf("parquet: %d columns for %d leaves", len(columns), len(leaves))
+	}
+
+	buf := []byte(magic)
+	chunks := make([]any, 0, len(columns))
+	var totalSize int64
+	for i, leaf := range leaves {
+		if err := checkColumn(leaf, columns[i], rows); err != nil {
+			return err
+		}
+		offset := int64(len(buf))
+		page, err := encodePage(leaf, columns[i], codec)
+		if err != nil {
+			return fmt.Errorf("parquet: column %s: %w", leaf.Name(), err)
+		}
+		buf = append(buf, page.data...)
+		totalSize += page.uncompressed
+
+		path := make([]any, len(leaf.Path))
+		for j, name := range leaf.Path {
+			path[j] = name
+		}
+		chunks = append(chunks, tstruct{
+			{2, offset},
+			{3, tstruct{
+				{1, int32(leaf.Type)},
+				{2, tlist{thriftI32, []any{int32(encodingPlain), int32(encodingRLE)}}},
+				{3, tlist{thriftBinary, path}},
+				{4, int32(codec)},
+				{5, int64(columns[i].entries(leaf))},
+				{6, page.uncompressed},
+				{7, int64(len(page.data))},
+				{9, offset},
+			}},
+		})
+	}
+
+	footer := encodeStruct(tstruct{
+		{1, int32(1)},
+		{2, tlist{thriftStruct, schemaElements(schema)}},
+		{3, int64(rows)},
+		{4, tlist{thriftStruct, []any{tstruct{
+			{1, tlist{thriftStruct, chunks}},
+			{2, totalSize},
+			{3, int64(rows)},
+		}}}},
+		{6, CreatedBy},
+	})
+	buf = append(buf, footer...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(footer)))
+	buf = append(buf, magic...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// checkColumn makes sure the levels agree with the values and the row count,
+// which a reader would otherwise trip over.
+func checkColumn(leaf Leaf, column Column, rows int) error {
+	if leaf.MaxDef == 0 {
+		if len(column.Values) != rows {
+			return fmt.Errorf("parquet: column %s has %d values for %d rows", leaf.Name(), len(column.Values), rows)
+		}
+		return nil
+	}
+
+	defined := 0
+	for _, def := range column.Def {
+		if def < 0 || def > leaf.MaxDef {
+			return fmt.Errorf("parquet: column %s: definition level %d out of range", leaf.Name(), def)
+		}
+		if def == leaf.MaxDef {
+			defined++
+		}
+	}
+	if defined != len(column.Values) {
+		return fmt.Errorf("parquet: column %s has %d values for %d defined entries", leaf.Name(), len(column.Values), defined)
+	}
+
+	if leaf.MaxRep == 0 {
+		if len(column.Def) != rows {
+			return fmt.Errorf("parquet: column %s has %d entries for %d rows", leaf.Name(), len(column.Def), rows)
+		}
+		return nil
+	}
+	if len(column.Rep) != len(column.Def) {
+		return fmt.Errorf("parquet: column %s has %d repetition and %d definition levels", leaf.Name(), len(column.Rep), len(column.Def))
+	}
+	starts := 0
+	for i, rep := range column.Rep {
+		if rep < 0 || rep > leaf.MaxRep || (i == 0 && rep != 0) {
+			return fmt.Errorf("parquet: column %s: repetition level %d out of range", leaf.Name(), rep)
+		}
+		if rep == 0 {
+			starts++
+		}
+	}
+	if starts != rows {
+		return fmt.Errorf("parquet: column %s has %d records for %d rows", leaf.Name(), starts, rows)
+	}
+	return nil
+}
+
+type encodedPage struct {
+	data         []byte
+	uncompressed int64
+}
+
+// encodePage writes a column as one data page: repetition levels,
+// definition levels and the values, compressed together.
+func encodePage(leaf Leaf, column Column, codec Codec) (encodedPage, error) {
+	var body []byte
+	if leaf.MaxRep > 0 {
+		body = appendLevels(body, column.Rep, leaf.MaxRep)
+	}
+	if leaf.MaxDef > 0 {
+		body = appendLevels(body, column.Def, leaf.MaxDef)
+	}
+	body, err := appendPlain(body, leaf.Type, column.Values)
+	if err != nil {
+		return encodedPage{}, err
+	}
+
+	compressed, err := codec.Compress(body)
+	if err != nil {
+		return encodedPage{}, err
+	}
+	header := encodeStruct(tstruct{
+		{1, int32(pageData)},
+		{2, int32(len(body))},
+		{3, int32(len(compressed))},
+		{5, tstruct{
+			{1, int32(column.entries(leaf))},
+			{2, int32(encodingPlain)},
+			{3, int32(encodingRLE)},
+			{4, int32(encodingRLE)},
+		}},
+	})
+	return encodedPage{
+		data:         append(header, compressed...),
+		uncompressed: int64(len(header) + len(body)),
+	}, nil
+}
+
+// schemaElements flattens the schema tree depth first, the root first.
+func schemaElements(root Node) []any {
+	elements := []any{tstruct{
+		{4, root.Name},
+		{5, int32(len(root.Fields))},
+	}}
+	var walk func(n Node)
+	walk = func(n Node) {
+		element := tstruct{}
+		if len(n.Fields) == 0 {
+			element = append(element, tfield{1, int32(n.Type)})
+		}
+		element = append(element, tfield{3, int32(n.Repetition)}, tfield{4, n.Name})
+		if len(n.Fields) > 0 {
+			element = append(element, tfield{5, int32(len(n.Fields))})
+		}
+		if converted, logical := annotation(n.Logical); logical != nil {
+			element = append(element, tfield{6, converted}, tfield{10, logical})
+		}
+		elements = append(elements, element)
+		for _, field := range n.Fields {
+			walk(field)
+		}
+	}
+	for _, field := range root.Fields {
+		walk(field)
+	}
+	return elements
+}
+
+// annotation returns the legacy converted type and the LogicalType union of
+// a logical annotation, both of which readers still look at.
+func annotation(logical Logical) (int32, any) {
+	switch logical {
+	case String:
+		return convertedUTF8, tstruct{{1, tstruct{}}}
+	case Map:
+		return convertedMap, tstruct{{2, tstruct{}}}
+	case List:
+		return convertedList, tstruct{{3, tstruct{}}}
+	case TimestampMicros:
+		return convertedTimestampMicros, tstruct{{8, tstruct{{1, true}, {2, tstruct{{2, tstruct{}}}}}}}
+	case JSON:
+		return convertedJSON, tstruct{{12, tstruct{}}}
+	}
+	return 0, nil
+}
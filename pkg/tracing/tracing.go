@@ -0,0 +1,120 @@
+// Package tracing sets up the OpenTelemetry tracer provider and W3C trace
+// context propagation. With the "none" exporter nothing is installed and
+// every span created through the otel API is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	// Exporter is "none", "stdout" or "otlp".
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector, as host:port or a full URL.
+	Endpoint string
+	Insecure bool
+	// SampleRatio is the share of new traces recorded. Inbound requests
+	// that carry a sampled trace context are always recorded.
+	SampleRatio float64
+
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+
+	// Output receives stdout spans; defaults to os.Stdout.
+	Output io.Writer
+}
+
+// ValidExporter reports whether exporter is one Setup understands.
+func ValidExporter(exporter string) bool {
+	switch exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+		return true
+	}
+	return false
+}
+
+// Setup installs the global tracer provider and propagator. The returned
+// function flushes buffered spans and must be called before exiting.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	if config.Exporter == "" || config.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(
+			semconv.ServiceName(config.ServiceName),
+			semconv.ServiceVersion(config.ServiceVersion),
+			semconv.DeploymentEnvironment(config.Environment),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterStdout:
+		output := config.Output
+		if output == nil {
+			output = os.Stdout
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(output))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if strings.Contains(config.Endpoint, "://") {
+			options = append(options, otlptracehttp.WithEndpointURL(config.Endpoint))
+		} else if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q", config.Exporter)
+}